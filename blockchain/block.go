@@ -2,7 +2,9 @@ package blockchain
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
+	"fmt"
 	"log"
 	"time"
 )
@@ -14,6 +16,18 @@ type Block struct {
 	PrevHash     []byte
 	Nonce        int
 	Height       int
+	ExtraNonce   int
+}
+
+// gob assigns wire type ids process-wide in the order types are first seen,
+// and those ids end up in Serialize output that is hashed into transaction
+// ids and merkle roots. Encoding a block up front pins the ids so every
+// process (node, wallet, external miner) serializes identically.
+func init() {
+	var buff bytes.Buffer
+	tx := &Transaction{ID: []byte{0}, Inputs: []TxInput{{ID: []byte{0}}}, Outputs: []TxOutput{{PubKeyHash: []byte{0}}}}
+	Handle(gob.NewEncoder(&buff).Encode(Block{Hash: []byte{0}, Transactions: []*Transaction{tx}}))
+	Handle(gob.NewEncoder(&buff).Encode(TxOutputs{Outputs: tx.Outputs}))
 }
 
 func (b *Block) HashTransactions() []byte {
@@ -28,14 +42,27 @@ func (b *Block) HashTransactions() []byte {
 }
 
 func CreateBlock(txs []*Transaction, prevHash []byte, height int) *Block {
-	block := &Block{time.Now().Unix(), []byte{}, txs, prevHash, 0, height}
+	block, err := CreateBlockContext(context.Background(), txs, prevHash, height)
+	Handle(err)
+
+	return block
+}
+
+// CreateBlockContext mines a block on all CPUs, giving up with ctx.Err()
+// when ctx is cancelled.
+func CreateBlockContext(ctx context.Context, txs []*Transaction, prevHash []byte, height int) (*Block, error) {
+	block := &Block{Timestamp: time.Now().Unix(), Hash: []byte{}, Transactions: txs, PrevHash: prevHash, Height: height}
 	pow := NewProof(block)
-	nonce, hash := pow.Run()
+	nonce, hash, stats, err := pow.RunContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	block.Hash = hash[:]
 	block.Nonce = nonce
+	fmt.Printf("Mined block %d %x: %s\n", height, block.Hash, stats)
 
-	return block
+	return block, nil
 }
 
 func Genesis(coinbase *Transaction) *Block {
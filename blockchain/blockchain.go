@@ -2,7 +2,7 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/ecdsa"
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -11,8 +11,11 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/dgraph-io/badger"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
 )
 
 const (
@@ -23,8 +26,25 @@ const (
 type BlockChain struct {
 	LastHash []byte
 	Database *badger.DB
+
+	miningMu sync.Mutex
+	// mining holds the cancel funcs of the blocks being mined, by a number
+	// given to each
+	mining     map[int]context.CancelFunc
+	nextMining int
+
+	spendMu   sync.Mutex
+	pendingMu sync.Mutex
+	spending  bool
+	// pending are the blocks connected during a spend, whose listeners run
+	// once it is over
+	pending []*Block
 }
 
+// ErrStaleTip is returned by MineBlockContext when another block became the
+// tip before the mined one could be connected on top of it.
+var ErrStaleTip = errors.New("the chain tip moved while mining")
+
 func DBexists(path string) bool {
 	if _, err := os.Stat(path + "/MANIFEST"); os.IsNotExist(err) {
 		return false
@@ -63,7 +83,7 @@ func InitBlockChain(address, nodeId string) *BlockChain {
 
 	Handle(err)
 
-	blockchain := BlockChain{lastHash, db}
+	blockchain := BlockChain{LastHash: lastHash, Database: db}
 	return &blockchain
 }
 
@@ -94,7 +114,7 @@ func ContinueBlockChain(nodeId string) *BlockChain {
 	})
 	Handle(err)
 
-	chain := BlockChain{lastHash, db}
+	chain := BlockChain{LastHash: lastHash, Database: db}
 
 	return &chain
 }
@@ -173,15 +193,38 @@ func (chain *BlockChain) GetBestHeight() int {
 	return lastBlock.Height
 }
 
+// MineBlock mines transactions on top of the tip. When a block from a peer
+// takes the tip first the transactions are verified and mined again on the
+// new tip.
 func (chain *BlockChain) MineBlock(transactions []*Transaction) *Block {
-	var lastHash []byte
-	var lastHeight int
-
-	for _, tx := range transactions {
-		if !chain.VerifyTransaction(tx) {
-			log.Panic("Invalid Transaction")
+	for {
+		block, err := chain.MineBlockContext(context.Background(), transactions)
+		if errors.Is(err, ErrStaleTip) {
+			continue
 		}
+		Handle(err)
+
+		return block
 	}
+}
+
+// InterruptMining aborts the blocks being mined, e.g. because a competing
+// block for the same height arrived from a peer.
+func (chain *BlockChain) InterruptMining() {
+	chain.miningMu.Lock()
+	defer chain.miningMu.Unlock()
+
+	for _, cancel := range chain.mining {
+		cancel()
+	}
+}
+
+// MineBlockContext mines transactions on top of the current tip. It returns
+// ErrStaleTip if InterruptMining is called before a nonce is found or the
+// tip moved meanwhile, and an error if ctx is cancelled.
+func (chain *BlockChain) MineBlockContext(ctx context.Context, transactions []*Transaction) (*Block, error) {
+	var lastHash []byte
+	var lastHeight int
 
 	err := chain.Database.View(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte("lh"))
@@ -209,23 +252,64 @@ func (chain *BlockChain) MineBlock(transactions []*Transaction) *Block {
 	})
 	Handle(err)
 
-	newBlock := CreateBlock(transactions, lastHash, lastHeight+1)
+	// Verified after reading the tip, a block connected in between makes
+	// the tip stale and the transactions are verified again on the new one
+	if !chain.VerifyTransactions(transactions) {
+		log.Panic("Invalid Transaction")
+	}
+
+	miningCtx, cancel := context.WithCancel(ctx)
+	chain.miningMu.Lock()
+	if chain.mining == nil {
+		chain.mining = make(map[int]context.CancelFunc)
+	}
+	id := chain.nextMining
+	chain.nextMining++
+	chain.mining[id] = cancel
+	chain.miningMu.Unlock()
+
+	newBlock, err := CreateBlockContext(miningCtx, transactions, lastHash, lastHeight+1)
+
+	chain.miningMu.Lock()
+	delete(chain.mining, id)
+	chain.miningMu.Unlock()
+	cancel()
+
+	if err != nil {
+		if ctx.Err() == nil {
+			return nil, ErrStaleTip
+		}
+		return nil, fmt.Errorf("mining interrupted: %w", err)
+	}
 
+	// The block only becomes the tip if it still builds on it
 	err = chain.Database.Update(func(txn *badger.Txn) error {
-		err = txn.Set(newBlock.Hash, newBlock.Serialize())
+		item, err := txn.Get([]byte("lh"))
 		Handle(err)
-		err = txn.Set([]byte("lh"), newBlock.Hash)
-
-		chain.LastHash = newBlock.Hash
+		tip, err := item.ValueCopy(nil)
+		Handle(err)
+		if !bytes.Equal(tip, lastHash) {
+			return ErrStaleTip
+		}
 
-		return err
+		err = txn.Set(newBlock.Hash, newBlock.Serialize())
+		Handle(err)
+		return txn.Set([]byte("lh"), newBlock.Hash)
 	})
+	if err == ErrStaleTip || err == badger.ErrConflict {
+		return nil, ErrStaleTip
+	}
 	Handle(err)
+	chain.LastHash = newBlock.Hash
 
-	return newBlock
+	return newBlock, nil
 }
 
-func (chain *BlockChain) AddBlock(block *Block) {
+// AddBlock stores block and makes it the tip when it is higher than the
+// current one, reporting whether it did.
+func (chain *BlockChain) AddBlock(block *Block) bool {
+	newTip := false
+
 	err := chain.Database.Update(func(txn *badger.Txn) error {
 		if _, err := txn.Get(block.Hash); err == nil {
 			return nil
@@ -259,11 +343,19 @@ func (chain *BlockChain) AddBlock(block *Block) {
 			err = txn.Set([]byte("lh"), block.Hash)
 			Handle(err)
 			chain.LastHash = block.Hash
+			newTip = true
 		}
 
 		return nil
 	})
 	Handle(err)
+
+	// Whatever we were mining now builds on a stale tip
+	if newTip {
+		chain.InterruptMining()
+	}
+
+	return newTip
 }
 
 func (chain *BlockChain) FindUTXO() map[string]TxOutputs {
@@ -277,18 +369,24 @@ func (chain *BlockChain) FindUTXO() map[string]TxOutputs {
 
 		for _, tx := range block.Transactions {
 			txID := hex.EncodeToString(tx.ID)
+			// Spent outputs are kept as blanks so output indexes still match
+			unspent := TxOutputs{}
+			hasUnspent := false
 		Outputs:
 			for outIdx, out := range tx.Outputs {
 				if spentTXOs[txID] != nil {
 					for _, spentOut := range spentTXOs[txID] {
 						if spentOut == outIdx {
+							unspent.Outputs = append(unspent.Outputs, TxOutput{})
 							continue Outputs
 						}
 					}
 				}
-				outs := UTXO[txID]
-				outs.Outputs = append(outs.Outputs, out)
-				UTXO[txID] = outs
+				unspent.Outputs = append(unspent.Outputs, out)
+				hasUnspent = true
+			}
+			if hasUnspent {
+				UTXO[txID] = unspent
 			}
 			if !tx.IsCoinbase() {
 				for _, in := range tx.Inputs {
@@ -326,7 +424,34 @@ func (bc *BlockChain) FindTransaction(ID []byte) (Transaction, error) {
 
 }
 
-func (bc *BlockChain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey) {
+// FindTransactions looks up several transactions in one pass over the chain.
+func (bc *BlockChain) FindTransactions(IDs map[string]bool) map[string]Transaction {
+	found := make(map[string]Transaction)
+	if len(IDs) == 0 {
+		return found
+	}
+
+	iter := bc.Iterator()
+
+	for {
+		block := iter.Next()
+
+		for _, tx := range block.Transactions {
+			txID := hex.EncodeToString(tx.ID)
+			if IDs[txID] {
+				found[txID] = *tx
+			}
+		}
+
+		if len(found) == len(IDs) || len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return found
+}
+
+func (bc *BlockChain) SignTransaction(tx *Transaction, privKey wallet.PrivateKeyData) {
 	prevTXs := make(map[string]Transaction)
 
 	for _, in := range tx.Inputs {
@@ -338,6 +463,18 @@ func (bc *BlockChain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey)
 	tx.Sign(privKey, prevTXs)
 }
 
+func (bc *BlockChain) SignTransactionWithKeys(tx *Transaction, keys []wallet.PrivateKeyData) {
+	prevTXs := make(map[string]Transaction)
+
+	for _, in := range tx.Inputs {
+		prevTX, err := bc.FindTransaction(in.ID)
+		Handle(err)
+		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+	}
+
+	tx.SignWithKeys(keys, prevTXs)
+}
+
 func (bc *BlockChain) VerifyTransaction(tx *Transaction) bool {
 
 	if tx.IsCoinbase() {
@@ -352,7 +489,17 @@ func (bc *BlockChain) VerifyTransaction(tx *Transaction) bool {
 		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
 	}
 
-	return tx.Verify(prevTXs)
+	if !tx.ValueConserved(prevTXs) {
+		return false
+	}
+
+	for inIdx := range tx.Inputs {
+		if !tx.verifyInputCached(inIdx, prevTXs, SharedSigCache) {
+			return false
+		}
+	}
+
+	return true
 }
 
 func retry(dir string, originalOpts badger.Options) (*badger.DB, error) {
@@ -0,0 +1,272 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/dgraph-io/badger"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// The bust multipliers of the crash game come from a hash chain: the house
+// picks a random seed, hashes it CrashChainLength times and publishes the
+// last hash as its commitment. Round i of the chain uses the hash i steps
+// before the commitment, so the rounds reveal the chain backwards and every
+// revealed hash hashes to the one of the round before. Nobody can compute a
+// round's hash before it is revealed, and the house can't change it after
+// committing: sha256^i(hash of round i) must be the commitment.
+//
+// All bets of a round settle in one transaction carrying a CrashRecord data
+// output, paying the players who cashed out from the stakes and the house.
+
+var (
+	crashMarker   = []byte("\x6acrash:")
+	crashChainKey = []byte("crashchain")
+	crashChainMu  sync.Mutex
+)
+
+const (
+	// CrashChainLength is the number of rounds of a hash chain
+	CrashChainLength = 10000
+	// CrashHouseEdge in percent, the chance of a bust at 1.00x
+	CrashHouseEdge = 1
+)
+
+// CrashChain is a hash chain of the house. Seed is secret, Next the round
+// of the chain the next game plays.
+type CrashChain struct {
+	Seed       []byte
+	Commitment []byte
+	Next       int
+}
+
+// CrashSeed is the hash of one round and where it sits in its chain.
+type CrashSeed struct {
+	Hash       []byte
+	Commitment []byte
+	ChainRound int
+}
+
+// CrashChains hands out the hashes of the node's hash chains in order.
+type CrashChains struct {
+	Blockchain *BlockChain
+}
+
+func newCrashChain() CrashChain {
+	seed := make([]byte, 32)
+	_, err := rand.Read(seed)
+	Handle(err)
+
+	return CrashChain{Seed: seed, Commitment: crashHash(seed, CrashChainLength), Next: 1}
+}
+
+// crashHash is hash hashed n times.
+func crashHash(hash []byte, n int) []byte {
+	for i := 0; i < n; i++ {
+		sum := sha256.Sum256(hash)
+		hash = sum[:]
+	}
+	return hash
+}
+
+func (c CrashChains) load(txn *badger.Txn) (CrashChain, bool, error) {
+	var chain CrashChain
+	found, err := getGob(txn, crashChainKey, &chain)
+	return chain, found, err
+}
+
+// Commitment is the hash the rounds to come are checked against, starting
+// a chain if there is none.
+func (c CrashChains) Commitment() []byte {
+	crashChainMu.Lock()
+	defer crashChainMu.Unlock()
+
+	var chain CrashChain
+	err := c.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		var found bool
+		var err error
+		chain, found, err = c.load(txn)
+		if err != nil {
+			return err
+		}
+		if !found || chain.Next > CrashChainLength {
+			chain = newCrashChain()
+			return txn.Set(crashChainKey, gobEncode(chain))
+		}
+		return nil
+	})
+	Handle(err)
+
+	return chain.Commitment
+}
+
+// Next takes the hash of the next round, starting a new chain when the
+// current one is used up.
+func (c CrashChains) Next() CrashSeed {
+	crashChainMu.Lock()
+	defer crashChainMu.Unlock()
+
+	var seed CrashSeed
+	err := c.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		chain, found, err := c.load(txn)
+		if err != nil {
+			return err
+		}
+		if !found || chain.Next > CrashChainLength {
+			chain = newCrashChain()
+		}
+
+		seed = CrashSeed{
+			Hash:       crashHash(chain.Seed, CrashChainLength-chain.Next),
+			Commitment: chain.Commitment,
+			ChainRound: chain.Next,
+		}
+		chain.Next++
+		return txn.Set(crashChainKey, gobEncode(chain))
+	})
+	Handle(err)
+
+	return seed
+}
+
+// CrashBust is the bust multiplier of a round hash in hundredths, 100 for
+// a bust at 1.00x. The first 52 bits of the hash are a uniform h in [0, e)
+// and the bust is (100 - edge)% of e / (e - h), so the round reaches x with
+// probability (100 - edge) / x.
+func CrashBust(hash []byte) int {
+	const e = uint64(1) << 52
+
+	h := binary.BigEndian.Uint64(hash[:8]) >> 12
+	bust := int((100 - CrashHouseEdge) * e / (e - h))
+	if bust < 100 {
+		return 100
+	}
+	return bust
+}
+
+// CrashWinChance is the chance a round reaches cashOut, a multiplier in
+// hundredths.
+func CrashWinChance(cashOut int) float64 {
+	if cashOut <= 100-CrashHouseEdge {
+		return 1
+	}
+	return float64(100-CrashHouseEdge) / float64(cashOut)
+}
+
+// PreviousCrashHash is the hash of the round before the round of hash.
+func PreviousCrashHash(hash []byte) []byte {
+	return crashHash(hash, 1)
+}
+
+// VerifyCrashHash checks a revealed round hash against a commitment. It
+// returns the round of the chain the hash belongs to, 0 if it isn't part of
+// the chain.
+func VerifyCrashHash(hash, commitment []byte) int {
+	for round := 1; round <= CrashChainLength; round++ {
+		hash = crashHash(hash, 1)
+		if bytes.Equal(hash, commitment) {
+			return round
+		}
+	}
+	return 0
+}
+
+// CrashBet is one bet of a round. Multipliers are in hundredths: CashOut is
+// the automatic cash-out of the bet, CashedOut where the player got out, 0
+// if the round busted first.
+type CrashBet struct {
+	Player    string `json:"player"`
+	Amount    int    `json:"amount"`
+	CashOut   int    `json:"cashOut"`
+	CashedOut int    `json:"cashedOut,omitempty"`
+	Payout    int    `json:"payout"`
+}
+
+// ExpectedReturn is what the bet returns on average at the multiplier it
+// cashed out at, or aimed for.
+func (b CrashBet) ExpectedReturn() float64 {
+	target := b.CashOut
+	if b.CashedOut != 0 {
+		target = b.CashedOut
+	}
+	return float64(b.Amount*target/100) * CrashWinChance(target)
+}
+
+type CrashRecord struct {
+	Round      int        `json:"round"`
+	ChainRound int        `json:"chainRound"`
+	Commitment string     `json:"commitment"`
+	Hash       string     `json:"hash"`
+	Bust       int        `json:"bust"`
+	House      string     `json:"house"`
+	Bets       []CrashBet `json:"bets"`
+	// Void rounds were cut short, every bet got its stake back
+	Void bool `json:"void,omitempty"`
+}
+
+func newCrashRecordOutput(record CrashRecord) *TxOutput {
+	payload, err := json.Marshal(record)
+	Handle(err)
+
+	return &TxOutput{Value: 0, PubKeyHash: append(append([]byte{}, crashMarker...), payload...)}
+}
+
+// CrashRecord returns the crash round settled by a transaction, if any.
+func (tx *Transaction) CrashRecord() (*CrashRecord, error) {
+	for _, out := range tx.Outputs {
+		if !out.IsData() || !bytes.HasPrefix(out.PubKeyHash, crashMarker) {
+			continue
+		}
+		var record CrashRecord
+		if err := json.Unmarshal(out.PubKeyHash[len(crashMarker):], &record); err != nil {
+			return nil, fmt.Errorf("malformed crash record in %x: %w", tx.ID, err)
+		}
+		return &record, nil
+	}
+
+	return nil, nil
+}
+
+// NewCrashTransaction settles the bets of a round from escrow. Each bet
+// locked its stake and what the house could lose on it when it was placed,
+// see NewEscrowTransaction. The players get their payouts and the house the
+// rest.
+func NewCrashTransaction(escrow *wallet.Wallet, locked []EscrowOutput, record CrashRecord, utxoSet *UTXOSet) *Transaction {
+	var inputs []TxInput
+	var outputs []TxOutput
+
+	total := 0
+	for _, out := range locked {
+		inputs = append(inputs, TxInput{out.TxID, out.Out, nil, escrow.PublicKey})
+		total += out.Value
+	}
+
+	payouts := 0
+	for _, bet := range record.Bets {
+		if bet.Payout > 0 {
+			outputs = append(outputs, *NewTXOutput(bet.Payout, bet.Player))
+			payouts += bet.Payout
+		}
+	}
+	if payouts > total {
+		log.Panicf("Error: crash payouts of %d are more than the %d in escrow", payouts, total)
+	}
+	if total > payouts {
+		outputs = append(outputs, *NewTXOutput(total-payouts, record.House))
+	}
+
+	outputs = append(outputs, *newCrashRecordOutput(record))
+
+	tx := Transaction{nil, inputs, outputs}
+	tx.ID = tx.Hash()
+	utxoSet.Blockchain.SignTransaction(&tx, escrow.PrivateKey)
+
+	return &tx
+}
@@ -0,0 +1,173 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
+	"github.com/dgraph-io/badger"
+)
+
+// Games that take more than one step, like blackjack, can't settle in the
+// transaction that places the bet. Their stakes are locked in outputs of the
+// node's escrow wallet instead, one escrow transaction per stake, and a
+// settlement transaction spends all of them once the game is over.
+//
+// Escrow transactions carry a marker output. The index remembers who signed
+// the escrow output of a marked transaction, so a settlement counts for a
+// house only when it spends stakes that house locked.
+
+var (
+	escrowMarker    = []byte("\x6aescrow:")
+	escrowOutPrefix = []byte("escrowout-")
+)
+
+// EscrowOutput points at an output locked by an escrow transaction.
+type EscrowOutput struct {
+	TxID  []byte
+	Out   int
+	Value int
+}
+
+// NewEscrowTransaction locks stake of the player and cover of the house in a
+// single escrow output, output 0 of the transaction. cover is what the house
+// could lose on top of the stake.
+func NewEscrowTransaction(w, house *wallet.Wallet, escrow string, stake, cover int, utxoSet *UTXOSet) (*Transaction, EscrowOutput) {
+	var inputs []TxInput
+
+	from := string(w.Address())
+	houseAddress := string(house.Address())
+	if from == houseAddress {
+		log.Panic("Error: the house can't bet against itself")
+	}
+
+	acc, validOutputs := utxoSet.FindSpendableOutputs(wallet.PublicKeyHash(w.PublicKey), stake)
+	if acc < stake {
+		log.Panic("Error: not enough funds for game")
+	}
+
+	houseAcc, houseOutputs := utxoSet.FindSpendableOutputs(wallet.PublicKeyHash(house.PublicKey), cover)
+	if houseAcc < cover {
+		log.Panicf("Error: house bankroll of %d can't cover a possible payout of %d", houseAcc, cover+stake)
+	}
+
+	for _, spendable := range []struct {
+		outputs map[string][]int
+		pubKey  []byte
+	}{{validOutputs, w.PublicKey}, {houseOutputs, house.PublicKey}} {
+		for txid, outs := range spendable.outputs {
+			txID, err := hex.DecodeString(txid)
+			Handle(err)
+
+			for _, out := range outs {
+				inputs = append(inputs, TxInput{txID, out, nil, spendable.pubKey})
+			}
+		}
+	}
+
+	outputs := []TxOutput{*NewTXOutput(stake+cover, escrow)}
+	if acc > stake {
+		outputs = append(outputs, *NewTXOutput(acc-stake, from))
+	}
+	if houseAcc > cover {
+		outputs = append(outputs, *NewTXOutput(houseAcc-cover, houseAddress))
+	}
+	outputs = append(outputs, TxOutput{Value: 0, PubKeyHash: append([]byte{}, escrowMarker...)})
+
+	tx := Transaction{nil, inputs, outputs}
+	tx.ID = tx.Hash()
+	utxoSet.Blockchain.SignTransactionWithKeys(&tx, []wallet.PrivateKeyData{w.PrivateKey, house.PrivateKey})
+
+	return &tx, EscrowOutput{TxID: tx.ID, Out: 0, Value: stake + cover}
+}
+
+// NewSettlementTransaction releases the escrowed stakes of a game: the
+// record's payout goes to the player and the rest back to the house.
+func NewSettlementTransaction(escrow *wallet.Wallet, locked []EscrowOutput, record GameRecord, utxoSet *UTXOSet) *Transaction {
+	var inputs []TxInput
+
+	total := 0
+	for _, out := range locked {
+		inputs = append(inputs, TxInput{out.TxID, out.Out, nil, escrow.PublicKey})
+		total += out.Value
+	}
+	if record.Payout > total {
+		log.Panicf("Error: payout of %d is more than the %d in escrow", record.Payout, total)
+	}
+
+	var outputs []TxOutput
+	if record.Payout > 0 {
+		outputs = append(outputs, *NewTXOutput(record.Payout, record.Player))
+	}
+	if total > record.Payout {
+		outputs = append(outputs, *NewTXOutput(total-record.Payout, record.House))
+	}
+	outputs = append(outputs, *NewGameRecordOutput(record))
+
+	tx := Transaction{nil, inputs, outputs}
+	tx.ID = tx.Hash()
+	utxoSet.Blockchain.SignTransaction(&tx, escrow.PrivateKey)
+
+	return &tx
+}
+
+func escrowOutKey(txID []byte) []byte {
+	return append(append([]byte{}, escrowOutPrefix...), txID...)
+}
+
+// indexEscrow records the public key hashes that signed the escrow output of
+// tx, if it is a marked escrow transaction.
+func indexEscrow(txn *badger.Txn, tx *Transaction) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+
+	marked := false
+	for _, out := range tx.Outputs {
+		if out.IsData() && bytes.Equal(out.PubKeyHash, escrowMarker) {
+			marked = true
+		}
+	}
+	if !marked {
+		return nil
+	}
+
+	var signers [][]byte
+	for _, in := range tx.Inputs {
+		signers = append(signers, wallet.PublicKeyHash(in.PubKey))
+	}
+	return txn.Set(escrowOutKey(tx.ID), gobEncode(signers))
+}
+
+// signedBy reports whether the owner of address signed tx, either spending
+// its own outputs or escrow outputs it locked.
+func signedBy(txn *badger.Txn, tx *Transaction, address string) (bool, error) {
+	pubKeyHash := addressPubKeyHash(address)
+	if pubKeyHash == nil || tx.IsCoinbase() {
+		return false, nil
+	}
+
+	for _, in := range tx.Inputs {
+		if bytes.Equal(wallet.PublicKeyHash(in.PubKey), pubKeyHash) {
+			return true, nil
+		}
+
+		// The escrow output is output 0, the others are change
+		if in.Out != 0 {
+			continue
+		}
+		var signers [][]byte
+		found, err := getGob(txn, escrowOutKey(in.ID), &signers)
+		if err != nil {
+			return false, err
+		}
+		for _, signer := range signers {
+			if found && bytes.Equal(signer, pubKeyHash) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
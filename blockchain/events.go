@@ -0,0 +1,71 @@
+package blockchain
+
+import "sync"
+
+var (
+	blockListeners   []func(chain *BlockChain, block *Block)
+	blockListenersMu sync.Mutex
+)
+
+// OnBlockConnected registers fn to run after each block is connected, that
+// is once the UTXO set and indexes include it. Listeners may mine blocks of
+// their own, which are connected in turn.
+func OnBlockConnected(fn func(chain *BlockChain, block *Block)) {
+	blockListenersMu.Lock()
+	defer blockListenersMu.Unlock()
+
+	blockListeners = append(blockListeners, fn)
+}
+
+// BlockConnected runs the listeners for block. UTXOSet.Update calls it, code
+// that rebuilds the UTXO set instead calls it for every block it connected,
+// oldest first. During a
+// spend the listeners are held back until UnlockSpends.
+func BlockConnected(chain *BlockChain, block *Block) {
+	chain.pendingMu.Lock()
+	if chain.spending {
+		chain.pending = append(chain.pending, block)
+		chain.pendingMu.Unlock()
+		return
+	}
+	chain.pendingMu.Unlock()
+
+	runListeners(chain, block)
+}
+
+// LockSpends keeps other spends out while the caller picks unspent outputs,
+// mines the transaction spending them and connects its block, so two spends
+// never pick the same outputs. It is taken before the locks of the games,
+// and listeners of the blocks connected meanwhile run in UnlockSpends once
+// nothing is held, as they may spend in turn.
+func (chain *BlockChain) LockSpends() {
+	chain.spendMu.Lock()
+
+	chain.pendingMu.Lock()
+	chain.spending = true
+	chain.pendingMu.Unlock()
+}
+
+func (chain *BlockChain) UnlockSpends() {
+	chain.pendingMu.Lock()
+	blocks := chain.pending
+	chain.pending = nil
+	chain.spending = false
+	chain.pendingMu.Unlock()
+
+	chain.spendMu.Unlock()
+
+	for _, block := range blocks {
+		runListeners(chain, block)
+	}
+}
+
+func runListeners(chain *BlockChain, block *Block) {
+	blockListenersMu.Lock()
+	listeners := append([]func(*BlockChain, *Block){}, blockListeners...)
+	blockListenersMu.Unlock()
+
+	for _, fn := range listeners {
+		fn(chain, block)
+	}
+}
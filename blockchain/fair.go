@@ -0,0 +1,372 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Provably fair games follow the usual commit–reveal scheme. The house
+// publishes sha256(serverSeed) before any bet, the player picks a client seed
+// and a nonce per bet, and every outcome is drawn from
+// HMAC-SHA256(serverSeed, "clientSeed:nonce:round"). Once a seed is rotated
+// out it is revealed so anyone can recompute past outcomes.
+
+var (
+	fairActiveKey  = []byte("fair-active")
+	fairSeedPrefix = []byte("fair-seed-")
+	fairUsedPrefix = []byte("fair-used-")
+	fairLockPrefix = []byte("fair-lock-")
+	fairMu         sync.Mutex
+)
+
+type ServerSeed struct {
+	Seed       []byte
+	Hash       []byte
+	Created    int64
+	Revealed   bool
+	RevealedAt int64
+}
+
+// Public hides the seed itself until it has been revealed.
+func (s ServerSeed) Public() ServerSeed {
+	if !s.Revealed {
+		s.Seed = nil
+	}
+	return s
+}
+
+// FairRNG is the deterministic random stream of one bet.
+type FairRNG struct {
+	serverSeed []byte
+	clientSeed string
+	nonce      int
+	round      int
+	buf        []byte
+}
+
+func NewFairRNG(serverSeed []byte, clientSeed string, nonce int) *FairRNG {
+	return &FairRNG{serverSeed: serverSeed, clientSeed: clientSeed, nonce: nonce}
+}
+
+func (r *FairRNG) Uint32() uint32 {
+	if len(r.buf) < 4 {
+		mac := hmac.New(sha256.New, r.serverSeed)
+		fmt.Fprintf(mac, "%s:%d:%d", r.clientSeed, r.nonce, r.round)
+		r.buf = mac.Sum(nil)
+		r.round++
+	}
+
+	v := binary.BigEndian.Uint32(r.buf[:4])
+	r.buf = r.buf[4:]
+
+	return v
+}
+
+// Intn returns an unbiased number in [0, n). Draws from the top of the
+// uint32 range that would favour low results are rejected.
+func (r *FairRNG) Intn(n int) int {
+	if n <= 0 || uint64(n) > math.MaxUint32 {
+		panic("FairRNG.Intn: invalid range")
+	}
+
+	limit := math.MaxUint32 - (math.MaxUint32 % uint32(n))
+	for {
+		v := r.Uint32()
+		if v < limit {
+			return int(v % uint32(n))
+		}
+	}
+}
+
+// FairRoll ties a bet to the seeds that decide it.
+type FairRoll struct {
+	ServerSeedHash []byte
+	ClientSeed     string
+	Nonce          int
+	*FairRNG
+}
+
+// FairSeeds manages server seeds in the chain database, the same way
+// UTXOSet manages unspent outputs.
+type FairSeeds struct {
+	Blockchain *BlockChain
+}
+
+// ServerSeedHash is the commitment published for a server seed.
+func ServerSeedHash(seed []byte) []byte {
+	hash := sha256.Sum256(seed)
+	return hash[:]
+}
+
+func newServerSeed() ServerSeed {
+	seed := make([]byte, 32)
+	_, err := rand.Read(seed)
+	Handle(err)
+
+	return ServerSeed{Seed: seed, Hash: ServerSeedHash(seed), Created: time.Now().Unix()}
+}
+
+func seedKey(hash []byte) []byte {
+	return append(append([]byte{}, fairSeedPrefix...), hex.EncodeToString(hash)...)
+}
+
+func (s ServerSeed) serialize() []byte {
+	var buff bytes.Buffer
+	Handle(gob.NewEncoder(&buff).Encode(s))
+	return buff.Bytes()
+}
+
+func deserializeServerSeed(data []byte) ServerSeed {
+	var s ServerSeed
+	Handle(gob.NewDecoder(bytes.NewReader(data)).Decode(&s))
+	return s
+}
+
+func getServerSeed(txn *badger.Txn, hash []byte) (ServerSeed, error) {
+	item, err := txn.Get(seedKey(hash))
+	if err != nil {
+		return ServerSeed{}, err
+	}
+	data, err := item.ValueCopy(nil)
+	if err != nil {
+		return ServerSeed{}, err
+	}
+	return deserializeServerSeed(data), nil
+}
+
+// activeSeed returns the committed seed, creating the first one on demand.
+func activeSeed(txn *badger.Txn) (ServerSeed, error) {
+	item, err := txn.Get(fairActiveKey)
+	if err == badger.ErrKeyNotFound {
+		seed := newServerSeed()
+		if err := txn.Set(seedKey(seed.Hash), seed.serialize()); err != nil {
+			return seed, err
+		}
+		return seed, txn.Set(fairActiveKey, seed.Hash)
+	}
+	if err != nil {
+		return ServerSeed{}, err
+	}
+
+	hash, err := item.ValueCopy(nil)
+	if err != nil {
+		return ServerSeed{}, err
+	}
+	return getServerSeed(txn, hash)
+}
+
+// Active returns the currently committed server seed (with Seed hidden).
+func (f FairSeeds) Active() ServerSeed {
+	fairMu.Lock()
+	defer fairMu.Unlock()
+
+	var seed ServerSeed
+	err := f.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		var err error
+		seed, err = activeSeed(txn)
+		return err
+	})
+	Handle(err)
+
+	return seed.Public()
+}
+
+// Rotate reveals the active seed and commits to a fresh one. It refuses
+// while games in progress still draw from the seed.
+func (f FairSeeds) Rotate() (revealed ServerSeed, next ServerSeed, err error) {
+	fairMu.Lock()
+	defer fairMu.Unlock()
+
+	err = f.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		current, err := activeSeed(txn)
+		if err != nil {
+			return err
+		}
+		if locks := countLocks(txn, current.Hash); locks > 0 {
+			return fmt.Errorf("server seed is in use by %d games in progress, rotate once they settle", locks)
+		}
+		current.Revealed = true
+		current.RevealedAt = time.Now().Unix()
+		if err := txn.Set(seedKey(current.Hash), current.serialize()); err != nil {
+			return err
+		}
+
+		next = newServerSeed()
+		if err := txn.Set(seedKey(next.Hash), next.serialize()); err != nil {
+			return err
+		}
+		revealed = current
+
+		return txn.Set(fairActiveKey, next.Hash)
+	})
+	if err != nil {
+		return ServerSeed{}, ServerSeed{}, err
+	}
+
+	return revealed, next.Public(), nil
+}
+
+// Multi-step games like blackjack keep drawing from their roll after the
+// bet is placed. They lock the seed for as long as they run, since
+// revealing it would reveal the rest of their cards.
+
+func lockKey(hash []byte, id string) []byte {
+	return []byte(fmt.Sprintf("%s%x-%s", fairLockPrefix, hash, id))
+}
+
+func countLocks(txn *badger.Txn, hash []byte) int {
+	prefix := []byte(fmt.Sprintf("%s%x-", fairLockPrefix, hash))
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	count := 0
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		count++
+	}
+	return count
+}
+
+// Lock keeps the seed from being rotated until Unlock is called with id.
+func (f FairSeeds) Lock(hash []byte, id string) {
+	err := f.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set(lockKey(hash, id), []byte{})
+	})
+	Handle(err)
+}
+
+func (f FairSeeds) Unlock(hash []byte, id string) {
+	err := f.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Delete(lockKey(hash, id))
+	})
+	Handle(err)
+}
+
+// Resume rebuilds the roll of a bet placed earlier with NewRoll, e.g. after
+// a restart. The seed stays inside the RNG.
+func (f FairSeeds) Resume(hash []byte, clientSeed string, nonce int) (*FairRoll, error) {
+	var seed ServerSeed
+
+	err := f.Blockchain.Database.View(func(txn *badger.Txn) error {
+		var err error
+		seed, err = getServerSeed(txn, hash)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("unknown server seed %x", hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &FairRoll{
+		ServerSeedHash: seed.Hash,
+		ClientSeed:     clientSeed,
+		Nonce:          nonce,
+		FairRNG:        NewFairRNG(seed.Seed, clientSeed, nonce),
+	}, nil
+}
+
+// Seeds lists every server seed, revealed ones with their seed published.
+func (f FairSeeds) Seeds() []ServerSeed {
+	var seeds []ServerSeed
+
+	err := f.Blockchain.Database.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(fairSeedPrefix); it.ValidForPrefix(fairSeedPrefix); it.Next() {
+			data, err := it.Item().ValueCopy(nil)
+			Handle(err)
+			seeds = append(seeds, deserializeServerSeed(data).Public())
+		}
+		return nil
+	})
+	Handle(err)
+
+	return seeds
+}
+
+// Lookup finds a server seed by its hash, with Seed hidden until revealed.
+func (f FairSeeds) Lookup(hash []byte) (ServerSeed, error) {
+	var seed ServerSeed
+
+	err := f.Blockchain.Database.View(func(txn *badger.Txn) error {
+		var err error
+		seed, err = getServerSeed(txn, hash)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return seed, fmt.Errorf("unknown server seed %x", hash)
+	}
+	if err != nil {
+		return seed, err
+	}
+
+	return seed.Public(), nil
+}
+
+// NewRoll binds a bet to the active seed, the client seed and nonce. Each
+// (clientSeed, nonce) pair can be used only once per server seed, otherwise a
+// player could replay a known winning roll.
+func (f FairSeeds) NewRoll(clientSeed string, nonce int) (*FairRoll, error) {
+	if clientSeed == "" {
+		return nil, errors.New("client seed is required")
+	}
+	if nonce < 0 {
+		return nil, errors.New("nonce must not be negative")
+	}
+
+	fairMu.Lock()
+	defer fairMu.Unlock()
+
+	var roll *FairRoll
+	err := f.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		seed, err := activeSeed(txn)
+		if err != nil {
+			return err
+		}
+
+		usedKey := []byte(fmt.Sprintf("%s%x-%x-%d", fairUsedPrefix, seed.Hash, clientSeed, nonce))
+		if _, err := txn.Get(usedKey); err == nil {
+			return fmt.Errorf("nonce %d was already used with this client seed", nonce)
+		}
+		if err := txn.Set(usedKey, []byte{1}); err != nil {
+			return err
+		}
+
+		roll = &FairRoll{
+			ServerSeedHash: seed.Hash,
+			ClientSeed:     clientSeed,
+			Nonce:          nonce,
+			FairRNG:        NewFairRNG(seed.Seed, clientSeed, nonce),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return roll, nil
+}
+
+// RandomClientSeed is used when a player doesn't bring their own seed.
+func RandomClientSeed() string {
+	seed := make([]byte, 16)
+	_, err := rand.Read(seed)
+	Handle(err)
+
+	return hex.EncodeToString(seed)
+}
@@ -0,0 +1,260 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Game transactions carry a GameRecord in an extra zero value output, the
+// same trick as Bitcoin's OP_RETURN. The record is covered by the input
+// signatures like any other output, and keeping it out of the Transaction
+// struct leaves the gob encoding, and so the IDs, of existing transactions
+// untouched. The payload is JSON so tools outside this repo can read it.
+
+var (
+	gameRecordMarker = []byte("\x6agame:")
+	gameTxPrefix     = []byte("gametx-")
+	gameAddrPrefix   = []byte("gameaddr-")
+)
+
+// GameRecord is everything needed to recompute a bet and its expected
+// return: the bet and payout, the outcome and the odds the payout was based
+// on, and the provably fair seeds the outcome was drawn from.
+type GameRecord struct {
+	Game    string `json:"game"`
+	Player  string `json:"player"`
+	House   string `json:"house"`
+	Bet     int    `json:"bet"`
+	Payout  int    `json:"payout"`
+	Outcome int    `json:"outcome"`
+	Guess   int    `json:"guess,omitempty"`
+	// Params are the game specific parameters of the bet
+	Params map[string]string `json:"params,omitempty"`
+
+	// A win pays Multiplier times the bet and happens for WinOutcomes of
+	// Outcomes equally likely outcomes.
+	Multiplier  int `json:"multiplier"`
+	WinOutcomes int `json:"winOutcomes"`
+	Outcomes    int `json:"outcomes"`
+
+	ServerSeedHash string `json:"serverSeedHash"`
+	ClientSeed     string `json:"clientSeed"`
+	Nonce          int    `json:"nonce"`
+
+	// The share of the bet paid into the jackpot, and the pool won on a hit
+	JackpotContribution int `json:"jackpotContribution,omitempty"`
+	JackpotPayout       int `json:"jackpotPayout,omitempty"`
+}
+
+// RTP is the expected return to player of the bet, 1 - house edge.
+func (r GameRecord) RTP() float64 {
+	if r.Outcomes == 0 {
+		return 0
+	}
+	return float64(r.Multiplier*r.WinOutcomes) / float64(r.Outcomes)
+}
+
+func NewGameRecordOutput(record GameRecord) *TxOutput {
+	payload, err := json.Marshal(record)
+	Handle(err)
+
+	return &TxOutput{Value: 0, PubKeyHash: append(append([]byte{}, gameRecordMarker...), payload...)}
+}
+
+// IsData reports whether the output only carries data and can't be spent.
+func (out *TxOutput) IsData() bool {
+	return out.Value == 0 && len(out.PubKeyHash) > 0 && out.PubKeyHash[0] == gameRecordMarker[0]
+}
+
+// GameRecord returns the record attached to a game transaction, if any.
+func (tx *Transaction) GameRecord() (*GameRecord, error) {
+	for _, out := range tx.Outputs {
+		if !out.IsData() || !bytes.HasPrefix(out.PubKeyHash, gameRecordMarker) {
+			continue
+		}
+		var record GameRecord
+		if err := json.Unmarshal(out.PubKeyHash[len(gameRecordMarker):], &record); err != nil {
+			return nil, fmt.Errorf("malformed game record in %x: %w", tx.ID, err)
+		}
+		return &record, nil
+	}
+
+	return nil, nil
+}
+
+// IndexedGame is a game record as stored in the node's index.
+type IndexedGame struct {
+	TxID      []byte
+	Height    int
+	Timestamp int64
+	Record    GameRecord
+}
+
+func (g IndexedGame) serialize() []byte {
+	var buff bytes.Buffer
+	Handle(gob.NewEncoder(&buff).Encode(g))
+	return buff.Bytes()
+}
+
+func deserializeIndexedGame(data []byte) IndexedGame {
+	var g IndexedGame
+	Handle(gob.NewDecoder(bytes.NewReader(data)).Decode(&g))
+	return g
+}
+
+func gameTxKey(txID []byte) []byte {
+	return append(append([]byte{}, gameTxPrefix...), txID...)
+}
+
+func gameAddrKey(address string, txID []byte) []byte {
+	key := append(append([]byte{}, gameAddrPrefix...), address...)
+	key = append(key, '-')
+	return append(key, txID...)
+}
+
+// indexGames adds the game records of block to the index. Anyone can write
+// a record, so only those the named house signed are indexed.
+func indexGames(txn *badger.Txn, block *Block) error {
+	for _, tx := range block.Transactions {
+		if err := indexEscrow(txn, tx); err != nil {
+			return err
+		}
+
+		record, err := tx.GameRecord()
+		if err != nil || record == nil {
+			continue
+		}
+		signed, err := signedBy(txn, tx, record.House)
+		if err != nil {
+			return err
+		}
+		if !signed {
+			continue
+		}
+
+		game := IndexedGame{TxID: tx.ID, Height: block.Height, Timestamp: block.Timestamp, Record: *record}
+		if err := txn.Set(gameTxKey(tx.ID), game.serialize()); err != nil {
+			return err
+		}
+		if err := txn.Set(gameAddrKey(record.Player, tx.ID), []byte{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GameIndex answers queries on the game records of the chain.
+type GameIndex struct {
+	Blockchain *BlockChain
+}
+
+// Reindex rebuilds the index from the blocks, oldest first so escrow
+// outputs are known before the settlements spending them.
+func (g GameIndex) Reindex() {
+	u := UTXOSet{Blockchain: g.Blockchain}
+	u.DeleteByPrefix(gameTxPrefix)
+	u.DeleteByPrefix(gameAddrPrefix)
+	u.DeleteByPrefix(escrowOutPrefix)
+
+	hashes := g.Blockchain.GetBlockHashes()
+	for i := len(hashes) - 1; i >= 0; i-- {
+		block, err := g.Blockchain.GetBlock(hashes[i])
+		Handle(err)
+
+		err = g.Blockchain.Database.Update(func(txn *badger.Txn) error {
+			return indexGames(txn, &block)
+		})
+		Handle(err)
+	}
+}
+
+func (g GameIndex) Get(txID []byte) (*IndexedGame, error) {
+	var game *IndexedGame
+
+	err := g.Blockchain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(gameTxKey(txID))
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		found := deserializeIndexedGame(data)
+		game = &found
+		return nil
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("no game record for %s", hex.EncodeToString(txID))
+	}
+
+	return game, err
+}
+
+// Records lists the indexed games, all of them when address is empty.
+func (g GameIndex) Records(address string) []IndexedGame {
+	var games []IndexedGame
+
+	err := g.Blockchain.Database.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		if address == "" {
+			for it.Seek(gameTxPrefix); it.ValidForPrefix(gameTxPrefix); it.Next() {
+				data, err := it.Item().ValueCopy(nil)
+				Handle(err)
+				games = append(games, deserializeIndexedGame(data))
+			}
+			return nil
+		}
+
+		prefix := gameAddrKey(address, nil)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			txID := it.Item().KeyCopy(nil)[len(prefix):]
+			item, err := txn.Get(gameTxKey(txID))
+			Handle(err)
+			data, err := item.ValueCopy(nil)
+			Handle(err)
+			games = append(games, deserializeIndexedGame(data))
+		}
+		return nil
+	})
+	Handle(err)
+
+	return games
+}
+
+// GameSummary totals a set of games. HouseEdge is what the house actually
+// kept, ExpectedEdge what the odds of the recorded bets promised.
+type GameSummary struct {
+	Bets         int     `json:"bets"`
+	Wagered      int     `json:"wagered"`
+	PaidOut      int     `json:"paidOut"`
+	HouseEdge    float64 `json:"houseEdge"`
+	ExpectedEdge float64 `json:"expectedEdge"`
+}
+
+func SummarizeGames(games []IndexedGame) GameSummary {
+	var summary GameSummary
+	var expectedReturn float64
+
+	for _, game := range games {
+		summary.Bets++
+		summary.Wagered += game.Record.Bet
+		summary.PaidOut += game.Record.Payout
+		expectedReturn += float64(game.Record.Bet) * game.Record.RTP()
+	}
+	if summary.Wagered > 0 {
+		wagered := float64(summary.Wagered)
+		summary.HouseEdge = (wagered - float64(summary.PaidOut)) / wagered
+		summary.ExpectedEdge = (wagered - expectedReturn) / wagered
+	}
+
+	return summary
+}
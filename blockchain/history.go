@@ -0,0 +1,46 @@
+package blockchain
+
+import (
+	"encoding/hex"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// UsedPubKeyHashes is the set of public key hashes, hex encoded, that ever
+// received or spent an output, for HD wallet discovery.
+func (chain *BlockChain) UsedPubKeyHashes() map[string]bool {
+	used := make(map[string]bool)
+
+	iter := chain.Iterator()
+	for {
+		block := iter.Next()
+		for _, tx := range block.Transactions {
+			for _, out := range tx.Outputs {
+				if !out.IsData() {
+					used[hex.EncodeToString(out.PubKeyHash)] = true
+				}
+			}
+			if tx.IsCoinbase() {
+				continue
+			}
+			for _, in := range tx.Inputs {
+				used[hex.EncodeToString(wallet.PublicKeyHash(in.PubKey))] = true
+			}
+		}
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return used
+}
+
+// Balance is the value of the unspent outputs of a public key hash.
+func (u UTXOSet) Balance(pubKeyHash []byte) int {
+	balance := 0
+	for _, out := range u.FindUnspentTransactions(pubKeyHash) {
+		balance += out.Value
+	}
+	return balance
+}
@@ -0,0 +1,51 @@
+package blockchain
+
+import (
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// HouseReport describes the solvency of the house bankroll. Bets settle in
+// the transaction that places them, so the bankroll has no outstanding
+// liabilities and MaxBets is the largest bet each game can take right now at
+// the top of its payout table. The cover of blackjack sessions in progress
+// sits in escrow and isn't part of the bankroll until they settle.
+type HouseReport struct {
+	Address  string         `json:"address"`
+	Bankroll int            `json:"bankroll"`
+	MaxBets  map[string]int `json:"maxBets"`
+	Games    GameSummary    `json:"games"`
+	// Profit is what the house won from players over all recorded games
+	Profit int `json:"profit"`
+}
+
+func NewHouseReport(chain *BlockChain, address string, maxMultipliers map[string]int) HouseReport {
+	UTXOSet := UTXOSet{Blockchain: chain}
+
+	pubKeyHash := wallet.Base58Decode([]byte(address))
+	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+
+	report := HouseReport{Address: address, MaxBets: make(map[string]int)}
+	for _, out := range UTXOSet.FindUnspentTransactions(pubKeyHash) {
+		report.Bankroll += out.Value
+	}
+	for game, multiplier := range maxMultipliers {
+		if multiplier > 1 {
+			report.MaxBets[game] = report.Bankroll / (multiplier - 1)
+		}
+	}
+
+	var games []IndexedGame
+	for _, game := range (GameIndex{Blockchain: chain}).Records("") {
+		if game.Record.House == address {
+			games = append(games, game)
+		}
+	}
+	report.Games = SummarizeGames(games)
+	report.Profit = report.Games.Wagered - report.Games.PaidOut
+	for _, game := range games {
+		// The house funds the jackpot out of its share
+		report.Profit -= game.Record.JackpotContribution
+	}
+
+	return report
+}
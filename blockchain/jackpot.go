@@ -0,0 +1,128 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// The progressive jackpot is a pool shared by all games. A share of every
+// bet is taken from what the house keeps and paid to the node's jackpot
+// wallet, one output per bet, and a bet that hits the jackpot spends all of
+// those outputs to the player.
+
+// DefaultJackpotPercent of every bet goes into the pool unless the
+// JACKPOT_PERCENT environment variable says otherwise.
+const DefaultJackpotPercent = 1.0
+
+type Jackpot struct {
+	Wallet *wallet.Wallet
+	// Percent of each bet paid into the pool
+	Percent float64
+}
+
+func JackpotPercent() float64 {
+	value := os.Getenv("JACKPOT_PERCENT")
+	if value == "" {
+		return DefaultJackpotPercent
+	}
+
+	percent, err := strconv.ParseFloat(value, 64)
+	if err != nil || percent < 0 || percent >= 100 {
+		log.Printf("Ignoring JACKPOT_PERCENT=%q, using %.2f%%", value, DefaultJackpotPercent)
+		return DefaultJackpotPercent
+	}
+	return percent
+}
+
+func NewJackpot(w *wallet.Wallet) *Jackpot {
+	return &Jackpot{Wallet: w, Percent: JackpotPercent()}
+}
+
+// jackpotCarry is what earlier bets owe the pool on top of the whole coins
+// they paid, in hundredths of a percent of a coin.
+var (
+	jackpotCarry   int
+	jackpotCarryMu sync.Mutex
+)
+
+// Contribution is the part of a bet that goes into the pool. Coins are
+// whole, so the share is rounded down and the fraction left over carries to
+// the next bet: over many bets the pool gets the configured percentage, to a
+// hundredth of a percent, without small bets paying more than their share.
+func (j *Jackpot) Contribution(amount int) int {
+	if j == nil || amount <= 0 {
+		return 0
+	}
+
+	jackpotCarryMu.Lock()
+	defer jackpotCarryMu.Unlock()
+
+	share := amount*int(math.Round(j.Percent*100)) + jackpotCarry
+	jackpotCarry = share % 10000
+	return share / 10000
+}
+
+func (j *Jackpot) Address() string {
+	return string(j.Wallet.Address())
+}
+
+// pool finds every output of the jackpot wallet.
+func (j *Jackpot) pool(utxoSet *UTXOSet) (int, []TxInput) {
+	var inputs []TxInput
+
+	total, outputs := utxoSet.FindSpendableOutputs(wallet.PublicKeyHash(j.Wallet.PublicKey), math.MaxInt)
+	for txid, outs := range outputs {
+		txID, err := hex.DecodeString(txid)
+		Handle(err)
+
+		for _, out := range outs {
+			inputs = append(inputs, TxInput{txID, out, nil, j.Wallet.PublicKey})
+		}
+	}
+
+	return total, inputs
+}
+
+// JackpotPool is the amount currently in the pool of address.
+func JackpotPool(chain *BlockChain, address string) int {
+	if address == "" {
+		return 0
+	}
+
+	pubKeyHash := wallet.Base58Decode([]byte(address))
+	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+
+	pool := 0
+	for _, out := range (UTXOSet{Blockchain: chain}).FindUnspentTransactions(pubKeyHash) {
+		pool += out.Value
+	}
+	return pool
+}
+
+// JackpotReport describes the pool and what it paid out so far.
+type JackpotReport struct {
+	Address string  `json:"address"`
+	Pool    int     `json:"pool"`
+	Percent float64 `json:"percent"`
+	Hits    int     `json:"hits"`
+	Paid    int     `json:"paid"`
+}
+
+func NewJackpotReport(chain *BlockChain, address string) JackpotReport {
+	report := JackpotReport{Address: address, Pool: JackpotPool(chain, address), Percent: JackpotPercent()}
+
+	for _, game := range (GameIndex{Blockchain: chain}).Records("") {
+		if game.Record.JackpotPayout > 0 {
+			report.Hits++
+			report.Paid += game.Record.JackpotPayout
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,545 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// The lottery runs in rounds of LotteryRoundBlocks blocks. A ticket is a
+// payment to the lottery address, one coin per ticket, with a data output
+// carrying the player's commitment. The round a ticket belongs to is decided
+// by the height of the block it's mined in. Round r is drawn by the block
+// LotteryDrawDelay heights after its last one: the winning ticket is
+// sha256(drawBlockHash || txid || commitment ...) mod tickets, over the
+// tickets in (height, txid) order. Nobody knows the draw block's hash while
+// tickets can still be bought, but whoever mines it knows the outcome
+// before publishing it and can grind nonces or withhold the block until it
+// suits them. The commitments are public by then and don't prevent that.
+// This node mines its own blocks, so on a chain it mines alone the players
+// have to trust it; the delay gives blocks of other miners time to come in
+// first. The node holding the lottery wallet pays the pot out as soon as
+// the draw block is connected.
+
+var (
+	lotteryMarker       = []byte("\x6alottery:")
+	lotteryTicketPrefix = []byte("lotteryticket-")
+	lotteryDrawPrefix   = []byte("lotterydraw-")
+	lotteryDrawing      int32
+)
+
+// DefaultLotteryRound is the round length in blocks unless LOTTERY_ROUND
+// says otherwise. Keep it fixed for the life of a chain, rounds are derived
+// from it.
+const DefaultLotteryRound = 10
+
+func LotteryRoundBlocks() int {
+	value := os.Getenv("LOTTERY_ROUND")
+	if value == "" {
+		return DefaultLotteryRound
+	}
+
+	blocks, err := strconv.Atoi(value)
+	if err != nil || blocks < 2 {
+		log.Printf("Ignoring LOTTERY_ROUND=%q, using %d blocks", value, DefaultLotteryRound)
+		return DefaultLotteryRound
+	}
+	return blocks
+}
+
+// LotteryDrawDelay is the number of blocks between the last block of a
+// round and the block that draws it.
+const LotteryDrawDelay = 6
+
+// LotteryDrawHeight is the height of the block drawing round.
+func LotteryDrawHeight(round int) int {
+	return (round+1)*LotteryRoundBlocks() - 1 + LotteryDrawDelay
+}
+
+// LotteryRecord is the data output of lottery transactions: either a ticket
+// purchase or the draw paying out a round.
+type LotteryRecord struct {
+	Lottery    string       `json:"lottery"`
+	Player     string       `json:"player,omitempty"`
+	Commitment string       `json:"commitment,omitempty"`
+	Draw       *LotteryDraw `json:"draw,omitempty"`
+}
+
+type LotteryDraw struct {
+	Round         int    `json:"round"`
+	DrawHeight    int    `json:"drawHeight"`
+	DrawHash      string `json:"drawHash"`
+	Seed          string `json:"seed"`
+	Tickets       int    `json:"tickets"`
+	Pot           int    `json:"pot"`
+	WinningTicket int    `json:"winningTicket"`
+	Winner        string `json:"winner"`
+}
+
+type LotteryTicket struct {
+	TxID       []byte
+	Lottery    string
+	Round      int
+	Height     int
+	Player     string
+	Commitment string
+	// Tickets is the amount paid to the lottery, Outs the outputs paying it
+	Tickets int
+	Outs    []int
+}
+
+type IndexedDraw struct {
+	TxID   []byte
+	Height int
+	Draw   LotteryDraw
+}
+
+func newLotteryRecordOutput(record LotteryRecord) *TxOutput {
+	payload, err := json.Marshal(record)
+	Handle(err)
+
+	return &TxOutput{Value: 0, PubKeyHash: append(append([]byte{}, lotteryMarker...), payload...)}
+}
+
+// LotteryRecord returns the lottery record of a transaction, if any.
+func (tx *Transaction) LotteryRecord() (*LotteryRecord, error) {
+	for _, out := range tx.Outputs {
+		if !out.IsData() || !bytes.HasPrefix(out.PubKeyHash, lotteryMarker) {
+			continue
+		}
+		var record LotteryRecord
+		if err := json.Unmarshal(out.PubKeyHash[len(lotteryMarker):], &record); err != nil {
+			return nil, fmt.Errorf("malformed lottery record in %x: %w", tx.ID, err)
+		}
+		return &record, nil
+	}
+
+	return nil, nil
+}
+
+// NewLotteryTicketTransaction buys tickets for w, one coin each.
+func NewLotteryTicketTransaction(w *wallet.Wallet, lottery string, tickets int, commitment string, utxoSet *UTXOSet) *Transaction {
+	var inputs []TxInput
+
+	acc, validOutputs := utxoSet.FindSpendableOutputs(wallet.PublicKeyHash(w.PublicKey), tickets)
+	if acc < tickets {
+		log.Panic("Error: not enough funds for tickets")
+	}
+
+	for txid, outs := range validOutputs {
+		txID, err := hex.DecodeString(txid)
+		Handle(err)
+
+		for _, out := range outs {
+			inputs = append(inputs, TxInput{txID, out, nil, w.PublicKey})
+		}
+	}
+
+	from := string(w.Address())
+	outputs := []TxOutput{*NewTXOutput(tickets, lottery)}
+	if acc > tickets {
+		outputs = append(outputs, *NewTXOutput(acc-tickets, from))
+	}
+	outputs = append(outputs, *newLotteryRecordOutput(LotteryRecord{Lottery: lottery, Player: from, Commitment: commitment}))
+
+	tx := Transaction{nil, inputs, outputs}
+	tx.ID = tx.Hash()
+	utxoSet.Blockchain.SignTransaction(&tx, w.PrivateKey)
+
+	return &tx
+}
+
+func lotteryTicketKey(round int, txID []byte) []byte {
+	return []byte(fmt.Sprintf("%s%08d-%x", lotteryTicketPrefix, round, txID))
+}
+
+func lotteryDrawKey(round int) []byte {
+	return []byte(fmt.Sprintf("%s%08d", lotteryDrawPrefix, round))
+}
+
+func gobEncode(v interface{}) []byte {
+	var buff bytes.Buffer
+	Handle(gob.NewEncoder(&buff).Encode(v))
+	return buff.Bytes()
+}
+
+func gobDecode(data []byte, v interface{}) {
+	Handle(gob.NewDecoder(bytes.NewReader(data)).Decode(v))
+}
+
+// indexLottery adds the tickets and draws of block to the index.
+func indexLottery(txn *badger.Txn, block *Block) error {
+	rounds := LotteryRoundBlocks()
+
+	for _, tx := range block.Transactions {
+		record, err := tx.LotteryRecord()
+		if err != nil || record == nil {
+			continue
+		}
+
+		if record.Draw != nil {
+			draw := IndexedDraw{TxID: tx.ID, Height: block.Height, Draw: *record.Draw}
+			if err := txn.Set(lotteryDrawKey(draw.Draw.Round), gobEncode(draw)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// A ticket of a player that isn't an address could never be paid
+		lotteryHash := addressPubKeyHash(record.Lottery)
+		if lotteryHash == nil || addressPubKeyHash(record.Player) == nil {
+			continue
+		}
+		ticket := LotteryTicket{
+			TxID:       tx.ID,
+			Lottery:    record.Lottery,
+			Round:      block.Height / rounds,
+			Height:     block.Height,
+			Player:     record.Player,
+			Commitment: record.Commitment,
+		}
+		for i, out := range tx.Outputs {
+			if !out.IsData() && bytes.Equal(out.PubKeyHash, lotteryHash) {
+				ticket.Tickets += out.Value
+				ticket.Outs = append(ticket.Outs, i)
+			}
+		}
+		if ticket.Tickets == 0 {
+			continue
+		}
+		if err := txn.Set(lotteryTicketKey(ticket.Round, tx.ID), gobEncode(ticket)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addressPubKeyHash decodes an address named in a record, nil when it isn't
+// a valid address.
+func addressPubKeyHash(address string) (pubKeyHash []byte) {
+	defer func() {
+		if recover() != nil {
+			pubKeyHash = nil
+		}
+	}()
+
+	if !wallet.ValidateAddress(address) {
+		return nil
+	}
+	pubKeyHash = wallet.Base58Decode([]byte(address))
+	return pubKeyHash[1 : len(pubKeyHash)-4]
+}
+
+// Lottery answers queries on the lottery and draws due rounds.
+type Lottery struct {
+	Blockchain *BlockChain
+}
+
+// Reindex rebuilds the ticket and draw index from the blocks.
+func (l Lottery) Reindex() {
+	u := UTXOSet{Blockchain: l.Blockchain}
+	u.DeleteByPrefix(lotteryTicketPrefix)
+	u.DeleteByPrefix(lotteryDrawPrefix)
+
+	iter := l.Blockchain.Iterator()
+	for {
+		block := iter.Next()
+
+		err := l.Blockchain.Database.Update(func(txn *badger.Txn) error {
+			return indexLottery(txn, block)
+		})
+		Handle(err)
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+}
+
+// Tickets lists the tickets of round in draw order, or of every round when
+// round is negative.
+func (l Lottery) Tickets(round int) []LotteryTicket {
+	var tickets []LotteryTicket
+
+	prefix := lotteryTicketPrefix
+	if round >= 0 {
+		prefix = []byte(fmt.Sprintf("%s%08d-", lotteryTicketPrefix, round))
+	}
+
+	err := l.Blockchain.Database.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			data, err := it.Item().ValueCopy(nil)
+			Handle(err)
+
+			var ticket LotteryTicket
+			gobDecode(data, &ticket)
+			tickets = append(tickets, ticket)
+		}
+		return nil
+	})
+	Handle(err)
+
+	sort.Slice(tickets, func(i, j int) bool {
+		if tickets[i].Round != tickets[j].Round {
+			return tickets[i].Round < tickets[j].Round
+		}
+		if tickets[i].Height != tickets[j].Height {
+			return tickets[i].Height < tickets[j].Height
+		}
+		return bytes.Compare(tickets[i].TxID, tickets[j].TxID) < 0
+	})
+
+	return tickets
+}
+
+// Draws lists the past draws, oldest first.
+func (l Lottery) Draws() []IndexedDraw {
+	var draws []IndexedDraw
+
+	err := l.Blockchain.Database.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(lotteryDrawPrefix); it.ValidForPrefix(lotteryDrawPrefix); it.Next() {
+			data, err := it.Item().ValueCopy(nil)
+			Handle(err)
+
+			var draw IndexedDraw
+			gobDecode(data, &draw)
+			draws = append(draws, draw)
+		}
+		return nil
+	})
+	Handle(err)
+
+	return draws
+}
+
+func (l Lottery) drawn(round int) bool {
+	err := l.Blockchain.Database.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(lotteryDrawKey(round))
+		return err
+	})
+	return err == nil
+}
+
+// LotteryRound summarises one round.
+type LotteryRound struct {
+	Round       int          `json:"round"`
+	StartHeight int          `json:"startHeight"`
+	EndHeight   int          `json:"endHeight"`
+	DrawHeight  int          `json:"drawHeight"`
+	Tickets     int          `json:"tickets"`
+	Players     int          `json:"players"`
+	Draw        *LotteryDraw `json:"draw,omitempty"`
+	DrawTx      string       `json:"drawTx,omitempty"`
+}
+
+// Rounds lists every round with tickets, plus the current one.
+func (l Lottery) Rounds() []LotteryRound {
+	blocks := LotteryRoundBlocks()
+	current := (l.Blockchain.GetBestHeight() + 1) / blocks
+
+	rounds := make(map[int]*LotteryRound)
+	round := func(r int) *LotteryRound {
+		if rounds[r] == nil {
+			rounds[r] = &LotteryRound{Round: r, StartHeight: r * blocks, EndHeight: (r+1)*blocks - 1, DrawHeight: LotteryDrawHeight(r)}
+		}
+		return rounds[r]
+	}
+	round(current)
+
+	players := make(map[int]map[string]bool)
+	for _, ticket := range l.Tickets(-1) {
+		r := round(ticket.Round)
+		r.Tickets += ticket.Tickets
+		if players[ticket.Round] == nil {
+			players[ticket.Round] = make(map[string]bool)
+		}
+		players[ticket.Round][ticket.Player] = true
+		r.Players = len(players[ticket.Round])
+	}
+	for _, draw := range l.Draws() {
+		draw := draw
+		r := round(draw.Draw.Round)
+		r.Draw = &draw.Draw
+		r.DrawTx = hex.EncodeToString(draw.TxID)
+	}
+
+	var list []LotteryRound
+	for _, r := range rounds {
+		list = append(list, *r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Round < list[j].Round })
+
+	return list
+}
+
+// DrawWinner picks the winning ticket of a round from the draw block hash
+// and the tickets in draw order. Anyone can recompute it, but the miner of
+// the draw block could compute it first.
+func DrawWinner(round int, drawBlock *Block, tickets []LotteryTicket) LotteryDraw {
+	hash := sha256.New()
+	hash.Write(drawBlock.Hash)
+	total := 0
+	for _, ticket := range tickets {
+		hash.Write(ticket.TxID)
+		hash.Write([]byte(ticket.Commitment))
+		total += ticket.Tickets
+	}
+	seed := hash.Sum(nil)
+
+	draw := LotteryDraw{
+		Round:      round,
+		DrawHeight: drawBlock.Height,
+		DrawHash:   hex.EncodeToString(drawBlock.Hash),
+		Seed:       hex.EncodeToString(seed),
+		Tickets:    total,
+		Pot:        total,
+	}
+	if total == 0 {
+		return draw
+	}
+
+	winning := new(big.Int).Mod(new(big.Int).SetBytes(seed), big.NewInt(int64(total)))
+	draw.WinningTicket = int(winning.Int64())
+
+	n := draw.WinningTicket
+	for _, ticket := range tickets {
+		if n < ticket.Tickets {
+			draw.Winner = ticket.Player
+			break
+		}
+		n -= ticket.Tickets
+	}
+
+	return draw
+}
+
+// blockAt finds the block at height on the main chain.
+func (l Lottery) blockAt(height int) (*Block, error) {
+	iter := l.Blockchain.Iterator()
+	for {
+		block := iter.Next()
+		if block.Height == height {
+			return block, nil
+		}
+		if len(block.PrevHash) == 0 || block.Height < height {
+			return nil, fmt.Errorf("no block at height %d", height)
+		}
+	}
+}
+
+// NewLotteryPayoutTransaction pays the pot of a drawn round to its winner.
+func NewLotteryPayoutTransaction(lottery *wallet.Wallet, draw LotteryDraw, tickets []LotteryTicket, utxoSet *UTXOSet) *Transaction {
+	if addressPubKeyHash(draw.Winner) == nil {
+		log.Panicf("Error: lottery winner %q is not an address", draw.Winner)
+	}
+
+	var inputs []TxInput
+	for _, ticket := range tickets {
+		for _, out := range ticket.Outs {
+			inputs = append(inputs, TxInput{ticket.TxID, out, nil, lottery.PublicKey})
+		}
+	}
+
+	outputs := []TxOutput{
+		*NewTXOutput(draw.Pot, draw.Winner),
+		*newLotteryRecordOutput(LotteryRecord{Lottery: string(lottery.Address()), Draw: &draw}),
+	}
+
+	tx := Transaction{nil, inputs, outputs}
+	tx.ID = tx.Hash()
+	utxoSet.Blockchain.SignTransaction(&tx, lottery.PrivateKey)
+
+	return &tx
+}
+
+// DrawDue draws and pays out every finished round that hasn't been drawn.
+// Each payout is mined in its own block. A call made while the payouts of
+// an earlier call are being connected returns right away.
+func (l Lottery) DrawDue(lottery *wallet.Wallet) []LotteryDraw {
+	if !atomic.CompareAndSwapInt32(&lotteryDrawing, 0, 1) {
+		return nil
+	}
+	defer atomic.StoreInt32(&lotteryDrawing, 0)
+	l.Blockchain.LockSpends()
+	defer l.Blockchain.UnlockSpends()
+
+	best := l.Blockchain.GetBestHeight()
+
+	byRound := make(map[int][]LotteryTicket)
+	for _, ticket := range l.Tickets(-1) {
+		if ticket.Lottery != string(lottery.Address()) {
+			continue
+		}
+		byRound[ticket.Round] = append(byRound[ticket.Round], ticket)
+	}
+
+	var rounds []int
+	for round := range byRound {
+		rounds = append(rounds, round)
+	}
+	sort.Ints(rounds)
+
+	var draws []LotteryDraw
+	for _, round := range rounds {
+		if LotteryDrawHeight(round) > best || l.drawn(round) {
+			continue
+		}
+
+		func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					log.Printf("Lottery round %d not drawn, trying again on the next block: %v", round, rec)
+				}
+			}()
+
+			drawBlock, err := l.blockAt(LotteryDrawHeight(round))
+			Handle(err)
+
+			draw := DrawWinner(round, drawBlock, byRound[round])
+			UTXOSet := UTXOSet{Blockchain: l.Blockchain}
+			tx := NewLotteryPayoutTransaction(lottery, draw, byRound[round], &UTXOSet)
+
+			block := l.Blockchain.MineBlock([]*Transaction{tx})
+			UTXOSet.Update(block)
+
+			log.Printf("Lottery round %d drawn: %s wins %d coins", round, draw.Winner, draw.Pot)
+			draws = append(draws, draw)
+		}()
+	}
+
+	return draws
+}
+
+// WatchLottery draws due rounds whenever a block is connected, if this node
+// holds the lottery wallet.
+func WatchLottery(nodeID string) {
+	OnBlockConnected(func(chain *BlockChain, block *Block) {
+		wallets, err := wallet.CreateWallets(nodeID)
+		if err != nil || wallets.Lottery == "" {
+			return
+		}
+		lottery := wallets.GetWallet(wallets.Lottery)
+
+		Lottery{Blockchain: chain}.DrawDue(&lottery)
+	})
+}
@@ -0,0 +1,153 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"log"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// A multisig output is locked to several keys at once and spent with
+// signatures of some of them, like Bitcoin's m-of-n CHECKMULTISIG. Its
+// PubKeyHash is the marker, the number of signatures required and the
+// public key hashes of the keys that may sign. An input spending it leaves
+// PubKey empty and carries a MultiSigWitness in Signature instead, so the
+// signed digest is the same as for ordinary inputs and each key can sign
+// on its own.
+
+const multiSigMarker = 0x52
+
+type MultiSigWitness struct {
+	PubKeys    [][]byte
+	Signatures [][]byte
+}
+
+// NewMultiSigOutput locks value to required of the keys of addresses.
+func NewMultiSigOutput(value, required int, addresses []string) *TxOutput {
+	if required < 1 || required > len(addresses) || len(addresses) > 255 {
+		log.Panicf("Error: can't require %d of %d signatures", required, len(addresses))
+	}
+
+	lock := []byte{multiSigMarker, byte(required)}
+	for _, address := range addresses {
+		out := TxOutput{}
+		out.Lock([]byte(address))
+		lock = append(lock, out.PubKeyHash...)
+	}
+
+	return &TxOutput{Value: value, PubKeyHash: lock}
+}
+
+func (out *TxOutput) IsMultiSig() bool {
+	return out.Value > 0 && len(out.PubKeyHash) > 2 && out.PubKeyHash[0] == multiSigMarker &&
+		(len(out.PubKeyHash)-2)%20 == 0
+}
+
+// MultiSigKeys returns the number of signatures a multisig output requires
+// and the public key hashes allowed to sign it.
+func (out *TxOutput) MultiSigKeys() (int, [][]byte) {
+	var hashes [][]byte
+	for i := 2; i+20 <= len(out.PubKeyHash); i += 20 {
+		hashes = append(hashes, out.PubKeyHash[i:i+20])
+	}
+
+	return int(out.PubKeyHash[1]), hashes
+}
+
+func (w MultiSigWitness) serialize() []byte {
+	var buff bytes.Buffer
+	Handle(gob.NewEncoder(&buff).Encode(w))
+	return buff.Bytes()
+}
+
+func deserializeWitness(data []byte) (MultiSigWitness, error) {
+	var witness MultiSigWitness
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&witness)
+	return witness, err
+}
+
+// signMultiSig adds the signatures of keys allowed to sign input inIdx to its
+// witness, stopping once it has enough.
+func (tx *Transaction) signMultiSig(inIdx int, keys []wallet.PrivateKeyData, prevTXs map[string]Transaction) bool {
+	in := &tx.Inputs[inIdx]
+	out := prevTXs[hex.EncodeToString(in.ID)].Outputs[in.Out]
+	required, hashes := out.MultiSigKeys()
+
+	var witness MultiSigWitness
+	if len(in.Signature) > 0 {
+		var err error
+		witness, err = deserializeWitness(in.Signature)
+		Handle(err)
+	}
+
+	sigHash := tx.SigHash(inIdx, prevTXs)
+	for _, key := range keys {
+		if len(witness.Signatures) >= required {
+			break
+		}
+		pubKey := key.PublicKey()
+		keyHash := wallet.PublicKeyHash(pubKey)
+		if !containsHash(hashes, keyHash) || containsKey(witness.PubKeys, keyHash) {
+			continue
+		}
+		witness.PubKeys = append(witness.PubKeys, pubKey)
+		witness.Signatures = append(witness.Signatures, key.Sign(sigHash))
+	}
+
+	in.PubKey = nil
+	in.Signature = witness.serialize()
+
+	return len(witness.Signatures) >= required
+}
+
+// verifyMultiSig checks that input inIdx carries enough valid signatures of
+// distinct keys of the multisig output it spends. An output requiring no
+// signature can't be spent, rather than by anyone.
+func (tx *Transaction) verifyMultiSig(inIdx int, prevTXs map[string]Transaction) bool {
+	in := tx.Inputs[inIdx]
+	out := prevTXs[hex.EncodeToString(in.ID)].Outputs[in.Out]
+	required, hashes := out.MultiSigKeys()
+	if required < 1 {
+		return false
+	}
+
+	witness, err := deserializeWitness(in.Signature)
+	if err != nil || len(in.PubKey) != 0 || len(witness.PubKeys) != len(witness.Signatures) {
+		return false
+	}
+
+	sigHash := tx.SigHash(inIdx, prevTXs)
+	var signed [][]byte
+	for i, pubKey := range witness.PubKeys {
+		keyHash := wallet.PublicKeyHash(pubKey)
+		if !containsHash(hashes, keyHash) || containsHash(signed, keyHash) {
+			return false
+		}
+		if !wallet.VerifySignature(pubKey, sigHash, witness.Signatures[i]) {
+			return false
+		}
+		signed = append(signed, keyHash)
+	}
+
+	return len(signed) >= required
+}
+
+func containsHash(hashes [][]byte, hash []byte) bool {
+	for _, h := range hashes {
+		if bytes.Equal(h, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsKey(pubKeys [][]byte, hash []byte) bool {
+	for _, pubKey := range pubKeys {
+		if bytes.Equal(wallet.PublicKeyHash(pubKey), hash) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,41 +2,81 @@ package blockchain
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
 	"log"
 	"math"
 	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const Difficulty = 12
 
 type ProofOfWork struct {
-	Block  *Block
-	Target *big.Int
+	Block   *Block
+	Target  *big.Int
+	Workers int
 }
 
-func NewProof(b *Block) *ProofOfWork {
-	target := big.NewInt(1)
-	target.Lsh(target, uint(256-Difficulty))
+// MiningStats describes the work done by the last Run of a ProofOfWork.
+type MiningStats struct {
+	Hashes  uint64
+	Elapsed time.Duration
+}
 
-	pow := &ProofOfWork{b, target}
+func (s MiningStats) HashRate() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Hashes) / s.Elapsed.Seconds()
+}
+
+func (s MiningStats) String() string {
+	return fmt.Sprintf("%d hashes in %v (%.0f H/s)", s.Hashes, s.Elapsed.Round(time.Millisecond), s.HashRate())
+}
+
+func NewProof(b *Block) *ProofOfWork {
+	pow := &ProofOfWork{b, TargetForBits(Difficulty), runtime.NumCPU()}
 
 	return pow
 }
 
 func (pow *ProofOfWork) InitData(nonce int) []byte {
-	data := bytes.Join(
-		[][]byte{
-			pow.Block.PrevHash,
-			pow.Block.HashTransactions(),
-			ToHex(int64(nonce)),
-			ToHex(int64(Difficulty)),
-		},
-		[]byte{},
-	)
-	return data
+	return pow.initData(pow.Block.HashTransactions(), nonce)
+}
+
+// initData builds the header preimage from a precomputed transaction root.
+// Timestamp and extra nonce are only committed once the nonce space has been
+// exhausted at least once, which keeps blocks mined before they existed valid.
+func (pow *ProofOfWork) initData(txRoot []byte, nonce int) []byte {
+	return HeaderData(pow.Block.PrevHash, txRoot, nonce, pow.Block.Timestamp, pow.Block.ExtraNonce)
+}
+
+// HeaderData is the proof-of-work preimage of a block header. Miners that
+// only receive a header (e.g. over Stratum) hash this directly.
+func HeaderData(prevHash, txRoot []byte, nonce int, timestamp int64, extraNonce int) []byte {
+	parts := [][]byte{
+		prevHash,
+		txRoot,
+		ToHex(int64(nonce)),
+		ToHex(int64(Difficulty)),
+	}
+	if extraNonce != 0 {
+		parts = append(parts, ToHex(timestamp), ToHex(int64(extraNonce)))
+	}
+
+	return bytes.Join(parts, []byte{})
+}
+
+// TargetForBits is the hash target for a number of leading zero bits.
+func TargetForBits(bits int) *big.Int {
+	target := big.NewInt(1)
+	return target.Lsh(target, uint(256-bits))
 }
 
 func (pow *ProofOfWork) Validate() bool {
@@ -61,25 +101,72 @@ func ToHex(num int64) []byte {
 }
 
 func (pow *ProofOfWork) Run() (int, []byte) {
-	var intHash big.Int
-	var hash [32]byte
+	nonce, hash, _, err := pow.RunContext(context.Background())
+	Handle(err)
 
-	nonce := 0
+	return nonce, hash
+}
 
-	for nonce < math.MaxInt64 {
-		data := pow.InitData(nonce)
-		hash = sha256.Sum256(data)
+// RunContext searches for a nonce on pow.Workers goroutines, each striding
+// through its own slice of the nonce space. When the whole space is used up
+// the timestamp is refreshed and the extra nonce bumped before trying again.
+// It returns ctx.Err() if the context is cancelled first.
+func (pow *ProofOfWork) RunContext(ctx context.Context) (int, []byte, MiningStats, error) {
+	workers := pow.Workers
+	if workers < 1 {
+		workers = 1
+	}
 
-		fmt.Printf("\r%x", hash)
-		intHash.SetBytes(hash[:])
+	var hashes atomic.Uint64
+	start := time.Now()
+
+	for {
+		txRoot := pow.Block.HashTransactions()
+		roundCtx, cancel := context.WithCancel(ctx)
+
+		var once sync.Once
+		var wg sync.WaitGroup
+		foundNonce := -1
+		var foundHash []byte
+
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(first int) {
+				defer wg.Done()
+				var intHash big.Int
+
+				for i, nonce := 0, first; nonce >= 0 && nonce < math.MaxInt64; i, nonce = i+1, nonce+workers {
+					if i%1024 == 0 && roundCtx.Err() != nil {
+						return
+					}
+
+					hash := sha256.Sum256(pow.initData(txRoot, nonce))
+					hashes.Add(1)
+					intHash.SetBytes(hash[:])
+
+					if intHash.Cmp(pow.Target) == -1 {
+						once.Do(func() {
+							foundNonce = nonce
+							foundHash = hash[:]
+							cancel()
+						})
+						return
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		cancel()
 
-		if intHash.Cmp(pow.Target) == -1 {
-			break
-		} else {
-			nonce++
+		stats := MiningStats{hashes.Load(), time.Since(start)}
+		if foundNonce >= 0 {
+			return foundNonce, foundHash, stats, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, nil, stats, err
 		}
-	}
-	fmt.Println()
 
-	return nonce, hash[:]
+		pow.Block.ExtraNonce++
+		pow.Block.Timestamp = time.Now().Unix()
+	}
 }
@@ -0,0 +1,484 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// A PSBT, a partially signed transaction after Bitcoin's BIP174, carries an
+// unsigned transaction to its signers along with the previous outputs its
+// inputs spend and hints at the keys that may sign them, so a signer needs
+// neither the chain nor the network. Each signer adds its signatures to its
+// own copy, the copies are combined, and once every input has enough
+// signatures the PSBT is finalised into a transaction a node accepts.
+// The inputs of the unsigned transaction carry no public keys and it has
+// no ID yet, since the ID covers the public keys: they are filled in when
+// it is finalised, so a node that only knows the addresses can create it.
+
+var psbtMagic = []byte("psbt\xff")
+
+type PSBT struct {
+	Tx     Transaction
+	Inputs []PSBTInput
+	// Addresses are the addresses the outputs pay, where the creator knows
+	// them, for signers to see where the coins go
+	Addresses []string
+}
+
+// PSBTInput is what signing an input of the transaction needs, and the
+// signatures it has so far.
+type PSBTInput struct {
+	Prev PrevOutput
+	// Keys are the keys that may sign, the key of the address or those of
+	// the multisig lock of the previous output
+	Keys       []KeyHint
+	Signatures []PartialSig
+}
+
+// KeyHint names a key that may sign an input by its public key hash. The
+// address and the HD path it derives at are filled in when the creator of
+// the PSBT knows them.
+type KeyHint struct {
+	PubKeyHash []byte
+	Address    string
+	Path       string
+}
+
+type PartialSig struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+// NewPSBT wraps tx, with prevOuts the outputs its inputs spend. Whatever
+// signatures and public keys tx has are left out.
+func NewPSBT(tx *Transaction, prevOuts []PrevOutput) (*PSBT, error) {
+	p := PSBT{Tx: Transaction{Outputs: append([]TxOutput{}, tx.Outputs...)}}
+
+	for i, in := range tx.Inputs {
+		p.Tx.Inputs = append(p.Tx.Inputs, TxInput{in.ID, in.Out, nil, nil})
+
+		var prev *PrevOutput
+		for j := range prevOuts {
+			if bytes.Equal(prevOuts[j].TxID, in.ID) && prevOuts[j].Out == in.Out {
+				prev = &prevOuts[j]
+				break
+			}
+		}
+		if prev == nil {
+			return nil, fmt.Errorf("no previous output for input %d", i)
+		}
+
+		input := PSBTInput{Prev: *prev}
+		for _, hash := range prevKeyHashes(*prev) {
+			input.Keys = append(input.Keys, KeyHint{PubKeyHash: hash})
+		}
+		if len(input.Keys) == 0 {
+			return nil, fmt.Errorf("input %d spends an output no key can sign", i)
+		}
+		p.Inputs = append(p.Inputs, input)
+	}
+
+	return &p, nil
+}
+
+// NewFundedPSBT builds a PSBT paying amount to the address to. It spends
+// the outputs named by TxID and Out in outpoints, then unspent outputs of
+// the from addresses as far as needed, and pays the rest to change, or to
+// the first from address when change is empty.
+func NewFundedPSBT(outpoints []PrevOutput, from []string, to, change string, amount int, UTXO *UTXOSet) (*PSBT, error) {
+	var inputs []TxInput
+	var prevOuts []PrevOutput
+	spent := make(map[string]bool)
+	acc := 0
+
+	spend := func(txID []byte, out int) error {
+		outpoint := fmt.Sprintf("%x:%d", txID, out)
+		if spent[outpoint] {
+			return nil
+		}
+		output, ok := UTXO.Unspent(txID, out)
+		if !ok {
+			return fmt.Errorf("%s is not an unspent output", outpoint)
+		}
+		spent[outpoint] = true
+		inputs = append(inputs, TxInput{txID, out, nil, nil})
+		prevOuts = append(prevOuts, PrevOutput{txID, out, output.Value, output.PubKeyHash})
+		acc += output.Value
+		return nil
+	}
+
+	for _, prev := range outpoints {
+		if err := spend(prev.TxID, prev.Out); err != nil {
+			return nil, err
+		}
+	}
+	for _, address := range from {
+		if acc >= amount {
+			break
+		}
+		// Outputs already spent above count again here, so ask for the
+		// whole amount to be sure to end up with enough
+		_, validOutputs := UTXO.FindSpendableOutputs(NewTXOutput(0, address).PubKeyHash, amount)
+		for txid, outs := range validOutputs {
+			txID, err := hex.DecodeString(txid)
+			Handle(err)
+			for _, out := range outs {
+				if err := spend(txID, out); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if acc < amount {
+		return nil, fmt.Errorf("not enough funds: %d of %d", acc, amount)
+	}
+
+	outputs := []TxOutput{*NewTXOutput(amount, to)}
+	if acc > amount {
+		if change == "" && len(from) == 0 {
+			return nil, errors.New("a change address is needed")
+		}
+		if change == "" {
+			change = from[0]
+		}
+		outputs = append(outputs, *NewTXOutput(acc-amount, change))
+	}
+
+	p, err := NewPSBT(&Transaction{nil, inputs, outputs}, prevOuts)
+	if err != nil {
+		return nil, err
+	}
+	p.Addresses = []string{to}
+	if len(outputs) > 1 {
+		p.Addresses = append(p.Addresses, change)
+	}
+	hints := make(map[string]string)
+	for _, address := range from {
+		hints[address] = ""
+	}
+	p.AddKeyHints(hints)
+
+	return p, nil
+}
+
+// prevKeyHashes are the public key hashes of the keys that may sign for
+// an output: that of its address, or those of its multisig lock.
+func prevKeyHashes(prev PrevOutput) [][]byte {
+	out := TxOutput{prev.Value, prev.PubKeyHash}
+	if out.IsMultiSig() {
+		_, hashes := out.MultiSigKeys()
+		return hashes
+	}
+	if len(prev.PubKeyHash) == 20 {
+		return [][]byte{prev.PubKeyHash}
+	}
+	return nil
+}
+
+// AddKeyHints fills in the addresses, and HD paths where they are not
+// empty, of the keys in hints, a map of addresses to paths.
+func (p *PSBT) AddKeyHints(hints map[string]string) {
+	for address, path := range hints {
+		if !wallet.ValidateAddress(address) {
+			continue
+		}
+		hash := NewTXOutput(0, address).PubKeyHash
+		for i := range p.Inputs {
+			for j := range p.Inputs[i].Keys {
+				key := &p.Inputs[i].Keys[j]
+				if !bytes.Equal(key.PubKeyHash, hash) {
+					continue
+				}
+				key.Address = address
+				if path != "" {
+					key.Path = path
+				}
+			}
+		}
+	}
+}
+
+func (p *PSBT) prevTXs() map[string]Transaction {
+	var prevOuts []PrevOutput
+	for _, in := range p.Inputs {
+		prevOuts = append(prevOuts, in.Prev)
+	}
+	return PrevTransactions(prevOuts)
+}
+
+// Required is the number of signatures input i needs.
+func (p *PSBT) Required(i int) int {
+	out := TxOutput{p.Inputs[i].Prev.Value, p.Inputs[i].Prev.PubKeyHash}
+	if out.IsMultiSig() {
+		required, _ := out.MultiSigKeys()
+		return required
+	}
+	return 1
+}
+
+func (in *PSBTInput) hasKey(keyHash []byte) bool {
+	for _, key := range in.Keys {
+		if bytes.Equal(key.PubKeyHash, keyHash) {
+			return true
+		}
+	}
+	return false
+}
+
+func (in *PSBTInput) signedBy(keyHash []byte) bool {
+	for _, sig := range in.Signatures {
+		if bytes.Equal(wallet.PublicKeyHash(sig.PubKey), keyHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing lists the keys that may still sign inputs short of signatures,
+// each key once.
+func (p *PSBT) Missing() []KeyHint {
+	var missing []KeyHint
+	seen := make(map[string]bool)
+	for i, in := range p.Inputs {
+		if len(in.Signatures) >= p.Required(i) {
+			continue
+		}
+		for _, key := range in.Keys {
+			if in.signedBy(key.PubKeyHash) || seen[string(key.PubKeyHash)] {
+				continue
+			}
+			seen[string(key.PubKeyHash)] = true
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// Sign signs the inputs key may sign that are short of signatures, and
+// returns how many it signed.
+func (p *PSBT) Sign(key wallet.PrivateKeyData) int {
+	pubKey := key.PublicKey()
+	keyHash := wallet.PublicKeyHash(pubKey)
+	prevTXs := p.prevTXs()
+
+	signed := 0
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		if !in.hasKey(keyHash) || in.signedBy(keyHash) || len(in.Signatures) >= p.Required(i) {
+			continue
+		}
+		in.Signatures = append(in.Signatures, PartialSig{pubKey, key.Sign(p.Tx.SigHash(i, prevTXs))})
+		signed++
+	}
+
+	return signed
+}
+
+// verifySig checks that sig is a valid signature of input i by one of the
+// keys that may sign it.
+func (p *PSBT) verifySig(i int, sig PartialSig, prevTXs map[string]Transaction) error {
+	if !p.Inputs[i].hasKey(wallet.PublicKeyHash(sig.PubKey)) {
+		return fmt.Errorf("input %d is signed by a key that can't sign it", i)
+	}
+	if !wallet.VerifySignature(sig.PubKey, p.Tx.SigHash(i, prevTXs), sig.Signature) {
+		return fmt.Errorf("input %d has an invalid signature", i)
+	}
+	return nil
+}
+
+// Combine adds the signatures of another copy of the same PSBT, and
+// returns how many were new.
+func (p *PSBT) Combine(other *PSBT) (int, error) {
+	if !bytes.Equal(p.Tx.Hash(), other.Tx.Hash()) || len(p.Inputs) != len(other.Inputs) {
+		return 0, errors.New("the PSBTs are of different transactions")
+	}
+	for i, in := range other.Inputs {
+		prev := p.Inputs[i].Prev
+		if in.Prev.Value != prev.Value || !bytes.Equal(in.Prev.PubKeyHash, prev.PubKeyHash) {
+			return 0, fmt.Errorf("the PSBTs disagree on the output input %d spends", i)
+		}
+	}
+
+	prevTXs := p.prevTXs()
+	added := 0
+	for i, in := range other.Inputs {
+		for _, sig := range in.Signatures {
+			if p.Inputs[i].signedBy(wallet.PublicKeyHash(sig.PubKey)) {
+				continue
+			}
+			if err := p.verifySig(i, sig, prevTXs); err != nil {
+				return added, err
+			}
+			p.Inputs[i].Signatures = append(p.Inputs[i].Signatures, sig)
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+// Complete reports whether every input has enough signatures.
+func (p *PSBT) Complete() bool {
+	for i, in := range p.Inputs {
+		if len(in.Signatures) < p.Required(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fee is what the inputs are worth over the outputs. The chain takes no fees,
+// so it must be 0.
+func (p *PSBT) Fee() int {
+	fee := 0
+	for _, in := range p.Inputs {
+		fee += in.Prev.Value
+	}
+	for _, out := range p.Tx.Outputs {
+		fee -= out.Value
+	}
+	return fee
+}
+
+// Finalize puts the signatures in the transaction: the public key and
+// signature of an address, a witness for a multisig lock. The transaction
+// then gets its ID and is checked against the previous outputs.
+func (p *PSBT) Finalize() (*Transaction, error) {
+	tx := Transaction{Outputs: append([]TxOutput{}, p.Tx.Outputs...)}
+	for i, in := range p.Inputs {
+		required := p.Required(i)
+		if len(in.Signatures) < required {
+			return nil, fmt.Errorf("input %d has %d of the %d signatures it needs", i, len(in.Signatures), required)
+		}
+
+		input := TxInput{ID: p.Tx.Inputs[i].ID, Out: p.Tx.Inputs[i].Out}
+		if out := (TxOutput{in.Prev.Value, in.Prev.PubKeyHash}); out.IsMultiSig() {
+			var witness MultiSigWitness
+			for _, sig := range in.Signatures[:required] {
+				witness.PubKeys = append(witness.PubKeys, sig.PubKey)
+				witness.Signatures = append(witness.Signatures, sig.Signature)
+			}
+			input.Signature = witness.serialize()
+		} else {
+			input.PubKey = in.Signatures[0].PubKey
+			input.Signature = in.Signatures[0].Signature
+		}
+		tx.Inputs = append(tx.Inputs, input)
+	}
+	tx.ID = tx.UnsignedHash()
+
+	if fee := p.Fee(); fee != 0 {
+		return nil, fmt.Errorf("the inputs are worth %d more than the outputs, the chain takes no fees", fee)
+	}
+	prevTXs := p.prevTXs()
+	if !tx.ValueConserved(prevTXs) {
+		return nil, errors.New("the outputs aren't worth what the inputs are")
+	}
+	if !tx.Verify(prevTXs) {
+		return nil, errors.New("the signatures don't verify")
+	}
+
+	return &tx, nil
+}
+
+// check makes sure a PSBT read from outside is well formed and its
+// signatures are valid.
+func (p *PSBT) check() error {
+	if len(p.Tx.Inputs) == 0 || len(p.Tx.Outputs) == 0 || len(p.Inputs) != len(p.Tx.Inputs) {
+		return errors.New("the transaction needs inputs and outputs")
+	}
+	if p.Tx.ID != nil {
+		return errors.New("the transaction is already signed")
+	}
+
+	if fee := p.Fee(); fee != 0 {
+		return fmt.Errorf("the inputs are worth %d more than the outputs, the chain takes no fees", fee)
+	}
+	if len(p.Addresses) > len(p.Tx.Outputs) {
+		return errors.New("there are more addresses than outputs")
+	}
+	for i, address := range p.Addresses {
+		if address == "" {
+			continue
+		}
+		if !wallet.ValidateAddress(address) || !bytes.Equal(NewTXOutput(0, address).PubKeyHash, p.Tx.Outputs[i].PubKeyHash) {
+			return fmt.Errorf("output %d doesn't pay %s", i, address)
+		}
+	}
+
+	for i, in := range p.Tx.Inputs {
+		if in.Signature != nil || in.PubKey != nil {
+			return fmt.Errorf("input %d is already signed", i)
+		}
+		prev := p.Inputs[i].Prev
+		if !bytes.Equal(prev.TxID, in.ID) || prev.Out != in.Out {
+			return fmt.Errorf("input %d has the wrong previous output", i)
+		}
+		hashes := prevKeyHashes(prev)
+		if len(hashes) != len(p.Inputs[i].Keys) {
+			return fmt.Errorf("input %d has the wrong keys", i)
+		}
+		for j, hash := range hashes {
+			if !bytes.Equal(p.Inputs[i].Keys[j].PubKeyHash, hash) {
+				return fmt.Errorf("input %d has the wrong keys", i)
+			}
+		}
+	}
+
+	prevTXs := p.prevTXs()
+	for i, in := range p.Inputs {
+		var signed [][]byte
+		for _, sig := range in.Signatures {
+			keyHash := wallet.PublicKeyHash(sig.PubKey)
+			if containsHash(signed, keyHash) {
+				return fmt.Errorf("input %d is signed twice by a key", i)
+			}
+			if err := p.verifySig(i, sig, prevTXs); err != nil {
+				return err
+			}
+			signed = append(signed, keyHash)
+		}
+	}
+
+	return nil
+}
+
+func (p *PSBT) Serialize() []byte {
+	var buff bytes.Buffer
+	buff.Write(psbtMagic)
+	Handle(gob.NewEncoder(&buff).Encode(p))
+	return buff.Bytes()
+}
+
+// Encode is the PSBT as base64 text, to copy to a machine that signs it.
+func (p *PSBT) Encode() string {
+	return base64.StdEncoding.EncodeToString(p.Serialize())
+}
+
+// ParsePSBT reads a PSBT as Encode writes it and checks it.
+func ParsePSBT(text string) (*PSBT, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("malformed PSBT: %w", err)
+	}
+	if !bytes.HasPrefix(data, psbtMagic) {
+		return nil, errors.New("not a PSBT")
+	}
+
+	var p PSBT
+	if err := gob.NewDecoder(bytes.NewReader(data[len(psbtMagic):])).Decode(&p); err != nil {
+		return nil, fmt.Errorf("malformed PSBT: %w", err)
+	}
+	if err := p.check(); err != nil {
+		return nil, fmt.Errorf("invalid PSBT: %w", err)
+	}
+
+	return &p, nil
+}
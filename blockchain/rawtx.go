@@ -0,0 +1,221 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// Raw transactions let a client keep its keys: the node builds an unsigned
+// transaction with the previous outputs it spends, the client signs the
+// digest of each input, SigHash, and hands back the signed transaction.
+// An ID is the hash of the transaction before it is signed, so it covers
+// the public keys of the inputs but not their signatures.
+
+// PrevOutput is an output spent by an input, all a signer needs of the
+// previous transaction to compute the digest of the input.
+type PrevOutput struct {
+	TxID       []byte
+	Out        int
+	Value      int
+	PubKeyHash []byte
+}
+
+// UnsignedHash is the hash of the transaction with the signatures left
+// out, what its ID must be.
+func (tx *Transaction) UnsignedHash() []byte {
+	txCopy := *tx
+	txCopy.Inputs = make([]TxInput, len(tx.Inputs))
+	for i, in := range tx.Inputs {
+		txCopy.Inputs[i] = TxInput{in.ID, in.Out, nil, in.PubKey}
+	}
+
+	return txCopy.Hash()
+}
+
+// NewUnsignedTransaction builds a transaction sending amount from the key
+// pubKey to an address, like NewTransaction but without signing it.
+func NewUnsignedTransaction(pubKey []byte, to string, amount int, UTXO *UTXOSet) (*Transaction, []PrevOutput, error) {
+	if kt, key := wallet.DecodePublicKey(pubKey); len(key) == len(pubKey) {
+		return nil, nil, fmt.Errorf("not an encoded %s public key", kt)
+	}
+	pubKeyHash := wallet.PublicKeyHash(pubKey)
+	acc, validOutputs := UTXO.FindSpendableOutputs(pubKeyHash, amount)
+	if acc < amount {
+		return nil, nil, errors.New("not enough funds")
+	}
+
+	var inputs []TxInput
+	var prevOuts []PrevOutput
+	for txid, outs := range validOutputs {
+		txID, err := hex.DecodeString(txid)
+		Handle(err)
+
+		for _, out := range outs {
+			output, _ := UTXO.Unspent(txID, out)
+			inputs = append(inputs, TxInput{txID, out, nil, pubKey})
+			prevOuts = append(prevOuts, PrevOutput{txID, out, output.Value, output.PubKeyHash})
+		}
+	}
+
+	outputs := []TxOutput{*NewTXOutput(amount, to)}
+	if acc > amount {
+		from := wallet.Wallet{PublicKey: pubKey}
+		outputs = append(outputs, *NewTXOutput(acc-amount, string(from.Address())))
+	}
+
+	tx := Transaction{nil, inputs, outputs}
+	tx.ID = tx.Hash()
+
+	return &tx, prevOuts, nil
+}
+
+// ParseTransaction reads a serialized transaction from a client, an error
+// rather than a panic when it is malformed.
+func ParseTransaction(data []byte) (Transaction, error) {
+	var tx Transaction
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&tx); err != nil {
+		return Transaction{}, fmt.Errorf("malformed transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// PrevTransactions stands in for the previous transactions of prevOuts, for
+// SigHash and Verify, with only the outputs spent filled in.
+func PrevTransactions(prevOuts []PrevOutput) map[string]Transaction {
+	prevTXs := make(map[string]Transaction)
+	for _, prev := range prevOuts {
+		id := hex.EncodeToString(prev.TxID)
+		prevTx := prevTXs[id]
+		prevTx.ID = prev.TxID
+		for len(prevTx.Outputs) <= prev.Out {
+			prevTx.Outputs = append(prevTx.Outputs, TxOutput{})
+		}
+		prevTx.Outputs[prev.Out] = TxOutput{prev.Value, prev.PubKeyHash}
+		prevTXs[id] = prevTx
+	}
+
+	return prevTXs
+}
+
+// SigHashes is the digest each input of tx signs.
+func (tx *Transaction) SigHashes(prevOuts []PrevOutput) ([][]byte, error) {
+	prevTXs := PrevTransactions(prevOuts)
+
+	var hashes [][]byte
+	for i, in := range tx.Inputs {
+		prevTx, ok := prevTXs[hex.EncodeToString(in.ID)]
+		if !ok || in.Out < 0 || in.Out >= len(prevTx.Outputs) || prevTx.Outputs[in.Out].PubKeyHash == nil {
+			return nil, fmt.Errorf("no previous output for input %d", i)
+		}
+		hashes = append(hashes, tx.SigHash(i, prevTXs))
+	}
+
+	return hashes, nil
+}
+
+// SignPrevOutputs signs the inputs of tx that spend outputs of key, the
+// other inputs are left for other signers. It returns the number of
+// inputs signed.
+func (tx *Transaction) SignPrevOutputs(key wallet.PrivateKeyData, prevOuts []PrevOutput) (int, error) {
+	hashes, err := tx.SigHashes(prevOuts)
+	if err != nil {
+		return 0, err
+	}
+
+	signed := 0
+	for i, in := range tx.Inputs {
+		if !key.Owns(in.PubKey) {
+			continue
+		}
+		tx.Inputs[i].Signature = key.Sign(hashes[i])
+		signed++
+	}
+
+	return signed, nil
+}
+
+// Unspent looks up an output in the UTXO set.
+func (u UTXOSet) Unspent(txID []byte, out int) (TxOutput, bool) {
+	var output TxOutput
+	found := false
+
+	err := u.Blockchain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(append(append([]byte{}, utxoPrefix...), txID...))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		v, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		outs := DeserializeOutputs(v)
+		if out >= 0 && out < len(outs.Outputs) && outs.Outputs[out].Value > 0 {
+			output, found = outs.Outputs[out], true
+		}
+		return nil
+	})
+	Handle(err)
+
+	return output, found
+}
+
+// ValidateRawTransaction checks a signed transaction handed in by a client
+// before it is mined: it must have its ID, spend unspent outputs once each,
+// pay only to addresses or multisig locks, pay out exactly what it spends,
+// and carry a valid signature for every input.
+func (chain *BlockChain) ValidateRawTransaction(tx *Transaction) error {
+	if tx.IsCoinbase() {
+		return errors.New("coinbase transactions can't be submitted")
+	}
+	if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+		return errors.New("the transaction needs inputs and outputs")
+	}
+	if !bytes.Equal(tx.ID, tx.UnsignedHash()) {
+		return fmt.Errorf("transaction %x has a wrong id", tx.ID)
+	}
+
+	UTXOSet := UTXOSet{Blockchain: chain}
+	spent := make(map[string]bool)
+	for i, in := range tx.Inputs {
+		outpoint := fmt.Sprintf("%x:%d", in.ID, in.Out)
+		if spent[outpoint] {
+			return fmt.Errorf("output %s is spent twice", outpoint)
+		}
+		spent[outpoint] = true
+		if _, ok := UTXOSet.Unspent(in.ID, in.Out); !ok {
+			return fmt.Errorf("input %d spends %s, which is not an unspent output", i, outpoint)
+		}
+	}
+
+	for i, out := range tx.Outputs {
+		if out.Value <= 0 {
+			return fmt.Errorf("output %d has no value", i)
+		}
+		if len(out.PubKeyHash) != 20 && !out.IsMultiSig() {
+			return fmt.Errorf("output %d is locked to neither an address nor multisig keys", i)
+		}
+		// Requiring no signature lets anyone spend it, too many locks it forever
+		if out.IsMultiSig() {
+			if required, hashes := out.MultiSigKeys(); required < 1 || required > len(hashes) {
+				return fmt.Errorf("output %d requires %d of %d signatures", i, required, len(hashes))
+			}
+		}
+	}
+
+	if err := chain.verifyBlockTransactions([]*Transaction{tx}); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,385 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sync"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Stats aggregates the bets on the chain per player and game, and the
+// house's results. Game records and the bets of crash rounds the house
+// signed count for the player and the house, settled wagers between players
+// for both players only. The totals are kept in the chain database with the hash of the last
+// block counted: a connected block extending it is added, anything else,
+// like a reorg or a block received out of order, recounts the chain.
+// Queries catch up with the tip first.
+
+var (
+	statsPrefix       = []byte("stats-")
+	statsPlayerPrefix = []byte("stats-player-")
+	statsHouseKey     = []byte("stats-house")
+	statsTipKey       = []byte("stats-tip")
+	statsMu           sync.Mutex
+)
+
+// statsBatchBlocks is how many blocks Sync counts per database transaction.
+const statsBatchBlocks = 500
+
+// BetStats totals a run of bets from the player's side.
+type BetStats struct {
+	Bets   int `json:"bets"`
+	Wins   int `json:"wins"`
+	Losses int `json:"losses"`
+	Pushes int `json:"pushes"`
+	// Returned counts everything paid back, stakes and jackpots included
+	Wagered  int `json:"wagered"`
+	Returned int `json:"returned"`
+	// Net is the profit of the player, negative when it lost
+	Net         int `json:"net"`
+	BiggestWin  int `json:"biggestWin"`
+	BiggestLoss int `json:"biggestLoss"`
+	// Streak is the current run, positive for wins and negative for losses.
+	// Pushes don't break it.
+	Streak            int `json:"streak"`
+	LongestWinStreak  int `json:"longestWinStreak"`
+	LongestLossStreak int `json:"longestLossStreak"`
+}
+
+func (s *BetStats) add(bet, returned int) {
+	net := returned - bet
+
+	s.Bets++
+	s.Wagered += bet
+	s.Returned += returned
+	s.Net += net
+
+	switch {
+	case net > 0:
+		s.Wins++
+		if s.Streak < 0 {
+			s.Streak = 0
+		}
+		s.Streak++
+		if s.Streak > s.LongestWinStreak {
+			s.LongestWinStreak = s.Streak
+		}
+		if net > s.BiggestWin {
+			s.BiggestWin = net
+		}
+	case net < 0:
+		s.Losses++
+		if s.Streak > 0 {
+			s.Streak = 0
+		}
+		s.Streak--
+		if -s.Streak > s.LongestLossStreak {
+			s.LongestLossStreak = -s.Streak
+		}
+		if -net > s.BiggestLoss {
+			s.BiggestLoss = -net
+		}
+	default:
+		s.Pushes++
+	}
+}
+
+type PlayerStats struct {
+	Address  string              `json:"address"`
+	Total    BetStats            `json:"total"`
+	Games    map[string]BetStats `json:"games"`
+	FirstBet int64               `json:"firstBet,omitempty"`
+	LastBet  int64               `json:"lastBet,omitempty"`
+}
+
+func (p *PlayerStats) add(game string, bet, returned int, timestamp int64) {
+	p.Total.add(bet, returned)
+
+	stats := p.Games[game]
+	stats.add(bet, returned)
+	p.Games[game] = stats
+
+	if p.FirstBet == 0 {
+		p.FirstBet = timestamp
+	}
+	p.LastBet = timestamp
+}
+
+// HouseGameStats totals the bets of house games from the house's side.
+type HouseGameStats struct {
+	Bets    int `json:"bets"`
+	Wagered int `json:"wagered"`
+	PaidOut int `json:"paidOut"`
+	// Profit is what the house kept after funding the jackpot
+	Profit        int `json:"profit"`
+	BiggestPayout int `json:"biggestPayout"`
+	// HouseEdge is realised, ExpectedEdge what the odds of the bets promised
+	HouseEdge      float64 `json:"houseEdge"`
+	ExpectedEdge   float64 `json:"expectedEdge"`
+	ExpectedReturn float64 `json:"expectedReturn"`
+}
+
+func (h *HouseGameStats) add(record GameRecord) {
+	h.addBet(record.Bet, record.Payout, record.JackpotContribution, float64(record.Bet)*record.RTP())
+}
+
+func (h *HouseGameStats) addBet(bet, payout, jackpotContribution int, expectedReturn float64) {
+	h.Bets++
+	h.Wagered += bet
+	h.PaidOut += payout
+	h.Profit += bet - payout - jackpotContribution
+	h.ExpectedReturn += expectedReturn
+	if payout > h.BiggestPayout {
+		h.BiggestPayout = payout
+	}
+
+	if h.Wagered > 0 {
+		wagered := float64(h.Wagered)
+		h.HouseEdge = (wagered - float64(h.PaidOut)) / wagered
+		h.ExpectedEdge = (wagered - h.ExpectedReturn) / wagered
+	}
+}
+
+type HouseStats struct {
+	Total       HouseGameStats            `json:"total"`
+	Games       map[string]HouseGameStats `json:"games"`
+	Players     int                       `json:"players"`
+	JackpotHits int                       `json:"jackpotHits"`
+	JackpotPaid int                       `json:"jackpotPaid"`
+	// Wagers between players, which the house takes no part in
+	PeerWagers      int `json:"peerWagers"`
+	PeerWagerVolume int `json:"peerWagerVolume"`
+	// Height of the last block counted
+	Height int `json:"height"`
+}
+
+type statsTip struct {
+	Hash   []byte
+	Height int
+}
+
+// Stats answers queries on the bet statistics of the chain.
+type Stats struct {
+	Blockchain *BlockChain
+}
+
+func statsPlayerKey(address string) []byte {
+	return append(append([]byte{}, statsPlayerPrefix...), address...)
+}
+
+func getGob(txn *badger.Txn, key []byte, v interface{}) (bool, error) {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	data, err := item.ValueCopy(nil)
+	if err != nil {
+		return false, err
+	}
+	return true, gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// statsBatch holds the totals a set of blocks changes until they are
+// written.
+type statsBatch struct {
+	txn     *badger.Txn
+	players map[string]*PlayerStats
+	house   *HouseStats
+}
+
+func (b *statsBatch) player(address string) *PlayerStats {
+	if player, ok := b.players[address]; ok {
+		return player
+	}
+
+	player := &PlayerStats{Address: address}
+	found, err := getGob(b.txn, statsPlayerKey(address), player)
+	Handle(err)
+	if !found {
+		b.house.Players++
+	}
+	if player.Games == nil {
+		player.Games = make(map[string]BetStats)
+	}
+	b.players[address] = player
+	return player
+}
+
+// signedBy reports whether house signed the records of tx, like the game
+// index requires. The index is updated before the statistics count a block.
+func (b *statsBatch) signedBy(tx *Transaction, house string) bool {
+	signed, err := signedBy(b.txn, tx, house)
+	Handle(err)
+	return signed
+}
+
+func (b *statsBatch) add(block *Block) {
+	for _, tx := range block.Transactions {
+		record, err := tx.GameRecord()
+		if err == nil && record != nil && b.signedBy(tx, record.House) {
+			b.player(record.Player).add(record.Game, record.Bet, record.Payout+record.JackpotPayout, block.Timestamp)
+
+			b.house.Total.add(*record)
+			game := b.house.Games[record.Game]
+			game.add(*record)
+			b.house.Games[record.Game] = game
+			if record.JackpotPayout > 0 {
+				b.house.JackpotHits++
+				b.house.JackpotPaid += record.JackpotPayout
+			}
+		}
+
+		// Void crash rounds refunded every bet and change nothing
+		crash, err := tx.CrashRecord()
+		if err == nil && crash != nil && !crash.Void && b.signedBy(tx, crash.House) {
+			for _, bet := range crash.Bets {
+				b.player(bet.Player).add("crash", bet.Amount, bet.Payout, block.Timestamp)
+
+				b.house.Total.addBet(bet.Amount, bet.Payout, 0, bet.ExpectedReturn())
+				game := b.house.Games["crash"]
+				game.addBet(bet.Amount, bet.Payout, 0, bet.ExpectedReturn())
+				b.house.Games["crash"] = game
+			}
+		}
+
+		// Wagers count once settled, refunds change nothing
+		wager, err := tx.WagerRecord()
+		if err == nil && wager != nil && wager.Winner != "" && !wager.Refunded {
+			for _, player := range []string{wager.PlayerA, wager.PlayerB} {
+				returned := 0
+				if player == wager.Winner {
+					returned = 2 * wager.Stake
+				}
+				b.player(player).add("wager", wager.Stake, returned, block.Timestamp)
+			}
+			b.house.PeerWagers++
+			b.house.PeerWagerVolume += 2 * wager.Stake
+		}
+	}
+	b.house.Height = block.Height
+}
+
+func (b *statsBatch) write(tip *Block) error {
+	for address, player := range b.players {
+		if err := b.txn.Set(statsPlayerKey(address), gobEncode(player)); err != nil {
+			return err
+		}
+	}
+	if err := b.txn.Set(statsHouseKey, gobEncode(b.house)); err != nil {
+		return err
+	}
+	return b.txn.Set(statsTipKey, gobEncode(statsTip{Hash: tip.Hash, Height: tip.Height}))
+}
+
+// Sync counts the blocks up to the tip of the chain that the statistics
+// don't include yet, recounting the chain when they were counted on
+// another branch.
+func (s Stats) Sync() {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	// The tip is read from the database, LastHash changes under miners
+	var tip statsTip
+	var lastHash []byte
+	err := s.Blockchain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("lh"))
+		if err != nil {
+			return err
+		}
+		if lastHash, err = item.ValueCopy(nil); err != nil {
+			return err
+		}
+		_, err = getGob(txn, statsTipKey, &tip)
+		return err
+	})
+	Handle(err)
+	if bytes.Equal(tip.Hash, lastHash) {
+		return
+	}
+
+	// Walk back to the last block counted, or to genesis when it isn't on
+	// this branch
+	var blocks []*Block
+	extends := false
+	iter := &BlockChainIterator{lastHash, s.Blockchain.Database}
+	for {
+		block := iter.Next()
+		if tip.Hash != nil && bytes.Equal(block.Hash, tip.Hash) {
+			extends = true
+			break
+		}
+		blocks = append(blocks, block)
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	if !extends {
+		u := UTXOSet{Blockchain: s.Blockchain}
+		u.DeleteByPrefix(statsPrefix)
+	}
+
+	// Recounting a long chain is too much for one transaction, so blocks
+	// are counted in batches, oldest first. Each batch records the block it
+	// ends at and an interrupted recount goes on from there.
+	for end := len(blocks); end > 0; end -= statsBatchBlocks {
+		start := end - statsBatchBlocks
+		if start < 0 {
+			start = 0
+		}
+
+		err = s.Blockchain.Database.Update(func(txn *badger.Txn) error {
+			batch := &statsBatch{txn: txn, players: make(map[string]*PlayerStats), house: &HouseStats{}}
+			if _, err := getGob(txn, statsHouseKey, batch.house); err != nil {
+				return err
+			}
+			if batch.house.Games == nil {
+				batch.house.Games = make(map[string]HouseGameStats)
+			}
+
+			for i := end - 1; i >= start; i-- {
+				batch.add(blocks[i])
+			}
+			return batch.write(blocks[start])
+		})
+		Handle(err)
+	}
+}
+
+// Player returns the statistics of address, empty when it never bet.
+func (s Stats) Player(address string) PlayerStats {
+	s.Sync()
+
+	player := PlayerStats{Address: address, Games: make(map[string]BetStats)}
+	err := s.Blockchain.Database.View(func(txn *badger.Txn) error {
+		_, err := getGob(txn, statsPlayerKey(address), &player)
+		return err
+	})
+	Handle(err)
+
+	return player
+}
+
+func (s Stats) House() HouseStats {
+	s.Sync()
+
+	house := HouseStats{Games: make(map[string]HouseGameStats)}
+	err := s.Blockchain.Database.View(func(txn *badger.Txn) error {
+		_, err := getGob(txn, statsHouseKey, &house)
+		return err
+	})
+	Handle(err)
+
+	return house
+}
+
+// WatchStats counts every connected block into the statistics.
+func WatchStats() {
+	OnBlockConnected(func(chain *BlockChain, block *Block) {
+		Stats{Blockchain: chain}.Sync()
+	})
+}
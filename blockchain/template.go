@@ -0,0 +1,187 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+// BlockTemplate is everything an external miner needs to build and solve
+// the next block: the header fields to commit to, the coinbase it may
+// create and the transactions it must include after its coinbase.
+type BlockTemplate struct {
+	PrevHash      []byte
+	Height        int
+	Timestamp     int64
+	Difficulty    int
+	Target        *big.Int
+	CoinbaseValue int
+	Transactions  []*Transaction
+}
+
+// NewBlockTemplate builds a template on top of the current tip. Transactions
+// that no longer verify, or spend an output an earlier one already spends,
+// are left out.
+func (chain *BlockChain) NewBlockTemplate(txs []*Transaction) *BlockTemplate {
+	lastHash, lastHeight := chain.tip()
+
+	var valid []*Transaction
+	for _, tx := range txs {
+		if tx.IsCoinbase() {
+			continue
+		}
+		// Signatures already checked come from the signature cache
+		if chain.VerifyTransactions(append(append([]*Transaction{}, valid...), tx)) {
+			valid = append(valid, tx)
+		}
+	}
+
+	return &BlockTemplate{
+		PrevHash:      lastHash,
+		Height:        lastHeight + 1,
+		Timestamp:     time.Now().Unix(),
+		Difficulty:    Difficulty,
+		Target:        NewProof(&Block{}).Target,
+		CoinbaseValue: BlockReward,
+		Transactions:  valid,
+	}
+}
+
+// NewBlock assembles an unsolved block from the template and the miner's own
+// coinbase. The caller still has to find the nonce.
+func (t *BlockTemplate) NewBlock(coinbase *Transaction) *Block {
+	txs := append([]*Transaction{coinbase}, t.Transactions...)
+
+	return &Block{
+		Timestamp:    t.Timestamp,
+		Hash:         []byte{},
+		Transactions: txs,
+		PrevHash:     t.PrevHash,
+		Height:       t.Height,
+	}
+}
+
+func (chain *BlockChain) tip() ([]byte, int) {
+	var lastHash []byte
+	var lastHeight int
+
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("lh"))
+		Handle(err)
+		lastHash, err = item.ValueCopy(nil)
+		Handle(err)
+
+		item, err = txn.Get(lastHash)
+		Handle(err)
+		lastBlockData, err := item.ValueCopy(nil)
+		Handle(err)
+
+		lastHeight = Deserialize(lastBlockData).Height
+
+		return nil
+	})
+	Handle(err)
+
+	return lastHash, lastHeight
+}
+
+// ValidateBlock checks a solved block that is meant to extend the current
+// tip: header linkage, proof of work, coinbase value and every signature.
+func (chain *BlockChain) ValidateBlock(block *Block) error {
+	lastHash, lastHeight := chain.tip()
+
+	if !bytes.Equal(block.PrevHash, lastHash) {
+		return errors.New("block does not extend the current tip")
+	}
+	if block.Height != lastHeight+1 {
+		return fmt.Errorf("block height %d, expected %d", block.Height, lastHeight+1)
+	}
+	if len(block.Transactions) == 0 || !block.Transactions[0].IsCoinbase() {
+		return errors.New("block must start with a coinbase transaction")
+	}
+
+	pow := NewProof(block)
+	hash := sha256.Sum256(pow.InitData(block.Nonce))
+	if !bytes.Equal(hash[:], block.Hash) || !pow.Validate() {
+		return errors.New("invalid proof of work")
+	}
+
+	spent := make(map[string]bool)
+	for i, tx := range block.Transactions {
+		if !bytes.Equal(tx.ID, tx.UnsignedHash()) {
+			return fmt.Errorf("transaction %x has a wrong id", tx.ID)
+		}
+		if _, err := tx.GameRecord(); err != nil {
+			return err
+		}
+		if i > 0 && tx.IsCoinbase() {
+			return errors.New("block has more than one coinbase transaction")
+		}
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, in := range tx.Inputs {
+			outpoint := fmt.Sprintf("%s:%d", hex.EncodeToString(in.ID), in.Out)
+			if spent[outpoint] {
+				return fmt.Errorf("output %s is spent twice", outpoint)
+			}
+			spent[outpoint] = true
+		}
+	}
+
+	coinbaseValue := 0
+	for i, out := range block.Transactions[0].Outputs {
+		if out.Value < 0 || out.Value == 0 && !out.IsData() {
+			return fmt.Errorf("coinbase output %d is worth %d coins", i, out.Value)
+		}
+		coinbaseValue += out.Value
+	}
+	if coinbaseValue > BlockReward {
+		return fmt.Errorf("coinbase creates %d coins, reward is %d", coinbaseValue, BlockReward)
+	}
+
+	if err := chain.verifyBlockTransactions(block.Transactions[1:]); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (chain *BlockChain) verifyBlockTransactions(txs []*Transaction) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid transaction: %v", r)
+		}
+	}()
+
+	if !chain.VerifyTransactions(txs) {
+		return errors.New("invalid transaction signature or value")
+	}
+
+	return nil
+}
+
+// SubmitBlock validates a block solved outside this process and connects it
+// to the chain. Both happen under the spend lock, so no other block or spend
+// moves the tip or spends its inputs in between.
+func (chain *BlockChain) SubmitBlock(block *Block) error {
+	chain.LockSpends()
+	defer chain.UnlockSpends()
+
+	if err := chain.ValidateBlock(block); err != nil {
+		return err
+	}
+	if !chain.AddBlock(block) {
+		return errors.New("block did not become the tip")
+	}
+	UTXOSet := UTXOSet{Blockchain: chain}
+	UTXOSet.Update(block)
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// Tournament entries and payouts are ordinary transactions of the node's
+// tournament wallet with a TournamentRecord data output. An entry pays the
+// fee to the wallet as output 0, the payout spends the entries of one
+// tournament and splits them among the places, so the prize pool of every
+// tournament can be checked on chain. The chips played with in between
+// never touch the chain.
+
+var tournamentMarker = []byte("\x6atournament:")
+
+type TournamentPlace struct {
+	Place  int    `json:"place"`
+	Player string `json:"player"`
+	Chips  int    `json:"chips"`
+	Prize  int    `json:"prize"`
+}
+
+type TournamentRecord struct {
+	ID         string `json:"id"`
+	Tournament string `json:"tournament"`
+	// Player of an entry
+	Player string `json:"player,omitempty"`
+	// Results of the payout
+	Results []TournamentPlace `json:"results,omitempty"`
+}
+
+func newTournamentRecordOutput(record TournamentRecord) *TxOutput {
+	payload, err := json.Marshal(record)
+	Handle(err)
+
+	return &TxOutput{Value: 0, PubKeyHash: append(append([]byte{}, tournamentMarker...), payload...)}
+}
+
+// TournamentRecord returns the tournament record of a transaction, if any.
+func (tx *Transaction) TournamentRecord() (*TournamentRecord, error) {
+	for _, out := range tx.Outputs {
+		if !out.IsData() || !bytes.HasPrefix(out.PubKeyHash, tournamentMarker) {
+			continue
+		}
+		var record TournamentRecord
+		if err := json.Unmarshal(out.PubKeyHash[len(tournamentMarker):], &record); err != nil {
+			return nil, fmt.Errorf("malformed tournament record in %x: %w", tx.ID, err)
+		}
+		return &record, nil
+	}
+
+	return nil, nil
+}
+
+// NewTournamentEntryTransaction pays the entry fee of w to the tournament
+// wallet.
+func NewTournamentEntryTransaction(w *wallet.Wallet, record TournamentRecord, fee int, utxoSet *UTXOSet) *Transaction {
+	var inputs []TxInput
+
+	acc, validOutputs := utxoSet.FindSpendableOutputs(wallet.PublicKeyHash(w.PublicKey), fee)
+	if acc < fee {
+		log.Panic("Error: not enough funds for the entry fee")
+	}
+
+	for txid, outs := range validOutputs {
+		txID, err := hex.DecodeString(txid)
+		Handle(err)
+
+		for _, out := range outs {
+			inputs = append(inputs, TxInput{txID, out, nil, w.PublicKey})
+		}
+	}
+
+	outputs := []TxOutput{*NewTXOutput(fee, record.Tournament)}
+	if acc > fee {
+		outputs = append(outputs, *NewTXOutput(acc-fee, record.Player))
+	}
+	outputs = append(outputs, *newTournamentRecordOutput(record))
+
+	tx := Transaction{nil, inputs, outputs}
+	tx.ID = tx.Hash()
+	utxoSet.Blockchain.SignTransaction(&tx, w.PrivateKey)
+
+	return &tx
+}
+
+// NewTournamentPayoutTransaction spends the entries of a tournament, output
+// 0 of each of entryTxs, and pays the prizes of record's results.
+func NewTournamentPayoutTransaction(tournament *wallet.Wallet, record TournamentRecord, entryTxs [][]byte, utxoSet *UTXOSet) *Transaction {
+	var inputs []TxInput
+	for _, txID := range entryTxs {
+		inputs = append(inputs, TxInput{txID, 0, nil, tournament.PublicKey})
+	}
+
+	var outputs []TxOutput
+	for _, place := range record.Results {
+		if place.Prize > 0 {
+			outputs = append(outputs, *NewTXOutput(place.Prize, place.Player))
+		}
+	}
+	outputs = append(outputs, *newTournamentRecordOutput(record))
+
+	tx := Transaction{nil, inputs, outputs}
+	tx.ID = tx.Hash()
+	utxoSet.Blockchain.SignTransaction(&tx, tournament.PrivateKey)
+
+	return &tx
+}
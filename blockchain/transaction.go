@@ -2,20 +2,20 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
 	"fmt"
 	"log"
-	"math/big"
 	"strings"
 
 	"github.com/ItsHotdogFred/blockchain/wallet"
 )
 
+// BlockReward is the value a coinbase transaction may create.
+const BlockReward = 100
+
 type Transaction struct {
 	ID      []byte
 	Inputs  []TxInput
@@ -55,6 +55,12 @@ func DeserializeTransaction(data []byte) Transaction {
 }
 
 func CoinbaseTx(to, data string) *Transaction {
+	return CoinbaseTxWithOutputs([]TxOutput{*NewTXOutput(BlockReward, to)}, data)
+}
+
+// CoinbaseTxWithOutputs creates a coinbase splitting the reward over several
+// outputs, e.g. a mining pool paying its miners directly.
+func CoinbaseTxWithOutputs(outputs []TxOutput, data string) *Transaction {
 	if data == "" {
 		randData := make([]byte, 24)
 		_, err := rand.Read(randData)
@@ -64,15 +70,12 @@ func CoinbaseTx(to, data string) *Transaction {
 		data = fmt.Sprintf("%x", randData)
 	}
 
-
 	txin := TxInput{[]byte{}, -1, nil, []byte(data)}
-	txout := NewTXOutput(100, to)
 
-	tx := Transaction{nil, []TxInput{txin}, []TxOutput{*txout}}
+	tx := Transaction{nil, []TxInput{txin}, outputs}
 	tx.ID = tx.Hash()
 
 	return &tx
-
 }
 
 func NewTransaction(w *wallet.Wallet, to string, amount int, UTXO *UTXOSet) *Transaction {
@@ -106,7 +109,7 @@ func NewTransaction(w *wallet.Wallet, to string, amount int, UTXO *UTXOSet) *Tra
 
 	tx := Transaction{nil, inputs, outputs}
 	tx.ID = tx.Hash()
-	UTXO.Blockchain.SignTransaction(&tx, *w.PrivateKey.ToECDSA())
+	UTXO.Blockchain.SignTransaction(&tx, w.PrivateKey)
 
 	return &tx
 }
@@ -117,13 +120,33 @@ type GameResult struct {
 	Amount       int
 	Change       int
 	GameType     string
-	ServerNumber int  // For Number Range game
+
+	// Provably fair inputs, see fair.go
+	ServerSeedHash []byte
+	ClientSeed     string
+	Nonce          int
+
+	Record GameRecord
 }
 
-func NewGameTransaction(w *wallet.Wallet, amount int, utxoSet *UTXOSet, gameType string, calculateWinnings func(int) (bool, int)) *GameResult {
+// NewGameTransaction builds a bet of w against the house bankroll. The
+// player stakes amount and the house stakes what it could lose if the bet
+// pays maxPayout; the bet is refused when the bankroll can't cover that.
+// play decides the bet and returns its record with the outcome, payout and
+// odds filled in, and whether it hit the jackpot. The payout goes to the
+// player, the jackpot's share of the bet to the pool and everything else of
+// the stakes to the house, so the transaction never creates coins. jackpot
+// may be nil.
+func NewGameTransaction(w *wallet.Wallet, house *wallet.Wallet, jackpot *Jackpot, amount int, utxoSet *UTXOSet, gameType string, roll *FairRoll, maxPayout int, play func(int) (GameRecord, bool)) *GameResult {
 	var inputs []TxInput
 	var outputs []TxOutput
 
+	from := string(w.Address())
+	houseAddress := string(house.Address())
+	if from == houseAddress {
+		log.Panic("Error: the house can't bet against itself")
+	}
+
 	pubKeyHash := wallet.PublicKeyHash(w.PublicKey)
 	acc, validOutputs := utxoSet.FindSpendableOutputs(pubKeyHash, amount)
 
@@ -131,23 +154,50 @@ func NewGameTransaction(w *wallet.Wallet, amount int, utxoSet *UTXOSet, gameType
 		log.Panic("Error: not enough funds for game")
 	}
 
-	for txid, outs := range validOutputs {
-		txID, err := hex.DecodeString(txid)
-		Handle(err)
+	// The house pays the jackpot's share out of what it keeps
+	contribution := jackpot.Contribution(amount)
+	exposure := maxPayout - amount + contribution
+	houseAcc, houseOutputs := utxoSet.FindSpendableOutputs(wallet.PublicKeyHash(house.PublicKey), exposure)
+	if houseAcc < exposure {
+		log.Panicf("Error: house bankroll of %d can't cover a possible payout of %d", houseAcc, exposure+amount)
+	}
 
-		for _, out := range outs {
-			input := TxInput{txID, out, nil, w.PublicKey}
-			inputs = append(inputs, input)
+	for _, spendable := range []struct {
+		outputs map[string][]int
+		pubKey  []byte
+	}{{validOutputs, w.PublicKey}, {houseOutputs, house.PublicKey}} {
+		for txid, outs := range spendable.outputs {
+			txID, err := hex.DecodeString(txid)
+			Handle(err)
+
+			for _, out := range outs {
+				input := TxInput{txID, out, nil, spendable.pubKey}
+				inputs = append(inputs, input)
+			}
 		}
 	}
 
-	from := string(w.Address())
-
-	// Use the provided function to calculate game result
-	won, winnings := calculateWinnings(amount)
+	// Use the provided function to play the game
+	record, hit := play(amount)
+	record.Game = gameType
+	record.Player = from
+	record.House = houseAddress
+	record.Bet = amount
+	record.ServerSeedHash = hex.EncodeToString(roll.ServerSeedHash)
+	record.ClientSeed = roll.ClientSeed
+	record.Nonce = roll.Nonce
+	record.JackpotContribution = contribution
+
+	keys := []wallet.PrivateKeyData{w.PrivateKey, house.PrivateKey}
+	if hit && jackpot != nil {
+		pool, poolInputs := jackpot.pool(utxoSet)
+		inputs = append(inputs, poolInputs...)
+		keys = append(keys, jackpot.Wallet.PrivateKey)
+		record.JackpotPayout = pool
+	}
 
-	if winnings > 0 {
-		outputs = append(outputs, *NewTXOutput(winnings, from))
+	if won := record.Payout + record.JackpotPayout; won > 0 {
+		outputs = append(outputs, *NewTXOutput(won, from))
 	}
 
 	// Add change if there was any excess input
@@ -155,193 +205,124 @@ func NewGameTransaction(w *wallet.Wallet, amount int, utxoSet *UTXOSet, gameType
 		outputs = append(outputs, *NewTXOutput(acc-amount, from))
 	}
 
+	// The house keeps its stake and the bet, minus what it paid out
+	if houseTotal := houseAcc + amount - record.Payout - contribution; houseTotal > 0 {
+		outputs = append(outputs, *NewTXOutput(houseTotal, houseAddress))
+	}
+	if contribution > 0 {
+		outputs = append(outputs, *NewTXOutput(contribution, jackpot.Address()))
+	}
+
+	outputs = append(outputs, *NewGameRecordOutput(record))
+
 	tx := Transaction{nil, inputs, outputs}
 	tx.ID = tx.Hash()
-	utxoSet.Blockchain.SignTransaction(&tx, *w.PrivateKey.ToECDSA())
+	utxoSet.Blockchain.SignTransactionWithKeys(&tx, keys)
 
 	return &GameResult{
-		Transaction: &tx,
-		Won:         won,
-		Amount:      amount,
-		Change:      acc - amount,
-		GameType:    gameType,
+		Transaction:    &tx,
+		Won:            record.Payout+record.JackpotPayout > 0,
+		Amount:         amount,
+		Change:         acc - amount,
+		GameType:       gameType,
+		ServerSeedHash: roll.ServerSeedHash,
+		ClientSeed:     roll.ClientSeed,
+		Nonce:          roll.Nonce,
+		Record:         record,
 	}
 }
 
-type CoinflipResult struct {
-	Transaction *Transaction
-	Won         bool
-	Amount      int
-	Change      int
+func (tx *Transaction) IsCoinbase() bool {
+	return len(tx.Inputs) == 1 && len(tx.Inputs[0].ID) == 0 && tx.Inputs[0].Out == -1
 }
 
-func NewCoinflipTransaction(w *wallet.Wallet, amount int, UTXO *UTXOSet) *GameResult {
-	// Coinflip-specific logic: 50/50 chance, double or nothing
-	coinflipLogic := func(betAmount int) (bool, int) {
-		// Generate random coinflip result
-		randomBytes := make([]byte, 1)
-		_, err := rand.Read(randomBytes)
-		if err != nil {
-			log.Panic(err)
-		}
-		coinflipResult := int(randomBytes[0]) % 2 // 0 or 1
-
-		if coinflipResult == 1 {
-			// Win: get bet amount back + winnings (net gain = bet amount)
-			winnings := betAmount + betAmount  // Original bet + winnings
-			fmt.Printf("Coinflip WIN! You gained %d coins\n", betAmount)
-			return true, winnings
-		} else {
-			// Lose: no winnings
-			fmt.Printf("Coinflip LOSS! You lost %d coins\n", betAmount)
-			return false, 0
-		}
+func (tx *Transaction) Sign(privKey wallet.PrivateKeyData, prevTxs map[string]Transaction) {
+	if tx.IsCoinbase() {
+		return
 	}
 
-	return NewGameTransaction(w, amount, UTXO, "coinflip", coinflipLogic)
-}
-
-func NewDiceRollTransaction(w *wallet.Wallet, amount int, UTXO *UTXOSet) *GameResult {
-	// Dice roll logic: 33% chance to win 3x the bet
-	diceLogic := func(betAmount int) (bool, int) {
-		// Generate random number 1-6
-		randomBytes := make([]byte, 1)
-		_, err := rand.Read(randomBytes)
-		if err != nil {
-			log.Panic(err)
-		}
-		diceRoll := (int(randomBytes[0]) % 6) + 1 // 1-6
-
-		if diceRoll == 6 {
-			// Win: 3x the bet amount
-			winnings := betAmount * 3
-			fmt.Printf("Dice Roll WIN! Rolled a 6! You won %d coins\n", winnings)
-			return true, winnings
-		} else {
-			// Lose: no winnings
-			fmt.Printf("Dice Roll LOSS! Rolled a %d. You lost %d coins\n", diceRoll, betAmount)
-			return false, 0
+	for _, in := range tx.Inputs {
+		if prevTxs[hex.EncodeToString(in.ID)].ID == nil {
+			log.Panic("ERROR: Previous transaction is not correct")
 		}
 	}
 
-	return NewGameTransaction(w, amount, UTXO, "dice", diceLogic)
+	for inId := range tx.Inputs {
+		tx.Inputs[inId].Signature = privKey.Sign(tx.SigHash(inId, prevTxs))
+	}
 }
 
-func NewNumberRangeTransaction(w *wallet.Wallet, amount int, userGuess int, UTXO *UTXOSet) *GameResult {
-	var inputs []TxInput
-	var outputs []TxOutput
-
-	pubKeyHash := wallet.PublicKeyHash(w.PublicKey)
-	acc, validOutputs := UTXO.FindSpendableOutputs(pubKeyHash, amount)
-
-	if acc < amount {
-		log.Panic("Error: not enough funds for game")
+// SignWithKeys signs every input with the key owning its public key, for
+// transactions spending coins of several wallets. Multisig inputs get the
+// signatures of every key given that may sign them.
+func (tx *Transaction) SignWithKeys(keys []wallet.PrivateKeyData, prevTxs map[string]Transaction) {
+	if tx.IsCoinbase() {
+		return
 	}
 
-	for txid, outs := range validOutputs {
-		txID, err := hex.DecodeString(txid)
-		Handle(err)
-
-		for _, out := range outs {
-			input := TxInput{txID, out, nil, w.PublicKey}
-			inputs = append(inputs, input)
+	for inId, in := range tx.Inputs {
+		prevTx := prevTxs[hex.EncodeToString(in.ID)]
+		if prevTx.ID == nil {
+			log.Panic("ERROR: Previous transaction is not correct")
 		}
-	}
-
-	from := string(w.Address())
-
-	// Generate random server number (1-100)
-	randomBytes := make([]byte, 1)
-	_, err := rand.Read(randomBytes)
-	if err != nil {
-		log.Panic(err)
-	}
-	serverNumber := (int(randomBytes[0]) % 100) + 1 // 1-100
-
-	// Check if user's guess is within ±5 range
-	lowerBound := userGuess - 5
-	upperBound := userGuess + 5
-
-	// Handle boundary conditions
-	if lowerBound < 1 {
-		lowerBound = 1
-	}
-	if upperBound > 100 {
-		upperBound = 100
-	}
-
-	var won bool
-	var winnings int
-
-	if serverNumber >= lowerBound && serverNumber <= upperBound {
-		// Win: 5x the bet amount
-		winnings = amount * 5
-		fmt.Printf("Number Range WIN! Server: %d, Your guess: %d (range %d-%d). You won %d coins\n", serverNumber, userGuess, lowerBound, upperBound, winnings)
-		won = true
-	} else {
-		// Lose: no winnings
-		fmt.Printf("Number Range LOSS! Server: %d, Your guess: %d (range %d-%d). You lost %d coins\n", serverNumber, userGuess, lowerBound, upperBound, amount)
-		won = false
-	}
 
-	if winnings > 0 {
-		outputs = append(outputs, *NewTXOutput(winnings, from))
-	}
-
-	// Add change if there was any excess input
-	if acc > amount {
-		outputs = append(outputs, *NewTXOutput(acc-amount, from))
-	}
-
-	tx := Transaction{nil, inputs, outputs}
-	tx.ID = tx.Hash()
-	UTXO.Blockchain.SignTransaction(&tx, *w.PrivateKey.ToECDSA())
+		if prevTx.Outputs[in.Out].IsMultiSig() {
+			if !tx.signMultiSig(inId, keys, prevTxs) {
+				log.Panicf("ERROR: not enough keys for multisig input %d", inId)
+			}
+			continue
+		}
 
-	return &GameResult{
-		Transaction:  &tx,
-		Won:          won,
-		Amount:       amount,
-		Change:       acc - amount,
-		GameType:     "numberrange",
-		ServerNumber: serverNumber,
+		signed := false
+		for _, key := range keys {
+			if key.Owns(in.PubKey) {
+				tx.Inputs[inId].Signature = key.Sign(tx.SigHash(inId, prevTxs))
+				signed = true
+				break
+			}
+		}
+		if !signed {
+			log.Panicf("ERROR: no key for input %d", inId)
+		}
 	}
 }
 
-func NewCoinflipTransactionLegacy(w *wallet.Wallet, amount int, UTXO *UTXOSet) *Transaction {
-	result := NewCoinflipTransaction(w, amount, UTXO)
-	return result.Transaction
-}
-
-func (tx *Transaction) IsCoinbase() bool {
-	return len(tx.Inputs) == 1 && len(tx.Inputs[0].ID) == 0 && tx.Inputs[0].Out == -1
-}
-
-func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTxs map[string]Transaction) {
+// ValueConserved checks that tx neither creates nor burns coins: there are no
+// fees, so its outputs must be worth exactly the outputs it spends. Signatures
+// don't cover the values spent, and a signer told wrong ones would otherwise
+// sign away the difference.
+func (tx *Transaction) ValueConserved(prevTXs map[string]Transaction) bool {
 	if tx.IsCoinbase() {
-		return
+		return true
 	}
 
-	for _, in := range tx.Inputs {
-		if prevTxs[hex.EncodeToString(in.ID)].ID == nil {
-			log.Panic("ERROR: Previous transaction is not correct")
+	in, out := 0, 0
+	for _, input := range tx.Inputs {
+		prevTx, ok := prevTXs[hex.EncodeToString(input.ID)]
+		if !ok || input.Out < 0 || input.Out >= len(prevTx.Outputs) {
+			return false
 		}
+		in += prevTx.Outputs[input.Out].Value
+	}
+	for _, output := range tx.Outputs {
+		if output.Value < 0 {
+			return false
+		}
+		out += output.Value
 	}
 
-	txCopy := tx.TrimmedCopy()
-
-	for inId, in := range txCopy.Inputs {
-		prevTX := prevTxs[hex.EncodeToString(in.ID)]
-		txCopy.Inputs[inId].Signature = nil
-		txCopy.Inputs[inId].PubKey = prevTX.Outputs[in.Out].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Inputs[inId].PubKey = nil
+	return out == in
+}
 
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.ID)
-		Handle(err)
-		signature := append(r.Bytes(), s.Bytes()...)
+// SigHash is the digest signed by input inIdx: the trimmed transaction with
+// that input's PubKey replaced by the PubKeyHash of the output it spends.
+func (tx *Transaction) SigHash(inIdx int, prevTXs map[string]Transaction) []byte {
+	txCopy := tx.TrimmedCopy()
+	in := tx.Inputs[inIdx]
+	prevTx := prevTXs[hex.EncodeToString(in.ID)]
+	txCopy.Inputs[inIdx].PubKey = prevTx.Outputs[in.Out].PubKeyHash
 
-		tx.Inputs[inId].Signature = signature
-	}
+	return txCopy.Hash()
 }
 
 func (tx *Transaction) TrimmedCopy() Transaction {
@@ -372,30 +353,8 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
-
-	for inId, in := range tx.Inputs {
-		prevTx := prevTXs[hex.EncodeToString(in.ID)]
-		txCopy.Inputs[inId].Signature = nil
-		txCopy.Inputs[inId].PubKey = prevTx.Outputs[in.Out].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Inputs[inId].PubKey = nil
-
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(in.Signature)
-		r.SetBytes(in.Signature[:(sigLen / 2)])
-		s.SetBytes(in.Signature[(sigLen / 2):])
-
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(in.PubKey)
-		x.SetBytes(in.PubKey[:(keyLen / 2)])
-		y.SetBytes(in.PubKey[(keyLen / 2):])
-
-		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-		if !ecdsa.Verify(&rawPubKey, txCopy.ID, &r, &s) {
+	for inId := range tx.Inputs {
+		if !tx.VerifyInput(inId, prevTXs) {
 			return false
 		}
 	}
@@ -403,6 +362,24 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 	return true
 }
 
+// VerifyInput checks that input inIdx is signed by the key its previous
+// output is locked to, or by enough of the keys of a multisig output.
+func (tx *Transaction) VerifyInput(inIdx int, prevTXs map[string]Transaction) bool {
+	in := tx.Inputs[inIdx]
+	prevTx := prevTXs[hex.EncodeToString(in.ID)]
+	if in.Out < 0 || in.Out >= len(prevTx.Outputs) {
+		return false
+	}
+	if prevTx.Outputs[in.Out].IsMultiSig() {
+		return tx.verifyMultiSig(inIdx, prevTXs)
+	}
+	if !prevTx.Outputs[in.Out].IsLockedWithKey(wallet.PublicKeyHash(in.PubKey)) {
+		return false
+	}
+
+	return wallet.VerifySignature(in.PubKey, tx.SigHash(inIdx, prevTXs), in.Signature)
+}
+
 func (tx Transaction) String() string {
 	var lines []string
 
@@ -416,6 +393,11 @@ func (tx Transaction) String() string {
 	}
 
 	for i, output := range tx.Outputs {
+		if output.IsData() {
+			lines = append(lines, fmt.Sprintf("     Output %d (data):", i))
+			lines = append(lines, fmt.Sprintf("       Data:   %s", output.PubKeyHash[1:]))
+			continue
+		}
 		lines = append(lines, fmt.Sprintf("     Output %d:", i))
 		lines = append(lines, fmt.Sprintf("       Value:  %d", output.Value))
 		lines = append(lines, fmt.Sprintf("       Script: %x", output.PubKeyHash))
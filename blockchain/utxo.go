@@ -123,6 +123,9 @@ func (u UTXOSet) Reindex() {
 		return nil
 	})
 	Handle(err)
+
+	GameIndex{Blockchain: u.Blockchain}.Reindex()
+	Lottery{Blockchain: u.Blockchain}.Reindex()
 }
 
 func (u *UTXOSet) Update(block *Block) {
@@ -141,14 +144,20 @@ func (u *UTXOSet) Update(block *Block) {
 
 					outs := DeserializeOutputs(v)
 
+					// Spent outputs are blanked rather than dropped so the
+					// remaining ones keep their index
+					unspent := 0
 					for outIdx, out := range outs.Outputs {
-						if outIdx != in.Out {
-							updatedOuts.Outputs = append(updatedOuts.Outputs, out)
-
+						if outIdx == in.Out {
+							out = TxOutput{}
 						}
+						if out.Value > 0 {
+							unspent++
+						}
+						updatedOuts.Outputs = append(updatedOuts.Outputs, out)
 					}
 
-					if len(updatedOuts.Outputs) == 0 {
+					if unspent == 0 {
 						if err := txn.Delete(inID); err != nil {
 							log.Panic(err)
 						}
@@ -168,9 +177,14 @@ func (u *UTXOSet) Update(block *Block) {
 				log.Panic(err)
 			}
 		}
-		return nil
+		if err := indexLottery(txn, block); err != nil {
+			return err
+		}
+		return indexGames(txn, block)
 	})
 	Handle(err)
+
+	BlockConnected(u.Blockchain, block)
 }
 
 func (u *UTXOSet) DeleteByPrefix(prefix []byte) {
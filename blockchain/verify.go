@@ -0,0 +1,163 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+const sigCacheSize = 50000
+
+// SigCache remembers signatures that already verified, keyed by (txid,
+// input). Each entry stores a hash of the signed digest, public key and
+// signature so a different signature under the same outpoint never hits.
+type SigCache struct {
+	mu      sync.RWMutex
+	entries map[string][32]byte
+	order   []string
+	size    int
+}
+
+// SharedSigCache is used by both mempool admission and block validation so a
+// transaction checked on arrival is not checked again when it is mined.
+var SharedSigCache = NewSigCache(sigCacheSize)
+
+func NewSigCache(size int) *SigCache {
+	return &SigCache{entries: make(map[string][32]byte), size: size}
+}
+
+func sigCacheKey(txID []byte, inIdx int) string {
+	return fmt.Sprintf("%x:%d", txID, inIdx)
+}
+
+func sigCacheEntry(sigHash []byte, in TxInput) [32]byte {
+	return sha256.Sum256(bytes.Join([][]byte{sigHash, in.PubKey, in.Signature}, []byte{}))
+}
+
+func (c *SigCache) Exists(key string, entry [32]byte) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cached, ok := c.entries[key]
+	return ok && cached == entry
+}
+
+// Add stores an entry, evicting the oldest ones once the cache is full.
+func (c *SigCache) Add(key string, entry [32]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = entry
+
+	for len(c.order) > c.size {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+func (c *SigCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.entries)
+}
+
+// verifyInputCached verifies one input, consulting and filling cache when it
+// is not nil.
+func (tx *Transaction) verifyInputCached(inIdx int, prevTXs map[string]Transaction, cache *SigCache) bool {
+	if cache == nil {
+		return tx.VerifyInput(inIdx, prevTXs)
+	}
+
+	in := tx.Inputs[inIdx]
+	prevTx := prevTXs[hex.EncodeToString(in.ID)]
+	if in.Out < 0 || in.Out >= len(prevTx.Outputs) {
+		return false
+	}
+
+	key := sigCacheKey(tx.ID, inIdx)
+	entry := sigCacheEntry(tx.SigHash(inIdx, prevTXs), in)
+	if cache.Exists(key, entry) {
+		return true
+	}
+	if !tx.VerifyInput(inIdx, prevTXs) {
+		return false
+	}
+	cache.Add(key, entry)
+
+	return true
+}
+
+// VerifyInputs checks every input of txs, skipping signatures cache already
+// holds. It stops at the first input that fails.
+func VerifyInputs(txs []*Transaction, prevTXs map[string]Transaction, cache *SigCache) bool {
+	for _, tx := range txs {
+		if tx.IsCoinbase() {
+			continue
+		}
+		for inIdx, in := range tx.Inputs {
+			if prevTXs[hex.EncodeToString(in.ID)].ID == nil {
+				return false
+			}
+			if !tx.verifyInputCached(inIdx, prevTXs, cache) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// VerifyTransactions checks transactions meant for the next block: every
+// input spends an unspent output, or one of an earlier transaction of the
+// batch, no output is spent twice, every transaction pays out exactly what
+// it spends and all signatures are valid. Previous transactions are looked
+// up in a single pass over the chain.
+func (chain *BlockChain) VerifyTransactions(txs []*Transaction) bool {
+	needed := make(map[string]bool)
+	prevTXs := make(map[string]Transaction)
+
+	UTXOSet := UTXOSet{Blockchain: chain}
+	spent := make(map[string]bool)
+	for _, tx := range txs {
+		if !tx.IsCoinbase() {
+			for _, in := range tx.Inputs {
+				outpoint := fmt.Sprintf("%x:%d", in.ID, in.Out)
+				if spent[outpoint] {
+					return false
+				}
+				spent[outpoint] = true
+
+				id := hex.EncodeToString(in.ID)
+				if prevTx, ok := prevTXs[id]; ok {
+					if in.Out < 0 || in.Out >= len(prevTx.Outputs) {
+						return false
+					}
+					continue
+				}
+				if _, ok := UTXOSet.Unspent(in.ID, in.Out); !ok {
+					return false
+				}
+				needed[id] = true
+			}
+		}
+		prevTXs[hex.EncodeToString(tx.ID)] = *tx
+	}
+
+	for id, prevTx := range chain.FindTransactions(needed) {
+		prevTXs[id] = prevTx
+	}
+
+	for _, tx := range txs {
+		if !tx.ValueConserved(prevTXs) {
+			return false
+		}
+	}
+
+	return VerifyInputs(txs, prevTXs, SharedSigCache)
+}
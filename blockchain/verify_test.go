@@ -0,0 +1,78 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+const (
+	benchTxs    = 100
+	benchInputs = 4
+)
+
+// benchBlock builds signed transactions spending the outputs of one funding
+// transaction, like a block to verify, without a database.
+func benchBlock(b *testing.B, kt wallet.KeyType) ([]*Transaction, map[string]Transaction) {
+	b.Helper()
+
+	w := wallet.MakeWalletWithType(kt)
+	address := string(w.Address())
+
+	funding := Transaction{Inputs: []TxInput{{ID: []byte{}, Out: -1, PubKey: []byte("bench")}}}
+	for i := 0; i < benchTxs*benchInputs; i++ {
+		funding.Outputs = append(funding.Outputs, *NewTXOutput(1, address))
+	}
+	funding.ID = funding.Hash()
+	prevTXs := map[string]Transaction{hex.EncodeToString(funding.ID): funding}
+
+	var txs []*Transaction
+	for t := 0; t < benchTxs; t++ {
+		tx := Transaction{}
+		for i := 0; i < benchInputs; i++ {
+			tx.Inputs = append(tx.Inputs, TxInput{ID: funding.ID, Out: t*benchInputs + i, PubKey: w.PublicKey})
+		}
+		tx.Outputs = append(tx.Outputs, *NewTXOutput(benchInputs, address))
+		tx.ID = tx.Hash()
+		tx.Sign(w.PrivateKey, prevTXs)
+		txs = append(txs, &tx)
+	}
+
+	return txs, prevTXs
+}
+
+func benchmarkVerifySequential(b *testing.B, kt wallet.KeyType) {
+	txs, prevTXs := benchBlock(b, kt)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		for _, tx := range txs {
+			if !tx.Verify(prevTXs) {
+				b.Fatal("verification failed")
+			}
+		}
+	}
+}
+
+// benchmarkVerifyCached verifies a block whose signatures are already in
+// the cache, as when a block brings transactions seen on arrival.
+func benchmarkVerifyCached(b *testing.B, kt wallet.KeyType) {
+	txs, prevTXs := benchBlock(b, kt)
+	cache := NewSigCache(benchTxs * benchInputs)
+	if !VerifyInputs(txs, prevTXs, cache) {
+		b.Fatal("verification failed")
+	}
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if !VerifyInputs(txs, prevTXs, cache) {
+			b.Fatal("verification failed")
+		}
+	}
+}
+
+func BenchmarkVerifySequentialP256(b *testing.B)    { benchmarkVerifySequential(b, wallet.ECDSAP256) }
+func BenchmarkVerifyCachedP256(b *testing.B)        { benchmarkVerifyCached(b, wallet.ECDSAP256) }
+func BenchmarkVerifySequentialEd25519(b *testing.B) { benchmarkVerifySequential(b, wallet.Ed25519) }
+func BenchmarkVerifyCachedEd25519(b *testing.B)     { benchmarkVerifyCached(b, wallet.Ed25519) }
@@ -0,0 +1,173 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// A wager is a bet between two players. Both stakes are locked in one 2-of-3
+// multisig output of the two players and the node's oracle, so no single
+// party can take the pot: the oracle and the winner sign the payout, and
+// after the timeout the oracle and either player, or both players together,
+// sign the refund. The funding, payout and refund transactions each carry a
+// WagerRecord data output so the wager can be audited from the chain.
+
+var wagerMarker = []byte("\x6awager:")
+
+// DefaultWagerTimeout is the number of blocks after funding a wager can be
+// refunded unless WAGER_TIMEOUT says otherwise.
+const DefaultWagerTimeout = 20
+
+func WagerTimeoutBlocks() int {
+	value := os.Getenv("WAGER_TIMEOUT")
+	if value == "" {
+		return DefaultWagerTimeout
+	}
+
+	blocks, err := strconv.Atoi(value)
+	if err != nil || blocks < 1 {
+		log.Printf("Ignoring WAGER_TIMEOUT=%q, using %d blocks", value, DefaultWagerTimeout)
+		return DefaultWagerTimeout
+	}
+	return blocks
+}
+
+type WagerRecord struct {
+	ID      string `json:"id"`
+	PlayerA string `json:"playerA"`
+	PlayerB string `json:"playerB"`
+	Oracle  string `json:"oracle"`
+	Stake   int    `json:"stake"`
+	// Commitments are the sha256 of each player's secret
+	CommitmentA string `json:"commitmentA"`
+	CommitmentB string `json:"commitmentB"`
+	// Timeout is the height from which the wager can be refunded
+	Timeout int `json:"timeout"`
+
+	// Set once the wager is over
+	SecretA  string `json:"secretA,omitempty"`
+	SecretB  string `json:"secretB,omitempty"`
+	Winner   string `json:"winner,omitempty"`
+	Refunded bool   `json:"refunded,omitempty"`
+}
+
+func newWagerRecordOutput(record WagerRecord) *TxOutput {
+	payload, err := json.Marshal(record)
+	Handle(err)
+
+	return &TxOutput{Value: 0, PubKeyHash: append(append([]byte{}, wagerMarker...), payload...)}
+}
+
+// WagerRecord returns the wager record of a transaction, if any.
+func (tx *Transaction) WagerRecord() (*WagerRecord, error) {
+	for _, out := range tx.Outputs {
+		if !out.IsData() || !bytes.HasPrefix(out.PubKeyHash, wagerMarker) {
+			continue
+		}
+		var record WagerRecord
+		if err := json.Unmarshal(out.PubKeyHash[len(wagerMarker):], &record); err != nil {
+			return nil, fmt.Errorf("malformed wager record in %x: %w", tx.ID, err)
+		}
+		return &record, nil
+	}
+
+	return nil, nil
+}
+
+// WagerCommitment is the commitment to a secret.
+func WagerCommitment(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// WagerWinner decides a wager from both revealed secrets: player A wins when
+// sha256(secretA || secretB) is even.
+func WagerWinner(record WagerRecord) (string, error) {
+	if WagerCommitment(record.SecretA) != record.CommitmentA {
+		return "", fmt.Errorf("secret of %s doesn't match its commitment", record.PlayerA)
+	}
+	if WagerCommitment(record.SecretB) != record.CommitmentB {
+		return "", fmt.Errorf("secret of %s doesn't match its commitment", record.PlayerB)
+	}
+
+	hash := sha256.Sum256([]byte(record.SecretA + record.SecretB))
+	if hash[len(hash)-1]%2 == 0 {
+		return record.PlayerA, nil
+	}
+	return record.PlayerB, nil
+}
+
+// NewWagerTransaction locks the stakes of a and b in a 2-of-3 output of both
+// players and the oracle, output 0 of the transaction.
+func NewWagerTransaction(a, b *wallet.Wallet, record WagerRecord, utxoSet *UTXOSet) (*Transaction, EscrowOutput) {
+	var inputs []TxInput
+	var outputs []TxOutput
+
+	if record.PlayerA == record.PlayerB {
+		log.Panic("Error: a player can't wager against itself")
+	}
+	if record.Stake <= 0 {
+		log.Panic("Error: stake must be greater than 0")
+	}
+
+	pot := 2 * record.Stake
+	outputs = append(outputs, *NewMultiSigOutput(pot, 2, []string{record.PlayerA, record.PlayerB, record.Oracle}))
+
+	for _, player := range []*wallet.Wallet{a, b} {
+		address := string(player.Address())
+		acc, validOutputs := utxoSet.FindSpendableOutputs(wallet.PublicKeyHash(player.PublicKey), record.Stake)
+		if acc < record.Stake {
+			log.Panicf("Error: %s doesn't have the %d coins to stake", address, record.Stake)
+		}
+
+		for txid, outs := range validOutputs {
+			txID, err := hex.DecodeString(txid)
+			Handle(err)
+
+			for _, out := range outs {
+				inputs = append(inputs, TxInput{txID, out, nil, player.PublicKey})
+			}
+		}
+		if acc > record.Stake {
+			outputs = append(outputs, *NewTXOutput(acc-record.Stake, address))
+		}
+	}
+	outputs = append(outputs, *newWagerRecordOutput(record))
+
+	tx := Transaction{nil, inputs, outputs}
+	tx.ID = tx.Hash()
+	utxoSet.Blockchain.SignTransactionWithKeys(&tx, []wallet.PrivateKeyData{a.PrivateKey, b.PrivateKey})
+
+	return &tx, EscrowOutput{TxID: tx.ID, Out: 0, Value: pot}
+}
+
+// NewWagerPayoutTransaction spends the pot of a wager: all of it to the
+// winner of record, or each stake back to its player when it's a refund.
+// keys must hold two of the three keys the pot is locked to.
+func NewWagerPayoutTransaction(pot EscrowOutput, record WagerRecord, keys []wallet.PrivateKeyData, utxoSet *UTXOSet) *Transaction {
+	var outputs []TxOutput
+
+	switch {
+	case record.Refunded:
+		outputs = append(outputs, *NewTXOutput(record.Stake, record.PlayerA), *NewTXOutput(pot.Value-record.Stake, record.PlayerB))
+	case record.Winner == record.PlayerA || record.Winner == record.PlayerB:
+		outputs = append(outputs, *NewTXOutput(pot.Value, record.Winner))
+	default:
+		log.Panic("Error: the wager has neither a winner nor a refund")
+	}
+	outputs = append(outputs, *newWagerRecordOutput(record))
+
+	tx := Transaction{nil, []TxInput{{pot.TxID, pot.Out, nil, nil}}, outputs}
+	tx.ID = tx.Hash()
+	utxoSet.Blockchain.SignTransactionWithKeys(&tx, keys)
+
+	return &tx
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// benchVerify builds an in-memory block of signed transactions and times
+// verification without the signature cache, filling it and with it warm. It
+// needs no node or database.
+func (cli *CommandLine) benchVerify(txCount, inputsPerTx int, keyType wallet.KeyType) {
+	w := wallet.MakeWalletWithType(keyType)
+	address := string(w.Address())
+
+	funding := blockchain.Transaction{Inputs: []blockchain.TxInput{{ID: []byte{}, Out: -1, PubKey: []byte("bench")}}}
+	for i := 0; i < txCount*inputsPerTx; i++ {
+		funding.Outputs = append(funding.Outputs, *blockchain.NewTXOutput(1, address))
+	}
+	funding.ID = funding.Hash()
+	prevTXs := map[string]blockchain.Transaction{hex.EncodeToString(funding.ID): funding}
+
+	var txs []*blockchain.Transaction
+	for t := 0; t < txCount; t++ {
+		tx := blockchain.Transaction{}
+		for i := 0; i < inputsPerTx; i++ {
+			tx.Inputs = append(tx.Inputs, blockchain.TxInput{ID: funding.ID, Out: t*inputsPerTx + i, PubKey: w.PublicKey})
+		}
+		tx.Outputs = append(tx.Outputs, *blockchain.NewTXOutput(inputsPerTx, address))
+		tx.ID = tx.Hash()
+		tx.Sign(w.PrivateKey, prevTXs)
+		txs = append(txs, &tx)
+	}
+
+	start := time.Now()
+	for _, tx := range txs {
+		if !tx.Verify(prevTXs) {
+			log.Panic("sequential verification failed")
+		}
+	}
+	sequential := time.Since(start)
+
+	cache := blockchain.NewSigCache(txCount * inputsPerTx)
+
+	start = time.Now()
+	if !blockchain.VerifyInputs(txs, prevTXs, cache) {
+		log.Panic("verification failed")
+	}
+	filling := time.Since(start)
+
+	start = time.Now()
+	if !blockchain.VerifyInputs(txs, prevTXs, cache) {
+		log.Panic("cached verification failed")
+	}
+	cached := time.Since(start)
+
+	fmt.Printf("%d transactions x %d inputs (%s keys)\n", txCount, inputsPerTx, keyType)
+	fmt.Printf("  uncached:       %v\n", sequential)
+	fmt.Printf("  filling cache:  %v (%.1fx)\n", filling, float64(sequential)/float64(filling))
+	fmt.Printf("  cached:         %v (%.1fx)\n", cached, float64(sequential)/float64(cached))
+}
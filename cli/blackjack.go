@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+func blackjackTable(chain *blockchain.BlockChain, nodeID string) games.BlackjackTable {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return games.BlackjackTable{Blockchain: chain, Wallets: wallets, NodeID: nodeID}
+}
+
+func printBlackjack(state games.BlackjackState) {
+	fmt.Printf("Session: %s\n", state.ID)
+	fmt.Printf("Dealer:  %s (%d)\n", strings.Join(state.Dealer, " "), state.DealerTotal)
+	for i, hand := range state.Hands {
+		marker := " "
+		if !state.Settled && i == state.Active {
+			marker = ">"
+		}
+		fmt.Printf("%s Hand %d: %s (%d), stake %d", marker, i+1, strings.Join(hand.Cards, " "), hand.Total, hand.Stake)
+		if hand.Payout != nil {
+			fmt.Printf(", paid %d", *hand.Payout)
+		}
+		fmt.Println()
+	}
+
+	if state.Settled {
+		fmt.Printf("Settled: bet %d, paid %d in %s\n", state.Bet, state.Payout, state.SettleTx)
+	} else {
+		fmt.Printf("Options: %s (stands at %s)\n", strings.Join(state.Options, ", "), time.Unix(state.Expires, 0).Format(time.Kitchen))
+	}
+	fmt.Printf("Server seed hash: %s\n", state.ServerSeedHash)
+	fmt.Printf("Client seed:      %s\n", state.ClientSeed)
+	fmt.Printf("Nonce:            %d\n", state.Nonce)
+}
+
+func (cli *CommandLine) blackjack(from string, amount int, clientSeed string, nonce int, nodeID string) {
+	if !wallet.ValidateAddress(from) {
+		log.Panic("Address is not Valid")
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	session, err := blackjackTable(chain, nodeID).Open(from, amount, clientSeed, nonce)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printBlackjack(session.State())
+}
+
+func (cli *CommandLine) blackjackAction(id, action, nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	session, err := blackjackTable(chain, nodeID).Act(id, action)
+	if err != nil {
+		fmt.Println(err)
+	}
+	if session != nil {
+		printBlackjack(session.State())
+	}
+}
+
+// blackjackSessions settles timed out sessions, then lists the sessions of
+// address.
+func (cli *CommandLine) blackjackSessions(address, nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	table := blackjackTable(chain, nodeID)
+	for _, id := range table.ExpireSessions() {
+		fmt.Printf("Session %s timed out\n", id)
+	}
+
+	for _, session := range table.Sessions(address) {
+		state := session.State()
+		status := "open, " + strings.Join(state.Options, "/")
+		if state.Settled {
+			status = fmt.Sprintf("settled, paid %d", state.Payout)
+		}
+		fmt.Printf("%s %s bet %d (%s)\n", state.ID, state.Player, state.Bet, status)
+	}
+}
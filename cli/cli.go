@@ -9,6 +9,7 @@ import (
 	"strconv"
 
 	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
 	"github.com/ItsHotdogFred/blockchain/wallet"
 	"github.com/ItsHotdogFred/blockchain/network"
 )
@@ -20,13 +21,59 @@ func (cli *CommandLine) printUsage() {
 	fmt.Println(" getbalance -address ADDRESS - get the balance for")
 	fmt.Println(" printchain - Prints the blocks in the chain")
 	fmt.Println(" send -from FROM -to TO -amount AMOUNT - Send amount of coins")
-	fmt.Println(" createwallet - Creates a new Wallet")
+	fmt.Println(" sendraw -from FROM -to TO -amount AMOUNT [-node URL] - Send through a node's API, signing here")
+	fmt.Println(" createpsbt -from FROM[,FROM] [-inputs TXID:OUT,...] -to TO -amount AMOUNT [-change ADDRESS -out FILE] - Create a PSBT to sign elsewhere, FROM may be house")
+	fmt.Println(" signpsbt -in FILE [-out FILE -address ADDRESS] - Sign a PSBT with the keys of the wallet file, offline")
+	fmt.Println(" combinepsbt -in FILE,FILE[,...] [-out FILE] - Merge the signatures of copies of a PSBT")
+	fmt.Println(" finalizepsbt -in FILE [-node URL] - Finalise a signed PSBT, submitting it to the node at URL")
+	fmt.Println(" createwallet -type TYPE - Creates a new Wallet (p256 or ed25519)")
 	fmt.Println(" listaddresses - Lists the addresses in our wallet file")
+	fmt.Println(" createhd [-words 12|24 -passphrase P -type TYPE] - Start an HD keychain on a new mnemonic")
+	fmt.Println(" restorehd -mnemonic WORDS [-passphrase P -type TYPE -gap N] - Restore an HD keychain and its used addresses")
+	fmt.Println(" hdaddress [-account N -change] - Derive the next HD address of an account")
+	fmt.Println(" hdwallet [-mnemonic -scan -gap N] - List the HD addresses and their balances")
+	fmt.Println(" encryptwallet - Encrypt the wallet file with a passphrase")
+	fmt.Println(" changepassphrase - Re-encrypt the wallet file under a new passphrase")
+	fmt.Println(" walletunlock [-node URL -timeout SECONDS] - Unlock a node's wallet so its API can sign")
+	fmt.Println(" walletlock [-node URL] - Lock a node's wallet")
 	fmt.Println(" reindexutxo - Rebuilds the UTXO set")
 	fmt.Println(" startnode -miner ADDRESS - Start a node with ID specified in NODE_ID env.")
-	fmt.Println(" coinflip -from FROM -amount AMOUNT - Coinflip to double or lose your coins")
-	fmt.Println(" diceroll -from FROM -amount AMOUNT - Dice roll (33% chance to win 3x)")
-	fmt.Println(" numberrange -from FROM -amount AMOUNT -guess NUMBER - Number Range (±5 range wins 5x)")
+	printGameUsage()
+	fmt.Println(" createhouse -type TYPE - Creates the house bankroll wallet games are played against")
+	fmt.Println(" housestatus - Shows the house bankroll and its solvency")
+	fmt.Println(" jackpot - Shows the progressive jackpot pool")
+	fmt.Println(" gamerecords [-address ADDRESS] - List the game records on chain and the house edge")
+	fmt.Println(" stats [-address ADDRESS] - Show the statistics of a player, or of the house")
+	fmt.Println(" fairseed - Show the hash of the active server seed")
+	fmt.Println(" rotateseed - Reveal the active server seed and commit to a new one")
+	fmt.Println(" verifyroll -game GAME -serverseed SEED -seed SEED -nonce N - Recompute a game outcome from a revealed seed")
+	fmt.Println(" games - Lists the games with their parameters and payout tables")
+	fmt.Println(" slotsrtp - Compute the exact RTP of the slot machine from its reels and pay table")
+	fmt.Println(" simulate -game GAME -rounds N -amount AMOUNT [-seed N -rng fair|byte] [game flags] - Simulate a game offline and check its RTP")
+	fmt.Println(" blackjack -from FROM -amount AMOUNT [-seed SEED -nonce N] - Start a blackjack session")
+	fmt.Println(" bjaction -id SESSION -action hit|stand|double|split - Play the active blackjack hand")
+	fmt.Println(" bjsessions [-address ADDRESS] - List blackjack sessions, settling timed out ones")
+	fmt.Println(" buyticket -from FROM -tickets N [-commitment TEXT] - Buy lottery tickets for the current round, one coin each")
+	fmt.Println(" lottery [-round N] - List the lottery rounds, or the tickets of one round")
+	fmt.Println(" lotterywinners - List the past lottery draws")
+	fmt.Println(" createwager -from FROM -amount AMOUNT [-opponent ADDRESS -secret SECRET] - Offer a wager to another player")
+	fmt.Println(" acceptwager -id WAGER -from FROM [-secret SECRET] - Accept a wager, locking both stakes")
+	fmt.Println(" revealwager -id WAGER -from FROM -secret SECRET - Reveal your secret, the second reveal settles the wager")
+	fmt.Println(" refundwager -id WAGER -from FROM - Refund both stakes of a wager past its timeout")
+	fmt.Println(" cancelwager -id WAGER -from FROM - Withdraw a wager nobody accepted")
+	fmt.Println(" wagers [-address ADDRESS] - List wagers")
+	fmt.Println(" crashrounds [-round N -limit N] - List the past crash rounds, or the bets of one")
+	fmt.Println(" verifycrash -hash HASH -commitment HASH [-rounds N] - Check a crash round hash against its chain's commitment")
+	fmt.Println(" createtournament -game GAME -fee FEE -chips N -blocks N [-prizes 50,30,20] - Open a tournament ending N blocks from now")
+	fmt.Println(" jointournament -id TOURNAMENT -from FROM - Pay the entry fee and get the starting chips")
+	fmt.Println(" tournamentbet -id TOURNAMENT -from FROM -amount CHIPS [-seed SEED -nonce N] [game flags] - Bet tournament chips")
+	fmt.Println(" tournaments [-id TOURNAMENT] - List tournaments, or show the leaderboard or results of one")
+	fmt.Println(" setpolicy -address ADDRESS [-losslimit N -wagercap N -cooloff HOURS -exclude YYYY-MM-DD -node URL] - Sign and set your responsible gambling policy")
+	fmt.Println(" policy -address ADDRESS - Show the responsible gambling policy of an address")
+	fmt.Println(" externalminer -node URL -address ADDRESS -workers N - Mine against a node's template/submit API")
+	fmt.Println(" startpool -node URL -address POOLADDR -listen HOST:PORT -sharebits N -window N -fee PCT - Run a Stratum mining pool")
+	fmt.Println(" poolminer -pool HOST:PORT -worker ADDRESS.RIG -workers N - Stand-in Stratum miner")
+	fmt.Println(" benchverify -txs N -inputs M -type TYPE - Benchmark signature verification with and without the cache")
 }
 
 func (cli *CommandLine) ValidateArgs() {
@@ -46,7 +93,7 @@ func (cli *CommandLine) StartNode(nodeID string) {
 func (cli *CommandLine) reindexUTXO(nodeID string) {
 	chain := blockchain.ContinueBlockChain(nodeID)
 	defer chain.Database.Close()
-	UTXOSet := blockchain.UTXOSet{chain}
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
 	UTXOSet.Reindex()
 
 	count := UTXOSet.CountTransactions()
@@ -54,7 +101,7 @@ func (cli *CommandLine) reindexUTXO(nodeID string) {
 } 
 
 func (cli *CommandLine) listAddresses(nodeID string) {
-	wallets, _ := wallet.CreateWallets(nodeID)
+	wallets := loadWallets(nodeID)
 	addresses := wallets.GetAllAddresses()
 
 	for _, address := range addresses {
@@ -62,9 +109,9 @@ func (cli *CommandLine) listAddresses(nodeID string) {
 	}
 }
 
-func (cli *CommandLine) createWallet(nodeID string) {
-	wallets, _ := wallet.CreateWallets(nodeID)
-	address := wallets.AddWallet()
+func (cli *CommandLine) createWallet(keyType wallet.KeyType, nodeID string) {
+	wallets := loadWallets(nodeID)
+	address := wallets.AddWalletWithType(keyType)
 	wallets.SaveFile(nodeID)
 
 	// Create initial balance transaction (100 coins)
@@ -79,7 +126,7 @@ func (cli *CommandLine) createWallet(nodeID string) {
 	block := chain.MineBlock(txs)
 	UTXOSet.Update(block)
 
-	fmt.Printf("New address is: %s with 100 initial balance\n", address)
+	fmt.Printf("New %s address is: %s with 100 initial balance\n", keyType, address)
 }
 
 func (cli *CommandLine) printChain(nodeID string) {
@@ -115,7 +162,7 @@ func (cli *CommandLine) getBalance(address string, nodeID string) {
 	}
 
 	chain := blockchain.ContinueBlockChain(nodeID)
-	UTXOSet := blockchain.UTXOSet{chain}
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
 	defer chain.Database.Close()
 
 	balance := 0
@@ -139,7 +186,7 @@ func (cli *CommandLine) send(from, to string, amount int, nodeID string) {
 	}
 
 	chain := blockchain.ContinueBlockChain(nodeID)
-	UTXOSet := blockchain.UTXOSet{chain}
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
 	defer chain.Database.Close()
 
 	wallets, err := wallet.CreateWallets(nodeID)
@@ -157,75 +204,6 @@ func (cli *CommandLine) send(from, to string, amount int, nodeID string) {
 	fmt.Println("Success!")
 }
 
-func (cli *CommandLine) coinflip(from string, amount int, nodeID string) {
-	if !wallet.ValidateAddress(from) {
-		log.Panic("Address is not Valid")
-	}
-
-	chain := blockchain.ContinueBlockChain(nodeID)
-	UTXOSet := blockchain.UTXOSet{chain}
-	defer chain.Database.Close()
-
-	wallets, err := wallet.CreateWallets(nodeID)
-	if err != nil {
-		log.Panic(err)
-	}
-	wallet := wallets.GetWallet(from)
-
-	result := blockchain.NewCoinflipTransaction(&wallet, amount, &UTXOSet)
-	txs := []*blockchain.Transaction{result.Transaction}
-	block := chain.MineBlock(txs)
-	UTXOSet.Update(block)
-
-	fmt.Println("Coinflip transaction completed!")
-}
-
-func (cli *CommandLine) diceroll(from string, amount int, nodeID string) {
-	if !wallet.ValidateAddress(from) {
-		log.Panic("Address is not Valid")
-	}
-
-	chain := blockchain.ContinueBlockChain(nodeID)
-	UTXOSet := blockchain.UTXOSet{chain}
-	defer chain.Database.Close()
-
-	wallets, err := wallet.CreateWallets(nodeID)
-	if err != nil {
-		log.Panic(err)
-	}
-	wallet := wallets.GetWallet(from)
-
-	result := blockchain.NewDiceRollTransaction(&wallet, amount, &UTXOSet)
-	txs := []*blockchain.Transaction{result.Transaction}
-	block := chain.MineBlock(txs)
-	UTXOSet.Update(block)
-
-	fmt.Println("Dice roll transaction completed!")
-}
-
-func (cli *CommandLine) numberrange(from string, amount int, guess int, nodeID string) {
-	if !wallet.ValidateAddress(from) {
-		log.Panic("Address is not Valid")
-	}
-
-	chain := blockchain.ContinueBlockChain(nodeID)
-	UTXOSet := blockchain.UTXOSet{chain}
-	defer chain.Database.Close()
-
-	wallets, err := wallet.CreateWallets(nodeID)
-	if err != nil {
-		log.Panic(err)
-	}
-	wallet := wallets.GetWallet(from)
-
-	result := blockchain.NewNumberRangeTransaction(&wallet, amount, guess, &UTXOSet)
-	txs := []*blockchain.Transaction{result.Transaction}
-	block := chain.MineBlock(txs)
-	UTXOSet.Update(block)
-
-	fmt.Println("Number Range transaction completed!")
-}
-
 func (cli *CommandLine) Run() {
 	cli.ValidateArgs()
 
@@ -234,30 +212,194 @@ func (cli *CommandLine) Run() {
 		fmt.Printf("NODE_ID env is not set!")
 		runtime.Goexit()
 	}
+	if err := games.LoadConfigFile(); err != nil {
+		log.Panic(err)
+	}
+	// Commands ask for the passphrase of an encrypted wallet file when they
+	// need it, a node waits for walletunlock instead
+	if os.Args[1] != "startnode" {
+		wallet.PassphrasePrompt = walletPassphrase
+	}
+	blockchain.WatchLottery(nodeID)
+	games.WatchWagers(nodeID)
+	games.WatchTournaments(nodeID)
+	blockchain.WatchStats()
 
 	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
-	coinflipCmd := flag.NewFlagSet("coinflip", flag.ExitOnError)
-	diceRollCmd := flag.NewFlagSet("diceroll", flag.ExitOnError)
-	numberRangeCmd := flag.NewFlagSet("numberrange", flag.ExitOnError)
+	sendRawCmd := flag.NewFlagSet("sendraw", flag.ExitOnError)
+	createPSBTCmd := flag.NewFlagSet("createpsbt", flag.ExitOnError)
+	signPSBTCmd := flag.NewFlagSet("signpsbt", flag.ExitOnError)
+	combinePSBTCmd := flag.NewFlagSet("combinepsbt", flag.ExitOnError)
+	finalizePSBTCmd := flag.NewFlagSet("finalizepsbt", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
 	_ = printChainCmd
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	createHDCmd := flag.NewFlagSet("createhd", flag.ExitOnError)
+	restoreHDCmd := flag.NewFlagSet("restorehd", flag.ExitOnError)
+	hdAddressCmd := flag.NewFlagSet("hdaddress", flag.ExitOnError)
+	hdWalletCmd := flag.NewFlagSet("hdwallet", flag.ExitOnError)
+	encryptWalletCmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	changePassphraseCmd := flag.NewFlagSet("changepassphrase", flag.ExitOnError)
+	walletUnlockCmd := flag.NewFlagSet("walletunlock", flag.ExitOnError)
+	walletLockCmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
 	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	benchVerifyCmd := flag.NewFlagSet("benchverify", flag.ExitOnError)
+	externalMinerCmd := flag.NewFlagSet("externalminer", flag.ExitOnError)
+	startPoolCmd := flag.NewFlagSet("startpool", flag.ExitOnError)
+	poolMinerCmd := flag.NewFlagSet("poolminer", flag.ExitOnError)
+	gameCmds := newGameCommands()
+	fairSeedCmd := flag.NewFlagSet("fairseed", flag.ExitOnError)
+	gameRecordsCmd := flag.NewFlagSet("gamerecords", flag.ExitOnError)
+	statsCmd := flag.NewFlagSet("stats", flag.ExitOnError)
+	gamesCmd := flag.NewFlagSet("games", flag.ExitOnError)
+	simulateCmd := flag.NewFlagSet("simulate", flag.ExitOnError)
+	slotsRTPCmd := flag.NewFlagSet("slotsrtp", flag.ExitOnError)
+	createHouseCmd := flag.NewFlagSet("createhouse", flag.ExitOnError)
+	houseStatusCmd := flag.NewFlagSet("housestatus", flag.ExitOnError)
+	jackpotCmd := flag.NewFlagSet("jackpot", flag.ExitOnError)
+	rotateSeedCmd := flag.NewFlagSet("rotateseed", flag.ExitOnError)
+	verifyRollCmd := flag.NewFlagSet("verifyroll", flag.ExitOnError)
+	blackjackCmd := flag.NewFlagSet("blackjack", flag.ExitOnError)
+	bjActionCmd := flag.NewFlagSet("bjaction", flag.ExitOnError)
+	bjSessionsCmd := flag.NewFlagSet("bjsessions", flag.ExitOnError)
+	buyTicketCmd := flag.NewFlagSet("buyticket", flag.ExitOnError)
+	lotteryCmd := flag.NewFlagSet("lottery", flag.ExitOnError)
+	lotteryWinnersCmd := flag.NewFlagSet("lotterywinners", flag.ExitOnError)
+	createWagerCmd := flag.NewFlagSet("createwager", flag.ExitOnError)
+	acceptWagerCmd := flag.NewFlagSet("acceptwager", flag.ExitOnError)
+	revealWagerCmd := flag.NewFlagSet("revealwager", flag.ExitOnError)
+	refundWagerCmd := flag.NewFlagSet("refundwager", flag.ExitOnError)
+	cancelWagerCmd := flag.NewFlagSet("cancelwager", flag.ExitOnError)
+	wagersCmd := flag.NewFlagSet("wagers", flag.ExitOnError)
+	crashRoundsCmd := flag.NewFlagSet("crashrounds", flag.ExitOnError)
+	verifyCrashCmd := flag.NewFlagSet("verifycrash", flag.ExitOnError)
+	createTournamentCmd := flag.NewFlagSet("createtournament", flag.ExitOnError)
+	joinTournamentCmd := flag.NewFlagSet("jointournament", flag.ExitOnError)
+	tournamentBetCmd := flag.NewFlagSet("tournamentbet", flag.ExitOnError)
+	tournamentsCmd := flag.NewFlagSet("tournaments", flag.ExitOnError)
+	setPolicyCmd := flag.NewFlagSet("setpolicy", flag.ExitOnError)
+	policyCmd := flag.NewFlagSet("policy", flag.ExitOnError)
 
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address")
+	createWalletType := createWalletCmd.String("type", "p256", "Key type: p256 or ed25519")
+	createHDWords := createHDCmd.Int("words", 12, "Mnemonic length: 12, 15, 18, 21 or 24 words")
+	createHDPassphrase := createHDCmd.String("passphrase", "", "Optional BIP39 passphrase, needed to restore")
+	createHDType := createHDCmd.String("type", "p256", "Key type: p256 or ed25519")
+	restoreHDMnemonic := restoreHDCmd.String("mnemonic", "", "The mnemonic words")
+	restoreHDPassphrase := restoreHDCmd.String("passphrase", "", "The BIP39 passphrase, if any")
+	restoreHDType := restoreHDCmd.String("type", "p256", "Key type: p256 or ed25519")
+	restoreHDGap := restoreHDCmd.Int("gap", wallet.HDGapLimit, "Unused addresses in a row that end the scan")
+	hdAddressAccount := hdAddressCmd.Int("account", 0, "Account number")
+	hdAddressChange := hdAddressCmd.Bool("change", false, "Derive a change address")
+	hdWalletMnemonic := hdWalletCmd.Bool("mnemonic", false, "Show the mnemonic")
+	hdWalletScan := hdWalletCmd.Bool("scan", false, "Scan the chain for used addresses first")
+	hdWalletGap := hdWalletCmd.Int("gap", wallet.HDGapLimit, "Unused addresses in a row that end the scan")
+	walletUnlockNode := walletUnlockCmd.String("node", "http://localhost:6969", "API URL of the node whose wallet to unlock")
+	walletUnlockTimeout := walletUnlockCmd.Int("timeout", 300, "Seconds until the wallet locks again")
+	walletLockNode := walletLockCmd.String("node", "http://localhost:6969", "API URL of the node whose wallet to lock")
 	sendFrom := sendCmd.String("from", "", "Source wallet address")
 	sendTo := sendCmd.String("to", "", "Destination wallet address")
 	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
-	coinflipFrom := coinflipCmd.String("from", "", "Source wallet address")
-	coinflipAmount := coinflipCmd.Int("amount", 0, "Amount to bet")
-	diceRollFrom := diceRollCmd.String("from", "", "Source wallet address")
-	diceRollAmount := diceRollCmd.Int("amount", 0, "Amount to bet")
-	numberRangeFrom := numberRangeCmd.String("from", "", "Source wallet address")
-	numberRangeAmount := numberRangeCmd.Int("amount", 0, "Amount to bet")
-	numberRangeGuess := numberRangeCmd.Int("guess", 0, "Number guess (1-100)")
+	sendRawFrom := sendRawCmd.String("from", "", "Source wallet address, its key stays here")
+	sendRawTo := sendRawCmd.String("to", "", "Destination wallet address")
+	sendRawAmount := sendRawCmd.Int("amount", 0, "Amount to send")
+	sendRawNode := sendRawCmd.String("node", "http://localhost:6969", "API URL of the node that builds and mines the transaction")
+	createPSBTFrom := createPSBTCmd.String("from", "", "Comma separated addresses to spend from, house for the house address")
+	createPSBTInputs := createPSBTCmd.String("inputs", "", "Comma separated outputs to spend, as TXID:OUT")
+	createPSBTTo := createPSBTCmd.String("to", "", "Destination wallet address")
+	createPSBTAmount := createPSBTCmd.Int("amount", 0, "Amount to send")
+	createPSBTChange := createPSBTCmd.String("change", "", "Address the change goes to, the first from address by default")
+	createPSBTOut := createPSBTCmd.String("out", "", "File to write the PSBT to, printed when empty")
+	signPSBTIn := signPSBTCmd.String("in", "", "PSBT file to sign")
+	signPSBTOut := signPSBTCmd.String("out", "", "File to write the signed PSBT to, the input file by default")
+	signPSBTAddress := signPSBTCmd.String("address", "", "Only sign with the key of this address")
+	combinePSBTIn := combinePSBTCmd.String("in", "", "Comma separated PSBT files to combine")
+	combinePSBTOut := combinePSBTCmd.String("out", "", "File to write the combined PSBT to, printed when empty")
+	finalizePSBTIn := finalizePSBTCmd.String("in", "", "Signed PSBT file")
+	finalizePSBTNode := finalizePSBTCmd.String("node", "", "API URL of the node to submit the transaction to, printed when empty")
+	createHouseType := createHouseCmd.String("type", "p256", "Key type: p256 or ed25519")
+	gameRecordsAddress := gameRecordsCmd.String("address", "", "Only show bets of this address")
+	statsAddress := statsCmd.String("address", "", "Player address (the house if empty)")
+	verifyRollGame := verifyRollCmd.String("game", "", "Game name, see the games command")
+	verifyRollServerSeed := verifyRollCmd.String("serverseed", "", "Revealed server seed (hex)")
+	verifyRollSeed := verifyRollCmd.String("seed", "", "Client seed")
+	verifyRollNonce := verifyRollCmd.Int("nonce", 0, "Bet nonce")
+	simulateGame := simulateCmd.String("game", "", "Game name, see the games command")
+	simulateRounds := simulateCmd.Int("rounds", 1000000, "Number of rounds")
+	simulateAmount := simulateCmd.Int("amount", 1, "Amount to bet each round")
+	simulateSeed := simulateCmd.Int64("seed", 1, "Seed of the RNG, the same seed gives the same results")
+	simulateRNG := simulateCmd.String("rng", games.SimulateFair, "RNG: fair, or byte for the biased randomBytes[0] % n")
+	simulateGameParams := gameParamFlags(simulateCmd)
+	blackjackFrom := blackjackCmd.String("from", "", "Player address")
+	blackjackAmount := blackjackCmd.Int("amount", 0, "Amount to bet")
+	blackjackSeed := blackjackCmd.String("seed", "", "Client seed (random if empty)")
+	blackjackNonce := blackjackCmd.Int("nonce", 0, "Bet nonce, unique per client seed")
+	bjActionID := bjActionCmd.String("id", "", "Session ID")
+	bjActionAction := bjActionCmd.String("action", "", "hit, stand, double or split")
+	bjSessionsAddress := bjSessionsCmd.String("address", "", "Only show sessions of this address")
+	buyTicketFrom := buyTicketCmd.String("from", "", "Player address")
+	buyTicketTickets := buyTicketCmd.Int("tickets", 1, "Number of tickets, one coin each")
+	buyTicketCommitment := buyTicketCmd.String("commitment", "", "Commitment mixed into the draw (random if empty)")
+	lotteryRound := lotteryCmd.Int("round", -1, "Show the tickets of this round")
+	createWagerFrom := createWagerCmd.String("from", "", "Player address")
+	createWagerAmount := createWagerCmd.Int("amount", 0, "Stake of each player")
+	createWagerOpponent := createWagerCmd.String("opponent", "", "Only this address may accept (anyone if empty)")
+	createWagerSecret := createWagerCmd.String("secret", "", "Secret to commit to (random if empty)")
+	acceptWagerID := acceptWagerCmd.String("id", "", "Wager ID")
+	acceptWagerFrom := acceptWagerCmd.String("from", "", "Player address")
+	acceptWagerSecret := acceptWagerCmd.String("secret", "", "Secret to commit to (random if empty)")
+	revealWagerID := revealWagerCmd.String("id", "", "Wager ID")
+	revealWagerFrom := revealWagerCmd.String("from", "", "Player address")
+	revealWagerSecret := revealWagerCmd.String("secret", "", "The secret committed to")
+	refundWagerID := refundWagerCmd.String("id", "", "Wager ID")
+	refundWagerFrom := refundWagerCmd.String("from", "", "Player address")
+	cancelWagerID := cancelWagerCmd.String("id", "", "Wager ID")
+	cancelWagerFrom := cancelWagerCmd.String("from", "", "Player address")
+	wagersAddress := wagersCmd.String("address", "", "Only show wagers of this address")
+	crashRoundsRound := crashRoundsCmd.Int("round", 0, "Show the bets of this round")
+	crashRoundsLimit := crashRoundsCmd.Int("limit", 20, "Number of rounds to list")
+	verifyCrashHash := verifyCrashCmd.String("hash", "", "Revealed hash of a round")
+	verifyCrashCommitment := verifyCrashCmd.String("commitment", "", "Commitment of the round's hash chain")
+	verifyCrashRounds := verifyCrashCmd.Int("rounds", 10, "Number of rounds to recompute, back from the hash's")
+	createTournamentGame := createTournamentCmd.String("game", "", "Game name, see the games command")
+	createTournamentFee := createTournamentCmd.Int("fee", 0, "Entry fee in coins")
+	createTournamentChips := createTournamentCmd.Int("chips", 1000, "Starting chips of every player")
+	createTournamentBlocks := createTournamentCmd.Int("blocks", 20, "Blocks from now the tournament ends at")
+	createTournamentPrizes := createTournamentCmd.String("prizes", "", "Percent of the pool of each place (50,30,20 if empty)")
+	joinTournamentID := joinTournamentCmd.String("id", "", "Tournament ID")
+	joinTournamentFrom := joinTournamentCmd.String("from", "", "Player address")
+	tournamentBetID := tournamentBetCmd.String("id", "", "Tournament ID")
+	tournamentBetFrom := tournamentBetCmd.String("from", "", "Player address")
+	tournamentBetAmount := tournamentBetCmd.Int("amount", 0, "Chips to bet")
+	tournamentBetSeed := tournamentBetCmd.String("seed", "", "Client seed (random if empty)")
+	tournamentBetNonce := tournamentBetCmd.Int("nonce", 0, "Bet nonce, unique per client seed")
+	tournamentBetParams := gameParamFlags(tournamentBetCmd)
+	tournamentsID := tournamentsCmd.String("id", "", "Show this tournament")
+	setPolicyAddress := setPolicyCmd.String("address", "", "Your address, its key signs the policy")
+	setPolicyLossLimit := setPolicyCmd.Int("losslimit", -1, "Daily loss limit, 0 for none (kept if negative)")
+	setPolicyWagerCap := setPolicyCmd.Int("wagercap", -1, "Most coins staked a day, 0 for none (kept if negative)")
+	setPolicyCoolOff := setPolicyCmd.Int("cooloff", 0, "Take a break from betting for this many hours")
+	setPolicyExclude := setPolicyCmd.String("exclude", "", "Exclude yourself from betting through this date, YYYY-MM-DD")
+	setPolicyNode := setPolicyCmd.String("node", "", "Post the signed policy to this node's API instead of the local database")
+	policyAddress := policyCmd.String("address", "", "The address")
+	benchVerifyTxs := benchVerifyCmd.Int("txs", 500, "Number of transactions in the block")
+	benchVerifyInputs := benchVerifyCmd.Int("inputs", 4, "Inputs per transaction")
+	benchVerifyType := benchVerifyCmd.String("type", "p256", "Key type: p256 or ed25519")
+	externalMinerNode := externalMinerCmd.String("node", "http://localhost:6969", "API URL of the node to mine for")
+	externalMinerAddress := externalMinerCmd.String("address", "", "Address receiving the block reward")
+	externalMinerWorkers := externalMinerCmd.Int("workers", 0, "Mining goroutines (default: one per CPU)")
+	startPoolNode := startPoolCmd.String("node", "http://localhost:6969", "API URL of the node the pool mines for")
+	startPoolAddress := startPoolCmd.String("address", "", "Pool address receiving fees and dust")
+	startPoolListen := startPoolCmd.String("listen", "0.0.0.0:3333", "Stratum listen address")
+	startPoolShareBits := startPoolCmd.Int("sharebits", 8, "Share difficulty in leading zero bits")
+	startPoolWindow := startPoolCmd.Int("window", 1000, "PPLNS window size in shares")
+	startPoolFee := startPoolCmd.Float64("fee", 1, "Pool fee in percent")
+	poolMinerPool := poolMinerCmd.String("pool", "localhost:3333", "Stratum pool address")
+	poolMinerWorker := poolMinerCmd.String("worker", "", "Worker name, ADDRESS or ADDRESS.rig")
+	poolMinerWorkers := poolMinerCmd.Int("workers", 1, "Mining goroutines")
 	_ = startNodeCmd.String("miner", "", "Mining address (deprecated - transactions are self-mined)")
 
 	switch os.Args[1] {
@@ -281,6 +423,46 @@ func (cli *CommandLine) Run() {
 		if err != nil {
 			log.Panic(err)
 		}
+	case "createhd":
+		err := createHDCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "restorehd":
+		err := restoreHDCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "hdaddress":
+		err := hdAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "hdwallet":
+		err := hdWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "encryptwallet":
+		err := encryptWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "changepassphrase":
+		err := changePassphraseCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletunlock":
+		err := walletUnlockCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletlock":
+		err := walletLockCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "createwallet":
 		err := createWalletCmd.Parse(os.Args[2:])
 		if err != nil {
@@ -291,18 +473,28 @@ func (cli *CommandLine) Run() {
 		if err != nil {
 			log.Panic(err)
 		}
-	case "coinflip":
-		err := coinflipCmd.Parse(os.Args[2:])
+	case "sendraw":
+		err := sendRawCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createpsbt":
+		err := createPSBTCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
-	case "diceroll":
-		err := diceRollCmd.Parse(os.Args[2:])
+	case "signpsbt":
+		err := signPSBTCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
-	case "numberrange":
-		err := numberRangeCmd.Parse(os.Args[2:])
+	case "combinepsbt":
+		err := combinePSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "finalizepsbt":
+		err := finalizePSBTCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
@@ -311,6 +503,188 @@ func (cli *CommandLine) Run() {
 		if err != nil {
 			log.Panic(err)
 		}
+	case "benchverify":
+		err := benchVerifyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "externalminer":
+		err := externalMinerCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "startpool":
+		err := startPoolCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "poolminer":
+		err := poolMinerCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createhouse":
+		err := createHouseCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "housestatus":
+		err := houseStatusCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "jackpot":
+		err := jackpotCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "games":
+		err := gamesCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "simulate":
+		err := simulateCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "slotsrtp":
+		err := slotsRTPCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "gamerecords":
+		err := gameRecordsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "fairseed":
+		err := fairSeedCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	default:
+		if cmd, ok := gameCmds[os.Args[1]]; ok {
+			err := cmd.flags.Parse(os.Args[2:])
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+	case "rotateseed":
+		err := rotateSeedCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "verifyroll":
+		err := verifyRollCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "blackjack":
+		err := blackjackCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "bjaction":
+		err := bjActionCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "bjsessions":
+		err := bjSessionsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "buyticket":
+		err := buyTicketCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "lottery":
+		err := lotteryCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "lotterywinners":
+		err := lotteryWinnersCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createwager":
+		err := createWagerCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "acceptwager":
+		err := acceptWagerCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "revealwager":
+		err := revealWagerCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "refundwager":
+		err := refundWagerCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "cancelwager":
+		err := cancelWagerCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "wagers":
+		err := wagersCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "crashrounds":
+		err := crashRoundsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "verifycrash":
+		err := verifyCrashCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createtournament":
+		err := createTournamentCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "jointournament":
+		err := joinTournamentCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "tournamentbet":
+		err := tournamentBetCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "tournaments":
+		err := tournamentsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "stats":
+		err := statsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "setpolicy":
+		err := setPolicyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "policy":
+		err := policyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	}
 
 
@@ -331,29 +705,46 @@ func (cli *CommandLine) Run() {
 		}
 		cli.send(*sendFrom, *sendTo, *sendAmount, nodeID)
 	}
-
-	if coinflipCmd.Parsed() {
-		if *coinflipFrom == "" || *coinflipAmount <= 0 {
-			coinflipCmd.Usage()
+	if sendRawCmd.Parsed() {
+		if *sendRawFrom == "" || *sendRawTo == "" || *sendRawAmount <= 0 {
+			sendRawCmd.Usage()
 			runtime.Goexit()
 		}
-		cli.coinflip(*coinflipFrom, *coinflipAmount, nodeID)
+		cli.sendRaw(*sendRawFrom, *sendRawTo, *sendRawAmount, *sendRawNode, nodeID)
 	}
-
-	if diceRollCmd.Parsed() {
-		if *diceRollFrom == "" || *diceRollAmount <= 0 {
-			diceRollCmd.Usage()
+	if createPSBTCmd.Parsed() {
+		if (*createPSBTFrom == "" && *createPSBTInputs == "") || *createPSBTTo == "" || *createPSBTAmount <= 0 {
+			createPSBTCmd.Usage()
 			runtime.Goexit()
 		}
-		cli.diceroll(*diceRollFrom, *diceRollAmount, nodeID)
+		cli.createPSBT(*createPSBTFrom, *createPSBTInputs, *createPSBTTo, *createPSBTChange, *createPSBTAmount, *createPSBTOut, nodeID)
 	}
-
-	if numberRangeCmd.Parsed() {
-		if *numberRangeFrom == "" || *numberRangeAmount <= 0 || *numberRangeGuess < 1 || *numberRangeGuess > 100 {
-			numberRangeCmd.Usage()
+	if signPSBTCmd.Parsed() {
+		if *signPSBTIn == "" {
+			signPSBTCmd.Usage()
 			runtime.Goexit()
 		}
-		cli.numberrange(*numberRangeFrom, *numberRangeAmount, *numberRangeGuess, nodeID)
+		cli.signPSBT(*signPSBTIn, *signPSBTOut, *signPSBTAddress, nodeID)
+	}
+	if combinePSBTCmd.Parsed() {
+		if *combinePSBTIn == "" {
+			combinePSBTCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.combinePSBT(*combinePSBTIn, *combinePSBTOut)
+	}
+	if finalizePSBTCmd.Parsed() {
+		if *finalizePSBTIn == "" {
+			finalizePSBTCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.finalizePSBT(*finalizePSBTIn, *finalizePSBTNode)
+	}
+
+	for _, cmd := range gameCmds {
+		if cmd.flags.Parsed() {
+			cli.playGame(cmd, nodeID)
+		}
 	}
 
 	if startNodeCmd.Parsed() {
@@ -370,12 +761,247 @@ func (cli *CommandLine) Run() {
 	}
 
 	if createWalletCmd.Parsed() {
-		cli.createWallet(nodeID)
+		keyType, err := wallet.ParseKeyType(*createWalletType)
+		if err != nil {
+			createWalletCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.createWallet(keyType, nodeID)
 	}
 	if listAddressesCmd.Parsed() {
 		cli.listAddresses(nodeID)
 	}
+	if createHDCmd.Parsed() {
+		keyType, err := wallet.ParseKeyType(*createHDType)
+		if err != nil {
+			createHDCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.createHD(*createHDWords, *createHDPassphrase, keyType, nodeID)
+	}
+	if restoreHDCmd.Parsed() {
+		keyType, err := wallet.ParseKeyType(*restoreHDType)
+		if err != nil || *restoreHDMnemonic == "" {
+			restoreHDCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.restoreHD(*restoreHDMnemonic, *restoreHDPassphrase, keyType, *restoreHDGap, nodeID)
+	}
+	if hdAddressCmd.Parsed() {
+		cli.hdAddress(*hdAddressAccount, *hdAddressChange, nodeID)
+	}
+	if hdWalletCmd.Parsed() {
+		cli.hdWallet(*hdWalletMnemonic, *hdWalletScan, *hdWalletGap, nodeID)
+	}
+	if encryptWalletCmd.Parsed() {
+		cli.encryptWallet(nodeID)
+	}
+	if changePassphraseCmd.Parsed() {
+		cli.changePassphrase(nodeID)
+	}
+	if walletUnlockCmd.Parsed() {
+		if *walletUnlockTimeout < 1 {
+			walletUnlockCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.walletUnlock(*walletUnlockNode, *walletUnlockTimeout)
+	}
+	if walletLockCmd.Parsed() {
+		cli.walletLock(*walletLockNode)
+	}
 	if reindexUTXOCmd.Parsed() {
 		cli.reindexUTXO(nodeID)
-	} 
+	}
+	if benchVerifyCmd.Parsed() {
+		keyType, err := wallet.ParseKeyType(*benchVerifyType)
+		if err != nil || *benchVerifyTxs <= 0 || *benchVerifyInputs <= 0 {
+			benchVerifyCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.benchVerify(*benchVerifyTxs, *benchVerifyInputs, keyType)
+	}
+	if externalMinerCmd.Parsed() {
+		if *externalMinerAddress == "" || !wallet.ValidateAddress(*externalMinerAddress) {
+			externalMinerCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.externalMiner(*externalMinerNode, *externalMinerAddress, *externalMinerWorkers)
+	}
+	if startPoolCmd.Parsed() {
+		if *startPoolAddress == "" || !wallet.ValidateAddress(*startPoolAddress) ||
+			*startPoolShareBits < 1 || *startPoolShareBits > blockchain.Difficulty || *startPoolWindow <= 0 {
+			startPoolCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.startPool(*startPoolNode, *startPoolAddress, *startPoolListen, *startPoolShareBits, *startPoolWindow, *startPoolFee)
+	}
+	if poolMinerCmd.Parsed() {
+		if *poolMinerWorker == "" {
+			poolMinerCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.poolMiner(*poolMinerPool, *poolMinerWorker, *poolMinerWorkers)
+	}
+	if createHouseCmd.Parsed() {
+		keyType, err := wallet.ParseKeyType(*createHouseType)
+		if err != nil {
+			createHouseCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.createHouse(keyType, nodeID)
+	}
+	if houseStatusCmd.Parsed() {
+		cli.houseStatus(nodeID)
+	}
+	if jackpotCmd.Parsed() {
+		cli.jackpotStatus(nodeID)
+	}
+	if gamesCmd.Parsed() {
+		cli.listGames()
+	}
+	if simulateCmd.Parsed() {
+		if *simulateGame == "" {
+			simulateCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.simulate(*simulateGame, *simulateRounds, *simulateAmount, *simulateSeed, *simulateRNG, simulateGameParams)
+	}
+	if slotsRTPCmd.Parsed() {
+		cli.slotsRTP()
+	}
+	if gameRecordsCmd.Parsed() {
+		cli.gameRecords(*gameRecordsAddress, nodeID)
+	}
+	if statsCmd.Parsed() {
+		cli.stats(*statsAddress, nodeID)
+	}
+	if fairSeedCmd.Parsed() {
+		cli.fairSeed(nodeID)
+	}
+	if rotateSeedCmd.Parsed() {
+		cli.rotateSeed(nodeID)
+	}
+	if verifyRollCmd.Parsed() {
+		if *verifyRollGame == "" || *verifyRollServerSeed == "" || *verifyRollSeed == "" {
+			verifyRollCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.verifyRoll(*verifyRollGame, *verifyRollServerSeed, *verifyRollSeed, *verifyRollNonce)
+	}
+	if blackjackCmd.Parsed() {
+		if *blackjackFrom == "" || *blackjackAmount <= 0 {
+			blackjackCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.blackjack(*blackjackFrom, *blackjackAmount, *blackjackSeed, *blackjackNonce, nodeID)
+	}
+	if bjActionCmd.Parsed() {
+		if *bjActionID == "" || *bjActionAction == "" {
+			bjActionCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.blackjackAction(*bjActionID, *bjActionAction, nodeID)
+	}
+	if bjSessionsCmd.Parsed() {
+		cli.blackjackSessions(*bjSessionsAddress, nodeID)
+	}
+	if buyTicketCmd.Parsed() {
+		if *buyTicketFrom == "" || *buyTicketTickets <= 0 {
+			buyTicketCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.buyTickets(*buyTicketFrom, *buyTicketTickets, *buyTicketCommitment, nodeID)
+	}
+	if lotteryCmd.Parsed() {
+		cli.lotteryRounds(*lotteryRound, nodeID)
+	}
+	if lotteryWinnersCmd.Parsed() {
+		cli.lotteryWinners(nodeID)
+	}
+	if createWagerCmd.Parsed() {
+		if *createWagerFrom == "" || *createWagerAmount <= 0 {
+			createWagerCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.createWager(*createWagerFrom, *createWagerOpponent, *createWagerAmount, *createWagerSecret, nodeID)
+	}
+	if acceptWagerCmd.Parsed() {
+		if *acceptWagerID == "" || *acceptWagerFrom == "" {
+			acceptWagerCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.acceptWager(*acceptWagerID, *acceptWagerFrom, *acceptWagerSecret, nodeID)
+	}
+	if revealWagerCmd.Parsed() {
+		if *revealWagerID == "" || *revealWagerFrom == "" || *revealWagerSecret == "" {
+			revealWagerCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.revealWager(*revealWagerID, *revealWagerFrom, *revealWagerSecret, nodeID)
+	}
+	if refundWagerCmd.Parsed() {
+		if *refundWagerID == "" || *refundWagerFrom == "" {
+			refundWagerCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.refundWager(*refundWagerID, *refundWagerFrom, nodeID)
+	}
+	if cancelWagerCmd.Parsed() {
+		if *cancelWagerID == "" || *cancelWagerFrom == "" {
+			cancelWagerCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.cancelWager(*cancelWagerID, *cancelWagerFrom, nodeID)
+	}
+	if wagersCmd.Parsed() {
+		cli.listWagers(*wagersAddress, nodeID)
+	}
+	if crashRoundsCmd.Parsed() {
+		cli.crashRounds(*crashRoundsRound, *crashRoundsLimit, nodeID)
+	}
+	if verifyCrashCmd.Parsed() {
+		if *verifyCrashHash == "" || *verifyCrashCommitment == "" {
+			verifyCrashCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.verifyCrash(*verifyCrashHash, *verifyCrashCommitment, *verifyCrashRounds)
+	}
+	if createTournamentCmd.Parsed() {
+		if *createTournamentGame == "" || *createTournamentFee <= 0 {
+			createTournamentCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.createTournament(*createTournamentGame, *createTournamentFee, *createTournamentChips, *createTournamentBlocks, *createTournamentPrizes, nodeID)
+	}
+	if joinTournamentCmd.Parsed() {
+		if *joinTournamentID == "" || *joinTournamentFrom == "" {
+			joinTournamentCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.joinTournament(*joinTournamentID, *joinTournamentFrom, nodeID)
+	}
+	if tournamentBetCmd.Parsed() {
+		if *tournamentBetID == "" || *tournamentBetFrom == "" || *tournamentBetAmount <= 0 {
+			tournamentBetCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.tournamentBet(*tournamentBetID, *tournamentBetFrom, *tournamentBetAmount, *tournamentBetSeed, *tournamentBetNonce, tournamentBetParams, nodeID)
+	}
+	if tournamentsCmd.Parsed() {
+		cli.tournaments(*tournamentsID, nodeID)
+	}
+	if setPolicyCmd.Parsed() {
+		if *setPolicyAddress == "" {
+			setPolicyCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.setPolicy(*setPolicyAddress, *setPolicyLossLimit, *setPolicyWagerCap, *setPolicyCoolOff, *setPolicyExclude, *setPolicyNode, nodeID)
+	}
+	if policyCmd.Parsed() {
+		if *policyAddress == "" {
+			policyCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.showPolicy(*policyAddress, nodeID)
+	}
 }
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+)
+
+func multiplier(hundredths int) string {
+	return fmt.Sprintf("%d.%02dx", hundredths/100, hundredths%100)
+}
+
+// crashRounds lists the last rounds of the crash game, or the bets of one.
+func (cli *CommandLine) crashRounds(round, limit int, nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	table := games.CrashTable{Blockchain: chain}
+	if round > 0 {
+		r, err := table.Round(round)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Round %d busted at %s\n", r.Round, multiplier(r.Bust))
+		fmt.Printf("Hash %s, round %d of the chain committed to by %s\n", r.Hash, r.ChainRound, r.Commitment)
+		for _, bet := range r.Bets {
+			result := "busted"
+			if bet.CashedOut != 0 {
+				result = "cashed out at " + multiplier(bet.CashedOut)
+			}
+			fmt.Printf(" %s bet %d, auto %s, %s, paid %d\n", bet.Player, bet.Amount, multiplier(bet.CashOut), result, bet.Payout)
+		}
+		if r.SettleTx != "" {
+			fmt.Printf("Settled in %s\n", r.SettleTx)
+		}
+		return
+	}
+
+	for _, r := range table.Rounds(limit) {
+		fmt.Printf("Round %d: %s, %d bets, hash %s\n", r.Round, multiplier(r.Bust), len(r.Bets), r.Hash)
+	}
+}
+
+// verifyCrash checks a revealed hash against the commitment of its chain
+// and recomputes the busts of its round and the rounds before, offline.
+func (cli *CommandLine) verifyCrash(hashHex, commitmentHex string, rounds int) {
+	hash, err := hex.DecodeString(hashHex)
+	if err != nil {
+		fmt.Println("hash must be hex")
+		return
+	}
+	commitment, err := hex.DecodeString(commitmentHex)
+	if err != nil {
+		fmt.Println("commitment must be hex")
+		return
+	}
+
+	round := blockchain.VerifyCrashHash(hash, commitment)
+	if round == 0 {
+		fmt.Printf("INVALID: the hash doesn't lead to the commitment within %d rounds\n", blockchain.CrashChainLength)
+		return
+	}
+	fmt.Printf("OK: the hash is round %d of the chain\n", round)
+
+	for i := 0; i < rounds && round-i > 0; i++ {
+		fmt.Printf(" round %d: %s (%x)\n", round-i, multiplier(blockchain.CrashBust(hash)), hash)
+		hash = blockchain.PreviousCrashHash(hash)
+	}
+}
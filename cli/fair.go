@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+)
+
+// newRoll commits a CLI bet to the active server seed. A random client seed
+// is picked when none is given.
+func newRoll(chain *blockchain.BlockChain, clientSeed string, nonce int) *blockchain.FairRoll {
+	if clientSeed == "" {
+		clientSeed = blockchain.RandomClientSeed()
+	}
+	roll, err := blockchain.FairSeeds{Blockchain: chain}.NewRoll(clientSeed, nonce)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return roll
+}
+
+func printRoll(result *blockchain.GameResult) {
+	fmt.Printf("Server seed hash: %x\n", result.ServerSeedHash)
+	fmt.Printf("Client seed:      %s\n", result.ClientSeed)
+	fmt.Printf("Nonce:            %d\n", result.Nonce)
+}
+
+func printSeed(label string, seed blockchain.ServerSeed) {
+	fmt.Printf("%s hash: %x\n", label, seed.Hash)
+	if seed.Revealed {
+		fmt.Printf("%s seed: %x\n", label, seed.Seed)
+	}
+}
+
+func (cli *CommandLine) fairSeed(nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	printSeed("Active", blockchain.FairSeeds{Blockchain: chain}.Active())
+}
+
+func (cli *CommandLine) rotateSeed(nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	revealed, next, err := blockchain.FairSeeds{Blockchain: chain}.Rotate()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printSeed("Revealed", revealed)
+	printSeed("Next", next)
+}
+
+// verifyRoll recomputes an outcome from a revealed seed. The seed is given
+// directly so results can be checked without trusting the node.
+func (cli *CommandLine) verifyRoll(game, serverSeed, clientSeed string, nonce int) {
+	seed, err := hex.DecodeString(serverSeed)
+	if err != nil {
+		log.Panic(err)
+	}
+	outcome, outcomeText, err := games.Verify(game, seed, clientSeed, nonce)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Server seed hash: %x\n", blockchain.ServerSeedHash(seed))
+	fmt.Printf("Outcome: %d (%s)\n", outcome, outcomeText)
+}
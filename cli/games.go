@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// gameCommand is a subcommand generated from a registered game, with the
+// common bet flags plus one string flag per game parameter.
+type gameCommand struct {
+	game   games.Game
+	flags  *flag.FlagSet
+	from   *string
+	amount *int
+	seed   *string
+	nonce  *int
+	params map[string]*string
+}
+
+// newGameCommands creates a command for every game name and alias.
+func newGameCommands() map[string]*gameCommand {
+	cmds := make(map[string]*gameCommand)
+
+	for _, game := range games.All() {
+		info := game.Info()
+		for _, name := range append([]string{info.Name}, info.Aliases...) {
+			cmd := &gameCommand{
+				game:   game,
+				flags:  flag.NewFlagSet(name, flag.ExitOnError),
+				params: make(map[string]*string),
+			}
+			cmd.from = cmd.flags.String("from", "", "Source wallet address")
+			cmd.amount = cmd.flags.Int("amount", 0, "Amount to bet")
+			cmd.seed = cmd.flags.String("seed", "", "Client seed (random if empty)")
+			cmd.nonce = cmd.flags.Int("nonce", 0, "Bet nonce, unique per client seed")
+			for _, param := range info.Params {
+				cmd.params[param.Name] = cmd.flags.String(param.Name, "", param.Description)
+			}
+			cmds[name] = cmd
+		}
+	}
+
+	return cmds
+}
+
+func printGameUsage() {
+	for _, game := range games.All() {
+		info := game.Info()
+
+		usage := " " + info.Name + " -from FROM -amount AMOUNT"
+		for _, param := range info.Params {
+			usage += fmt.Sprintf(" -%s %s", param.Name, strings.ToUpper(param.Name))
+		}
+		fmt.Printf("%s [-seed SEED -nonce N] - %s\n", usage, info.Description)
+	}
+}
+
+func (cli *CommandLine) playGame(cmd *gameCommand, nodeID string) {
+	params := games.Params{}
+	for name, value := range cmd.params {
+		if *value != "" {
+			params[name] = *value
+		}
+	}
+	if *cmd.from == "" {
+		cmd.flags.Usage()
+		runtime.Goexit()
+	}
+	if err := games.Validate(cmd.game, *cmd.amount, params); err != nil {
+		fmt.Println(err)
+		cmd.flags.Usage()
+		runtime.Goexit()
+	}
+	if !wallet.ValidateAddress(*cmd.from) {
+		log.Panic("Address is not Valid")
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+	defer chain.Database.Close()
+
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	player := wallets.GetWallet(*cmd.from)
+	house, err := wallets.HouseWallet()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	jackpot := wallets.JackpotWallet(nodeID)
+
+	roll := newRoll(chain, *cmd.seed, *cmd.nonce)
+	result, err := games.Play(cmd.game, &player, &house, blockchain.NewJackpot(&jackpot), *cmd.amount, params, &UTXOSet, roll)
+	if err != nil {
+		log.Panic(err)
+	}
+	block := chain.MineBlock([]*blockchain.Transaction{result.Transaction})
+	UTXOSet.Update(block)
+
+	info := cmd.game.Info()
+	outcome := cmd.game.Describe(result.Record.Outcome)
+	if result.Record.JackpotPayout > 0 {
+		fmt.Printf("%s JACKPOT! %s, you received %d coins including the %d coin jackpot\n", info.Title, outcome,
+			result.Record.Payout+result.Record.JackpotPayout, result.Record.JackpotPayout)
+	} else if result.Won {
+		fmt.Printf("%s WIN! %s, you received %d coins\n", info.Title, outcome, result.Record.Payout)
+	} else {
+		fmt.Printf("%s LOSS! %s, you lost %d coins\n", info.Title, outcome, result.Amount)
+	}
+	printGrid(cmd.game, result.Record.Outcome)
+	printRoll(result)
+}
+
+func (cli *CommandLine) listGames() {
+	for _, game := range games.All() {
+		info := game.Info()
+
+		fmt.Printf("%s - %s\n", info.Name, info.Description)
+		if len(info.Aliases) > 0 {
+			fmt.Printf("  aliases: %s\n", strings.Join(info.Aliases, ", "))
+		}
+		for _, param := range info.Params {
+			fmt.Printf("  -%s (%s): %s\n", param.Name, param.Type, param.Description)
+		}
+		for _, payout := range info.Payouts {
+			fmt.Printf("  %-12s pays %dx, wins %d/%d, RTP %.2f%%\n", payout.Bet, payout.Multiplier, payout.WinOutcomes, payout.Outcomes, payout.RTP())
+		}
+		printLimits(info.Name)
+	}
+	printLimits("blackjack")
+}
+
+func printLimits(name string) {
+	gc := games.CurrentConfig().Games[name]
+	if gc.MinBet == 0 && gc.MaxBet == 0 && gc.MaxPayout == 0 {
+		return
+	}
+
+	limit := func(value int) string {
+		if value == 0 {
+			return "none"
+		}
+		return fmt.Sprint(value)
+	}
+	fmt.Printf("  %s limits: min bet %s, max bet %s, max payout %s\n", name, limit(gc.MinBet), limit(gc.MaxBet), limit(gc.MaxPayout))
+}
+
+// gameRecords prints the indexed bets of address, or of everyone when it is
+// empty, followed by the realised and expected house edge.
+func (cli *CommandLine) gameRecords(address, nodeID string) {
+	if address != "" && !wallet.ValidateAddress(address) {
+		fmt.Println("Address is not Valid")
+		return
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	games := blockchain.GameIndex{Blockchain: chain}.Records(address)
+	for _, game := range games {
+		r := game.Record
+		fmt.Printf("%x height %d: %s by %s, bet %d, outcome %d, payout %d (x%d on %d/%d), seed %s client %s nonce %d\n",
+			game.TxID, game.Height, r.Game, r.Player, r.Bet, r.Outcome, r.Payout,
+			r.Multiplier, r.WinOutcomes, r.Outcomes, r.ServerSeedHash, r.ClientSeed, r.Nonce)
+	}
+
+	summary := blockchain.SummarizeGames(games)
+	fmt.Printf("%d bets, %d wagered, %d paid out\n", summary.Bets, summary.Wagered, summary.PaidOut)
+	fmt.Printf("House edge: %.2f%% realised, %.2f%% expected\n", summary.HouseEdge*100, summary.ExpectedEdge*100)
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// createHD starts an HD keychain and derives its first receiving address.
+func (cli *CommandLine) createHD(words int, passphrase string, keyType wallet.KeyType, nodeID string) {
+	wallets := loadWallets(nodeID)
+	mnemonic, err := wallets.CreateHD(words, passphrase, keyType)
+	if err != nil {
+		log.Panic(err)
+	}
+	address, err := wallets.NewHDAddress(0, false)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveFile(nodeID)
+
+	fmt.Println("Write down these words, with the passphrase if you set one. They restore every HD address:")
+	fmt.Printf("\n  %s\n\n", mnemonic)
+	fmt.Printf("First address %s (%s)\n", address.Address, address.Path)
+}
+
+// restoreHD starts an HD keychain from a mnemonic and finds the addresses
+// used on chain.
+func (cli *CommandLine) restoreHD(mnemonic, passphrase string, keyType wallet.KeyType, gap int, nodeID string) {
+	wallets := loadWallets(nodeID)
+	if err := wallets.RestoreHD(mnemonic, passphrase, keyType); err != nil {
+		log.Panic(err)
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	found := discoverHD(chain, wallets, gap)
+	if len(found) == 0 {
+		if _, err := wallets.NewHDAddress(0, false); err != nil {
+			log.Panic(err)
+		}
+	}
+	wallets.SaveFile(nodeID)
+
+	fmt.Printf("Restored %d used addresses\n", len(found))
+	printHDAddresses(chain, wallets)
+}
+
+func discoverHD(chain *blockchain.BlockChain, wallets *wallet.Wallets, gap int) []wallet.HDAddress {
+	used := chain.UsedPubKeyHashes()
+	found, err := wallets.DiscoverHD(func(pubKeyHash []byte) bool {
+		return used[hex.EncodeToString(pubKeyHash)]
+	}, gap)
+	if err != nil {
+		log.Panic(err)
+	}
+	return found
+}
+
+// hdAddress derives the next address of an account.
+func (cli *CommandLine) hdAddress(account int, change bool, nodeID string) {
+	if account < 0 {
+		log.Panic("account can't be negative")
+	}
+
+	wallets := loadWallets(nodeID)
+	address, err := wallets.NewHDAddress(uint32(account), change)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveFile(nodeID)
+
+	fmt.Printf("New address %s (%s)\n", address.Address, address.Path)
+}
+
+// hdWallet lists the HD addresses with their balances, after scanning the
+// chain for used ones when scan is set.
+func (cli *CommandLine) hdWallet(showMnemonic, scan bool, gap int, nodeID string) {
+	wallets := loadWallets(nodeID)
+	if wallets.HD == nil {
+		fmt.Println("No HD keychain, run createhd or restorehd first")
+		return
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	if scan {
+		found := discoverHD(chain, wallets, gap)
+		wallets.SaveFile(nodeID)
+		fmt.Printf("Found %d used addresses\n", len(found))
+	}
+	if showMnemonic {
+		fmt.Printf("Mnemonic: %s\n", wallets.HD.Mnemonic)
+	}
+	fmt.Printf("%s keychain\n", wallets.HD.Type)
+	printHDAddresses(chain, wallets)
+}
+
+func printHDAddresses(chain *blockchain.BlockChain, wallets *wallet.Wallets) {
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+
+	total := 0
+	for _, address := range wallets.HDAddresses() {
+		w := wallets.GetWallet(address.Address)
+		balance := UTXOSet.Balance(wallet.PublicKeyHash(w.PublicKey))
+		total += balance
+		fmt.Printf(" %-22s %s: %d\n", address.Path, address.Address, balance)
+	}
+	fmt.Printf("Total: %d\n", total)
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// createHouse creates the house bankroll wallet. It starts with the same
+// initial balance as any new wallet; send it more coins to take bigger bets.
+func (cli *CommandLine) createHouse(keyType wallet.KeyType, nodeID string) {
+	wallets := loadWallets(nodeID)
+	address := wallets.AddHouseWallet(keyType)
+	wallets.SaveFile(nodeID)
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+
+	cbTx := blockchain.CoinbaseTx(address, "House bankroll")
+	block := chain.MineBlock([]*blockchain.Transaction{cbTx})
+	UTXOSet.Update(block)
+
+	fmt.Printf("New house address is: %s with 100 initial bankroll\n", address)
+}
+
+func (cli *CommandLine) houseStatus(nodeID string) {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	if wallets.House == "" {
+		fmt.Println("No house wallet, run createhouse first")
+		return
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	report := blockchain.NewHouseReport(chain, wallets.House, games.MaxMultipliers())
+
+	fmt.Printf("House:    %s\n", report.Address)
+	fmt.Printf("Bankroll: %d\n", report.Bankroll)
+	fmt.Printf("Profit:   %d over %d bets (%d wagered, %d paid out)\n",
+		report.Profit, report.Games.Bets, report.Games.Wagered, report.Games.PaidOut)
+
+	var games []string
+	for game := range report.MaxBets {
+		games = append(games, game)
+	}
+	sort.Strings(games)
+	for _, game := range games {
+		fmt.Printf("Max %s bet: %d\n", game, report.MaxBets[game])
+	}
+}
+
+func (cli *CommandLine) jackpotStatus(nodeID string) {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	report := blockchain.NewJackpotReport(chain, wallets.Jackpot)
+	if report.Address == "" {
+		report.Address = "(created with the first bet)"
+	}
+
+	fmt.Printf("Jackpot: %s\n", report.Address)
+	fmt.Printf("Pool:    %d\n", report.Pool)
+	fmt.Printf("Share:   %.2f%% of every bet\n", report.Percent)
+	fmt.Printf("Hits:    %d, %d paid out\n", report.Hits, report.Paid)
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// buyTickets buys tickets in this node's lottery. The block holding the
+// purchase may draw a finished round, which the lottery watcher pays out.
+func (cli *CommandLine) buyTickets(from string, tickets int, commitment, nodeID string) {
+	if !wallet.ValidateAddress(from) {
+		log.Panic("Address is not Valid")
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	player := wallets.GetWallet(from)
+	lottery := wallets.LotteryWallet(nodeID)
+
+	if err := (games.Policies{Blockchain: chain}).Check(from, tickets); err != nil {
+		log.Panic(err)
+	}
+
+	if commitment == "" {
+		commitment = blockchain.RandomClientSeed()
+	}
+
+	tx := blockchain.NewLotteryTicketTransaction(&player, string(lottery.Address()), tickets, commitment, &UTXOSet)
+	block := chain.MineBlock([]*blockchain.Transaction{tx})
+
+	round := block.Height / blockchain.LotteryRoundBlocks()
+	fmt.Printf("Bought %d tickets for round %d in %x\n", tickets, round, tx.ID)
+	fmt.Printf("Commitment: %s\n", commitment)
+	fmt.Printf("Drawn at height %d\n", blockchain.LotteryDrawHeight(round))
+
+	UTXOSet.Update(block)
+}
+
+// lotteryRounds lists the rounds, or the tickets of one round.
+func (cli *CommandLine) lotteryRounds(round int, nodeID string) {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	lottery := blockchain.Lottery{Blockchain: chain}
+
+	if round >= 0 {
+		for _, ticket := range lottery.Tickets(round) {
+			fmt.Printf("%x %s %d tickets at height %d, commitment %s\n",
+				ticket.TxID, ticket.Player, ticket.Tickets, ticket.Height, ticket.Commitment)
+		}
+		for _, draw := range lottery.Draws() {
+			if draw.Draw.Round == round {
+				printDraw(draw)
+			}
+		}
+		return
+	}
+
+	address := wallets.Lottery
+	if address == "" {
+		address = "(created with the first ticket)"
+	}
+	fmt.Printf("Lottery: %s\n", address)
+	fmt.Printf("Rounds:  %d blocks each\n", blockchain.LotteryRoundBlocks())
+
+	for _, r := range lottery.Rounds() {
+		status := "open"
+		if r.Draw != nil {
+			status = fmt.Sprintf("won by %s", r.Draw.Winner)
+		} else if r.DrawHeight <= chain.GetBestHeight() && r.Tickets > 0 {
+			status = "awaiting payout"
+		}
+		fmt.Printf("Round %d (blocks %d-%d, drawn at %d): %d tickets from %d players, %s\n",
+			r.Round, r.StartHeight, r.EndHeight, r.DrawHeight, r.Tickets, r.Players, status)
+	}
+}
+
+func (cli *CommandLine) lotteryWinners(nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	for _, draw := range (blockchain.Lottery{Blockchain: chain}).Draws() {
+		printDraw(draw)
+	}
+}
+
+func printDraw(draw blockchain.IndexedDraw) {
+	fmt.Printf("Round %d: ticket %d of %d won by %s, %d coins paid in %x\n",
+		draw.Draw.Round, draw.Draw.WinningTicket, draw.Draw.Tickets, draw.Draw.Winner, draw.Draw.Pot, draw.TxID)
+	fmt.Printf("  draw block %d %s, seed %s\n", draw.Draw.DrawHeight, draw.Draw.DrawHash, draw.Draw.Seed)
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/network"
+	"github.com/ItsHotdogFred/blockchain/pool"
+)
+
+const templatePollInterval = 5 * time.Second
+
+// externalMiner runs a standalone mining process against a node's
+// template/submit API. Work is abandoned as soon as the node reports a new
+// tip, and solved blocks go through the node's normal block validation.
+func (cli *CommandLine) externalMiner(apiURL, address string, workers int) {
+	fmt.Printf("Mining for %s against %s\n", address, apiURL)
+
+	for {
+		template, err := network.FetchBlockTemplate(apiURL)
+		if err != nil {
+			fmt.Printf("Could not fetch template: %v\n", err)
+			time.Sleep(templatePollInterval)
+			continue
+		}
+
+		coinbase := blockchain.CoinbaseTx(address, "")
+		block := template.NewBlock(coinbase)
+		pow := blockchain.NewProof(block)
+		if workers > 0 {
+			pow.Workers = workers
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go watchTip(ctx, cancel, apiURL, template.PrevHash)
+
+		nonce, hash, stats, err := pow.RunContext(ctx)
+		cancel()
+		if err != nil {
+			fmt.Printf("Tip changed after %s, fetching new work\n", stats)
+			continue
+		}
+
+		block.Nonce = nonce
+		block.Hash = hash
+		fmt.Printf("Solved block %d %x: %s\n", block.Height, block.Hash, stats)
+
+		if err := network.SubmitSolvedBlock(apiURL, block); err != nil {
+			fmt.Printf("Block rejected: %v\n", err)
+		} else {
+			fmt.Println("Block accepted")
+		}
+	}
+}
+
+// watchTip cancels the current work once the node's template no longer
+// builds on prevHash.
+func watchTip(ctx context.Context, cancel context.CancelFunc, apiURL string, prevHash []byte) {
+	ticker := time.NewTicker(templatePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			template, err := network.FetchBlockTemplate(apiURL)
+			if err == nil && !bytes.Equal(template.PrevHash, prevHash) {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// startPool runs a Stratum pool server that gets its work from apiURL.
+func (cli *CommandLine) startPool(apiURL, poolAddress, listen string, shareBits, window int, fee float64) {
+	server := pool.NewServer(apiURL, poolAddress, shareBits, window, fee)
+
+	go func() {
+		for range time.Tick(30 * time.Second) {
+			for worker, stats := range server.Stats() {
+				fmt.Printf("  %s: %d accepted, %d rejected, %d blocks\n", worker, stats.Accepted, stats.Rejected, stats.Blocks)
+			}
+			fmt.Printf("  next block pays: %v\n", server.PendingPayouts())
+		}
+	}()
+
+	if err := server.ListenAndServe(listen); err != nil {
+		log.Panic(err)
+	}
+}
+
+// poolMiner runs the stand-in Stratum miner against a pool.
+func (cli *CommandLine) poolMiner(poolAddr, worker string, workers int) {
+	client := pool.NewClient(poolAddr, worker, workers)
+	if err := client.Run(context.Background()); err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("Disconnected: %d shares accepted, %d rejected\n", client.Accepted, client.Rejected)
+}
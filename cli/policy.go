@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/network"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// setPolicy signs a policy message with the address's key from this node's
+// wallet file. It is applied to this node's database, or posted to the API
+// at apiURL when given. Negative limits keep the current ones.
+func (cli *CommandLine) setPolicy(address string, lossLimit, wagerCap, coolOff int, excludeUntil, apiURL, nodeID string) {
+	if !wallet.ValidateAddress(address) {
+		log.Panic("Address is not Valid")
+	}
+
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	if _, ok := wallets.Wallets[address]; !ok {
+		log.Panicf("Wallet %s not found", address)
+	}
+	w := wallets.GetWallet(address)
+
+	message := games.PolicyMessage{
+		Address:      address,
+		CoolOffHours: coolOff,
+		ExcludeUntil: excludeUntil,
+		Timestamp:    time.Now().UnixMilli(),
+	}
+	if lossLimit >= 0 {
+		message.DailyLossLimit = &lossLimit
+	}
+	if wagerCap >= 0 {
+		message.WagerCap = &wagerCap
+	}
+	signed := games.SignPolicy(message, &w)
+
+	var status *games.PolicyStatus
+	if apiURL != "" {
+		status, err = network.SubmitPolicy(apiURL, signed)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	} else {
+		chain := blockchain.ContinueBlockChain(nodeID)
+		defer chain.Database.Close()
+
+		policies := games.Policies{Blockchain: chain}
+		if _, err := policies.Apply(signed); err != nil {
+			fmt.Println(err)
+			return
+		}
+		s := policies.Status(address)
+		status = &s
+	}
+
+	printPolicy(*status)
+}
+
+func (cli *CommandLine) showPolicy(address, nodeID string) {
+	if !wallet.ValidateAddress(address) {
+		log.Panic("Address is not Valid")
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	printPolicy(games.Policies{Blockchain: chain}.Status(address))
+}
+
+func printPolicy(status games.PolicyStatus) {
+	limit := func(value int) string {
+		if value == 0 {
+			return "none"
+		}
+		return fmt.Sprint(value)
+	}
+	until := func(unix int64) string {
+		if unix <= time.Now().Unix() {
+			return "no"
+		}
+		return "until " + time.Unix(unix, 0).UTC().Format(time.RFC3339)
+	}
+
+	fmt.Printf("Policy of %s\n", status.Address)
+	fmt.Printf("  daily loss limit %s, lost %d in the last 24 hours\n", limit(status.DailyLossLimit), status.Lost)
+	fmt.Printf("  wager cap %s, staked %d in the last 24 hours\n", limit(status.WagerCap), status.Wagered)
+	if status.Pending != nil {
+		fmt.Printf("  from %s: daily loss limit %s, wager cap %s\n", time.Unix(status.PendingAt, 0).UTC().Format(time.RFC3339),
+			limit(status.Pending.DailyLossLimit), limit(status.Pending.WagerCap))
+	}
+	fmt.Printf("  cooling off: %s\n", until(status.CoolOffUntil))
+	fmt.Printf("  self-excluded: %s\n", until(status.ExcludedUntil))
+}
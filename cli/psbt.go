@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/network"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// PSBTs go between machines as files of base64 text. createpsbt needs the
+// chain, signpsbt only the wallet file, so the house keys can stay on a
+// machine that is never online.
+
+func readPSBT(path string) *blockchain.PSBT {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Panic(err)
+	}
+	p, err := blockchain.ParsePSBT(string(data))
+	if err != nil {
+		log.Panicf("%s: %v", path, err)
+	}
+	return p
+}
+
+// writePSBT writes p to path, or prints it when path is empty.
+func writePSBT(p *blockchain.PSBT, path string) {
+	if path == "" {
+		fmt.Println(p.Encode())
+		return
+	}
+	if err := os.WriteFile(path, []byte(p.Encode()+"\n"), 0644); err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("PSBT written to %s\n", path)
+}
+
+// parseOutpoints reads a comma separated list of TXID:OUT.
+func parseOutpoints(list string) []blockchain.PrevOutput {
+	var outpoints []blockchain.PrevOutput
+	for _, outpoint := range splitList(list) {
+		parts := strings.Split(outpoint, ":")
+		if len(parts) != 2 {
+			log.Panicf("Outpoint %s is not TXID:OUT", outpoint)
+		}
+		txID, err := hex.DecodeString(parts[0])
+		if err != nil {
+			log.Panicf("Outpoint %s: %v", outpoint, err)
+		}
+		out, err := strconv.Atoi(parts[1])
+		if err != nil {
+			log.Panicf("Outpoint %s: %v", outpoint, err)
+		}
+		outpoints = append(outpoints, blockchain.PrevOutput{TxID: txID, Out: out})
+	}
+	return outpoints
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// describePSBT shows what a PSBT spends and pays, and how far it is signed.
+func describePSBT(p *blockchain.PSBT) {
+	fmt.Println("Inputs:")
+	for i, in := range p.Inputs {
+		var keys []string
+		for _, key := range in.Keys {
+			if key.Address != "" {
+				keys = append(keys, key.Address)
+			} else {
+				keys = append(keys, fmt.Sprintf("%x", key.PubKeyHash))
+			}
+		}
+		fmt.Printf("  %d: %x:%d, %d coins, %d of %d signatures, keys %s\n",
+			i, in.Prev.TxID, in.Prev.Out, in.Prev.Value, len(in.Signatures), p.Required(i), strings.Join(keys, ", "))
+	}
+	fmt.Println("Outputs:")
+	for i, out := range p.Tx.Outputs {
+		to := fmt.Sprintf("%x", out.PubKeyHash)
+		if i < len(p.Addresses) && p.Addresses[i] != "" {
+			to = p.Addresses[i]
+		} else if out.IsMultiSig() {
+			required, hashes := out.MultiSigKeys()
+			to = fmt.Sprintf("%d of %d multisig", required, len(hashes))
+		}
+		fmt.Printf("  %d: %d coins to %s\n", i, out.Value, to)
+	}
+}
+
+// createPSBT builds a PSBT on this node's chain. The word house in from
+// stands for the house address of the wallet file, whose keys need not be
+// here.
+func (cli *CommandLine) createPSBT(from, inputs, to, change string, amount int, out, nodeID string) {
+	wallets := loadWallets(nodeID)
+
+	var addresses []string
+	for _, address := range splitList(from) {
+		if address == "house" {
+			if wallets.House == "" {
+				log.Panic("No house wallet, run createhouse first")
+			}
+			address = wallets.House
+		}
+		addresses = append(addresses, address)
+	}
+	for _, address := range append(append([]string{to}, addresses...), splitList(change)...) {
+		if !wallet.ValidateAddress(address) {
+			log.Panicf("Address %s is not Valid", address)
+		}
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+
+	p, err := blockchain.NewFundedPSBT(parseOutpoints(inputs), addresses, to, change, amount, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+	// Name the keys of this wallet file, some may be multisig keys, and the
+	// paths of its HD addresses
+	hints := make(map[string]string)
+	for _, address := range wallets.GetAllAddresses() {
+		hints[address] = ""
+	}
+	if wallets.HD != nil {
+		for address, path := range wallets.HD.Paths {
+			hints[address] = path
+		}
+	}
+	p.AddKeyHints(hints)
+
+	if out != "" {
+		describePSBT(p)
+	}
+	writePSBT(p, out)
+}
+
+// signPSBT signs a PSBT with the keys of the wallet file, needing neither
+// the chain nor the network. With address set only that key signs.
+func (cli *CommandLine) signPSBT(in, out, address, nodeID string) {
+	p := readPSBT(in)
+	wallets := loadWallets(nodeID)
+	describePSBT(p)
+
+	signed := 0
+	for _, key := range p.Missing() {
+		if address != "" && key.Address != address && !hasHash(address, key.PubKeyHash) {
+			continue
+		}
+		w, ok := wallets.FindKey(key.PubKeyHash, key.Path)
+		if !ok {
+			continue
+		}
+		n := p.Sign(w.PrivateKey)
+		if n > 0 {
+			fmt.Printf("Signed %d inputs with %s\n", n, w.Address())
+		}
+		signed += n
+	}
+	if signed == 0 {
+		fmt.Println("No input left that a key of this wallet file can sign")
+		return
+	}
+
+	if out == "" {
+		out = in
+	}
+	writePSBT(p, out)
+	if p.Complete() {
+		fmt.Println("All inputs are signed, run finalizepsbt")
+	}
+}
+
+func hasHash(address string, pubKeyHash []byte) bool {
+	return wallet.ValidateAddress(address) &&
+		bytes.Equal(blockchain.NewTXOutput(0, address).PubKeyHash, pubKeyHash)
+}
+
+// combinePSBT merges the signatures of copies of a PSBT signed apart.
+func (cli *CommandLine) combinePSBT(ins, out string) {
+	paths := splitList(ins)
+	if len(paths) < 2 {
+		log.Panic("Give at least two PSBTs to combine")
+	}
+
+	p := readPSBT(paths[0])
+	for _, path := range paths[1:] {
+		added, err := p.Combine(readPSBT(path))
+		if err != nil {
+			log.Panicf("%s: %v", path, err)
+		}
+		fmt.Printf("Added %d signatures from %s\n", added, path)
+	}
+
+	writePSBT(p, out)
+	if p.Complete() {
+		fmt.Println("All inputs are signed, run finalizepsbt")
+	}
+}
+
+// finalizePSBT turns a fully signed PSBT into a transaction and submits it
+// to the node at apiURL, or prints it for /tx/submit when apiURL is empty.
+func (cli *CommandLine) finalizePSBT(in, apiURL string) {
+	p := readPSBT(in)
+	tx, err := p.Finalize()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if apiURL == "" {
+		fmt.Printf("Transaction %x:\n%x\n", tx.ID, tx.Serialize())
+		return
+	}
+	block, err := network.SubmitSignedTransaction(apiURL, tx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Transaction %x mined in block %s\n", tx.ID, block)
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/network"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// sendRaw sends coins through a node that doesn't hold the key: the node
+// at apiURL builds the transaction, it is checked and signed here with the
+// key of this wallet file, and handed back signed.
+func (cli *CommandLine) sendRaw(from, to string, amount int, apiURL, nodeID string) {
+	if !wallet.ValidateAddress(from) || !wallet.ValidateAddress(to) {
+		log.Panic("Address is not Valid")
+	}
+
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	if _, ok := wallets.Wallets[from]; !ok {
+		log.Panicf("Wallet %s not found", from)
+	}
+	w := wallets.GetWallet(from)
+
+	unsigned, err := network.FetchUnsignedTransaction(apiURL, network.BuildTxRequest{
+		PubKey: fmt.Sprintf("%x", w.PublicKey),
+		To:     to,
+		Amount: amount,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	tx, prevOuts, err := unsigned.Transaction()
+	if err != nil {
+		log.Panic(err)
+	}
+	if err := checkUnsigned(tx, &w, to, amount); err != nil {
+		log.Panicf("The node built a different transaction: %v", err)
+	}
+
+	signed, err := tx.SignPrevOutputs(w.PrivateKey, prevOuts)
+	if err != nil {
+		log.Panic(err)
+	}
+	if signed != len(tx.Inputs) {
+		log.Panicf("Signed %d of %d inputs", signed, len(tx.Inputs))
+	}
+
+	block, err := network.SubmitSignedTransaction(apiURL, tx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Sent %d to %s in transaction %x, block %s\n", amount, to, tx.ID, block)
+}
+
+// checkUnsigned makes sure a transaction built by a node only spends coins
+// of w and pays amount to the address to, the rest back to w.
+func checkUnsigned(tx *blockchain.Transaction, w *wallet.Wallet, to string, amount int) error {
+	if !bytes.Equal(tx.ID, tx.UnsignedHash()) {
+		return fmt.Errorf("wrong id %x", tx.ID)
+	}
+	for i, in := range tx.Inputs {
+		if !bytes.Equal(in.PubKey, w.PublicKey) {
+			return fmt.Errorf("input %d isn't ours", i)
+		}
+	}
+
+	own := wallet.PublicKeyHash(w.PublicKey)
+	recipient := blockchain.NewTXOutput(0, to).PubKeyHash
+	paid := 0
+	for i, out := range tx.Outputs {
+		switch {
+		case bytes.Equal(out.PubKeyHash, recipient):
+			paid += out.Value
+		case !bytes.Equal(out.PubKeyHash, own):
+			return fmt.Errorf("output %d pays someone else", i)
+		}
+	}
+	if paid != amount && !bytes.Equal(recipient, own) {
+		return fmt.Errorf("it pays %d, not %d", paid, amount)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/ItsHotdogFred/blockchain/games"
+)
+
+// gameParamFlags defines a string flag for every parameter of every game,
+// so one command can take the parameters of any game.
+func gameParamFlags(flags *flag.FlagSet) map[string]*string {
+	params := make(map[string]*string)
+	for _, game := range games.All() {
+		for _, param := range game.Info().Params {
+			if _, ok := params[param.Name]; !ok {
+				params[param.Name] = flags.String(param.Name, "", param.Description+" ("+game.Info().Name+")")
+			}
+		}
+	}
+	return params
+}
+
+// gameParams collects the parameters of game set with gameParamFlags.
+func gameParams(game games.Game, flags map[string]*string) games.Params {
+	params := games.Params{}
+	for _, param := range game.Info().Params {
+		if value := *flags[param.Name]; value != "" {
+			params[param.Name] = value
+		}
+	}
+	return params
+}
+
+// simulate plays rounds bets of a game offline and compares the measured
+// returns with the odds.
+func (cli *CommandLine) simulate(name string, rounds, amount int, seed int64, rng string, flags map[string]*string) {
+	game, ok := games.Get(name)
+	if !ok {
+		fmt.Printf("Unknown game %q, see the games command\n", name)
+		return
+	}
+
+	report, err := games.Simulate(game, rounds, amount, gameParams(game, flags), seed, rng)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("%s: %d rounds of %d with the %s RNG, seed %d\n", report.Game, report.Rounds, report.Bet, report.RNG, report.Seed)
+	fmt.Printf("Wagered %d, paid out %d, %d wins\n", report.Wagered, report.PaidOut, report.Wins)
+	fmt.Printf("Win rate: %.4f%% measured, %s expected, %s advertised\n", report.WinRate, exact(report.ExpectedWinRate), exact(report.AdvertisedWinRate))
+	fmt.Printf("RTP:      %.4f%% measured, %s expected, %.4f%% advertised (%+.2f standard errors)\n", report.RTP, exact(report.ExpectedRTP), report.AdvertisedRTP, report.RTPDeviation)
+	fmt.Printf("Variance: %.4f measured, %s expected, per round as a multiple of the bet\n", report.Variance, exactValue(report.ExpectedVariance))
+
+	if report.Freedom > 0 {
+		var outcomes []int
+		for outcome := range report.Outcomes {
+			outcomes = append(outcomes, outcome)
+		}
+		sort.Ints(outcomes)
+
+		low, high := outcomes[0], outcomes[0]
+		for _, outcome := range outcomes {
+			if report.Outcomes[outcome] < report.Outcomes[low] {
+				low = outcome
+			}
+			if report.Outcomes[outcome] > report.Outcomes[high] {
+				high = outcome
+			}
+		}
+		fmt.Printf("Outcomes: %d distinct, least drawn %d (%d times), most drawn %d (%d times)\n",
+			len(outcomes), low, report.Outcomes[low], high, report.Outcomes[high])
+		fmt.Printf("Bias:     chi-square %.2f with %d degrees of freedom, z %+.2f\n", report.ChiSquare, report.Freedom, report.BiasZ)
+	}
+
+	if report.Suspicious() {
+		fmt.Println("SUSPICIOUS: the results are further off the odds than chance explains")
+	} else {
+		fmt.Println("OK: the results match the odds")
+	}
+}
+
+func exact(percent float64) string {
+	if percent == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.4f%%", percent)
+}
+
+func exactValue(value float64) string {
+	if value == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.4f", value)
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ItsHotdogFred/blockchain/games"
+)
+
+// printGrid prints the symbols shown by a game like slots, if it has any.
+func printGrid(game games.Game, outcome int) {
+	g, ok := game.(games.GridGame)
+	if !ok {
+		return
+	}
+	for _, row := range g.Grid(outcome) {
+		fmt.Printf("  | %s |\n", strings.Join(row, " | "))
+	}
+}
+
+// slotsRTP proves the return of the slot machine in use from its reels.
+func (cli *CommandLine) slotsRTP() {
+	m := games.CurrentSlotMachine()
+	report := games.SlotsRTP(m)
+
+	fmt.Printf("%d reels of %v stops, %d rows, %d paylines: %d stop combinations\n",
+		report.Reels, report.Stops, m.Rows, report.Lines, report.Combinations)
+	for _, pay := range report.Pays {
+		fmt.Printf("  %d %-10s pays %4dx the line bet, %10d combinations, %8.4f%% of lines, RTP %7.4f%%\n",
+			pay.Pay.Count, pay.Pay.Symbol, pay.Pay.Multiplier, pay.Combinations, pay.Probability, pay.RTP)
+	}
+	fmt.Printf("Hit rate %.4f%% per line, a spin pays at most %dx the line bet\n", report.HitRate, report.MaxSpinPay)
+	fmt.Printf("RTP %.4f%% (%d / %d), house edge %.4f%%\n", report.RTP, report.WeightedPays, report.Combinations, report.HouseEdge)
+	if report.Enumerated {
+		fmt.Printf("Spinning every stop combination on all lines returns %.4f%%\n", report.EnumeratedRTP)
+	} else {
+		fmt.Println("Too many stop combinations to spin them all")
+	}
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// stats prints the statistics of address, or of the house when it is
+// empty.
+func (cli *CommandLine) stats(address, nodeID string) {
+	if address != "" && !wallet.ValidateAddress(address) {
+		log.Panic("Address is not Valid")
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	stats := blockchain.Stats{Blockchain: chain}
+	if address == "" {
+		printHouseStats(stats.House())
+		return
+	}
+
+	player := stats.Player(address)
+	fmt.Printf("Statistics of %s\n", address)
+	printBetStats("all games", player.Total)
+	for _, game := range sortedKeys(player.Games) {
+		printBetStats(game, player.Games[game])
+	}
+}
+
+func printBetStats(name string, s blockchain.BetStats) {
+	fmt.Printf("  %s: %d bets, %d wins, %d losses, %d pushes, wagered %d, net %+d\n",
+		name, s.Bets, s.Wins, s.Losses, s.Pushes, s.Wagered, s.Net)
+	if s.Bets > 0 {
+		fmt.Printf("    biggest win %d, biggest loss %d, streak %+d, longest %d wins and %d losses\n",
+			s.BiggestWin, s.BiggestLoss, s.Streak, s.LongestWinStreak, s.LongestLossStreak)
+	}
+}
+
+func printHouseStats(h blockchain.HouseStats) {
+	fmt.Printf("House statistics at height %d, %d players\n", h.Height, h.Players)
+	printHouseGameStats("all games", h.Total)
+	for _, game := range sortedKeys(h.Games) {
+		printHouseGameStats(game, h.Games[game])
+	}
+	fmt.Printf("  jackpot: %d hits, %d paid\n", h.JackpotHits, h.JackpotPaid)
+	fmt.Printf("  wagers between players: %d, %d coins\n", h.PeerWagers, h.PeerWagerVolume)
+}
+
+func printHouseGameStats(name string, s blockchain.HouseGameStats) {
+	fmt.Printf("  %s: %d bets, wagered %d, paid out %d, profit %+d, biggest payout %d, edge %.2f%% realised, %.2f%% expected\n",
+		name, s.Bets, s.Wagered, s.PaidOut, s.Profit, s.BiggestPayout, s.HouseEdge*100, s.ExpectedEdge*100)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
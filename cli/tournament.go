@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+func tournamentBook(chain *blockchain.BlockChain, nodeID string) games.TournamentBook {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return games.TournamentBook{Blockchain: chain, Wallets: wallets, NodeID: nodeID}
+}
+
+// parsePrizes reads percentages like "50,30,20", nil when empty.
+func parsePrizes(text string) ([]int, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	var prizes []int
+	for _, field := range strings.Split(text, ",") {
+		prize, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("prizes must be comma separated percentages, like 50,30,20")
+		}
+		prizes = append(prizes, prize)
+	}
+	return prizes, nil
+}
+
+func printTournament(t *games.Tournament, height int) {
+	fmt.Printf("Tournament: %s (%s)\n", t.ID, t.Status)
+	fmt.Printf("Game:       %s, %d chips for an entry fee of %d\n", t.Game, t.Chips, t.EntryFee)
+	fmt.Printf("Pool:       %d from %d entries, prizes %v%%\n", t.Pool(), len(t.Entries), t.Prizes)
+	if t.Status == games.TournamentOpen {
+		fmt.Printf("Ends:       at height %d, now %d\n", t.EndHeight, height)
+	}
+
+	if t.Status == games.TournamentFinished {
+		for _, place := range t.Results {
+			fmt.Printf(" %d. %s %d chips, won %d\n", place.Place, place.Player, place.Chips, place.Prize)
+		}
+		if t.PayoutTx != nil {
+			fmt.Printf("Paid in %s\n", hex.EncodeToString(t.PayoutTx))
+		}
+		return
+	}
+	for i, entry := range t.Leaderboard() {
+		fmt.Printf(" %d. %s %d chips, %d bets\n", i+1, entry.Player, entry.Chips, entry.Bets)
+	}
+}
+
+func (cli *CommandLine) createTournament(game string, fee, chips, blocks int, prizes, nodeID string) {
+	shares, err := parsePrizes(prizes)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	tournament, err := tournamentBook(chain, nodeID).Create(game, fee, chips, blocks, shares)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printTournament(tournament, chain.GetBestHeight())
+}
+
+func (cli *CommandLine) joinTournament(id, from, nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	tournament, err := tournamentBook(chain, nodeID).Register(id, from)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printTournament(tournament, chain.GetBestHeight())
+}
+
+func (cli *CommandLine) tournamentBet(id, from string, amount int, clientSeed string, nonce int, flags map[string]*string, nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	book := tournamentBook(chain, nodeID)
+	tournament, err := book.Tournament(id)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	game, ok := games.Get(tournament.Game)
+	if !ok {
+		fmt.Printf("Unknown game %q\n", tournament.Game)
+		return
+	}
+
+	tournament, bet, err := book.Play(id, from, amount, gameParams(game, flags), clientSeed, nonce)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("%s bet %d chips: outcome %d, paid %d\n", game.Info().Title, bet.Amount, bet.Outcome, bet.Payout)
+	fmt.Printf("Seed hash %s, client seed %s, nonce %d\n", bet.ServerSeedHash, bet.ClientSeed, bet.Nonce)
+	for _, entry := range tournament.Entries {
+		if entry.Player == from {
+			fmt.Printf("Chips: %d\n", entry.Chips)
+		}
+	}
+}
+
+// tournaments lists the tournaments, or shows the leaderboard or results of
+// one.
+func (cli *CommandLine) tournaments(id, nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	book := tournamentBook(chain, nodeID)
+	if id != "" {
+		tournament, err := book.Tournament(id)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		printTournament(tournament, chain.GetBestHeight())
+		return
+	}
+
+	for _, t := range book.Tournaments() {
+		fmt.Printf("%s %s, fee %d, %d entries, ends at height %d (%s)\n", t.ID, t.Game, t.EntryFee, len(t.Entries), t.EndHeight, t.Status)
+	}
+}
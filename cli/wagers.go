@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+func wagerBook(chain *blockchain.BlockChain, nodeID string) games.WagerBook {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return games.WagerBook{Blockchain: chain, Wallets: wallets, NodeID: nodeID}
+}
+
+// wagerSecret picks a random secret when none is given and returns it with
+// its commitment.
+func wagerSecret(secret string) (string, string) {
+	if secret == "" {
+		secret = blockchain.RandomClientSeed()
+	}
+	return secret, blockchain.WagerCommitment(secret)
+}
+
+func printWager(state games.WagerState) {
+	opponent := state.PlayerB
+	if opponent == "" {
+		opponent = "(anyone)"
+	}
+
+	fmt.Printf("Wager:   %s (%s)\n", state.ID, state.Status)
+	fmt.Printf("Players: %s vs %s, %d coins each\n", state.PlayerA, opponent, state.Stake)
+	if state.FundTx != "" {
+		fmt.Printf("Pot:     %d in %s, refundable from height %d\n", state.Pot, state.FundTx, state.Timeout)
+		fmt.Printf("Reveals: A %t, B %t\n", state.RevealedA, state.RevealedB)
+	}
+	switch state.Status {
+	case games.WagerSettled:
+		fmt.Printf("Winner:  %s, paid in %s\n", state.Winner, state.CloseTx)
+	case games.WagerRefunded:
+		fmt.Printf("Refunded in %s\n", state.CloseTx)
+	}
+}
+
+func (cli *CommandLine) createWager(from, opponent string, amount int, secret, nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	secret, commitment := wagerSecret(secret)
+	wager, err := wagerBook(chain, nodeID).Create(from, opponent, amount, commitment)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printWager(wager.State())
+	fmt.Printf("Secret:  %s (keep it, you need it to reveal)\n", secret)
+}
+
+func (cli *CommandLine) acceptWager(id, from, secret, nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	secret, commitment := wagerSecret(secret)
+	wager, err := wagerBook(chain, nodeID).Accept(id, from, commitment)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printWager(wager.State())
+	fmt.Printf("Secret:  %s (keep it, you need it to reveal)\n", secret)
+}
+
+func (cli *CommandLine) revealWager(id, from, secret, nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	wager, err := wagerBook(chain, nodeID).Reveal(id, from, secret)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printWager(wager.State())
+}
+
+func (cli *CommandLine) refundWager(id, from, nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	wager, err := wagerBook(chain, nodeID).Refund(id, from)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printWager(wager.State())
+}
+
+func (cli *CommandLine) cancelWager(id, from, nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	wager, err := wagerBook(chain, nodeID).Cancel(id, from)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printWager(wager.State())
+}
+
+func (cli *CommandLine) listWagers(address, nodeID string) {
+	chain := blockchain.ContinueBlockChain(nodeID)
+	defer chain.Database.Close()
+
+	for _, wager := range wagerBook(chain, nodeID).Wagers(address) {
+		state := wager.State()
+		opponent := state.PlayerB
+		if opponent == "" {
+			opponent = "(anyone)"
+		}
+		status := state.Status
+		if state.Status == games.WagerSettled {
+			status += ", won by " + state.Winner
+		}
+		fmt.Printf("%s %s vs %s, %d each (%s)\n", state.ID, state.PlayerA, opponent, state.Stake, status)
+	}
+}
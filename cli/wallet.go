@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/ItsHotdogFred/blockchain/network"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// loadWallets loads the wallet file for commands that start one when
+// there is none. Any other error, a wrong passphrase say, is fatal.
+func loadWallets(nodeID string) *wallet.Wallets {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil && !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+	return wallets
+}
+
+// readPassphrase asks for a passphrase on the terminal without echoing it,
+// or reads a line from stdin when it isn't a terminal.
+func readPassphrase(label string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s: ", label)
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		passphrase, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(passphrase), err
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// walletPassphrase is the passphrase of an encrypted wallet file, from the
+// WALLET_PASSPHRASE env when it is set.
+func walletPassphrase() (string, error) {
+	if passphrase := os.Getenv("WALLET_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	return readPassphrase("Wallet passphrase")
+}
+
+// newPassphrase asks for a new passphrase twice.
+func newPassphrase() string {
+	passphrase, err := readPassphrase("New passphrase")
+	if err != nil {
+		log.Panic(err)
+	}
+	again, err := readPassphrase("Repeat the new passphrase")
+	if err != nil {
+		log.Panic(err)
+	}
+	if passphrase != again {
+		log.Panic(errors.New("the passphrases don't match"))
+	}
+	return passphrase
+}
+
+func (cli *CommandLine) encryptWallet(nodeID string) {
+	if encrypted, _, _ := wallet.WalletStatus(nodeID); encrypted {
+		fmt.Println("The wallet file is already encrypted, use changepassphrase")
+		return
+	}
+	if err := wallet.EncryptWallet(nodeID, newPassphrase()); err != nil {
+		log.Panic(err)
+	}
+	fmt.Println("Wallet file encrypted. Keep the passphrase safe, the keys can't be read without it")
+}
+
+func (cli *CommandLine) changePassphrase(nodeID string) {
+	passphrase, err := readPassphrase("Current passphrase")
+	if err != nil {
+		log.Panic(err)
+	}
+	if err := wallet.Unlock(nodeID, passphrase, 0); err != nil {
+		log.Panic(err)
+	}
+	if err := wallet.ChangePassphrase(nodeID, passphrase, newPassphrase()); err != nil {
+		log.Panic(err)
+	}
+	fmt.Println("Passphrase changed")
+}
+
+// walletUnlock unlocks the wallet file of the node serving the API at
+// apiURL for timeout seconds.
+func (cli *CommandLine) walletUnlock(apiURL string, timeout int) {
+	passphrase, err := walletPassphrase()
+	if err != nil {
+		log.Panic(err)
+	}
+	status, err := network.SubmitWalletUnlock(apiURL, passphrase, timeout)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printWalletStatus(*status)
+}
+
+func (cli *CommandLine) walletLock(apiURL string) {
+	status, err := network.SubmitWalletLock(apiURL)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printWalletStatus(*status)
+}
+
+func printWalletStatus(status network.WalletStatusResponse) {
+	switch {
+	case !status.Encrypted:
+		fmt.Println("Wallet file is not encrypted")
+	case status.Locked:
+		fmt.Println("Wallet is locked")
+	case status.Until != "":
+		fmt.Printf("Wallet is unlocked until %s\n", status.Until)
+	default:
+		fmt.Println("Wallet is unlocked")
+	}
+}
@@ -0,0 +1,415 @@
+package games
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+)
+
+// Blackjack is played over several requests, so unlike the registered games
+// it doesn't settle in one transaction. Every stake goes into escrow (see
+// blockchain/escrow.go), the cards come from a shoe shuffled by the bet's
+// provably fair roll, and a settlement transaction pays out once all hands
+// are played.
+//
+// Rules: six decks, dealer stands on all 17s and peeks for blackjack, which
+// pays 3:2. Double on any two cards, split pairs up to four hands, split aces
+// get one card each.
+
+const (
+	blackjackDecks    = 6
+	blackjackMaxHands = 4
+)
+
+// blackjackPayouts states the odds of blackjack for records and the game
+// configuration. Only the limits of blackjack can be configured.
+var blackjackPayouts = []Payout{{Bet: "hand", Multiplier: 2, WinOutcomes: 199, Outcomes: 400}}
+
+// BlackjackTimeout is how long a session waits for the player. After that
+// the remaining hands stand.
+var BlackjackTimeout = 5 * time.Minute
+
+// Card is one card of the shoe: rank Ace to King, four suits.
+type Card int
+
+func (c Card) Rank() int { return int(c)%13 + 1 }
+
+func (c Card) Value() int {
+	if c.Rank() > 10 {
+		return 10
+	}
+	return c.Rank()
+}
+
+func (c Card) String() string {
+	ranks := []string{"A", "2", "3", "4", "5", "6", "7", "8", "9", "10", "J", "Q", "K"}
+	suits := []string{"♠", "♥", "♦", "♣"}
+	return ranks[c.Rank()-1] + suits[int(c)/13%4]
+}
+
+// NewShoe shuffles the decks with a Fisher-Yates shuffle driven by rng, so
+// the whole shoe can be recomputed from the revealed seeds.
+func NewShoe(rng RNG) []Card {
+	shoe := make([]Card, 52*blackjackDecks)
+	for i := range shoe {
+		shoe[i] = Card(i % 52)
+	}
+	for i := len(shoe) - 1; i > 0; i-- {
+		j := rng.Intn(i + 1)
+		shoe[i], shoe[j] = shoe[j], shoe[i]
+	}
+	return shoe
+}
+
+// handTotal counts an ace as 11 when that doesn't bust the hand.
+func handTotal(cards []Card) int {
+	total, aces := 0, 0
+	for _, c := range cards {
+		total += c.Value()
+		if c.Rank() == 1 {
+			aces++
+		}
+	}
+	if aces > 0 && total+10 <= 21 {
+		total += 10
+	}
+	return total
+}
+
+func cardString(cards []Card) string {
+	var s []string
+	for _, c := range cards {
+		s = append(s, c.String())
+	}
+	return strings.Join(s, " ")
+}
+
+type BlackjackHand struct {
+	Cards   []Card
+	Stake   int
+	Doubled bool
+	// Split hands can't be a blackjack
+	Split bool
+	Done  bool
+}
+
+func (h BlackjackHand) blackjack() bool {
+	return !h.Split && len(h.Cards) == 2 && handTotal(h.Cards) == 21
+}
+
+// BlackjackSession is the state of one game, stored in the chain database
+// until it settles and kept afterwards for the record.
+type BlackjackSession struct {
+	ID     string
+	Player string
+	House  string
+	Hands  []BlackjackHand
+	// Active is the hand waiting for a decision
+	Active int
+	Dealer []Card
+	// Drawn is the number of cards dealt from the shoe
+	Drawn   int
+	Actions []string
+
+	ServerSeedHash []byte
+	ClientSeed     string
+	Nonce          int
+
+	Escrow     []blockchain.EscrowOutput
+	Started    int64
+	LastAction int64
+
+	Settled  bool
+	Payout   int
+	SettleTx []byte
+}
+
+func (s *BlackjackSession) draw(shoe []Card) Card {
+	card := shoe[s.Drawn]
+	s.Drawn++
+	return card
+}
+
+func (s *BlackjackSession) deal(shoe []Card, bet int) {
+	hand := BlackjackHand{Stake: bet}
+	hand.Cards = append(hand.Cards, s.draw(shoe))
+	s.Dealer = append(s.Dealer, s.draw(shoe))
+	hand.Cards = append(hand.Cards, s.draw(shoe))
+	s.Dealer = append(s.Dealer, s.draw(shoe))
+	s.Hands = []BlackjackHand{hand}
+
+	// The dealer peeks, a blackjack on either side ends the game
+	if hand.blackjack() || handTotal(s.Dealer) == 21 {
+		s.Hands[0].Done = true
+	}
+	s.advance(shoe)
+}
+
+// advance moves on to the next hand that needs a decision, giving split
+// hands their second card on the way.
+func (s *BlackjackSession) advance(shoe []Card) {
+	for s.Active < len(s.Hands) {
+		hand := &s.Hands[s.Active]
+		if len(hand.Cards) == 1 {
+			hand.Cards = append(hand.Cards, s.draw(shoe))
+			if hand.Cards[0].Rank() == 1 {
+				hand.Done = true
+			}
+		}
+		if handTotal(hand.Cards) >= 21 {
+			hand.Done = true
+		}
+		if !hand.Done {
+			return
+		}
+		s.Active++
+	}
+}
+
+// Finished reports whether every hand has been played.
+func (s *BlackjackSession) Finished() bool {
+	return s.Active >= len(s.Hands)
+}
+
+// Options lists the actions the active hand allows.
+func (s *BlackjackSession) Options() []string {
+	if s.Settled || s.Finished() {
+		return nil
+	}
+
+	options := []string{"hit", "stand"}
+	hand := s.Hands[s.Active]
+	if len(hand.Cards) == 2 {
+		options = append(options, "double")
+		if hand.Cards[0].Rank() == hand.Cards[1].Rank() && len(s.Hands) < blackjackMaxHands {
+			options = append(options, "split")
+		}
+	}
+	return options
+}
+
+// Stake is what an action adds to the bet, 0 for hit and stand.
+func (s *BlackjackSession) Stake(action string) int {
+	if action == "double" || action == "split" {
+		return s.Hands[s.Active].Stake
+	}
+	return 0
+}
+
+// Allows checks that action is one of the options.
+func (s *BlackjackSession) Allows(action string) error {
+	for _, option := range s.Options() {
+		if option == action {
+			return nil
+		}
+	}
+	if s.Settled || s.Finished() {
+		return errSessionSettled
+	}
+	return fmt.Errorf("can't %s now, options are %s", action, strings.Join(s.Options(), ", "))
+}
+
+// play applies an action to the active hand. Stakes for double and split
+// must already be in escrow.
+func (s *BlackjackSession) play(shoe []Card, action string) error {
+	if err := s.Allows(action); err != nil {
+		return err
+	}
+
+	hand := &s.Hands[s.Active]
+	switch action {
+	case "hit":
+		hand.Cards = append(hand.Cards, s.draw(shoe))
+	case "stand":
+		hand.Done = true
+	case "double":
+		hand.Stake *= 2
+		hand.Doubled = true
+		hand.Cards = append(hand.Cards, s.draw(shoe))
+		hand.Done = true
+	case "split":
+		first := BlackjackHand{Cards: []Card{hand.Cards[0]}, Stake: hand.Stake, Split: true}
+		second := BlackjackHand{Cards: []Card{hand.Cards[1]}, Stake: hand.Stake, Split: true}
+		hands := append([]BlackjackHand{}, s.Hands[:s.Active]...)
+		hands = append(hands, first, second)
+		s.Hands = append(hands, s.Hands[s.Active+1:]...)
+	}
+
+	s.Actions = append(s.Actions, action)
+	s.advance(shoe)
+	if s.Finished() {
+		s.dealerPlay(shoe)
+	}
+	return nil
+}
+
+// standAll is the default action of a session that timed out.
+func (s *BlackjackSession) standAll(shoe []Card) {
+	for !s.Finished() {
+		s.Hands[s.Active].Done = true
+		s.Actions = append(s.Actions, "stand")
+		s.advance(shoe)
+	}
+	s.dealerPlay(shoe)
+}
+
+// dealerPlay draws to 17 unless the hands are already decided.
+func (s *BlackjackSession) dealerPlay(shoe []Card) {
+	if handTotal(s.Dealer) == 21 && len(s.Dealer) == 2 || len(s.Hands) == 1 && s.Hands[0].blackjack() {
+		return
+	}
+
+	live := false
+	for _, hand := range s.Hands {
+		live = live || handTotal(hand.Cards) <= 21
+	}
+	for live && handTotal(s.Dealer) < 17 {
+		s.Dealer = append(s.Dealer, s.draw(shoe))
+	}
+}
+
+// handPayout is what a finished hand returns, stake included.
+func (s *BlackjackSession) handPayout(hand BlackjackHand) int {
+	player, dealer := handTotal(hand.Cards), handTotal(s.Dealer)
+	dealerBlackjack := len(s.Dealer) == 2 && dealer == 21
+
+	switch {
+	case hand.blackjack() && dealerBlackjack:
+		return hand.Stake
+	case hand.blackjack():
+		return hand.Stake + hand.Stake*3/2
+	case player > 21, dealerBlackjack:
+		return 0
+	case dealer > 21, player > dealer:
+		return 2 * hand.Stake
+	case player == dealer:
+		return hand.Stake
+	}
+	return 0
+}
+
+func (s *BlackjackSession) totalStake() int {
+	total := 0
+	for _, hand := range s.Hands {
+		total += hand.Stake
+	}
+	return total
+}
+
+// record describes the finished game for the settlement transaction. Params
+// hold the actions and cards, so the game can be replayed from the shoe once
+// the server seed is revealed.
+func (s *BlackjackSession) record() blockchain.GameRecord {
+	var hands []string
+	payout := 0
+	for _, hand := range s.Hands {
+		hands = append(hands, cardString(hand.Cards))
+		payout += s.handPayout(hand)
+	}
+
+	return blockchain.GameRecord{
+		Game:    "blackjack",
+		Player:  s.Player,
+		House:   s.House,
+		Bet:     s.totalStake(),
+		Payout:  payout,
+		Outcome: handTotal(s.Dealer),
+		Params: map[string]string{
+			"actions": strings.Join(s.Actions, ","),
+			"hands":   strings.Join(hands, " | "),
+			"dealer":  cardString(s.Dealer),
+		},
+		// Blackjack has no single win probability. These odds state the
+		// usual return of basic strategy under these rules, about 99.5%.
+		Multiplier:  blackjackPayouts[0].Multiplier,
+		WinOutcomes: blackjackPayouts[0].WinOutcomes,
+		Outcomes:    blackjackPayouts[0].Outcomes,
+
+		ServerSeedHash: hex.EncodeToString(s.ServerSeedHash),
+		ClientSeed:     s.ClientSeed,
+		Nonce:          s.Nonce,
+	}
+}
+
+// BlackjackHandState is a hand as the player sees it.
+type BlackjackHandState struct {
+	Cards   []string `json:"cards"`
+	Total   int      `json:"total"`
+	Stake   int      `json:"stake"`
+	Doubled bool     `json:"doubled,omitempty"`
+	Done    bool     `json:"done"`
+	Payout  *int     `json:"payout,omitempty"`
+}
+
+// BlackjackState is the public view of a session. The dealer's hole card
+// stays hidden until the hands are played.
+type BlackjackState struct {
+	ID          string               `json:"id"`
+	Player      string               `json:"player"`
+	Hands       []BlackjackHandState `json:"hands"`
+	Active      int                  `json:"active"`
+	Dealer      []string             `json:"dealer"`
+	DealerTotal int                  `json:"dealerTotal"`
+	Actions     []string             `json:"actions"`
+	Options     []string             `json:"options"`
+	Expires     int64                `json:"expires,omitempty"`
+
+	ServerSeedHash string `json:"serverSeedHash"`
+	ClientSeed     string `json:"clientSeed"`
+	Nonce          int    `json:"nonce"`
+
+	Settled  bool   `json:"settled"`
+	Bet      int    `json:"bet"`
+	Payout   int    `json:"payout"`
+	SettleTx string `json:"settleTx,omitempty"`
+}
+
+func (s *BlackjackSession) State() BlackjackState {
+	state := BlackjackState{
+		ID:             s.ID,
+		Player:         s.Player,
+		Active:         s.Active,
+		Actions:        append([]string{}, s.Actions...),
+		Options:        append([]string{}, s.Options()...),
+		ServerSeedHash: hex.EncodeToString(s.ServerSeedHash),
+		ClientSeed:     s.ClientSeed,
+		Nonce:          s.Nonce,
+		Settled:        s.Settled,
+		Bet:            s.totalStake(),
+		Payout:         s.Payout,
+	}
+
+	for _, hand := range s.Hands {
+		view := BlackjackHandState{
+			Cards:   strings.Fields(cardString(hand.Cards)),
+			Total:   handTotal(hand.Cards),
+			Stake:   hand.Stake,
+			Doubled: hand.Doubled,
+			Done:    hand.Done,
+		}
+		if s.Settled {
+			payout := s.handPayout(hand)
+			view.Payout = &payout
+		}
+		state.Hands = append(state.Hands, view)
+	}
+
+	dealer := s.Dealer
+	if !s.Settled {
+		dealer = dealer[:1]
+		state.Expires = time.Unix(s.LastAction, 0).Add(BlackjackTimeout).Unix()
+	}
+	state.Dealer = strings.Fields(cardString(dealer))
+	state.DealerTotal = handTotal(dealer)
+	if s.SettleTx != nil {
+		state.SettleTx = hex.EncodeToString(s.SettleTx)
+	}
+
+	return state
+}
+
+var errSessionSettled = errors.New("this blackjack session is already over")
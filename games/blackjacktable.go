@@ -0,0 +1,277 @@
+package games
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+var (
+	blackjackPrefix = []byte("blackjack-")
+	blackjackMu     sync.Mutex
+)
+
+// BlackjackTable runs blackjack sessions against the node's house, keeping
+// them in the chain database so they survive restarts. The transaction
+// constructors it calls panic when funds are missing, like Play.
+type BlackjackTable struct {
+	Blockchain *blockchain.BlockChain
+	Wallets    *wallet.Wallets
+	NodeID     string
+}
+
+func blackjackKey(id string) []byte {
+	return append(append([]byte{}, blackjackPrefix...), id...)
+}
+
+func (t BlackjackTable) save(s *BlackjackSession) {
+	var buff bytes.Buffer
+	blockchain.Handle(gob.NewEncoder(&buff).Encode(s))
+
+	err := t.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set(blackjackKey(s.ID), buff.Bytes())
+	})
+	blockchain.Handle(err)
+}
+
+func (t BlackjackTable) load(id string) (*BlackjackSession, error) {
+	var session BlackjackSession
+
+	err := t.Blockchain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(blackjackKey(id))
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(&session)
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("no blackjack session %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// shoe recomputes the shoe of a session from its seeds.
+func (t BlackjackTable) shoe(s *BlackjackSession) []Card {
+	roll, err := blockchain.FairSeeds{Blockchain: t.Blockchain}.Resume(s.ServerSeedHash, s.ClientSeed, s.Nonce)
+	blockchain.Handle(err)
+
+	return NewShoe(roll)
+}
+
+func (t BlackjackTable) mine(tx *blockchain.Transaction) {
+	block := t.Blockchain.MineBlock([]*blockchain.Transaction{tx})
+	UTXOSet := blockchain.UTXOSet{Blockchain: t.Blockchain}
+	UTXOSet.Update(block)
+}
+
+// escrow locks stake and cover for a session on chain.
+func (t BlackjackTable) escrow(s *BlackjackSession, stake, cover int) {
+	player := t.Wallets.GetWallet(s.Player)
+	house, err := t.Wallets.HouseWallet()
+	blockchain.Handle(err)
+	escrow := t.Wallets.EscrowWallet(t.NodeID)
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: t.Blockchain}
+	tx, out := blockchain.NewEscrowTransaction(&player, &house, string(escrow.Address()), stake, cover, &UTXOSet)
+	t.mine(tx)
+
+	s.Escrow = append(s.Escrow, out)
+}
+
+// settle pays out a finished session and releases its seed.
+func (t BlackjackTable) settle(s *BlackjackSession) {
+	record := s.record()
+	escrow := t.Wallets.EscrowWallet(t.NodeID)
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: t.Blockchain}
+	tx := blockchain.NewSettlementTransaction(&escrow, s.Escrow, record, &UTXOSet)
+	t.mine(tx)
+
+	s.Settled = true
+	s.Payout = record.Payout
+	s.SettleTx = tx.ID
+	blockchain.FairSeeds{Blockchain: t.Blockchain}.Unlock(s.ServerSeedHash, s.ID)
+}
+
+// Open places a bet and deals the first cards. A blackjack on either side
+// settles right away.
+func (t BlackjackTable) Open(from string, bet int, clientSeed string, nonce int) (*BlackjackSession, error) {
+	if bet <= 0 {
+		return nil, errors.New("amount must be greater than 0")
+	}
+	// A blackjack pays 3:2, doubles and splits aren't held to the limits
+	if err := CheckLimits("blackjack", bet, bet*5/2); err != nil {
+		return nil, err
+	}
+	if _, ok := t.Wallets.Wallets[from]; !ok {
+		return nil, fmt.Errorf("wallet %s not found", from)
+	}
+	defer LockBets(from)()
+	if err := (Policies{Blockchain: t.Blockchain}).Check(from, bet); err != nil {
+		return nil, err
+	}
+	house, err := t.Wallets.HouseWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	t.Blockchain.LockSpends()
+	defer t.Blockchain.UnlockSpends()
+	blackjackMu.Lock()
+	defer blackjackMu.Unlock()
+
+	if clientSeed == "" {
+		clientSeed = blockchain.RandomClientSeed()
+	}
+	fair := blockchain.FairSeeds{Blockchain: t.Blockchain}
+	roll, err := fair.NewRoll(clientSeed, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	session := &BlackjackSession{
+		Player:         from,
+		House:          string(house.Address()),
+		ServerSeedHash: roll.ServerSeedHash,
+		ClientSeed:     roll.ClientSeed,
+		Nonce:          roll.Nonce,
+		Started:        now,
+		LastAction:     now,
+	}
+
+	// A blackjack pays 3:2, the house covers that
+	t.escrow(session, bet, bet*3/2)
+	session.ID = fmt.Sprintf("%x", session.Escrow[0].TxID)
+	fair.Lock(session.ServerSeedHash, session.ID)
+
+	session.deal(NewShoe(roll), bet)
+	if session.Finished() {
+		t.settle(session)
+	}
+	t.save(session)
+
+	return session, nil
+}
+
+// Act plays hit, stand, double or split on the active hand. Double and
+// split put another stake in escrow first.
+func (t BlackjackTable) Act(id, action string) (*BlackjackSession, error) {
+	// Doubles and splits are bets too
+	if session, err := t.load(id); err == nil {
+		defer LockBets(session.Player)()
+	}
+	t.Blockchain.LockSpends()
+	defer t.Blockchain.UnlockSpends()
+	blackjackMu.Lock()
+	defer blackjackMu.Unlock()
+
+	session, err := t.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Settled {
+		return session, errSessionSettled
+	}
+	if t.expired(session, time.Now()) {
+		t.timeout(session)
+		return session, errors.New("this blackjack session timed out, its hands stood")
+	}
+
+	// Check the action before any coins move
+	if err := session.Allows(action); err != nil {
+		return session, err
+	}
+
+	if stake := session.Stake(action); stake > 0 {
+		if err := (Policies{Blockchain: t.Blockchain}).Check(session.Player, stake); err != nil {
+			return session, err
+		}
+		t.escrow(session, stake, stake)
+	}
+	blockchain.Handle(session.play(t.shoe(session), action))
+	session.LastAction = time.Now().Unix()
+
+	if session.Finished() {
+		t.settle(session)
+	}
+	t.save(session)
+
+	return session, nil
+}
+
+func (t BlackjackTable) Session(id string) (*BlackjackSession, error) {
+	return t.load(id)
+}
+
+// Sessions lists the sessions of address, every session when it's empty.
+func (t BlackjackTable) Sessions(address string) []BlackjackSession {
+	var sessions []BlackjackSession
+
+	err := t.Blockchain.Database.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(blackjackPrefix); it.ValidForPrefix(blackjackPrefix); it.Next() {
+			data, err := it.Item().ValueCopy(nil)
+			blockchain.Handle(err)
+
+			var session BlackjackSession
+			blockchain.Handle(gob.NewDecoder(bytes.NewReader(data)).Decode(&session))
+			if address == "" || session.Player == address {
+				sessions = append(sessions, session)
+			}
+		}
+		return nil
+	})
+	blockchain.Handle(err)
+
+	return sessions
+}
+
+func (t BlackjackTable) expired(s *BlackjackSession, now time.Time) bool {
+	return !s.Settled && now.Sub(time.Unix(s.LastAction, 0)) > BlackjackTimeout
+}
+
+func (t BlackjackTable) timeout(s *BlackjackSession) {
+	s.standAll(t.shoe(s))
+	s.Actions = append(s.Actions, "timeout")
+	t.settle(s)
+	t.save(s)
+}
+
+// ExpireSessions stands and settles every session that waited longer than
+// BlackjackTimeout. It returns the IDs of the sessions it settled.
+func (t BlackjackTable) ExpireSessions() []string {
+	t.Blockchain.LockSpends()
+	defer t.Blockchain.UnlockSpends()
+	blackjackMu.Lock()
+	defer blackjackMu.Unlock()
+
+	var expired []string
+	now := time.Now()
+	for _, session := range t.Sessions("") {
+		if t.expired(&session, now) {
+			t.timeout(&session)
+			expired = append(expired, session.ID)
+		}
+	}
+
+	return expired
+}
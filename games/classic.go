@@ -0,0 +1,179 @@
+package games
+
+import (
+	"fmt"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+)
+
+func init() {
+	Register(coinflip{})
+	Register(dice{})
+	Register(numberRange{})
+}
+
+// settle pays multiplier times the bet when won.
+func settle(bet int, outcome int, won bool, odds Payout) blockchain.GameRecord {
+	record := blockchain.GameRecord{
+		Outcome:     outcome,
+		Multiplier:  odds.Multiplier,
+		WinOutcomes: odds.WinOutcomes,
+		Outcomes:    odds.Outcomes,
+	}
+	if won {
+		record.Payout = bet * odds.Multiplier
+	}
+	return record
+}
+
+// coinflip: 50/50 chance, double or nothing
+type coinflip struct{}
+
+var coinflipDefault = Payout{Bet: "heads", Multiplier: 2, WinOutcomes: 1, Outcomes: 2}
+
+func coinflipWin() Payout { return configured("coinflip", []Payout{coinflipDefault})[0] }
+
+func (coinflip) Info() Info {
+	win := coinflipWin()
+	return Info{
+		Name:        "coinflip",
+		Title:       "Coinflip",
+		Description: fmt.Sprintf("Coinflip to win %dx or lose your coins", win.Multiplier),
+		Payouts:     []Payout{win},
+	}
+}
+
+func (coinflip) Validate(bet int, params Params) error { return nil }
+
+func (coinflip) MaxPayout(bet int, params Params) int { return bet * coinflipWin().Multiplier }
+
+func (coinflip) Outcome(rng RNG) int { return rng.Intn(2) }
+
+func (coinflip) Settle(bet int, params Params, outcome int) blockchain.GameRecord {
+	return settle(bet, outcome, outcome == 1, coinflipWin())
+}
+
+func (coinflip) Describe(outcome int) string {
+	if outcome == 1 {
+		return "heads"
+	}
+	return "tails"
+}
+
+// dice: roll a 6, or one of the winningFaces highest faces, to win 3x the
+// bet
+type dice struct{}
+
+var diceDefault = Payout{Bet: "six", Multiplier: 3, WinOutcomes: 1, Outcomes: 6}
+
+func diceWin() Payout {
+	win := configured("dice", []Payout{diceDefault})[0]
+	if faces := gameConfig("dice").WinningFaces; faces > 1 {
+		win.Bet = fmt.Sprintf("%d-6", 7-faces)
+		win.WinOutcomes = faces
+	}
+	return win
+}
+
+// lowestWin is the lowest winning roll.
+func (dice) lowestWin() int { return 7 - diceWin().WinOutcomes }
+
+func (g dice) Info() Info {
+	win := diceWin()
+	description := fmt.Sprintf("Roll a 6 to win %dx your bet", win.Multiplier)
+	if win.WinOutcomes > 1 {
+		description = fmt.Sprintf("Roll %d or higher to win %dx your bet", g.lowestWin(), win.Multiplier)
+	}
+	return Info{
+		Name:        "dice",
+		Title:       "Dice Roll",
+		Description: description,
+		Aliases:     []string{"diceroll"},
+		Payouts:     []Payout{win},
+	}
+}
+
+func (dice) Validate(bet int, params Params) error { return nil }
+
+func (dice) MaxPayout(bet int, params Params) int { return bet * diceWin().Multiplier }
+
+func (dice) Outcome(rng RNG) int { return rng.Intn(6) + 1 }
+
+func (g dice) Settle(bet int, params Params, outcome int) blockchain.GameRecord {
+	return settle(bet, outcome, outcome >= g.lowestWin(), diceWin())
+}
+
+func (dice) Describe(outcome int) string { return fmt.Sprintf("rolled a %d", outcome) }
+
+// numberRange: guess 1-100, a server number within ±window of the guess
+// wins 5x
+type numberRange struct{}
+
+const numberRangeWindow = 5
+
+var numberRangeDefault = Payout{Bet: "guess", Multiplier: 5, WinOutcomes: 2*numberRangeWindow + 1, Outcomes: 100}
+
+func numberRangeWidth() int {
+	if window := gameConfig("numberrange").Window; window > 0 {
+		return window
+	}
+	return numberRangeWindow
+}
+
+func numberRangeWin() Payout {
+	win := configured("numberrange", []Payout{numberRangeDefault})[0]
+	win.WinOutcomes = 2*numberRangeWidth() + 1
+	return win
+}
+
+func (numberRange) Info() Info {
+	win := numberRangeWin()
+	return Info{
+		Name:        "numberrange",
+		Title:       "Number Range",
+		Description: fmt.Sprintf("Guess a number 1-100, within ±%d wins %dx", numberRangeWidth(), win.Multiplier),
+		Params: []Param{
+			{Name: "guess", Type: "int", Description: "Number guess (1-100)", Required: true, Min: 1, Max: 100},
+		},
+		Payouts: []Payout{win},
+	}
+}
+
+func (numberRange) Validate(bet int, params Params) error { return nil }
+
+func (numberRange) MaxPayout(bet int, params Params) int { return bet * numberRangeWin().Multiplier }
+
+func (numberRange) Outcome(rng RNG) int { return rng.Intn(100) + 1 }
+
+// bounds is the winning range of a guess, clipped to 1-100.
+func (numberRange) bounds(guess int) (int, int) {
+	lower, upper := guess-numberRangeWidth(), guess+numberRangeWidth()
+	if lower < 1 {
+		lower = 1
+	}
+	if upper > 100 {
+		upper = 100
+	}
+	return lower, upper
+}
+
+func (g numberRange) Settle(bet int, params Params, outcome int) blockchain.GameRecord {
+	guess, _ := params.Int("guess")
+	lower, upper := g.bounds(guess)
+
+	// Near the edges the range is clipped, and so are the odds
+	odds := numberRangeWin()
+	odds.WinOutcomes = upper - lower + 1
+
+	record := settle(bet, outcome, outcome >= lower && outcome <= upper, odds)
+	record.Guess = guess
+	return record
+}
+
+// JackpotHit: guessing the server number exactly wins the jackpot.
+func (numberRange) JackpotHit(params Params, outcome int) bool {
+	guess, _ := params.Int("guess")
+	return guess == outcome
+}
+
+func (numberRange) Describe(outcome int) string { return fmt.Sprintf("server number %d", outcome) }
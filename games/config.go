@@ -0,0 +1,294 @@
+package games
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+)
+
+// The odds and limits of the games can be changed in a JSON file, read at
+// startup from GAMES_CONFIG or else games.json in the working directory:
+//
+//	{"games": {"dice": {"multiplier": 4, "winningFaces": 1, "minBet": 1, "maxBet": 500}}}
+//
+// Games left out of the file, and fields left out of a game, keep their
+// defaults. A zero limit means no limit.
+
+const DefaultConfigFile = "games.json"
+
+// GameConfig is the configuration of one game.
+type GameConfig struct {
+	// Multiplier replaces the payout of games with a single payout line,
+	// Multipliers those of the named lines of the payout table.
+	Multiplier  int            `json:"multiplier,omitempty"`
+	Multipliers map[string]int `json:"multipliers,omitempty"`
+
+	// Win conditions: the number of winning faces of the dice, counted down
+	// from 6, and the ± window of numberrange.
+	WinningFaces int `json:"winningFaces,omitempty"`
+	Window       int `json:"window,omitempty"`
+
+	MinBet int `json:"minBet,omitempty"`
+	MaxBet int `json:"maxBet,omitempty"`
+	// MaxPayout refuses bets that could win more than this, stake included
+	MaxPayout int `json:"maxPayout,omitempty"`
+	// MinHouseEdge in percent the best bet has to keep, checked when the
+	// configuration is loaded. Payouts giving players the edge are always
+	// refused.
+	MinHouseEdge float64 `json:"minHouseEdge,omitempty"`
+
+	// Machine replaces the reels, paylines and pay table of slots
+	Machine *SlotMachine `json:"machine,omitempty"`
+}
+
+type Config struct {
+	Games map[string]GameConfig `json:"games"`
+}
+
+var activeConfig atomic.Pointer[Config]
+
+// CurrentConfig is the configuration in use.
+func CurrentConfig() Config {
+	if config := activeConfig.Load(); config != nil {
+		return *config
+	}
+	return Config{}
+}
+
+func gameConfig(name string) GameConfig {
+	return CurrentConfig().Games[name]
+}
+
+// ConfigFile is the file LoadConfigFile reads.
+func ConfigFile() string {
+	if path := os.Getenv("GAMES_CONFIG"); path != "" {
+		return path
+	}
+	return DefaultConfigFile
+}
+
+// LoadConfigFile loads ConfigFile. Without the file the defaults apply,
+// unless GAMES_CONFIG asked for it explicitly.
+func LoadConfigFile() error {
+	path := ConfigFile()
+	if _, err := os.Stat(path); os.IsNotExist(err) && os.Getenv("GAMES_CONFIG") == "" {
+		return nil
+	}
+	return LoadConfig(path)
+}
+
+// LoadConfig reads, checks and applies a configuration file. It is meant to
+// be called at startup, before bets are played.
+func LoadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	// The checks look at the payout tables as configured
+	previous := activeConfig.Load()
+	activeConfig.Store(&config)
+	if err := config.check(); err != nil {
+		activeConfig.Store(previous)
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// configurable are the names that may appear in a configuration: the
+// registered games, blackjack and crash.
+func configurable() map[string]Info {
+	infos := map[string]Info{
+		"blackjack": {Name: "blackjack", Payouts: blackjackPayouts},
+		"crash":     {Name: "crash", Payouts: crashPayouts},
+	}
+	for _, game := range All() {
+		infos[game.Info().Name] = game.Info()
+	}
+	return infos
+}
+
+func (c Config) check() error {
+	infos := configurable()
+
+	var names []string
+	for name := range c.Games {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		gc := c.Games[name]
+		info, ok := infos[name]
+		if !ok {
+			return fmt.Errorf("unknown game %q", name)
+		}
+
+		if gc.Multiplier < 0 || gc.MinBet < 0 || gc.MaxBet < 0 || gc.MaxPayout < 0 || gc.MinHouseEdge < 0 {
+			return fmt.Errorf("%s: values can't be negative", name)
+		}
+		if (name == "blackjack" || name == "crash") && (gc.Multiplier != 0 || len(gc.Multipliers) > 0 || gc.MinHouseEdge != 0) {
+			return fmt.Errorf("%s pays fixed odds, only its limits can be configured", name)
+		}
+		if name == "slots" && (gc.Multiplier != 0 || len(gc.Multipliers) > 0) {
+			return fmt.Errorf("slots pays the pay table of its machine, configure the machine instead")
+		}
+		if gc.Machine != nil && name != "slots" {
+			return fmt.Errorf("%s: machine only applies to slots", name)
+		}
+		if gc.Machine != nil {
+			if err := gc.Machine.check(); err != nil {
+				return fmt.Errorf("slots: %w", err)
+			}
+		}
+		if gc.Multiplier != 0 && len(defaultPayouts(name)) != 1 {
+			return fmt.Errorf("%s has several payout lines, set multipliers instead of multiplier", name)
+		}
+		for bet, multiplier := range gc.Multipliers {
+			if multiplier < 1 {
+				return fmt.Errorf("%s: multiplier of %s must be at least 1", name, bet)
+			}
+			if !hasPayout(defaultPayouts(name), bet) {
+				return fmt.Errorf("%s has no bet %q", name, bet)
+			}
+		}
+		if gc.WinningFaces != 0 && (name != "dice" || gc.WinningFaces > 5) {
+			return fmt.Errorf("%s: winningFaces only applies to dice, from 1 to 5", name)
+		}
+		if gc.Window != 0 && (name != "numberrange" || gc.Window > 49) {
+			return fmt.Errorf("%s: window only applies to numberrange, from 1 to 49", name)
+		}
+		if gc.MaxBet != 0 && gc.MinBet > gc.MaxBet {
+			return fmt.Errorf("%s: minBet is above maxBet", name)
+		}
+
+		if edge := HouseEdge(info); edge < gc.MinHouseEdge {
+			return fmt.Errorf("%s: the payouts leave a house edge of %.2f%%, below the minimum of %.2f%%", name, edge, gc.MinHouseEdge)
+		}
+	}
+
+	return nil
+}
+
+// defaultPayouts are the payout tables before configuration.
+func defaultPayouts(name string) []Payout {
+	switch name {
+	case "coinflip":
+		return []Payout{coinflipDefault}
+	case "dice":
+		return []Payout{diceDefault}
+	case "numberrange":
+		return []Payout{numberRangeDefault}
+	case "roulette":
+		return rouletteDefault
+	case "blackjack":
+		return blackjackPayouts
+	}
+	return nil
+}
+
+func hasPayout(payouts []Payout, bet string) bool {
+	for _, p := range payouts {
+		if p.Bet == bet {
+			return true
+		}
+	}
+	return false
+}
+
+// configured applies the multipliers of the configuration of name to a
+// payout table.
+func configured(name string, payouts []Payout) []Payout {
+	gc := gameConfig(name)
+
+	table := append([]Payout{}, payouts...)
+	for i := range table {
+		if gc.Multiplier != 0 && len(table) == 1 {
+			table[i].Multiplier = gc.Multiplier
+		}
+		if multiplier, ok := gc.Multipliers[table[i].Bet]; ok {
+			table[i].Multiplier = multiplier
+		}
+	}
+	return table
+}
+
+// RTP is the return to player of the bet, in percent.
+func (p Payout) RTP() float64 {
+	if p.Outcomes == 0 {
+		return 0
+	}
+	return 100 * float64(p.Multiplier*p.WinOutcomes) / float64(p.Outcomes)
+}
+
+// HouseEdge is the edge of the best bet of a game, in percent, or of its
+// only bet when the payout lines are combined.
+func HouseEdge(info Info) float64 {
+	best := 0.0
+	for _, p := range info.Payouts {
+		if info.Combined {
+			best += p.RTP()
+		} else if rtp := p.RTP(); rtp > best {
+			best = rtp
+		}
+	}
+	return 100 - best
+}
+
+// CheckLimits checks a bet against the limits of the game's configuration.
+// maxPayout is the most the bet can win.
+func CheckLimits(name string, bet, maxPayout int) error {
+	gc := gameConfig(name)
+
+	if bet < gc.MinBet {
+		return fmt.Errorf("the minimum %s bet is %d", name, gc.MinBet)
+	}
+	if gc.MaxBet != 0 && bet > gc.MaxBet {
+		return fmt.Errorf("the maximum %s bet is %d", name, gc.MaxBet)
+	}
+	if gc.MaxPayout != 0 && maxPayout > gc.MaxPayout {
+		return fmt.Errorf("this bet could pay %d, more than the %s maximum payout of %d", maxPayout, name, gc.MaxPayout)
+	}
+	return nil
+}
+
+// GameSettings is the live configuration of a game as the API shows it.
+type GameSettings struct {
+	Name      string     `json:"name"`
+	Payouts   []Payout   `json:"payouts"`
+	RTP       []float64  `json:"rtp"`
+	HouseEdge float64    `json:"houseEdge"`
+	Config    GameConfig `json:"config"`
+}
+
+// Settings lists the configuration in use of every game, with the return to
+// player of each payout line.
+func Settings() []GameSettings {
+	infos := configurable()
+
+	var names []string
+	for name := range infos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var settings []GameSettings
+	for _, name := range names {
+		info := infos[name]
+		s := GameSettings{Name: name, Payouts: info.Payouts, HouseEdge: HouseEdge(info), Config: gameConfig(name)}
+		for _, p := range info.Payouts {
+			s.RTP = append(s.RTP, p.RTP())
+		}
+		settings = append(settings, s)
+	}
+
+	return settings
+}
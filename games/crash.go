@@ -0,0 +1,660 @@
+package games
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// Crash runs in rounds on the server. Players bet while a round takes bets,
+// each with an automatic cash-out multiplier. Then the multiplier climbs
+// from 1.00x until it reaches the round's bust, taken from the house's hash
+// chain (see blockchain/crash.go). Players who cashed out before the bust,
+// automatically or with CashOut, win their bet times their multiplier, the
+// others lose it. Once busted the round's hash is revealed and all its bets
+// settle in one transaction. Rounds stop at MaxCrashCashOut, every bet has
+// cashed out by then.
+//
+// A bet locks its stake and what the house could lose on it in escrow when
+// it is placed, and the round settles from escrow. A round that fails to
+// settle is kept and settled again between rounds, and a round cut short by
+// a restart is void, its bets get their stakes back.
+
+var (
+	crashRoundPrefix     = []byte("crash-round-")
+	crashUnsettledPrefix = []byte("crash-unsettled-")
+	// crashOpenKey holds the bets of the round being played
+	crashOpenKey = []byte("crash-open")
+	crash        = &crashGame{subscribers: make(map[chan CrashState]struct{})}
+)
+
+const (
+	CrashBettingTime = 5 * time.Second
+	// CrashPause is the break between a bust and the next round
+	CrashPause = 3 * time.Second
+	// MaxCrashCashOut is the highest cash-out, 100.00x
+	MaxCrashCashOut = 10000
+
+	crashTick = 100 * time.Millisecond
+	// The multiplier is e^(crashGrowth * elapsed ms), 2x after 11.5 seconds
+	crashGrowth = 0.00006
+)
+
+// Phases of a round
+const (
+	CrashBetting = "betting"
+	CrashRunning = "running"
+	CrashBusted  = "busted"
+)
+
+// crashPayouts states the odds of a 2x cash-out for the game
+// configuration. Every cash-out has the same return, only the limits of
+// crash can be configured.
+var crashPayouts = []Payout{{Bet: "2x", Multiplier: 2, WinOutcomes: 100 - blockchain.CrashHouseEdge, Outcomes: 200}}
+
+// CrashState is the live state of a round as clients see it. Multipliers
+// are in hundredths. The hash and bust stay hidden until the round busts.
+type CrashState struct {
+	Round      int    `json:"round"`
+	Phase      string `json:"phase"`
+	Commitment string `json:"commitment"`
+	ChainRound int    `json:"chainRound"`
+	Multiplier int    `json:"multiplier"`
+	// StartsAt is when the multiplier starts climbing, in Unix milliseconds
+	StartsAt int64                 `json:"startsAt"`
+	Bets     []blockchain.CrashBet `json:"bets"`
+
+	Bust     int    `json:"bust,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+	SettleTx string `json:"settleTx,omitempty"`
+}
+
+// CrashRound is a past round as the node keeps it.
+type CrashRound struct {
+	blockchain.CrashRecord
+	Timestamp int64  `json:"timestamp"`
+	SettleTx  string `json:"settleTx,omitempty"`
+	// Escrow locks the bets until SettleTx spends it
+	Escrow []blockchain.EscrowOutput `json:"-"`
+}
+
+type crashGame struct {
+	mu          sync.Mutex
+	running     bool
+	state       CrashState
+	escrow      []blockchain.EscrowOutput
+	seed        blockchain.CrashSeed
+	started     time.Time
+	subscribers map[chan CrashState]struct{}
+}
+
+// crashMultiplier is the multiplier elapsed after the start, in hundredths.
+func crashMultiplier(elapsed time.Duration) int {
+	return int(100 * math.Exp(crashGrowth*float64(elapsed.Milliseconds())))
+}
+
+// copyState is the state with its own bets, safe to hand out.
+func (g *crashGame) copyState() CrashState {
+	state := g.state
+	state.Bets = append([]blockchain.CrashBet{}, g.state.Bets...)
+	return state
+}
+
+// broadcast sends the state to every subscriber. Slow subscribers miss
+// updates rather than hold up the round.
+func (g *crashGame) broadcast() {
+	state := g.copyState()
+	for ch := range g.subscribers {
+		select {
+		case ch <- state:
+		default:
+		}
+	}
+}
+
+// CrashTable takes the bets of the node's crash rounds and keeps the past
+// rounds in the chain database.
+type CrashTable struct {
+	Blockchain *blockchain.BlockChain
+	Wallets    *wallet.Wallets
+	NodeID     string
+}
+
+func crashRoundKey(round int) []byte {
+	return append(append([]byte{}, crashRoundPrefix...), fmt.Sprintf("%010d", round)...)
+}
+
+func crashUnsettledKey(round int) []byte {
+	return append(append([]byte{}, crashUnsettledPrefix...), fmt.Sprintf("%010d", round)...)
+}
+
+// save stores a round, which is no longer open once saved, and marks it
+// unsettled while its escrow is locked.
+func (t CrashTable) save(round *CrashRound) {
+	var buff bytes.Buffer
+	blockchain.Handle(gob.NewEncoder(&buff).Encode(round))
+
+	err := t.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(crashRoundKey(round.Round), buff.Bytes()); err != nil {
+			return err
+		}
+		if err := txn.Delete(crashOpenKey); err != nil {
+			return err
+		}
+		if round.SettleTx == "" && len(round.Escrow) > 0 {
+			return txn.Set(crashUnsettledKey(round.Round), nil)
+		}
+		return txn.Delete(crashUnsettledKey(round.Round))
+	})
+	blockchain.Handle(err)
+}
+
+// saveOpen stores the bets of the round being played, so a restart can
+// give their escrow back.
+func (t CrashTable) saveOpen(round *CrashRound) {
+	var buff bytes.Buffer
+	blockchain.Handle(gob.NewEncoder(&buff).Encode(round))
+
+	err := t.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set(crashOpenKey, buff.Bytes())
+	})
+	blockchain.Handle(err)
+}
+
+// unsettled returns the saved rounds whose escrow is still locked, and the
+// round left open by a restart if any.
+func (t CrashTable) unsettled() (rounds []int, open *CrashRound) {
+	err := t.Blockchain.Database.View(func(txn *badger.Txn) error {
+		if item, err := txn.Get(crashOpenKey); err == nil {
+			data, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			open = &CrashRound{}
+			if err := gob.NewDecoder(bytes.NewReader(data)).Decode(open); err != nil {
+				return err
+			}
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(crashUnsettledPrefix); it.ValidForPrefix(crashUnsettledPrefix); it.Next() {
+			var round int
+			if _, err := fmt.Sscanf(string(bytes.TrimPrefix(it.Item().Key(), crashUnsettledPrefix)), "%d", &round); err != nil {
+				return err
+			}
+			rounds = append(rounds, round)
+		}
+		return nil
+	})
+	blockchain.Handle(err)
+
+	return rounds, open
+}
+
+// Round returns a past round.
+func (t CrashTable) Round(n int) (*CrashRound, error) {
+	var round CrashRound
+
+	err := t.Blockchain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(crashRoundKey(n))
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(&round)
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("no crash round %d", n)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if round.Bets == nil {
+		round.Bets = []blockchain.CrashBet{}
+	}
+
+	return &round, nil
+}
+
+// Rounds lists the last limit rounds, newest first, all of them if limit
+// is 0.
+func (t CrashTable) Rounds(limit int) []CrashRound {
+	var rounds []CrashRound
+
+	err := t.Blockchain.Database.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		// Reverse iteration starts at the last key up to the seek key
+		for it.Seek(append(append([]byte{}, crashRoundPrefix...), 0xff)); it.ValidForPrefix(crashRoundPrefix); it.Next() {
+			if limit > 0 && len(rounds) == limit {
+				break
+			}
+			data, err := it.Item().ValueCopy(nil)
+			blockchain.Handle(err)
+
+			var round CrashRound
+			blockchain.Handle(gob.NewDecoder(bytes.NewReader(data)).Decode(&round))
+			if round.Bets == nil {
+				round.Bets = []blockchain.CrashBet{}
+			}
+			rounds = append(rounds, round)
+		}
+		return nil
+	})
+	blockchain.Handle(err)
+
+	return rounds
+}
+
+// Bets returns the bets of address in the rounds since since, and its bet
+// in the round being played if any.
+func (t CrashTable) Bets(address string, since time.Time) (settled []blockchain.CrashBet, open *blockchain.CrashBet) {
+	last := t.lastRound()
+	for _, round := range t.Rounds(0) {
+		if round.Timestamp < since.Unix() {
+			break
+		}
+		for _, bet := range round.Bets {
+			if bet.Player == address {
+				settled = append(settled, bet)
+			}
+		}
+	}
+
+	crash.mu.Lock()
+	defer crash.mu.Unlock()
+	// Until it is saved, busted or not
+	if crash.running && crash.state.Round > last {
+		for _, bet := range crash.state.Bets {
+			if bet.Player == address {
+				open = &bet
+			}
+		}
+	}
+	return settled, open
+}
+
+// State is the round being played.
+func (t CrashTable) State() (CrashState, error) {
+	crash.mu.Lock()
+	defer crash.mu.Unlock()
+
+	if !crash.running {
+		return CrashState{}, errors.New("the crash game isn't running on this node")
+	}
+	return crash.copyState(), nil
+}
+
+// Subscribe streams the state of the rounds on the channel it returns until
+// cancel is called.
+func (t CrashTable) Subscribe() (<-chan CrashState, func()) {
+	ch := make(chan CrashState, 16)
+
+	crash.mu.Lock()
+	crash.subscribers[ch] = struct{}{}
+	if crash.running {
+		ch <- crash.copyState()
+	}
+	crash.mu.Unlock()
+
+	cancel := func() {
+		crash.mu.Lock()
+		delete(crash.subscribers, ch)
+		crash.mu.Unlock()
+	}
+	return ch, cancel
+}
+
+// crashExposure is what the house could lose on the bets of the round.
+func crashExposure(bets []blockchain.CrashBet) int {
+	exposure := 0
+	for _, bet := range bets {
+		exposure += bet.Amount*bet.CashOut/100 - bet.Amount
+	}
+	return exposure
+}
+
+// Bet places a bet of amount by from on the round taking bets. cashOut is
+// the automatic cash-out in hundredths, 0 for MaxCrashCashOut.
+func (t CrashTable) Bet(from string, amount, cashOut int) (CrashState, error) {
+	if amount <= 0 {
+		return CrashState{}, errors.New("amount must be greater than 0")
+	}
+	if cashOut == 0 {
+		cashOut = MaxCrashCashOut
+	}
+	if cashOut <= 100 || cashOut > MaxCrashCashOut {
+		return CrashState{}, fmt.Errorf("cashOut must be above 1.00x and at most %.2fx", float64(MaxCrashCashOut)/100)
+	}
+	if err := CheckLimits("crash", amount, amount*cashOut/100); err != nil {
+		return CrashState{}, err
+	}
+	if _, ok := t.Wallets.Wallets[from]; !ok {
+		return CrashState{}, fmt.Errorf("wallet %s not found", from)
+	}
+	house, err := t.Wallets.HouseWallet()
+	if err != nil {
+		return CrashState{}, err
+	}
+	if from == string(house.Address()) {
+		return CrashState{}, errors.New("the house can't bet against itself")
+	}
+	defer LockBets(from)()
+	if err := (Policies{Blockchain: t.Blockchain}).Check(from, amount); err != nil {
+		return CrashState{}, err
+	}
+
+	// play takes the spend lock before it starts the round, so the round
+	// still takes bets once this one is escrowed
+	t.Blockchain.LockSpends()
+	defer t.Blockchain.UnlockSpends()
+
+	crash.mu.Lock()
+	if err := crash.takesBet(from); err != nil {
+		defer crash.mu.Unlock()
+		return crash.copyState(), err
+	}
+	crash.mu.Unlock()
+
+	// The stake and what the house could lose on the bet stay in escrow
+	// until the round settles
+	bet := blockchain.CrashBet{Player: from, Amount: amount, CashOut: cashOut}
+	player := t.Wallets.GetWallet(from)
+	escrow := t.Wallets.EscrowWallet(t.NodeID)
+	var out blockchain.EscrowOutput
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("%v", rec)
+			}
+		}()
+		UTXOSet := blockchain.UTXOSet{Blockchain: t.Blockchain}
+		var tx *blockchain.Transaction
+		tx, out = blockchain.NewEscrowTransaction(&player, &house, string(escrow.Address()), amount, crashExposure([]blockchain.CrashBet{bet}), &UTXOSet)
+		block := t.Blockchain.MineBlock([]*blockchain.Transaction{tx})
+		UTXOSet.Update(block)
+	}()
+
+	crash.mu.Lock()
+	defer crash.mu.Unlock()
+	if err != nil {
+		return crash.copyState(), err
+	}
+
+	crash.state.Bets = append(crash.state.Bets, bet)
+	crash.escrow = append(crash.escrow, out)
+	t.saveOpen(&CrashRound{
+		CrashRecord: blockchain.CrashRecord{
+			Round:      crash.state.Round,
+			ChainRound: crash.state.ChainRound,
+			Commitment: crash.state.Commitment,
+			Bets:       append([]blockchain.CrashBet{}, crash.state.Bets...),
+		},
+		Timestamp: time.Now().Unix(),
+		Escrow:    append([]blockchain.EscrowOutput{}, crash.escrow...),
+	})
+	crash.broadcast()
+
+	return crash.copyState(), nil
+}
+
+// takesBet refuses a bet of from unless the round takes bets and from has
+// none on it yet.
+func (g *crashGame) takesBet(from string) error {
+	if !g.running {
+		return errors.New("the crash game isn't running on this node")
+	}
+	if g.state.Phase != CrashBetting {
+		return fmt.Errorf("round %d has started, bet on the next one", g.state.Round)
+	}
+	for _, bet := range g.state.Bets {
+		if bet.Player == from {
+			return fmt.Errorf("%s already bet on round %d", from, g.state.Round)
+		}
+	}
+	return nil
+}
+
+// CashOut cashes out the bet of from at the multiplier the running round
+// is at.
+func (t CrashTable) CashOut(from string) (CrashState, error) {
+	crash.mu.Lock()
+	defer crash.mu.Unlock()
+
+	if !crash.running {
+		return CrashState{}, errors.New("the crash game isn't running on this node")
+	}
+	if crash.state.Phase != CrashRunning {
+		return crash.copyState(), fmt.Errorf("round %d isn't running", crash.state.Round)
+	}
+
+	multiplier := crashMultiplier(time.Since(crash.started))
+	if multiplier >= blockchain.CrashBust(crash.seed.Hash) {
+		return crash.copyState(), fmt.Errorf("round %d busted", crash.state.Round)
+	}
+	for i := range crash.state.Bets {
+		bet := &crash.state.Bets[i]
+		if bet.Player != from {
+			continue
+		}
+		if bet.CashedOut != 0 {
+			return crash.copyState(), fmt.Errorf("%s cashed out at %.2fx already", from, float64(bet.CashedOut)/100)
+		}
+		bet.CashedOut = multiplier
+		bet.Payout = bet.Amount * multiplier / 100
+		crash.broadcast()
+		return crash.copyState(), nil
+	}
+
+	return crash.copyState(), fmt.Errorf("%s has no bet on round %d", from, crash.state.Round)
+}
+
+// cashOutReached cashes out the bets whose automatic cash-out multiplier
+// reached.
+func (g *crashGame) cashOutReached(multiplier int) {
+	for i := range g.state.Bets {
+		bet := &g.state.Bets[i]
+		if bet.CashedOut == 0 && bet.CashOut <= multiplier {
+			bet.CashedOut = bet.CashOut
+			bet.Payout = bet.Amount * bet.CashOut / 100
+		}
+	}
+}
+
+func (t CrashTable) lastRound() int {
+	if rounds := t.Rounds(1); len(rounds) > 0 {
+		return rounds[0].Round
+	}
+	return 0
+}
+
+// settle pays out the bets of a round from their escrow and saves it.
+func (t CrashTable) settle(round *CrashRound) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("%v", rec)
+		}
+	}()
+
+	// Wallets may have been created while the round ran
+	wallets, err := wallet.CreateWallets(t.NodeID)
+	if err != nil {
+		return err
+	}
+	house, err := wallets.HouseWallet()
+	if err != nil {
+		return err
+	}
+	round.House = string(house.Address())
+	if len(round.Escrow) == 0 {
+		t.save(round)
+		return nil
+	}
+	escrow := wallets.EscrowWallet(t.NodeID)
+
+	t.Blockchain.LockSpends()
+	defer t.Blockchain.UnlockSpends()
+	UTXOSet := blockchain.UTXOSet{Blockchain: t.Blockchain}
+	tx := blockchain.NewCrashTransaction(&escrow, round.Escrow, round.CrashRecord, &UTXOSet)
+	block := t.Blockchain.MineBlock([]*blockchain.Transaction{tx})
+	UTXOSet.Update(block)
+
+	round.SettleTx = hex.EncodeToString(tx.ID)
+	t.save(round)
+
+	return nil
+}
+
+// settlePending settles the rounds that failed to settle when they busted.
+// A round left open by a restart never busted, it is void and its bets get
+// their stakes back.
+func (t CrashTable) settlePending() {
+	rounds, open := t.unsettled()
+	if open != nil {
+		open.Void = true
+		for i := range open.Bets {
+			open.Bets[i].CashedOut = 0
+			open.Bets[i].Payout = open.Bets[i].Amount
+		}
+		t.save(open)
+		rounds = append(rounds, open.Round)
+	}
+
+	for _, n := range rounds {
+		round, err := t.Round(n)
+		if err == nil {
+			err = t.settle(round)
+		}
+		if err != nil {
+			log.Printf("Crash round %d: failed to settle: %v", n, err)
+		}
+	}
+}
+
+// play runs one round: bets, the climb to the bust and the settlement.
+func (t CrashTable) play(round int) {
+	seed := blockchain.CrashChains{Blockchain: t.Blockchain}.Next()
+	bust := blockchain.CrashBust(seed.Hash)
+	end := bust
+	if end > MaxCrashCashOut {
+		end = MaxCrashCashOut
+	}
+
+	crash.mu.Lock()
+	crash.seed = seed
+	crash.state = CrashState{
+		Round:      round,
+		Phase:      CrashBetting,
+		Commitment: hex.EncodeToString(seed.Commitment),
+		ChainRound: seed.ChainRound,
+		Multiplier: 100,
+		StartsAt:   time.Now().Add(CrashBettingTime).UnixMilli(),
+		Bets:       []blockchain.CrashBet{},
+	}
+	crash.escrow = nil
+	crash.broadcast()
+	crash.mu.Unlock()
+
+	time.Sleep(CrashBettingTime)
+
+	// Bets being escrowed hold the spend lock and still make the round
+	t.Blockchain.LockSpends()
+	crash.mu.Lock()
+	crash.started = time.Now()
+	crash.state.Phase = CrashRunning
+	crash.broadcast()
+	crash.mu.Unlock()
+	t.Blockchain.UnlockSpends()
+
+	for {
+		time.Sleep(crashTick)
+
+		crash.mu.Lock()
+		multiplier := crashMultiplier(time.Since(crash.started))
+		if multiplier >= end {
+			crash.mu.Unlock()
+			break
+		}
+		crash.cashOutReached(multiplier)
+		crash.state.Multiplier = multiplier
+		crash.broadcast()
+		crash.mu.Unlock()
+	}
+
+	crash.mu.Lock()
+	crash.cashOutReached(bust)
+	crash.state.Phase = CrashBusted
+	crash.state.Multiplier = end
+	crash.state.Bust = bust
+	crash.state.Hash = hex.EncodeToString(seed.Hash)
+	record := blockchain.CrashRecord{
+		Round:      round,
+		ChainRound: seed.ChainRound,
+		Commitment: crash.state.Commitment,
+		Hash:       crash.state.Hash,
+		Bust:       bust,
+		Bets:       append([]blockchain.CrashBet{}, crash.state.Bets...),
+	}
+	stored := &CrashRound{
+		CrashRecord: record,
+		Timestamp:   time.Now().Unix(),
+		Escrow:      append([]blockchain.EscrowOutput{}, crash.escrow...),
+	}
+	crash.mu.Unlock()
+
+	// Saved unsettled first, settlePending tries again if this fails
+	t.save(stored)
+	if err := t.settle(stored); err != nil {
+		log.Printf("Crash round %d: failed to settle, trying again after the round: %v", round, err)
+	}
+
+	crash.mu.Lock()
+	crash.state.SettleTx = stored.SettleTx
+	crash.broadcast()
+	crash.mu.Unlock()
+}
+
+// RunCrash plays crash rounds one after the other, forever. Only one may
+// run per process.
+func RunCrash(chain *blockchain.BlockChain, nodeID string) {
+	crash.mu.Lock()
+	if crash.running {
+		crash.mu.Unlock()
+		return
+	}
+	crash.running = true
+	crash.mu.Unlock()
+
+	table := CrashTable{Blockchain: chain, NodeID: nodeID}
+	table.settlePending()
+	round := table.lastRound()
+	for {
+		round++
+		table.play(round)
+
+		time.Sleep(CrashPause)
+		table.settlePending()
+	}
+}
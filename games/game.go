@@ -0,0 +1,238 @@
+package games
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// RNG is the source of game outcomes. Bets are played with a
+// blockchain.FairRoll so their outcomes can be verified later.
+type RNG interface {
+	Intn(n int) int
+}
+
+// Param describes one parameter a game takes besides the bet amount.
+type Param struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // "int" or "string"
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	Min         int    `json:"min,omitempty"`
+	Max         int    `json:"max,omitempty"`
+}
+
+// Params are the parameter values of one bet, as given on the command line
+// or in a request.
+type Params map[string]string
+
+func (p Params) Int(name string) (int, error) {
+	v, err := strconv.Atoi(p[name])
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number", name)
+	}
+	return v, nil
+}
+
+// Payout is one line of a game's payout table. A winning bet pays
+// Multiplier times the stake, stake included, and wins for WinOutcomes of
+// Outcomes equally likely outcomes.
+type Payout struct {
+	Bet         string `json:"bet"`
+	Multiplier  int    `json:"multiplier"`
+	WinOutcomes int    `json:"winOutcomes"`
+	Outcomes    int    `json:"outcomes"`
+}
+
+type Info struct {
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Aliases     []string `json:"aliases,omitempty"`
+	Params      []Param  `json:"params"`
+	Payouts     []Payout `json:"payouts"`
+	// Combined payout lines are the pays of a single bet rather than bets
+	// of their own, like the pay table of slots, so their RTPs add up.
+	Combined bool `json:"combined,omitempty"`
+}
+
+// MaxMultiplier is the highest multiplier of the payout table.
+func (i Info) MaxMultiplier() int {
+	max := 0
+	for _, p := range i.Payouts {
+		if p.Multiplier > max {
+			max = p.Multiplier
+		}
+	}
+	return max
+}
+
+// Game is a casino game played against the house bankroll. The outcome of a
+// bet only depends on the RNG, Settle turns it into a payout.
+type Game interface {
+	Info() Info
+	// Validate checks a bet before any coins move.
+	Validate(bet int, params Params) error
+	// MaxPayout is the most the bet can win, stake included. The house has
+	// to put this much at stake for the bet to be accepted.
+	MaxPayout(bet int, params Params) int
+	// Outcome draws the raw outcome of a bet.
+	Outcome(rng RNG) int
+	// Settle fills in the payout and odds of a bet for an outcome.
+	Settle(bet int, params Params, outcome int) blockchain.GameRecord
+	// Describe renders an outcome for people, e.g. "rolled a 6".
+	Describe(outcome int) string
+}
+
+// JackpotGame is implemented by games with a rare outcome that wins the
+// progressive jackpot on top of the payout.
+type JackpotGame interface {
+	JackpotHit(params Params, outcome int) bool
+}
+
+// GridGame is implemented by games whose outcome is shown as a grid of
+// symbols, like the reels of slots, rows from the top.
+type GridGame interface {
+	Grid(outcome int) [][]string
+}
+
+var (
+	registry = make(map[string]Game)
+	aliases  = make(map[string]string)
+)
+
+// Register makes a game available by its name and aliases. It is meant to be
+// called from init functions.
+func Register(game Game) {
+	info := game.Info()
+	if _, exists := registry[info.Name]; exists {
+		panic("games: " + info.Name + " registered twice")
+	}
+	registry[info.Name] = game
+	for _, alias := range info.Aliases {
+		aliases[alias] = info.Name
+	}
+}
+
+// Get looks up a game by name or alias.
+func Get(name string) (Game, bool) {
+	if canonical, ok := aliases[name]; ok {
+		name = canonical
+	}
+	game, ok := registry[name]
+	return game, ok
+}
+
+// All returns the registered games sorted by name.
+func All() []Game {
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var all []Game
+	for _, name := range names {
+		all = append(all, registry[name])
+	}
+	return all
+}
+
+// MaxMultipliers maps each game to the top of its payout table.
+func MaxMultipliers() map[string]int {
+	multipliers := make(map[string]int)
+	for name, game := range registry {
+		multipliers[name] = game.Info().MaxMultiplier()
+	}
+	return multipliers
+}
+
+// validateParams checks params against the game's schema.
+func validateParams(info Info, params Params) error {
+	known := make(map[string]bool)
+	for _, p := range info.Params {
+		known[p.Name] = true
+
+		value, ok := params[p.Name]
+		if !ok || value == "" {
+			if p.Required {
+				return fmt.Errorf("%s is required", p.Name)
+			}
+			continue
+		}
+		if p.Type == "int" {
+			n, err := params.Int(p.Name)
+			if err != nil {
+				return err
+			}
+			if p.Max > p.Min && (n < p.Min || n > p.Max) {
+				return fmt.Errorf("%s must be between %d and %d", p.Name, p.Min, p.Max)
+			}
+		}
+	}
+	for name := range params {
+		if !known[name] {
+			return fmt.Errorf("%s takes no parameter %q", info.Name, name)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks a bet of amount on game without playing it.
+func Validate(game Game, amount int, params Params) error {
+	if amount <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+	if err := validateParams(game.Info(), params); err != nil {
+		return err
+	}
+	if err := game.Validate(amount, params); err != nil {
+		return err
+	}
+	return CheckLimits(game.Info().Name, amount, game.MaxPayout(amount, params))
+}
+
+// Play builds the signed transaction of a bet of player against house. It
+// panics like the other transaction constructors when funds are missing.
+// jackpot may be nil to play without the progressive jackpot.
+func Play(game Game, player, house *wallet.Wallet, jackpot *blockchain.Jackpot, amount int, params Params, utxoSet *blockchain.UTXOSet, roll *blockchain.FairRoll) (*blockchain.GameResult, error) {
+	if err := Validate(game, amount, params); err != nil {
+		return nil, err
+	}
+	if err := (Policies{Blockchain: utxoSet.Blockchain}).Check(string(player.Address()), amount); err != nil {
+		return nil, err
+	}
+
+	info := game.Info()
+	result := blockchain.NewGameTransaction(player, house, jackpot, amount, utxoSet, info.Name, roll, game.MaxPayout(amount, params), func(bet int) (blockchain.GameRecord, bool) {
+		outcome := game.Outcome(roll)
+		record := game.Settle(bet, params, outcome)
+		if len(params) > 0 {
+			record.Params = params
+		}
+
+		hit := false
+		if g, ok := game.(JackpotGame); ok {
+			hit = g.JackpotHit(params, outcome)
+		}
+		return record, hit
+	})
+
+	return result, nil
+}
+
+// Verify recomputes the outcome of a bet on a revealed server seed.
+func Verify(name string, serverSeed []byte, clientSeed string, nonce int) (int, string, error) {
+	game, ok := Get(name)
+	if !ok {
+		return 0, "", fmt.Errorf("unknown game %q", name)
+	}
+
+	outcome := game.Outcome(blockchain.NewFairRNG(serverSeed, clientSeed, nonce))
+	return outcome, game.Describe(outcome), nil
+}
@@ -0,0 +1,403 @@
+package games
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// Players can hold themselves to a responsible gambling policy: a daily loss
+// limit, a wager cap on the coins staked a day, a cool-off break and
+// self-exclusion until a date. The node keeps the policies in the chain
+// database and refuses every bet of an address that breaks its policy:
+// house games, blackjack, crash, lottery tickets and wagers between
+// players. The day's stakes and losses are those of the house games and
+// crash rounds of the last 24 hours, open blackjack hands and crash bets
+// included.
+//
+// A policy only changes with a message signed by the address's key, so
+// nobody else can lift it. Tighter limits apply at once, looser ones after
+// PolicyDelay, and a cool-off or self-exclusion can be extended but not cut
+// short.
+
+var (
+	policyPrefix = []byte("policy-")
+	policyMu     sync.Mutex
+
+	// betLocks hold an address from the check of its bet until the bet is
+	// mined, so two bets can't both pass a limit only one of them fits
+	betLocksMu sync.Mutex
+	betLocks   = make(map[string]*sync.Mutex)
+
+	// ErrPolicySignature is returned by Apply for a message the key of its
+	// address didn't sign
+	ErrPolicySignature = errors.New("the policy message is not signed by its address")
+)
+
+const (
+	// PolicyDelay is how long a loosened limit waits before it applies
+	PolicyDelay = 24 * time.Hour
+	// PolicyMessageAge is how far the timestamp of a policy message may be
+	// from the node's clock
+	PolicyMessageAge = 10 * time.Minute
+	// MaxCoolOffHours is the longest cool-off, self-exclusion is for longer
+	MaxCoolOffHours = 6 * 7 * 24
+
+	policyWindow = 24 * time.Hour
+)
+
+// PolicyLimits are the limits of a policy, zero for no limit.
+type PolicyLimits struct {
+	DailyLossLimit int `json:"dailyLossLimit"`
+	WagerCap       int `json:"wagerCap"`
+}
+
+type Policy struct {
+	Address string `json:"address"`
+	PolicyLimits
+	CoolOffUntil  int64 `json:"coolOffUntil,omitempty"`
+	ExcludedUntil int64 `json:"excludedUntil,omitempty"`
+
+	// Pending are loosened limits that apply from PendingAt
+	Pending   *PolicyLimits `json:"pending,omitempty"`
+	PendingAt int64         `json:"pendingAt,omitempty"`
+
+	// Updated is the timestamp of the last message, older ones are replays
+	Updated int64 `json:"updated,omitempty"`
+}
+
+// PolicyError is the error of a bet a policy refuses.
+type PolicyError struct {
+	Address string
+	Reason  string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("the responsible gambling policy of %s refuses this bet: %s", e.Address, e.Reason)
+}
+
+// PolicyMessage is what a player signs to change a policy. Omitted limits
+// are kept, zero removes them. CoolOffHours starts a break from now and
+// ExcludeUntil (YYYY-MM-DD) excludes the address through the end of that
+// day, UTC.
+type PolicyMessage struct {
+	Address        string `json:"address"`
+	DailyLossLimit *int   `json:"dailyLossLimit,omitempty"`
+	WagerCap       *int   `json:"wagerCap,omitempty"`
+	CoolOffHours   int    `json:"coolOffHours,omitempty"`
+	ExcludeUntil   string `json:"excludeUntil,omitempty"`
+	// Timestamp in Unix milliseconds, as Date.now() gives it
+	Timestamp int64 `json:"timestamp"`
+}
+
+// Text is the signed form of the message, one field per line.
+func (m PolicyMessage) Text() string {
+	limit := func(value *int) string {
+		if value == nil {
+			return "keep"
+		}
+		return fmt.Sprint(*value)
+	}
+
+	lines := []string{
+		"responsible gambling policy",
+		"address: " + m.Address,
+		"dailyLossLimit: " + limit(m.DailyLossLimit),
+		"wagerCap: " + limit(m.WagerCap),
+		fmt.Sprintf("coolOffHours: %d", m.CoolOffHours),
+		"excludeUntil: " + m.ExcludeUntil,
+		fmt.Sprintf("timestamp: %d", m.Timestamp),
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m PolicyMessage) Digest() []byte {
+	hash := sha256.Sum256([]byte(m.Text()))
+	return hash[:]
+}
+
+// SignedPolicy is a policy message with the public key of its address and
+// the signature of Digest, both hex.
+type SignedPolicy struct {
+	PolicyMessage
+	PubKey    string `json:"pubKey"`
+	Signature string `json:"signature"`
+}
+
+func SignPolicy(m PolicyMessage, w *wallet.Wallet) SignedPolicy {
+	return SignedPolicy{
+		PolicyMessage: m,
+		PubKey:        hex.EncodeToString(w.PublicKey),
+		Signature:     hex.EncodeToString(w.PrivateKey.Sign(m.Digest())),
+	}
+}
+
+// Verify checks that the key of the message's address signed it.
+func (s SignedPolicy) Verify() error {
+	pubKey, err := hex.DecodeString(s.PubKey)
+	if err != nil || len(pubKey) == 0 {
+		return errors.New("pubKey must be the hex public key of the address")
+	}
+	signature, err := hex.DecodeString(s.Signature)
+	if err != nil {
+		return errors.New("signature must be hex")
+	}
+
+	if string((wallet.Wallet{PublicKey: pubKey}).Address()) != s.Address {
+		return fmt.Errorf("the public key is not the key of %s", s.Address)
+	}
+	if !wallet.VerifySignature(pubKey, s.Digest(), signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// PolicyStatus is a policy with the stakes and losses it counts.
+type PolicyStatus struct {
+	Policy
+	Wagered int `json:"wagered24h"`
+	Lost    int `json:"lost24h"`
+}
+
+// Policies keeps the policies of a node in the chain database.
+type Policies struct {
+	Blockchain *blockchain.BlockChain
+}
+
+func policyKey(address string) []byte {
+	return append(append([]byte{}, policyPrefix...), address...)
+}
+
+func (p Policies) save(policy *Policy) {
+	var buff bytes.Buffer
+	blockchain.Handle(gob.NewEncoder(&buff).Encode(policy))
+
+	err := p.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set(policyKey(policy.Address), buff.Bytes())
+	})
+	blockchain.Handle(err)
+}
+
+// Policy returns the policy of address, an empty one if it has none.
+func (p Policies) Policy(address string) Policy {
+	policy := Policy{Address: address}
+
+	err := p.Blockchain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(policyKey(address))
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(&policy)
+	})
+	if err != nil && err != badger.ErrKeyNotFound {
+		blockchain.Handle(err)
+	}
+
+	if policy.Pending != nil && time.Now().Unix() >= policy.PendingAt {
+		policy.PolicyLimits = *policy.Pending
+		policy.Pending, policy.PendingAt = nil, 0
+	}
+	return policy
+}
+
+// tighter reports whether limit is at least as strict as current.
+func tighter(limit, current int) bool {
+	if current == 0 {
+		return true
+	}
+	return limit != 0 && limit <= current
+}
+
+// Apply checks a signed message and changes the policy of its address.
+func (p Policies) Apply(signed SignedPolicy) (*Policy, error) {
+	m := signed.PolicyMessage
+	if !wallet.ValidateAddress(m.Address) {
+		return nil, errors.New("invalid address")
+	}
+	if err := signed.Verify(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPolicySignature, err)
+	}
+
+	now := time.Now()
+	if age := now.Sub(time.UnixMilli(m.Timestamp)); age > PolicyMessageAge || age < -PolicyMessageAge {
+		return nil, fmt.Errorf("the message timestamp must be within %s of the node's clock", PolicyMessageAge)
+	}
+	for _, limit := range []*int{m.DailyLossLimit, m.WagerCap} {
+		if limit != nil && *limit < 0 {
+			return nil, errors.New("limits can't be negative")
+		}
+	}
+	if m.CoolOffHours < 0 || m.CoolOffHours > MaxCoolOffHours {
+		return nil, fmt.Errorf("a cool-off lasts from 1 to %d hours, use self-exclusion for longer", MaxCoolOffHours)
+	}
+
+	var excludedUntil int64
+	if m.ExcludeUntil != "" {
+		day, err := time.Parse("2006-01-02", m.ExcludeUntil)
+		if err != nil {
+			return nil, errors.New("excludeUntil must be a date, YYYY-MM-DD")
+		}
+		excludedUntil = day.Add(24 * time.Hour).Unix()
+		if excludedUntil <= now.Unix() {
+			return nil, errors.New("excludeUntil must not be in the past")
+		}
+	}
+
+	policyMu.Lock()
+	defer policyMu.Unlock()
+
+	policy := p.Policy(m.Address)
+	if m.Timestamp <= policy.Updated {
+		return nil, errors.New("the message is not newer than the last policy change")
+	}
+
+	// Tighter limits apply now, looser ones wait
+	limits := policy.PolicyLimits
+	if m.DailyLossLimit != nil {
+		limits.DailyLossLimit = *m.DailyLossLimit
+	}
+	if m.WagerCap != nil {
+		limits.WagerCap = *m.WagerCap
+	}
+	policy.Pending, policy.PendingAt = nil, 0
+	if !tighter(limits.DailyLossLimit, policy.DailyLossLimit) || !tighter(limits.WagerCap, policy.WagerCap) {
+		pending := limits
+		policy.Pending, policy.PendingAt = &pending, now.Add(PolicyDelay).Unix()
+	}
+	if tighter(limits.DailyLossLimit, policy.DailyLossLimit) {
+		policy.DailyLossLimit = limits.DailyLossLimit
+	}
+	if tighter(limits.WagerCap, policy.WagerCap) {
+		policy.WagerCap = limits.WagerCap
+	}
+
+	// Breaks only get longer
+	if m.CoolOffHours > 0 {
+		if until := now.Add(time.Duration(m.CoolOffHours) * time.Hour).Unix(); until > policy.CoolOffUntil {
+			policy.CoolOffUntil = until
+		}
+	}
+	if excludedUntil > policy.ExcludedUntil {
+		policy.ExcludedUntil = excludedUntil
+	}
+
+	policy.Updated = m.Timestamp
+	p.save(&policy)
+
+	return &policy, nil
+}
+
+// Activity is what address staked and lost in house games and crash since
+// since, counting the stakes of open blackjack sessions and crash bets as
+// lost.
+func (p Policies) Activity(address string, since time.Time) (wagered, lost int) {
+	for _, game := range (blockchain.GameIndex{Blockchain: p.Blockchain}).Records(address) {
+		if game.Timestamp < since.Unix() {
+			continue
+		}
+		wagered += game.Record.Bet
+		lost += game.Record.Bet - game.Record.Payout - game.Record.JackpotPayout
+	}
+	for _, session := range (BlackjackTable{Blockchain: p.Blockchain}).Sessions(address) {
+		if !session.Settled {
+			wagered += session.totalStake()
+			lost += session.totalStake()
+		}
+	}
+	settled, open := CrashTable{Blockchain: p.Blockchain}.Bets(address, since)
+	for _, bet := range settled {
+		wagered += bet.Amount
+		lost += bet.Amount - bet.Payout
+	}
+	if open != nil {
+		wagered += open.Amount
+		lost += open.Amount
+	}
+
+	if lost < 0 {
+		lost = 0
+	}
+	return wagered, lost
+}
+
+func (p Policies) Status(address string) PolicyStatus {
+	status := PolicyStatus{Policy: p.Policy(address)}
+	status.Wagered, status.Lost = p.Activity(address, time.Now().Add(-policyWindow))
+	return status
+}
+
+// LockBets holds the bets of addresses until the returned func is called.
+// A bet is placed under it from its Check until it is mined. It is taken
+// before the chain's spend lock, and addresses are locked in order so two
+// bets sharing them can't deadlock.
+func LockBets(addresses ...string) func() {
+	sorted := append([]string{}, addresses...)
+	sort.Strings(sorted)
+
+	var locks []*sync.Mutex
+	betLocksMu.Lock()
+	for i, address := range sorted {
+		if i > 0 && address == sorted[i-1] {
+			continue
+		}
+		if betLocks[address] == nil {
+			betLocks[address] = &sync.Mutex{}
+		}
+		locks = append(locks, betLocks[address])
+	}
+	betLocksMu.Unlock()
+
+	for _, mu := range locks {
+		mu.Lock()
+	}
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
+
+// Check refuses a bet of stake by address that would break its policy. The
+// error is a *PolicyError.
+func (p Policies) Check(address string, stake int) error {
+	policy := p.Policy(address)
+	now := time.Now()
+
+	refuse := func(format string, args ...interface{}) error {
+		return &PolicyError{Address: address, Reason: fmt.Sprintf(format, args...)}
+	}
+	if now.Unix() < policy.ExcludedUntil {
+		return refuse("self-excluded until %s", time.Unix(policy.ExcludedUntil, 0).UTC().Format(time.RFC3339))
+	}
+	if now.Unix() < policy.CoolOffUntil {
+		return refuse("cooling off until %s", time.Unix(policy.CoolOffUntil, 0).UTC().Format(time.RFC3339))
+	}
+	if policy.WagerCap == 0 && policy.DailyLossLimit == 0 {
+		return nil
+	}
+
+	wagered, lost := p.Activity(address, now.Add(-policyWindow))
+	if policy.WagerCap != 0 && wagered+stake > policy.WagerCap {
+		return refuse("staking %d more would take the last 24 hours to %d, over the wager cap of %d", stake, wagered+stake, policy.WagerCap)
+	}
+	if policy.DailyLossLimit != 0 && lost+stake > policy.DailyLossLimit {
+		return refuse("losing %d more would take the losses of the last 24 hours to %d, over the daily loss limit of %d", stake, lost+stake, policy.DailyLossLimit)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package games
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+)
+
+func init() {
+	Register(roulette{})
+}
+
+// European roulette: a single zero wheel with pockets 0-36. Several bets
+// can be placed on one spin, written as TYPE[:TARGET]=STAKE and separated by
+// commas, e.g. "straight:17=10,split:17-20=5,red=5,dozen:2=5".
+type roulette struct{}
+
+const roulettePockets = 37
+
+var rouletteRed = map[int]bool{
+	1: true, 3: true, 5: true, 7: true, 9: true, 12: true, 14: true, 16: true, 18: true,
+	19: true, 21: true, 23: true, 25: true, 27: true, 30: true, 32: true, 34: true, 36: true,
+}
+
+// rouletteDefault pays the standard odds, e.g. 35:1 for a straight up bet,
+// written as the total returned including the stake.
+var rouletteDefault = []Payout{
+	{Bet: "straight", Multiplier: 36, WinOutcomes: 1, Outcomes: roulettePockets},
+	{Bet: "split", Multiplier: 18, WinOutcomes: 2, Outcomes: roulettePockets},
+	{Bet: "street", Multiplier: 12, WinOutcomes: 3, Outcomes: roulettePockets},
+	{Bet: "corner", Multiplier: 9, WinOutcomes: 4, Outcomes: roulettePockets},
+	{Bet: "dozen", Multiplier: 3, WinOutcomes: 12, Outcomes: roulettePockets},
+	{Bet: "column", Multiplier: 3, WinOutcomes: 12, Outcomes: roulettePockets},
+	{Bet: "red", Multiplier: 2, WinOutcomes: 18, Outcomes: roulettePockets},
+	{Bet: "black", Multiplier: 2, WinOutcomes: 18, Outcomes: roulettePockets},
+	{Bet: "odd", Multiplier: 2, WinOutcomes: 18, Outcomes: roulettePockets},
+	{Bet: "even", Multiplier: 2, WinOutcomes: 18, Outcomes: roulettePockets},
+	{Bet: "low", Multiplier: 2, WinOutcomes: 18, Outcomes: roulettePockets},
+	{Bet: "high", Multiplier: 2, WinOutcomes: 18, Outcomes: roulettePockets},
+}
+
+func rouletteTable() []Payout { return configured("roulette", rouletteDefault) }
+
+func roulettePayout(kind string) Payout {
+	for _, p := range rouletteTable() {
+		if p.Bet == kind {
+			return p
+		}
+	}
+	return Payout{}
+}
+
+type rouletteBet struct {
+	Kind    string
+	Numbers map[int]bool
+	Stake   int
+}
+
+func (b rouletteBet) payout(pocket int) int {
+	if b.Numbers[pocket] {
+		return b.Stake * roulettePayout(b.Kind).Multiplier
+	}
+	return 0
+}
+
+func numberSet(numbers ...int) map[int]bool {
+	set := make(map[int]bool)
+	for _, n := range numbers {
+		set[n] = true
+	}
+	return set
+}
+
+// parseNumbers reads "17" or "17-20" style targets.
+func parseNumbers(target string) ([]int, error) {
+	var numbers []int
+	for _, part := range strings.Split(target, "-") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || n > 36 {
+			return nil, fmt.Errorf("%q is not a roulette number", part)
+		}
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// rouletteNumbers returns the pockets covered by a bet on the layout of
+// three columns and twelve rows, e.g. row 1 is 1, 2, 3.
+func rouletteNumbers(kind, target string) (map[int]bool, error) {
+	switch kind {
+	case "red", "black", "odd", "even", "low", "high":
+		if target != "" {
+			return nil, fmt.Errorf("%s takes no target", kind)
+		}
+		set := make(map[int]bool)
+		for n := 1; n <= 36; n++ {
+			if kind == "red" && rouletteRed[n] || kind == "black" && !rouletteRed[n] ||
+				kind == "odd" && n%2 == 1 || kind == "even" && n%2 == 0 ||
+				kind == "low" && n <= 18 || kind == "high" && n >= 19 {
+				set[n] = true
+			}
+		}
+		return set, nil
+
+	case "dozen", "column":
+		i, err := strconv.Atoi(target)
+		if err != nil || i < 1 || i > 3 {
+			return nil, fmt.Errorf("%s must be 1, 2 or 3", kind)
+		}
+		set := make(map[int]bool)
+		for n := 1; n <= 36; n++ {
+			if kind == "dozen" && (n-1)/12+1 == i || kind == "column" && (n-1)%3+1 == i {
+				set[n] = true
+			}
+		}
+		return set, nil
+	}
+
+	numbers, err := parseNumbers(target)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "straight":
+		if len(numbers) != 1 {
+			return nil, errors.New("straight needs one number")
+		}
+		return numberSet(numbers...), nil
+
+	case "split":
+		if len(numbers) != 2 || numbers[0] == 0 {
+			return nil, errors.New("split needs two adjacent numbers, e.g. 17-20")
+		}
+		a, b := numbers[0], numbers[1]
+		sameRow := b == a+1 && (a-1)/3 == (b-1)/3
+		if !sameRow && b != a+3 {
+			return nil, fmt.Errorf("%d and %d are not adjacent", a, b)
+		}
+		return numberSet(a, b), nil
+
+	case "street":
+		// Any number of the row picks the street
+		if len(numbers) != 1 || numbers[0] == 0 {
+			return nil, errors.New("street needs a number of the row, e.g. 13")
+		}
+		first := (numbers[0]-1)/3*3 + 1
+		return numberSet(first, first+1, first+2), nil
+
+	case "corner":
+		if len(numbers) != 4 || numbers[0] == 0 {
+			return nil, errors.New("corner needs four numbers, e.g. 17-18-20-21")
+		}
+		a := numbers[0]
+		if a%3 == 0 || numbers[1] != a+1 || numbers[2] != a+3 || numbers[3] != a+4 {
+			return nil, fmt.Errorf("%s is not a corner", target)
+		}
+		return numberSet(numbers...), nil
+	}
+
+	return nil, fmt.Errorf("unknown roulette bet %q", kind)
+}
+
+func parseRouletteBets(spec string) ([]rouletteBet, error) {
+	var bets []rouletteBet
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		betPart, stakePart, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("bet %q has no stake, use TYPE[:TARGET]=STAKE", item)
+		}
+		stake, err := strconv.Atoi(stakePart)
+		if err != nil || stake <= 0 {
+			return nil, fmt.Errorf("bet %q needs a positive stake", item)
+		}
+
+		kind, target, _ := strings.Cut(strings.ToLower(betPart), ":")
+		numbers, err := rouletteNumbers(kind, target)
+		if err != nil {
+			return nil, err
+		}
+		bets = append(bets, rouletteBet{Kind: kind, Numbers: numbers, Stake: stake})
+	}
+
+	return bets, nil
+}
+
+func (roulette) Info() Info {
+	return Info{
+		Name:        "roulette",
+		Title:       "Roulette",
+		Description: "European roulette, several bets per spin",
+		Params: []Param{
+			{Name: "bets", Type: "string", Required: true,
+				Description: "Bets as TYPE[:TARGET]=STAKE separated by commas, e.g. straight:17=10,red=5"},
+		},
+		Payouts: rouletteTable(),
+	}
+}
+
+func (roulette) Validate(bet int, params Params) error {
+	bets, err := parseRouletteBets(params["bets"])
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, b := range bets {
+		total += b.Stake
+	}
+	if total != bet {
+		return fmt.Errorf("stakes add up to %d but the amount is %d", total, bet)
+	}
+
+	return nil
+}
+
+// MaxPayout is the payout of the best pocket for this set of bets.
+func (roulette) MaxPayout(bet int, params Params) int {
+	bets, err := parseRouletteBets(params["bets"])
+	if err != nil {
+		return bet * roulettePayout("straight").Multiplier
+	}
+
+	max := 0
+	for pocket := 0; pocket < roulettePockets; pocket++ {
+		payout := 0
+		for _, b := range bets {
+			payout += b.payout(pocket)
+		}
+		if payout > max {
+			max = payout
+		}
+	}
+	return max
+}
+
+func (roulette) Outcome(rng RNG) int { return rng.Intn(roulettePockets) }
+
+// Settle pays every winning bet of the spin. With the standard table each
+// roulette bet returns 36/37 of its stake on average, whatever its kind, so
+// the record states the odds of the kind with the largest stake. Only a
+// configuration changing the lines unevenly makes that an approximation.
+func (roulette) Settle(bet int, params Params, outcome int) blockchain.GameRecord {
+	bets, _ := parseRouletteBets(params["bets"])
+
+	odds := roulettePayout("straight")
+	largest := 0
+	for _, b := range bets {
+		if b.Stake > largest {
+			odds, largest = roulettePayout(b.Kind), b.Stake
+		}
+	}
+	record := blockchain.GameRecord{
+		Outcome:     outcome,
+		Multiplier:  odds.Multiplier,
+		WinOutcomes: odds.WinOutcomes,
+		Outcomes:    odds.Outcomes,
+	}
+	for _, b := range bets {
+		record.Payout += b.payout(outcome)
+	}
+
+	return record
+}
+
+func (roulette) Describe(outcome int) string {
+	switch {
+	case outcome == 0:
+		return "0 green"
+	case rouletteRed[outcome]:
+		return fmt.Sprintf("%d red", outcome)
+	default:
+		return fmt.Sprintf("%d black", outcome)
+	}
+}
@@ -0,0 +1,249 @@
+package games
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+)
+
+// Simulate plays many rounds of a game offline to check that its logic and
+// the RNG produce the odds the payout table promises. Round i draws from the
+// provably fair RNG with a server seed derived from the simulation seed and
+// nonce i, exactly like a real bet, so a simulation is reproducible and can
+// run on all CPUs. The jackpot is left out, it is funded separately.
+
+// UniformGame is implemented by games whose outcomes are equally likely
+// values of a fixed set. Simulate computes the exact odds of their bets and
+// tests the outcomes for bias.
+type UniformGame interface {
+	OutcomeValues() []int
+}
+
+func (coinflip) OutcomeValues() []int    { return intRange(0, 1) }
+func (dice) OutcomeValues() []int        { return intRange(1, 6) }
+func (numberRange) OutcomeValues() []int { return intRange(1, 100) }
+func (roulette) OutcomeValues() []int    { return intRange(0, roulettePockets-1) }
+
+func intRange(from, to int) []int {
+	values := make([]int, 0, to-from+1)
+	for v := from; v <= to; v++ {
+		values = append(values, v)
+	}
+	return values
+}
+
+// Simulation RNGs
+const (
+	// SimulateFair is the provably fair RNG bets are played with
+	SimulateFair = "fair"
+	// SimulateByte reduces one random byte modulo n, like games did before
+	// the provably fair RNG, to show what a biased RNG looks like
+	SimulateByte = "byte"
+)
+
+// byteRNG is the biased randomBytes[0] % n the games used to draw from.
+type byteRNG struct {
+	*blockchain.FairRNG
+}
+
+func (r byteRNG) Intn(n int) int { return int(r.Uint32()>>24) % n }
+
+type SimulationReport struct {
+	Game   string `json:"game"`
+	RNG    string `json:"rng"`
+	Rounds int    `json:"rounds"`
+	Bet    int    `json:"bet"`
+	Params Params `json:"params,omitempty"`
+	Seed   int64  `json:"seed"`
+
+	Wagered int64 `json:"wagered"`
+	PaidOut int64 `json:"paidOut"`
+	Wins    int   `json:"wins"`
+
+	// Rates and returns in percent: measured, exact for the game logic
+	// (UniformGame only) and as advertised by the odds of the bet record
+	WinRate           float64 `json:"winRate"`
+	ExpectedWinRate   float64 `json:"expectedWinRate,omitempty"`
+	AdvertisedWinRate float64 `json:"advertisedWinRate"`
+	RTP               float64 `json:"rtp"`
+	ExpectedRTP       float64 `json:"expectedRtp,omitempty"`
+	AdvertisedRTP     float64 `json:"advertisedRtp"`
+
+	// Variance of the return of one round, as a multiple of the bet
+	Variance         float64 `json:"variance"`
+	ExpectedVariance float64 `json:"expectedVariance,omitempty"`
+	// RTPDeviation is how many standard errors the RTP is off the expected
+	// or, failing that, the advertised RTP
+	RTPDeviation float64 `json:"rtpDeviation"`
+
+	// Outcome bias: a chi-square test of the outcome counts against a
+	// uniform distribution, with its z-score (Wilson-Hilferty)
+	Outcomes  map[int]int `json:"outcomes,omitempty"`
+	ChiSquare float64     `json:"chiSquare,omitempty"`
+	Freedom   int         `json:"degreesOfFreedom,omitempty"`
+	BiasZ     float64     `json:"biasZ,omitempty"`
+}
+
+// Suspicious reports whether the RTP or the outcome distribution is further
+// off than chance explains, at four standard deviations.
+func (r SimulationReport) Suspicious() bool {
+	return math.Abs(r.RTPDeviation) > 4 || r.BiasZ > 4
+}
+
+type simulationTally struct {
+	paid     int64
+	sumSq    float64
+	wins     int
+	outcomes map[int]int
+}
+
+// Simulate plays rounds bets of bet with params on game. rng is SimulateFair
+// or SimulateByte.
+func Simulate(game Game, rounds, bet int, params Params, seed int64, rng string) (SimulationReport, error) {
+	if rounds <= 0 {
+		return SimulationReport{}, fmt.Errorf("rounds must be greater than 0")
+	}
+	if rng != SimulateFair && rng != SimulateByte {
+		return SimulationReport{}, fmt.Errorf("unknown RNG %q, use %s or %s", rng, SimulateFair, SimulateByte)
+	}
+	if err := Validate(game, bet, params); err != nil {
+		return SimulationReport{}, err
+	}
+
+	info := game.Info()
+	serverSeed := sha256.Sum256([]byte(fmt.Sprintf("simulate:%d", seed)))
+	newRNG := func(nonce int) RNG {
+		fair := blockchain.NewFairRNG(serverSeed[:], info.Name, nonce)
+		if rng == SimulateByte {
+			return byteRNG{fair}
+		}
+		return fair
+	}
+
+	// Settle once per outcome when the outcomes are known
+	payouts := make(map[int]int)
+	uniform, isUniform := game.(UniformGame)
+	if isUniform {
+		for _, v := range uniform.OutcomeValues() {
+			payouts[v] = game.Settle(bet, params, v).Payout
+		}
+	}
+	payout := func(outcome int) int {
+		if p, ok := payouts[outcome]; ok {
+			return p
+		}
+		return game.Settle(bet, params, outcome).Payout
+	}
+
+	workers := runtime.NumCPU()
+	tallies := make([]simulationTally, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			t := simulationTally{outcomes: make(map[int]int)}
+			for nonce := w; nonce < rounds; nonce += workers {
+				outcome := game.Outcome(newRNG(nonce))
+				p := payout(outcome)
+				t.paid += int64(p)
+				ret := float64(p) / float64(bet)
+				t.sumSq += ret * ret
+				if p > 0 {
+					t.wins++
+				}
+				t.outcomes[outcome]++
+			}
+			tallies[w] = t
+		}(w)
+	}
+	wg.Wait()
+
+	report := SimulationReport{
+		Game:     info.Name,
+		RNG:      rng,
+		Rounds:   rounds,
+		Bet:      bet,
+		Params:   params,
+		Seed:     seed,
+		Wagered:  int64(rounds) * int64(bet),
+		Outcomes: make(map[int]int),
+	}
+	sumSq := 0.0
+	for _, t := range tallies {
+		report.PaidOut += t.paid
+		report.Wins += t.wins
+		sumSq += t.sumSq
+		for outcome, n := range t.outcomes {
+			report.Outcomes[outcome] += n
+		}
+	}
+
+	n := float64(rounds)
+	mean := float64(report.PaidOut) / float64(report.Wagered)
+	report.WinRate = 100 * float64(report.Wins) / n
+	report.RTP = 100 * mean
+	report.Variance = sumSq/n - mean*mean
+
+	// The odds the bet record states. Those of combined payout lines only
+	// add up to an RTP, not a win rate.
+	record := game.Settle(bet, params, game.Outcome(newRNG(0)))
+	if record.Outcomes > 0 {
+		if !info.Combined {
+			report.AdvertisedWinRate = 100 * float64(record.WinOutcomes) / float64(record.Outcomes)
+		}
+		report.AdvertisedRTP = 100 * record.RTP()
+	}
+
+	expectedMean, expectedVariance := report.AdvertisedRTP/100, report.Variance
+	if isUniform {
+		values := uniform.OutcomeValues()
+		wins, sum, sumSq := 0, 0.0, 0.0
+		for _, v := range values {
+			ret := float64(payouts[v]) / float64(bet)
+			sum += ret
+			sumSq += ret * ret
+			if payouts[v] > 0 {
+				wins++
+			}
+		}
+		k := float64(len(values))
+		expectedMean = sum / k
+		expectedVariance = sumSq/k - expectedMean*expectedMean
+
+		report.ExpectedWinRate = 100 * float64(wins) / k
+		report.ExpectedRTP = 100 * expectedMean
+		report.ExpectedVariance = expectedVariance
+		report.ChiSquare, report.Freedom, report.BiasZ = chiSquareUniform(report.Outcomes, values, rounds)
+	}
+
+	if expectedVariance > 0 {
+		report.RTPDeviation = (mean - expectedMean) / math.Sqrt(expectedVariance/n)
+	}
+
+	return report, nil
+}
+
+// chiSquareUniform tests counts against equally likely values. The z-score
+// uses the Wilson-Hilferty approximation of the chi-square distribution.
+func chiSquareUniform(counts map[int]int, values []int, rounds int) (float64, int, float64) {
+	if len(values) < 2 {
+		return 0, 0, 0
+	}
+
+	expected := float64(rounds) / float64(len(values))
+	chi := 0.0
+	for _, v := range values {
+		d := float64(counts[v]) - expected
+		chi += d * d / expected
+	}
+
+	k := float64(len(values) - 1)
+	z := (math.Cbrt(chi/k) - (1 - 2/(9*k))) / math.Sqrt(2/(9*k))
+
+	return chi, len(values) - 1, z
+}
@@ -0,0 +1,464 @@
+package games
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+)
+
+func init() {
+	Register(slots{})
+}
+
+// A slot machine spins reels, each a strip of symbols, and shows Rows
+// consecutive stops of every reel. A payline picks one row per reel, and a
+// line pays when the symbols from the left reel on match a pay of the pay
+// table. The machine can be replaced in the configuration:
+//
+//	{"games": {"slots": {"machine": {"symbols": [...], "reels": [[...], ...],
+//	  "rows": 3, "paylines": [[1, 1, 1], ...],
+//	  "pays": [{"symbol": "seven", "count": 3, "multiplier": 100}, ...]}}}}
+//
+// The outcome of a spin is the stop of every reel, drawn one reel after the
+// other from the provably fair RNG and numbered in mixed radix: the stop of
+// the first reel plus the length of its strip times the stop of the second,
+// and so on.
+type slots struct{}
+
+// SlotPay pays Multiplier times the line bet, stake included, for Count or
+// more Symbol in a row from the left reel. Of the pays a line qualifies
+// for, the one with the highest count applies.
+type SlotPay struct {
+	Symbol     string `json:"symbol"`
+	Count      int    `json:"count"`
+	Multiplier int    `json:"multiplier"`
+}
+
+// SlotMachine defines the reels, paylines and pay table of the slots game.
+// Paylines list the row, counted from 0 at the top, the line crosses on
+// every reel.
+type SlotMachine struct {
+	Symbols  []string   `json:"symbols"`
+	Reels    [][]string `json:"reels"`
+	Rows     int        `json:"rows"`
+	Paylines [][]int    `json:"paylines"`
+	Pays     []SlotPay  `json:"pays"`
+}
+
+// maxSlotOutcomes caps the number of stop combinations so outcomes stay
+// well within an int.
+const maxSlotOutcomes = 1 << 48
+
+// defaultSlotMachine is a classic three reel machine with five lines: the
+// three rows and the two diagonals.
+var defaultSlotMachine = SlotMachine{
+	Symbols: []string{"cherry", "lemon", "orange", "plum", "bell", "bar", "seven"},
+	Reels: [][]string{
+		{"cherry", "lemon", "orange", "plum", "lemon", "bell", "orange", "lemon", "bar", "plum",
+			"lemon", "orange", "cherry", "plum", "lemon", "seven", "orange", "plum", "bell", "bar"},
+		{"lemon", "cherry", "plum", "orange", "lemon", "bar", "plum", "lemon", "bell", "orange",
+			"lemon", "plum", "seven", "orange", "lemon", "cherry", "plum", "orange", "bar", "bell"},
+		{"orange", "lemon", "cherry", "plum", "bell", "lemon", "orange", "bar", "plum", "lemon",
+			"seven", "orange", "plum", "lemon", "cherry", "bar", "orange", "lemon", "plum", "bell"},
+	},
+	Rows:     3,
+	Paylines: [][]int{{1, 1, 1}, {0, 0, 0}, {2, 2, 2}, {0, 1, 2}, {2, 1, 0}},
+	Pays: []SlotPay{
+		{Symbol: "cherry", Count: 1, Multiplier: 2},
+		{Symbol: "cherry", Count: 2, Multiplier: 5},
+		{Symbol: "cherry", Count: 3, Multiplier: 20},
+		{Symbol: "lemon", Count: 3, Multiplier: 12},
+		{Symbol: "orange", Count: 3, Multiplier: 20},
+		{Symbol: "plum", Count: 3, Multiplier: 20},
+		{Symbol: "bell", Count: 3, Multiplier: 60},
+		{Symbol: "bar", Count: 3, Multiplier: 100},
+		{Symbol: "seven", Count: 3, Multiplier: 500},
+	},
+}
+
+// CurrentSlotMachine is the slot machine in use.
+func CurrentSlotMachine() SlotMachine {
+	if machine := gameConfig("slots").Machine; machine != nil {
+		return *machine
+	}
+	return defaultSlotMachine
+}
+
+func (m SlotMachine) check() error {
+	if len(m.Reels) == 0 || m.Rows < 1 {
+		return errors.New("the machine needs reels and at least one row")
+	}
+
+	known := make(map[string]bool)
+	for _, symbol := range m.Symbols {
+		if symbol == "" || known[symbol] {
+			return fmt.Errorf("symbol %q is empty or listed twice", symbol)
+		}
+		known[symbol] = true
+	}
+
+	outcomes := 1
+	for i, strip := range m.Reels {
+		if len(strip) == 0 {
+			return fmt.Errorf("reel %d has no stops", i+1)
+		}
+		for _, symbol := range strip {
+			if !known[symbol] {
+				return fmt.Errorf("reel %d has unknown symbol %q", i+1, symbol)
+			}
+		}
+		if outcomes > maxSlotOutcomes/len(strip) {
+			return fmt.Errorf("the reels have more than %d stop combinations", maxSlotOutcomes)
+		}
+		outcomes *= len(strip)
+	}
+
+	if len(m.Paylines) == 0 {
+		return errors.New("the machine needs a payline")
+	}
+	for i, line := range m.Paylines {
+		if len(line) != len(m.Reels) {
+			return fmt.Errorf("payline %d needs a row for each of the %d reels", i+1, len(m.Reels))
+		}
+		for _, row := range line {
+			if row < 0 || row >= m.Rows {
+				return fmt.Errorf("payline %d has row %d, rows go from 0 to %d", i+1, row, m.Rows-1)
+			}
+		}
+	}
+
+	if len(m.Pays) == 0 {
+		return errors.New("the machine needs a pay table")
+	}
+	seen := make(map[string]bool)
+	for _, pay := range m.Pays {
+		if !known[pay.Symbol] {
+			return fmt.Errorf("pay for unknown symbol %q", pay.Symbol)
+		}
+		if pay.Count < 1 || pay.Count > len(m.Reels) {
+			return fmt.Errorf("%s pays for %d symbols, from 1 to %d reels", pay.Symbol, pay.Count, len(m.Reels))
+		}
+		if pay.Multiplier < 1 {
+			return fmt.Errorf("%s: multiplier must be at least 1", pay.name())
+		}
+		if seen[pay.name()] {
+			return fmt.Errorf("%s is paid twice", pay.name())
+		}
+		seen[pay.name()] = true
+	}
+
+	return nil
+}
+
+func (p SlotPay) name() string { return fmt.Sprintf("%dx %s", p.Count, p.Symbol) }
+
+// Outcomes is the number of stop combinations of the reels.
+func (m SlotMachine) Outcomes() int {
+	outcomes := 1
+	for _, strip := range m.Reels {
+		outcomes *= len(strip)
+	}
+	return outcomes
+}
+
+// stops decodes an outcome into the stop of every reel.
+func (m SlotMachine) stops(outcome int) []int {
+	stops := make([]int, len(m.Reels))
+	for i, strip := range m.Reels {
+		stops[i] = outcome % len(strip)
+		outcome /= len(strip)
+	}
+	return stops
+}
+
+// Grid is what the reels show for an outcome, row by row from the top. The
+// stop of a reel is its top row.
+func (m SlotMachine) Grid(outcome int) [][]string {
+	stops := m.stops(outcome)
+
+	grid := make([][]string, m.Rows)
+	for row := range grid {
+		grid[row] = make([]string, len(m.Reels))
+		for reel, strip := range m.Reels {
+			grid[row][reel] = strip[(stops[reel]+row)%len(strip)]
+		}
+	}
+	return grid
+}
+
+// pay finds the pay of count symbol in a row, if any.
+func (m SlotMachine) pay(symbol string, count int) (SlotPay, bool) {
+	best, found := SlotPay{}, false
+	for _, pay := range m.Pays {
+		if pay.Symbol == symbol && pay.Count <= count && (!found || pay.Count > best.Count) {
+			best, found = pay, true
+		}
+	}
+	return best, found
+}
+
+// linePay is the multiplier a payline of grid pays, 0 if it doesn't.
+func (m SlotMachine) linePay(grid [][]string, line []int) int {
+	symbol := grid[line[0]][0]
+	count := 1
+	for count < len(line) && grid[line[count]][count] == symbol {
+		count++
+	}
+
+	if pay, ok := m.pay(symbol, count); ok {
+		return pay.Multiplier
+	}
+	return 0
+}
+
+// spinPay is what the first lines paylines of an outcome pay together, as
+// a multiple of the line bet.
+func (m SlotMachine) spinPay(outcome, lines int) int {
+	grid := m.Grid(outcome)
+
+	total := 0
+	for _, line := range m.Paylines[:lines] {
+		total += m.linePay(grid, line)
+	}
+	return total
+}
+
+// maxSpinPay is the most the first lines paylines can pay together, as a
+// multiple of the line bet. Machines with too many stop combinations to
+// spin them all are assumed to pay the top pay on every line.
+func (m SlotMachine) maxSpinPay(lines int) int {
+	if m.Outcomes()*lines > maxSlotEnumeration {
+		return lines * m.topPay()
+	}
+
+	max := 0
+	for outcome := 0; outcome < m.Outcomes(); outcome++ {
+		if pay := m.spinPay(outcome, lines); pay > max {
+			max = pay
+		}
+	}
+	return max
+}
+
+// topPay is the highest multiplier of the pay table.
+func (m SlotMachine) topPay() int {
+	top := 0
+	for _, pay := range m.Pays {
+		if pay.Multiplier > top {
+			top = pay.Multiplier
+		}
+	}
+	return top
+}
+
+// lines is the number of paylines a bet plays, all unless params say less.
+func (m SlotMachine) lines(params Params) int {
+	if n, err := params.Int("lines"); err == nil && n >= 1 && n <= len(m.Paylines) {
+		return n
+	}
+	return len(m.Paylines)
+}
+
+func (slots) Info() Info {
+	m := CurrentSlotMachine()
+
+	// Every payline shows each reel at a uniformly random stop, so the pay
+	// table has the same odds on every line.
+	report := slotOdds(m)
+	payouts := make([]Payout, 0, len(report.Pays))
+	for _, pay := range report.Pays {
+		payouts = append(payouts, Payout{
+			Bet:         pay.Pay.name(),
+			Multiplier:  pay.Pay.Multiplier,
+			WinOutcomes: pay.Combinations,
+			Outcomes:    report.Combinations,
+		})
+	}
+
+	return Info{
+		Name:  "slots",
+		Title: "Slots",
+		Description: fmt.Sprintf("Spin %d reels on up to %d lines, the bet is split evenly across the lines, top pay %dx the line bet",
+			len(m.Reels), len(m.Paylines), m.topPay()),
+		Params: []Param{
+			{Name: "lines", Type: "int", Description: fmt.Sprintf("Number of paylines to play, all %d by default", len(m.Paylines)), Min: 1, Max: len(m.Paylines)},
+		},
+		Payouts:  payouts,
+		Combined: true,
+	}
+}
+
+func (slots) Validate(bet int, params Params) error {
+	if lines := CurrentSlotMachine().lines(params); bet%lines != 0 {
+		return fmt.Errorf("the bet must split evenly across %d lines", lines)
+	}
+	return nil
+}
+
+func (slots) MaxPayout(bet int, params Params) int {
+	m := CurrentSlotMachine()
+	lines := m.lines(params)
+	return bet / lines * m.maxSpinPay(lines)
+}
+
+func (slots) Outcome(rng RNG) int {
+	m := CurrentSlotMachine()
+
+	outcome, radix := 0, 1
+	for _, strip := range m.Reels {
+		outcome += rng.Intn(len(strip)) * radix
+		radix *= len(strip)
+	}
+	return outcome
+}
+
+// Settle pays every line played. A spin's odds don't fit one payout line,
+// so the record states them as the pays of a line weighted by the stop
+// combinations landing them, at 1x: its RTP is the RTP of the machine.
+func (slots) Settle(bet int, params Params, outcome int) blockchain.GameRecord {
+	m := CurrentSlotMachine()
+	lines := m.lines(params)
+	report := slotOdds(m)
+
+	return blockchain.GameRecord{
+		Outcome:     outcome,
+		Payout:      bet / lines * m.spinPay(outcome, lines),
+		Multiplier:  1,
+		WinOutcomes: report.WeightedPays,
+		Outcomes:    report.Combinations,
+	}
+}
+
+func (slots) Describe(outcome int) string {
+	var rows []string
+	for _, row := range CurrentSlotMachine().Grid(outcome) {
+		rows = append(rows, strings.Join(row, " "))
+	}
+	return "reels show " + strings.Join(rows, " / ")
+}
+
+// Grid implements GridGame.
+func (slots) Grid(outcome int) [][]string { return CurrentSlotMachine().Grid(outcome) }
+
+// SlotPayOdds are the odds of one pay of the pay table on a line.
+type SlotPayOdds struct {
+	Pay          SlotPay `json:"pay"`
+	Combinations int     `json:"combinations"`
+	// Probability of the pay on a line, and its share of the RTP, in percent
+	Probability float64 `json:"probability"`
+	RTP         float64 `json:"rtp"`
+}
+
+// SlotsReport is the theoretical return of a slot machine.
+type SlotsReport struct {
+	Reels        int   `json:"reels"`
+	Stops        []int `json:"stops"`
+	Lines        int   `json:"lines"`
+	Combinations int   `json:"combinations"`
+	// WeightedPays adds up the multiplier of every stop combination on one
+	// line, RTP = WeightedPays / Combinations
+	WeightedPays int           `json:"weightedPays"`
+	Pays         []SlotPayOdds `json:"pays"`
+	// Hit frequency of a line and RTP in percent. The RTP is the same for
+	// any number of lines, the bet is split across them.
+	HitRate   float64 `json:"hitRate"`
+	RTP       float64 `json:"rtp"`
+	HouseEdge float64 `json:"houseEdge"`
+	// MaxSpinPay is the most a spin on all lines pays, in line bets
+	MaxSpinPay int `json:"maxSpinPay"`
+
+	// Enumerated is set when every stop combination was spun through the
+	// game logic on all lines, which must pay out exactly the computed RTP
+	Enumerated    bool    `json:"enumerated"`
+	EnumeratedRTP float64 `json:"enumeratedRtp,omitempty"`
+}
+
+// maxSlotEnumeration caps the spins SlotsRTP plays to check its result.
+const maxSlotEnumeration = 2000000
+
+// SlotsRTP computes the exact return of a machine from its reels, see
+// slotOdds. Small machines are also checked by spinning every stop
+// combination, so the result covers the game logic as well as the
+// arithmetic.
+func SlotsRTP(m SlotMachine) SlotsReport {
+	report := slotOdds(m)
+	report.MaxSpinPay = m.maxSpinPay(report.Lines)
+
+	if report.Combinations*report.Lines <= maxSlotEnumeration {
+		total := 0
+		for outcome := 0; outcome < report.Combinations; outcome++ {
+			total += m.spinPay(outcome, report.Lines)
+		}
+		report.Enumerated = true
+		report.EnumeratedRTP = 100 * float64(total) / float64(report.Combinations*report.Lines)
+	}
+
+	return report
+}
+
+// slotOdds computes the odds of the pay table of a machine. Each reel
+// shows any stop with the same probability, independently of the others,
+// so on any payline the first count symbols are symbol with probability
+// c1/L1 * ... * ccount/Lcount, where c is how often the symbol is on a
+// reel's strip of L stops. The combinations of a pay are those where the
+// run from the left reel has exactly the length the pay applies to.
+func slotOdds(m SlotMachine) SlotsReport {
+	report := SlotsReport{
+		Reels:        len(m.Reels),
+		Lines:        len(m.Paylines),
+		Combinations: m.Outcomes(),
+	}
+
+	counts := make([]map[string]int, len(m.Reels))
+	for i, strip := range m.Reels {
+		report.Stops = append(report.Stops, len(strip))
+		counts[i] = make(map[string]int)
+		for _, symbol := range strip {
+			counts[i][symbol]++
+		}
+	}
+
+	combinations := make(map[string]int)
+	hits := 0
+	for _, symbol := range m.Symbols {
+		run := 1
+		for count := 1; count <= len(m.Reels); count++ {
+			run *= counts[count-1][symbol]
+
+			// Exactly count in a row: the next reel shows something else,
+			// the reels after it anything
+			exact := run
+			if count < len(m.Reels) {
+				exact *= len(m.Reels[count]) - counts[count][symbol]
+				for _, strip := range m.Reels[count+1:] {
+					exact *= len(strip)
+				}
+			}
+
+			if pay, ok := m.pay(symbol, count); ok && exact > 0 {
+				combinations[pay.name()] += exact
+				hits += exact
+			}
+		}
+	}
+
+	for _, pay := range m.Pays {
+		n := combinations[pay.name()]
+		odds := SlotPayOdds{
+			Pay:          pay,
+			Combinations: n,
+			Probability:  100 * float64(n) / float64(report.Combinations),
+			RTP:          100 * float64(n*pay.Multiplier) / float64(report.Combinations),
+		}
+		report.Pays = append(report.Pays, odds)
+		report.WeightedPays += n * pay.Multiplier
+	}
+
+	report.HitRate = 100 * float64(hits) / float64(report.Combinations)
+	report.RTP = 100 * float64(report.WeightedPays) / float64(report.Combinations)
+	report.HouseEdge = 100 - report.RTP
+
+	return report
+}
@@ -0,0 +1,437 @@
+package games
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// A tournament is played on one registered game with chips instead of
+// coins. Players pay the entry fee on chain (see blockchain/tournament.go)
+// and get the same stack of chips. Chip bets are drawn from the provably
+// fair RNG like real bets but only change the chip counts the node keeps.
+// Once the chain reaches the end height the entries are paid out to the top
+// places by chips, by the share of the pool each place wins. Ties go to
+// whoever entered first.
+
+var (
+	tournamentPrefix = []byte("tournament-")
+	tournamentMu     sync.Mutex
+)
+
+const (
+	TournamentOpen     = "open"
+	TournamentFinished = "finished"
+)
+
+// DefaultTournamentPrizes split the pool among the top three.
+var DefaultTournamentPrizes = []int{50, 30, 20}
+
+type TournamentEntry struct {
+	Player  string `json:"player"`
+	Chips   int    `json:"chips"`
+	Bets    int    `json:"bets"`
+	Wagered int    `json:"wagered"`
+	Entered int64  `json:"entered"`
+	EntryTx []byte `json:"-"`
+}
+
+// TournamentBet is one chip bet, kept so it can be verified once the server
+// seed is revealed.
+type TournamentBet struct {
+	Player         string `json:"player"`
+	Amount         int    `json:"amount"`
+	Params         Params `json:"params,omitempty"`
+	Outcome        int    `json:"outcome"`
+	Payout         int    `json:"payout"`
+	ServerSeedHash string `json:"serverSeedHash"`
+	ClientSeed     string `json:"clientSeed"`
+	Nonce          int    `json:"nonce"`
+}
+
+type Tournament struct {
+	ID         string `json:"id"`
+	Game       string `json:"game"`
+	EntryFee   int    `json:"entryFee"`
+	Chips      int    `json:"chips"`
+	Tournament string `json:"tournament"`
+	// Prizes are the percent of the pool each place wins, first place first
+	Prizes    []int             `json:"prizes"`
+	EndHeight int               `json:"endHeight"`
+	Created   int64             `json:"created"`
+	Status    string            `json:"status"`
+	Entries   []TournamentEntry `json:"entries"`
+	Bets      []TournamentBet   `json:"bets,omitempty"`
+
+	Results  []blockchain.TournamentPlace `json:"results,omitempty"`
+	PayoutTx []byte                       `json:"-"`
+}
+
+func (t *Tournament) Pool() int {
+	return t.EntryFee * len(t.Entries)
+}
+
+func (t *Tournament) entry(player string) *TournamentEntry {
+	for i := range t.Entries {
+		if t.Entries[i].Player == player {
+			return &t.Entries[i]
+		}
+	}
+	return nil
+}
+
+// Leaderboard ranks the entries by chips, ties by who entered first.
+func (t *Tournament) Leaderboard() []TournamentEntry {
+	entries := append([]TournamentEntry{}, t.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Chips > entries[j].Chips })
+	return entries
+}
+
+// places splits the pool among the leaders. Rounding leftovers, and the
+// shares of places nobody reached, go to first place.
+func (t *Tournament) places() []blockchain.TournamentPlace {
+	var places []blockchain.TournamentPlace
+
+	pool, paid := t.Pool(), 0
+	for i, entry := range t.Leaderboard() {
+		place := blockchain.TournamentPlace{Place: i + 1, Player: entry.Player, Chips: entry.Chips}
+		if i < len(t.Prizes) {
+			place.Prize = pool * t.Prizes[i] / 100
+			paid += place.Prize
+		}
+		places = append(places, place)
+	}
+	if len(places) > 0 {
+		places[0].Prize += pool - paid
+	}
+
+	return places
+}
+
+// TournamentBook keeps the tournaments of a node in the chain database.
+type TournamentBook struct {
+	Blockchain *blockchain.BlockChain
+	Wallets    *wallet.Wallets
+	NodeID     string
+}
+
+func tournamentKey(id string) []byte {
+	return append(append([]byte{}, tournamentPrefix...), id...)
+}
+
+func (b TournamentBook) save(t *Tournament) {
+	var buff bytes.Buffer
+	blockchain.Handle(gob.NewEncoder(&buff).Encode(t))
+
+	err := b.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set(tournamentKey(t.ID), buff.Bytes())
+	})
+	blockchain.Handle(err)
+}
+
+func (b TournamentBook) load(id string) (*Tournament, error) {
+	var tournament Tournament
+
+	err := b.Blockchain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(tournamentKey(id))
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(&tournament)
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("no tournament %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &tournament, nil
+}
+
+func (b TournamentBook) mine(tx *blockchain.Transaction) {
+	block := b.Blockchain.MineBlock([]*blockchain.Transaction{tx})
+	UTXOSet := blockchain.UTXOSet{Blockchain: b.Blockchain}
+	UTXOSet.Update(block)
+}
+
+// Create opens a tournament on game that ends blocks from now. prizes are
+// the percent of the pool of each place and must add up to 100, nil for
+// DefaultTournamentPrizes.
+func (b TournamentBook) Create(game string, entryFee, chips, blocks int, prizes []int) (*Tournament, error) {
+	g, ok := Get(game)
+	if !ok {
+		return nil, fmt.Errorf("unknown game %q", game)
+	}
+	if entryFee <= 0 || chips <= 0 {
+		return nil, errors.New("entry fee and chips must be greater than 0")
+	}
+	if blocks <= 0 {
+		return nil, errors.New("blocks must be greater than 0")
+	}
+	if prizes == nil {
+		prizes = DefaultTournamentPrizes
+	}
+	total := 0
+	for _, prize := range prizes {
+		if prize <= 0 {
+			return nil, errors.New("prizes must be greater than 0")
+		}
+		total += prize
+	}
+	if total != 100 {
+		return nil, fmt.Errorf("prizes add up to %d%%, not 100%%", total)
+	}
+
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	blockchain.Handle(err)
+
+	tw := b.Wallets.TournamentWallet(b.NodeID)
+	tournament := &Tournament{
+		ID:         hex.EncodeToString(id),
+		Game:       g.Info().Name,
+		EntryFee:   entryFee,
+		Chips:      chips,
+		Tournament: string(tw.Address()),
+		Prizes:     prizes,
+		EndHeight:  b.Blockchain.GetBestHeight() + blocks,
+		Created:    time.Now().Unix(),
+		Status:     TournamentOpen,
+	}
+
+	tournamentMu.Lock()
+	defer tournamentMu.Unlock()
+	b.save(tournament)
+
+	return tournament, nil
+}
+
+// open loads a tournament that still takes entries and bets.
+func (b TournamentBook) open(id string) (*Tournament, error) {
+	tournament, err := b.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.Status != TournamentOpen || b.Blockchain.GetBestHeight() >= tournament.EndHeight {
+		return tournament, fmt.Errorf("tournament %s is over", id)
+	}
+	return tournament, nil
+}
+
+// Register pays the entry fee of from and hands out the starting chips.
+func (b TournamentBook) Register(id, from string) (*Tournament, error) {
+	if _, ok := b.Wallets.Wallets[from]; !ok {
+		return nil, fmt.Errorf("wallet %s not found", from)
+	}
+
+	defer LockBets(from)()
+	b.Blockchain.LockSpends()
+	defer b.Blockchain.UnlockSpends()
+	tournamentMu.Lock()
+	defer tournamentMu.Unlock()
+
+	tournament, err := b.open(id)
+	if err != nil {
+		return tournament, err
+	}
+	if tournament.entry(from) != nil {
+		return tournament, fmt.Errorf("%s already entered tournament %s", from, id)
+	}
+	if err := (Policies{Blockchain: b.Blockchain}).Check(from, tournament.EntryFee); err != nil {
+		return tournament, err
+	}
+
+	player := b.Wallets.GetWallet(from)
+	record := blockchain.TournamentRecord{ID: id, Tournament: tournament.Tournament, Player: from}
+	UTXOSet := blockchain.UTXOSet{Blockchain: b.Blockchain}
+	tx := blockchain.NewTournamentEntryTransaction(&player, record, tournament.EntryFee, &UTXOSet)
+
+	// The entry is only saved once its fee is on chain. When its block was
+	// the last one, FinishDue runs after the locks are released.
+	b.mine(tx)
+	tournament.Entries = append(tournament.Entries, TournamentEntry{
+		Player:  from,
+		Chips:   tournament.Chips,
+		Entered: time.Now().Unix(),
+		EntryTx: tx.ID,
+	})
+	b.save(tournament)
+
+	return tournament, nil
+}
+
+// Play bets amount chips of from on the tournament's game.
+func (b TournamentBook) Play(id, from string, amount int, params Params, clientSeed string, nonce int) (*Tournament, *TournamentBet, error) {
+	tournamentMu.Lock()
+	defer tournamentMu.Unlock()
+
+	tournament, err := b.open(id)
+	if err != nil {
+		return tournament, nil, err
+	}
+	entry := tournament.entry(from)
+	if entry == nil {
+		return tournament, nil, fmt.Errorf("%s has not entered tournament %s", from, id)
+	}
+
+	game, ok := Get(tournament.Game)
+	if !ok {
+		return tournament, nil, fmt.Errorf("unknown game %q", tournament.Game)
+	}
+	// Chips aren't held to the limits of real bets
+	if amount <= 0 {
+		return tournament, nil, errors.New("amount must be greater than 0")
+	}
+	if amount > entry.Chips {
+		return tournament, nil, fmt.Errorf("%s has only %d chips", from, entry.Chips)
+	}
+	if err := validateParams(game.Info(), params); err != nil {
+		return tournament, nil, err
+	}
+	if err := game.Validate(amount, params); err != nil {
+		return tournament, nil, err
+	}
+
+	if clientSeed == "" {
+		clientSeed = blockchain.RandomClientSeed()
+	}
+	roll, err := blockchain.FairSeeds{Blockchain: b.Blockchain}.NewRoll(clientSeed, nonce)
+	if err != nil {
+		return tournament, nil, err
+	}
+
+	outcome := game.Outcome(roll)
+	record := game.Settle(amount, params, outcome)
+	bet := TournamentBet{
+		Player:         from,
+		Amount:         amount,
+		Params:         params,
+		Outcome:        outcome,
+		Payout:         record.Payout,
+		ServerSeedHash: hex.EncodeToString(roll.ServerSeedHash),
+		ClientSeed:     roll.ClientSeed,
+		Nonce:          roll.Nonce,
+	}
+
+	entry.Chips += record.Payout - amount
+	entry.Bets++
+	entry.Wagered += amount
+	tournament.Bets = append(tournament.Bets, bet)
+	b.save(tournament)
+
+	return tournament, &bet, nil
+}
+
+func (b TournamentBook) Tournament(id string) (*Tournament, error) {
+	return b.load(id)
+}
+
+// Tournaments lists every tournament, oldest first.
+func (b TournamentBook) Tournaments() []Tournament {
+	var tournaments []Tournament
+
+	err := b.Blockchain.Database.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(tournamentPrefix); it.ValidForPrefix(tournamentPrefix); it.Next() {
+			data, err := it.Item().ValueCopy(nil)
+			blockchain.Handle(err)
+
+			var tournament Tournament
+			blockchain.Handle(gob.NewDecoder(bytes.NewReader(data)).Decode(&tournament))
+			tournaments = append(tournaments, tournament)
+		}
+		return nil
+	})
+	blockchain.Handle(err)
+
+	sort.Slice(tournaments, func(i, j int) bool { return tournaments[i].Created < tournaments[j].Created })
+
+	return tournaments
+}
+
+// finish pays out a tournament. One without entries just closes.
+func (b TournamentBook) finish(t *Tournament) {
+	t.Results = t.places()
+	t.Status = TournamentFinished
+
+	if len(t.Entries) > 0 {
+		var entryTxs [][]byte
+		for _, entry := range t.Entries {
+			entryTxs = append(entryTxs, entry.EntryTx)
+		}
+
+		tw := b.Wallets.TournamentWallet(b.NodeID)
+		record := blockchain.TournamentRecord{ID: t.ID, Tournament: t.Tournament, Results: t.Results}
+		UTXOSet := blockchain.UTXOSet{Blockchain: b.Blockchain}
+		tx := blockchain.NewTournamentPayoutTransaction(&tw, record, entryTxs, &UTXOSet)
+		t.PayoutTx = tx.ID
+
+		// Saved once paid, a tournament whose payout failed stays open and
+		// the next block tries again
+		b.mine(tx)
+		b.save(t)
+		log.Printf("Tournament %s finished, %s wins %d coins", t.ID, t.Results[0].Player, t.Results[0].Prize)
+		return
+	}
+
+	b.save(t)
+}
+
+// FinishDue pays out every open tournament the chain has reached the end
+// height of and returns their IDs.
+func (b TournamentBook) FinishDue() []string {
+	b.Blockchain.LockSpends()
+	defer b.Blockchain.UnlockSpends()
+	tournamentMu.Lock()
+	defer tournamentMu.Unlock()
+
+	var finished []string
+	height := b.Blockchain.GetBestHeight()
+	for _, tournament := range b.Tournaments() {
+		if tournament.Status == TournamentOpen && height >= tournament.EndHeight && tournament.Tournament == b.Wallets.Tournament {
+			func() {
+				defer func() {
+					if rec := recover(); rec != nil {
+						log.Printf("Tournament %s not paid out, trying again on the next block: %v", tournament.ID, rec)
+					}
+				}()
+				b.finish(&tournament)
+				finished = append(finished, tournament.ID)
+			}()
+		}
+	}
+
+	return finished
+}
+
+// WatchTournaments pays out tournaments as their end blocks are connected,
+// if this node holds the tournament wallet.
+func WatchTournaments(nodeID string) {
+	blockchain.OnBlockConnected(func(chain *blockchain.BlockChain, block *blockchain.Block) {
+		wallets, err := wallet.CreateWallets(nodeID)
+		if err != nil || wallets.Tournament == "" {
+			return
+		}
+
+		TournamentBook{Blockchain: chain, Wallets: wallets, NodeID: nodeID}.FinishDue()
+	})
+}
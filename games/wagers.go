@@ -0,0 +1,445 @@
+package games
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// Wagers are bets between two players, see blockchain/wager.go for how the
+// stakes are locked. Player A offers a stake with a commitment to a secret,
+// player B accepts with a commitment of its own, which funds the pot. Both
+// then reveal their secrets to the oracle, which keeps them sealed until it
+// has both so neither player can back out after seeing the other's, and
+// pays the winner. A funded wager not settled by its timeout height is
+// refunded.
+
+var (
+	wagerPrefix = []byte("wager-")
+	wagerMu     sync.Mutex
+)
+
+const (
+	WagerOpen      = "open"
+	WagerFunded    = "funded"
+	WagerSettled   = "settled"
+	WagerRefunded  = "refunded"
+	WagerCancelled = "cancelled"
+)
+
+type Wager struct {
+	Record  blockchain.WagerRecord
+	Status  string
+	Created int64
+	Pot     blockchain.EscrowOutput
+	FundTx  []byte
+	CloseTx []byte
+}
+
+// WagerState is what players see of a wager. Secrets stay hidden until the
+// wager is settled.
+type WagerState struct {
+	blockchain.WagerRecord
+	Status    string `json:"status"`
+	Created   int64  `json:"created"`
+	Pot       int    `json:"pot"`
+	RevealedA bool   `json:"revealedA"`
+	RevealedB bool   `json:"revealedB"`
+	FundTx    string `json:"fundTx,omitempty"`
+	CloseTx   string `json:"closeTx,omitempty"`
+}
+
+func (w *Wager) State() WagerState {
+	state := WagerState{
+		WagerRecord: w.Record,
+		Status:      w.Status,
+		Created:     w.Created,
+		Pot:         w.Pot.Value,
+		RevealedA:   w.Record.SecretA != "",
+		RevealedB:   w.Record.SecretB != "",
+	}
+	if w.Status != WagerSettled {
+		state.SecretA, state.SecretB = "", ""
+	}
+	if w.FundTx != nil {
+		state.FundTx = hex.EncodeToString(w.FundTx)
+	}
+	if w.CloseTx != nil {
+		state.CloseTx = hex.EncodeToString(w.CloseTx)
+	}
+
+	return state
+}
+
+// WagerBook keeps the wagers of a node in the chain database.
+type WagerBook struct {
+	Blockchain *blockchain.BlockChain
+	Wallets    *wallet.Wallets
+	NodeID     string
+}
+
+func wagerKey(id string) []byte {
+	return append(append([]byte{}, wagerPrefix...), id...)
+}
+
+func (b WagerBook) save(w *Wager) {
+	var buff bytes.Buffer
+	blockchain.Handle(gob.NewEncoder(&buff).Encode(w))
+
+	err := b.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set(wagerKey(w.Record.ID), buff.Bytes())
+	})
+	blockchain.Handle(err)
+}
+
+func (b WagerBook) load(id string) (*Wager, error) {
+	var wager Wager
+
+	err := b.Blockchain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(wagerKey(id))
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(&wager)
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("no wager %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &wager, nil
+}
+
+func (b WagerBook) mine(tx *blockchain.Transaction) *blockchain.Block {
+	block := b.Blockchain.MineBlock([]*blockchain.Transaction{tx})
+	UTXOSet := blockchain.UTXOSet{Blockchain: b.Blockchain}
+	UTXOSet.Update(block)
+
+	return block
+}
+
+// keys are the keys this node holds of the pot of w, the oracle's first.
+func (b WagerBook) keys(w *Wager) []wallet.PrivateKeyData {
+	var keys []wallet.PrivateKeyData
+	for _, address := range []string{w.Record.Oracle, w.Record.PlayerA, w.Record.PlayerB} {
+		if key, ok := b.Wallets.Wallets[address]; ok {
+			keys = append(keys, key.PrivateKey)
+		}
+	}
+
+	return keys
+}
+
+func (b WagerBook) player(address string) (wallet.Wallet, error) {
+	if _, ok := b.Wallets.Wallets[address]; !ok {
+		return wallet.Wallet{}, fmt.Errorf("wallet %s not found", address)
+	}
+
+	return b.Wallets.GetWallet(address), nil
+}
+
+func validCommitment(commitment string) error {
+	if decoded, err := hex.DecodeString(commitment); err != nil || len(decoded) != 32 {
+		return errors.New("commitment must be the hex sha256 of a secret")
+	}
+	return nil
+}
+
+// Create offers a wager of stake. Only opponent may accept it, anyone when
+// opponent is empty. Nothing is locked until the wager is accepted.
+func (b WagerBook) Create(from, opponent string, stake int, commitment string) (*Wager, error) {
+	if stake <= 0 {
+		return nil, errors.New("amount must be greater than 0")
+	}
+	if _, err := b.player(from); err != nil {
+		return nil, err
+	}
+	if opponent == from {
+		return nil, errors.New("a player can't wager against itself")
+	}
+	if err := validCommitment(commitment); err != nil {
+		return nil, err
+	}
+	if err := (Policies{Blockchain: b.Blockchain}).Check(from, stake); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	blockchain.Handle(err)
+
+	wager := &Wager{
+		Record: blockchain.WagerRecord{
+			ID:          hex.EncodeToString(id),
+			PlayerA:     from,
+			PlayerB:     opponent,
+			Stake:       stake,
+			CommitmentA: commitment,
+		},
+		Status:  WagerOpen,
+		Created: time.Now().Unix(),
+	}
+
+	wagerMu.Lock()
+	defer wagerMu.Unlock()
+	b.save(wager)
+
+	return wager, nil
+}
+
+// Accept takes the other side of an open wager, locking both stakes.
+func (b WagerBook) Accept(id, from, commitment string) (*Wager, error) {
+	if err := validCommitment(commitment); err != nil {
+		return nil, err
+	}
+
+	// Both stakes are bets
+	if wager, err := b.load(id); err == nil {
+		defer LockBets(wager.Record.PlayerA, from)()
+	}
+	b.Blockchain.LockSpends()
+	defer b.Blockchain.UnlockSpends()
+	wagerMu.Lock()
+	defer wagerMu.Unlock()
+
+	wager, err := b.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if wager.Status != WagerOpen {
+		return wager, fmt.Errorf("wager %s is %s", id, wager.Status)
+	}
+	if from == wager.Record.PlayerA {
+		return wager, errors.New("a player can't wager against itself")
+	}
+	if wager.Record.PlayerB != "" && wager.Record.PlayerB != from {
+		return wager, fmt.Errorf("wager %s is offered to %s", id, wager.Record.PlayerB)
+	}
+
+	a, err := b.player(wager.Record.PlayerA)
+	if err != nil {
+		return wager, err
+	}
+	opponent, err := b.player(from)
+	if err != nil {
+		return wager, err
+	}
+	// Player A's policy may have changed since the offer
+	for _, player := range []string{wager.Record.PlayerA, from} {
+		if err := (Policies{Blockchain: b.Blockchain}).Check(player, wager.Record.Stake); err != nil {
+			return wager, err
+		}
+	}
+	oracle := b.Wallets.OracleWallet(b.NodeID)
+
+	wager.Record.PlayerB = from
+	wager.Record.CommitmentB = commitment
+	wager.Record.Oracle = string(oracle.Address())
+	// The funding block is the next one
+	wager.Record.Timeout = b.Blockchain.GetBestHeight() + 1 + blockchain.WagerTimeoutBlocks()
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: b.Blockchain}
+	tx, pot := blockchain.NewWagerTransaction(&a, &opponent, wager.Record, &UTXOSet)
+	b.mine(tx)
+
+	wager.Status = WagerFunded
+	wager.Pot = pot
+	wager.FundTx = tx.ID
+	b.save(wager)
+
+	return wager, nil
+}
+
+// Reveal hands a player's secret to the oracle. The second reveal settles
+// the wager.
+func (b WagerBook) Reveal(id, from, secret string) (*Wager, error) {
+	b.Blockchain.LockSpends()
+	defer b.Blockchain.UnlockSpends()
+	wagerMu.Lock()
+	defer wagerMu.Unlock()
+
+	wager, err := b.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if wager.Status != WagerFunded {
+		return wager, fmt.Errorf("wager %s is %s", id, wager.Status)
+	}
+
+	switch from {
+	case wager.Record.PlayerA:
+		if blockchain.WagerCommitment(secret) != wager.Record.CommitmentA {
+			return wager, errors.New("secret doesn't match the commitment")
+		}
+		wager.Record.SecretA = secret
+	case wager.Record.PlayerB:
+		if blockchain.WagerCommitment(secret) != wager.Record.CommitmentB {
+			return wager, errors.New("secret doesn't match the commitment")
+		}
+		wager.Record.SecretB = secret
+	default:
+		return wager, fmt.Errorf("%s isn't a player of wager %s", from, id)
+	}
+
+	if wager.Record.SecretA != "" && wager.Record.SecretB != "" {
+		winner, err := blockchain.WagerWinner(wager.Record)
+		blockchain.Handle(err)
+		wager.Record.Winner = winner
+		b.close(wager, WagerSettled)
+	}
+	b.save(wager)
+
+	return wager, nil
+}
+
+// close spends the pot of a wager to its winner or back to its players.
+func (b WagerBook) close(w *Wager, status string) {
+	UTXOSet := blockchain.UTXOSet{Blockchain: b.Blockchain}
+	tx := blockchain.NewWagerPayoutTransaction(w.Pot, w.Record, b.keys(w), &UTXOSet)
+	b.mine(tx)
+
+	w.Status = status
+	w.CloseTx = tx.ID
+}
+
+func (b WagerBook) refund(w *Wager) {
+	w.Record.Refunded = true
+	// A refund doesn't publish the secrets revealed so far
+	w.Record.SecretA, w.Record.SecretB = "", ""
+	b.close(w, WagerRefunded)
+}
+
+// Refund returns both stakes of a funded wager once its timeout height has
+// been reached.
+func (b WagerBook) Refund(id, from string) (*Wager, error) {
+	b.Blockchain.LockSpends()
+	defer b.Blockchain.UnlockSpends()
+	wagerMu.Lock()
+	defer wagerMu.Unlock()
+
+	wager, err := b.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if wager.Status != WagerFunded {
+		return wager, fmt.Errorf("wager %s is %s", id, wager.Status)
+	}
+	if from != wager.Record.PlayerA && from != wager.Record.PlayerB {
+		return wager, fmt.Errorf("%s isn't a player of wager %s", from, id)
+	}
+	if height := b.Blockchain.GetBestHeight(); height < wager.Record.Timeout {
+		return wager, fmt.Errorf("wager %s can be refunded from height %d, the chain is at %d", id, wager.Record.Timeout, height)
+	}
+
+	b.refund(wager)
+	b.save(wager)
+
+	return wager, nil
+}
+
+// Cancel withdraws an offer nobody accepted yet.
+func (b WagerBook) Cancel(id, from string) (*Wager, error) {
+	b.Blockchain.LockSpends()
+	defer b.Blockchain.UnlockSpends()
+	wagerMu.Lock()
+	defer wagerMu.Unlock()
+
+	wager, err := b.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if wager.Status != WagerOpen {
+		return wager, fmt.Errorf("wager %s is %s", id, wager.Status)
+	}
+	if from != wager.Record.PlayerA {
+		return wager, errors.New("only the player offering a wager can cancel it")
+	}
+
+	wager.Status = WagerCancelled
+	b.save(wager)
+
+	return wager, nil
+}
+
+func (b WagerBook) Wager(id string) (*Wager, error) {
+	return b.load(id)
+}
+
+// Wagers lists the wagers address takes part in, or is offered, oldest
+// first. Every wager when address is empty.
+func (b WagerBook) Wagers(address string) []Wager {
+	var wagers []Wager
+
+	err := b.Blockchain.Database.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(wagerPrefix); it.ValidForPrefix(wagerPrefix); it.Next() {
+			data, err := it.Item().ValueCopy(nil)
+			blockchain.Handle(err)
+
+			var wager Wager
+			blockchain.Handle(gob.NewDecoder(bytes.NewReader(data)).Decode(&wager))
+			if address == "" || wager.Record.PlayerA == address || wager.Record.PlayerB == address {
+				wagers = append(wagers, wager)
+			}
+		}
+		return nil
+	})
+	blockchain.Handle(err)
+
+	sort.Slice(wagers, func(i, j int) bool { return wagers[i].Created < wagers[j].Created })
+
+	return wagers
+}
+
+// ExpireWagers refunds every funded wager past its timeout height and
+// returns their IDs.
+func (b WagerBook) ExpireWagers() []string {
+	b.Blockchain.LockSpends()
+	defer b.Blockchain.UnlockSpends()
+	wagerMu.Lock()
+	defer wagerMu.Unlock()
+
+	var expired []string
+	height := b.Blockchain.GetBestHeight()
+	for _, wager := range b.Wagers("") {
+		if wager.Status == WagerFunded && height >= wager.Record.Timeout && len(b.keys(&wager)) >= 2 {
+			b.refund(&wager)
+			b.save(&wager)
+			expired = append(expired, wager.Record.ID)
+		}
+	}
+
+	return expired
+}
+
+// WatchWagers refunds timed out wagers whenever a block is connected, if
+// this node is the oracle of any.
+func WatchWagers(nodeID string) {
+	blockchain.OnBlockConnected(func(chain *blockchain.BlockChain, block *blockchain.Block) {
+		wallets, err := wallet.CreateWallets(nodeID)
+		if err != nil || wallets.Oracle == "" {
+			return
+		}
+
+		WagerBook{Blockchain: chain, Wallets: wallets, NodeID: nodeID}.ExpireWagers()
+	})
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"os"
 
 	"github.com/ItsHotdogFred/blockchain/blockchain"
 	"github.com/ItsHotdogFred/blockchain/cli"
+	"github.com/ItsHotdogFred/blockchain/games"
 	"github.com/ItsHotdogFred/blockchain/network"
 )
 
@@ -16,6 +18,10 @@ func main() {
 		// Run server mode only if "server" argument is explicitly provided
 		nodeID := os.Getenv("NODE_ID")
 
+		if err := games.LoadConfigFile(); err != nil {
+			log.Panic(err)
+		}
+
 		// Try to continue existing blockchain, if it doesn't exist, create a new one
 		var chain *blockchain.BlockChain
 
@@ -38,6 +44,16 @@ func main() {
 
 		defer chain.Database.Close()
 
+		// Pay out lottery rounds as their draw blocks are connected, refund
+		// wagers as they time out and keep the statistics current
+		blockchain.WatchLottery(nodeID)
+		games.WatchWagers(nodeID)
+		games.WatchTournaments(nodeID)
+		blockchain.WatchStats()
+
+		// Crash rounds run as long as the server does
+		go games.RunCrash(chain, nodeID)
+
 		go network.StartServer(nodeID, chain)
 		network.StartApiServer(6969, nodeID, chain)
 	} else {
@@ -1,17 +1,23 @@
 package network
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
 	"github.com/ItsHotdogFred/blockchain/wallet"
 )
 
@@ -27,20 +33,8 @@ type TransactionRequest struct {
 	Amount int    `json:"amount"`
 }
 
-type CoinflipRequest struct {
-	From   string `json:"from"`
-	Amount int    `json:"amount"`
-}
-
-type DiceRollRequest struct {
-	From   string `json:"from"`
-	Amount int    `json:"amount"`
-}
-
-type NumberRangeRequest struct {
-	From   string `json:"from"`
-	Amount int    `json:"amount"`
-	Guess  int    `json:"guess"`
+type CreateWalletRequest struct {
+	KeyType string `json:"keyType"`
 }
 
 var nodeID string
@@ -83,13 +77,16 @@ func (rl *RateLimiter) AllowRequest(clientIP string) bool {
 }
 
 type GameRequest struct {
-	From   string `json:"from"`
-	Amount int    `json:"amount"`
+	From       string       `json:"from"`
+	Amount     int          `json:"amount"`
+	ClientSeed string       `json:"clientSeed"`
+	Nonce      int          `json:"nonce"`
+	Params     games.Params `json:"params"`
 }
 
-type GameHandler func(*wallet.Wallet, int, *blockchain.UTXOSet) *blockchain.GameResult
+func handleGameTransaction(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain, req GameRequest, game games.Game) {
+	gameName := game.Info().Title
 
-func handleGameTransaction(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain, req GameRequest, handler GameHandler, gameName string) {
 	// Validate address with error handling
 	func() {
 		defer func() {
@@ -113,7 +110,7 @@ func handleGameTransaction(w http.ResponseWriter, r *http.Request, chain *blockc
 	// Load wallets and get sender wallet
 	wallets, err := wallet.CreateWallets(nodeID)
 	if err != nil {
-		http.Error(w, "Failed to load wallets", http.StatusInternalServerError)
+		walletError(w, err)
 		return
 	}
 	// Check if wallet exists
@@ -122,8 +119,34 @@ func handleGameTransaction(w http.ResponseWriter, r *http.Request, chain *blockc
 		return
 	}
 	senderWallet := wallets.GetWallet(req.From)
+	houseWallet, err := wallets.HouseWallet()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
 
-	// Create game transaction with error handling
+	// Refuse bad bets before they use up a nonce
+	if err := games.Validate(game, req.Amount, req.Params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Bind the bet to the committed server seed before playing it
+	if req.ClientSeed == "" {
+		req.ClientSeed = blockchain.RandomClientSeed()
+	}
+	roll, err := blockchain.FairSeeds{Blockchain: chain}.NewRoll(req.ClientSeed, req.Nonce)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Create game transaction with error handling. The player's bets wait
+	// from the policy check until it is mined, and no other spend may pick
+	// the same outputs meanwhile
+	defer games.LockBets(req.From)()
+	chain.LockSpends()
+	defer chain.UnlockSpends()
 	var gameResult *blockchain.GameResult
 	var txPanicked bool
 
@@ -134,7 +157,13 @@ func handleGameTransaction(w http.ResponseWriter, r *http.Request, chain *blockc
 				txPanicked = true
 			}
 		}()
-		gameResult = handler(&senderWallet, req.Amount, &UTXOSet)
+		var err error
+		jackpotWallet := wallets.JackpotWallet(nodeID)
+		gameResult, err = games.Play(game, &senderWallet, &houseWallet, blockchain.NewJackpot(&jackpotWallet), req.Amount, req.Params, &UTXOSet, roll)
+		if err != nil {
+			http.Error(w, err.Error(), refusalStatus(err, http.StatusBadRequest))
+			txPanicked = true
+		}
 	}()
 
 	if txPanicked || gameResult == nil {
@@ -162,37 +191,23 @@ func handleGameTransaction(w http.ResponseWriter, r *http.Request, chain *blockc
 	UTXOSet.Update(block)
 
 	// Calculate amount change and winnings
+	outcome := game.Describe(gameResult.Record.Outcome)
 	var amountChange int
 	var resultStr string
 	var message string
 	if gameResult.Won {
 		resultStr = "WIN"
-		// Calculate total coins received (includes original bet)
-		var totalReceived int
-		// Look at the transaction outputs to see what the player actually received
-		for _, output := range gameResult.Transaction.Outputs {
-			// Find the output that goes back to the sender (assuming it's the winnings)
-			if output.Value > req.Amount { // This indicates winnings (more than the bet)
-				totalReceived = output.Value
-				break
-			}
-		}
-		if totalReceived == 0 {
-			// Default calculation if we can't find specific output
-			if gameResult.GameType == "coinflip" {
-				totalReceived = req.Amount * 2 // Double the bet
-			} else if gameResult.GameType == "dice" {
-				totalReceived = req.Amount * 3 // Triple the bet
-			} else if gameResult.GameType == "numberrange" {
-				totalReceived = req.Amount * 5 // 5x the bet
-			}
-		}
+		// Total coins received, the original bet and any jackpot included
+		totalReceived := gameResult.Record.Payout + gameResult.Record.JackpotPayout
 		amountChange = totalReceived - req.Amount // Net gain
-		message = fmt.Sprintf("%s %s! You received %d coins (net gain: %d)", gameName, resultStr, totalReceived, amountChange)
+		message = fmt.Sprintf("%s %s! %s, you received %d coins (net gain: %d)", gameName, resultStr, outcome, totalReceived, amountChange)
+		if gameResult.Record.JackpotPayout > 0 {
+			message += fmt.Sprintf(" including the %d coin JACKPOT", gameResult.Record.JackpotPayout)
+		}
 	} else {
 		resultStr = "LOSS"
 		amountChange = -req.Amount
-		message = fmt.Sprintf("%s %s! You lost %d coins", gameName, resultStr, req.Amount)
+		message = fmt.Sprintf("%s %s! %s, you lost %d coins", gameName, resultStr, outcome, req.Amount)
 	}
 
 	// Return success response
@@ -205,11 +220,17 @@ func handleGameTransaction(w http.ResponseWriter, r *http.Request, chain *blockc
 		"message":      message,
 		"block":        fmt.Sprintf("%x", block.Hash),
 		"tx":           fmt.Sprintf("%x", gameResult.Transaction.ID),
-	}
 
-	// Add server number for Number Range game
-	if gameResult.GameType == "numberrange" && gameResult.ServerNumber != 0 {
-		response["serverNumber"] = gameResult.ServerNumber
+		"serverSeedHash": fmt.Sprintf("%x", gameResult.ServerSeedHash),
+		"clientSeed":     gameResult.ClientSeed,
+		"nonce":          gameResult.Nonce,
+		"record":         gameResult.Record,
+		"outcome":        gameResult.Record.Outcome,
+		"outcomeText":    outcome,
+		"jackpotPayout":  gameResult.Record.JackpotPayout,
+	}
+	if g, ok := game.(games.GridGame); ok {
+		response["grid"] = g.Grid(gameResult.Record.Outcome)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -237,8 +258,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 
 func rateLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip rate limiting for balance endpoint and OPTIONS requests
-		if r.URL.Path == "/balance" || r.Method == "OPTIONS" {
+		// Skip rate limiting for balance, mining template polls and OPTIONS requests
+		if r.URL.Path == "/balance" || r.URL.Path == "/mining/template" || r.Method == "OPTIONS" {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -267,6 +288,46 @@ func rateLimitMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// Custodial says whether the node spends for players from the wallets it
+// holds, on nothing but an address in the request. Anyone who can reach the
+// API can then spend any of those wallets, so a node others can reach turns
+// it off with CUSTODIAL=0 and clients sign their own transactions through
+// /tx/build and /tx/submit.
+func Custodial() bool {
+	return os.Getenv("CUSTODIAL") != "0"
+}
+
+// custodial guards a handler that spends from a wallet the node holds.
+func custodial(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "OPTIONS" && !Custodial() {
+			http.Error(w, "Spending from node wallets is disabled on this node, sign with /tx/build and /tx/submit", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// operator guards an action for the node's operator, like rotating the
+// server seed. The request must carry the token in ADMIN_TOKEN as
+// "Authorization: Bearer TOKEN"; without a token set the action is only
+// available from the CLI.
+func operator(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := os.Getenv("ADMIN_TOKEN")
+		if token == "" {
+			http.Error(w, "Operator actions need ADMIN_TOKEN to be set on the node", http.StatusForbidden)
+			return
+		}
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			http.Error(w, "Invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
 func StartApiServer(port int, ID string, chain *blockchain.BlockChain) {
 	nodeID = ID
 
@@ -281,24 +342,169 @@ func StartApiServer(port int, ID string, chain *blockchain.BlockChain) {
 	router.HandleFunc("/createwallet", func(w http.ResponseWriter, r *http.Request) {
 		APICreateWallet(w, r, chain)
 	}).Methods("POST", "OPTIONS")
-	router.HandleFunc("/send", func(w http.ResponseWriter, r *http.Request) {
+	router.HandleFunc("/wallet", GetWalletStatus).Methods("GET", "OPTIONS")
+	router.HandleFunc("/wallet/unlock", UnlockWallet).Methods("POST", "OPTIONS")
+	router.HandleFunc("/wallet/lock", LockWallet).Methods("POST", "OPTIONS")
+	router.HandleFunc("/hd", func(w http.ResponseWriter, r *http.Request) {
+		GetHDAddresses(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/hd/address", NewHDAddress).Methods("POST", "OPTIONS")
+	router.HandleFunc("/send", custodial(func(w http.ResponseWriter, r *http.Request) {
 		SendTransaction(w, r, chain)
+	})).Methods("POST", "OPTIONS")
+	router.HandleFunc("/tx/build", func(w http.ResponseWriter, r *http.Request) {
+		BuildTransaction(w, r, chain)
+	}).Methods("POST", "OPTIONS")
+	router.HandleFunc("/tx/submit", func(w http.ResponseWriter, r *http.Request) {
+		SubmitRawTransaction(w, r, chain)
 	}).Methods("POST", "OPTIONS")
 	router.HandleFunc("/balance", func(w http.ResponseWriter, r *http.Request) {
 		GetBalance(w, r, chain)
 	}).Methods("GET", "OPTIONS")
-	router.HandleFunc("/coinflip", func(w http.ResponseWriter, r *http.Request) {
-		CoinFlip(w, r, chain)
-	}).Methods("POST", "OPTIONS")
-	router.HandleFunc("/diceroll", func(w http.ResponseWriter, r *http.Request) {
-		DiceRoll(w, r, chain)
-	}).Methods("POST", "OPTIONS")
-	router.HandleFunc("/numberrange", func(w http.ResponseWriter, r *http.Request) {
-		NumberRange(w, r, chain)
-	}).Methods("POST", "OPTIONS")
+	router.HandleFunc("/fair/seed", func(w http.ResponseWriter, r *http.Request) {
+		GetFairSeed(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/fair/seeds", func(w http.ResponseWriter, r *http.Request) {
+		GetFairSeeds(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/fair/rotate", operator(func(w http.ResponseWriter, r *http.Request) {
+		RotateFairSeed(w, r, chain)
+	})).Methods("POST", "OPTIONS")
+	router.HandleFunc("/fair/verify", func(w http.ResponseWriter, r *http.Request) {
+		VerifyFairRoll(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/house", func(w http.ResponseWriter, r *http.Request) {
+		GetHouse(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/jackpot", func(w http.ResponseWriter, r *http.Request) {
+		GetJackpot(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/gamerecords", func(w http.ResponseWriter, r *http.Request) {
+		GetGameRecords(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/gamerecords/{txid}", func(w http.ResponseWriter, r *http.Request) {
+		GetGameRecord(w, r, chain)
+	}).Methods("GET", "OPTIONS")
 	router.HandleFunc("/blockchain", func(w http.ResponseWriter, r *http.Request) {
 		GetBlockchain(w, r, chain)
 	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/games", GetGames).Methods("GET", "OPTIONS")
+	router.HandleFunc("/games/config", GetGamesConfig).Methods("GET", "OPTIONS")
+	router.HandleFunc("/games/slots/rtp", GetSlotsRTP).Methods("GET", "OPTIONS")
+	router.HandleFunc("/games/{name}/play", custodial(func(w http.ResponseWriter, r *http.Request) {
+		game, ok := games.Get(mux.Vars(r)["name"])
+		if !ok {
+			http.Error(w, "Unknown game", http.StatusNotFound)
+			return
+		}
+		PlayGame(w, r, chain, game)
+	})).Methods("POST", "OPTIONS")
+	// Every game can also be played at /NAME, e.g. /coinflip or /diceroll
+	for _, game := range games.All() {
+		game := game
+		info := game.Info()
+		for _, name := range append([]string{info.Name}, info.Aliases...) {
+			router.HandleFunc("/"+name, custodial(func(w http.ResponseWriter, r *http.Request) {
+				PlayGame(w, r, chain, game)
+			})).Methods("POST", "OPTIONS")
+		}
+	}
+	router.HandleFunc("/blackjack", custodial(func(w http.ResponseWriter, r *http.Request) {
+		StartBlackjack(w, r, chain)
+	})).Methods("POST", "OPTIONS")
+	router.HandleFunc("/blackjack", func(w http.ResponseWriter, r *http.Request) {
+		GetBlackjackSessions(w, r, chain)
+	}).Methods("GET")
+	router.HandleFunc("/blackjack/{id}", func(w http.ResponseWriter, r *http.Request) {
+		GetBlackjackSession(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/blackjack/{id}/{action}", custodial(func(w http.ResponseWriter, r *http.Request) {
+		BlackjackAction(w, r, chain)
+	})).Methods("POST", "OPTIONS")
+	router.HandleFunc("/lottery", func(w http.ResponseWriter, r *http.Request) {
+		GetLottery(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/lottery/tickets", custodial(func(w http.ResponseWriter, r *http.Request) {
+		BuyTickets(w, r, chain)
+	})).Methods("POST", "OPTIONS")
+	router.HandleFunc("/lottery/rounds/{round}", func(w http.ResponseWriter, r *http.Request) {
+		GetLotteryRound(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/lottery/winners", func(w http.ResponseWriter, r *http.Request) {
+		GetLotteryWinners(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/wagers", custodial(func(w http.ResponseWriter, r *http.Request) {
+		CreateWager(w, r, chain)
+	})).Methods("POST", "OPTIONS")
+	router.HandleFunc("/wagers", func(w http.ResponseWriter, r *http.Request) {
+		GetWagers(w, r, chain)
+	}).Methods("GET")
+	router.HandleFunc("/wagers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		GetWager(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/wagers/{id}/{action}", custodial(func(w http.ResponseWriter, r *http.Request) {
+		WagerAction(w, r, chain)
+	})).Methods("POST", "OPTIONS")
+	router.HandleFunc("/stats/house", func(w http.ResponseWriter, r *http.Request) {
+		GetHouseStats(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/stats/{address}", func(w http.ResponseWriter, r *http.Request) {
+		GetPlayerStats(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/policies", func(w http.ResponseWriter, r *http.Request) {
+		SetPolicy(w, r, chain)
+	}).Methods("POST", "OPTIONS")
+	router.HandleFunc("/policies/{address}", func(w http.ResponseWriter, r *http.Request) {
+		GetPolicy(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/tournaments", operator(func(w http.ResponseWriter, r *http.Request) {
+		CreateTournament(w, r, chain)
+	})).Methods("POST", "OPTIONS")
+	router.HandleFunc("/tournaments", func(w http.ResponseWriter, r *http.Request) {
+		GetTournaments(w, r, chain)
+	}).Methods("GET")
+	router.HandleFunc("/tournaments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		GetTournament(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/tournaments/{id}/register", custodial(func(w http.ResponseWriter, r *http.Request) {
+		RegisterTournament(w, r, chain)
+	})).Methods("POST", "OPTIONS")
+	router.HandleFunc("/tournaments/{id}/play", custodial(func(w http.ResponseWriter, r *http.Request) {
+		PlayTournament(w, r, chain)
+	})).Methods("POST", "OPTIONS")
+	router.HandleFunc("/tournaments/{id}/leaderboard", func(w http.ResponseWriter, r *http.Request) {
+		GetTournamentLeaderboard(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/tournaments/{id}/results", func(w http.ResponseWriter, r *http.Request) {
+		GetTournamentResults(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/crash", func(w http.ResponseWriter, r *http.Request) {
+		GetCrash(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/crash/bets", custodial(func(w http.ResponseWriter, r *http.Request) {
+		PlaceCrashBet(w, r, chain)
+	})).Methods("POST", "OPTIONS")
+	router.HandleFunc("/crash/cashout", custodial(func(w http.ResponseWriter, r *http.Request) {
+		CrashCashOut(w, r, chain)
+	})).Methods("POST", "OPTIONS")
+	router.HandleFunc("/crash/stream", func(w http.ResponseWriter, r *http.Request) {
+		StreamCrash(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/crash/rounds", func(w http.ResponseWriter, r *http.Request) {
+		GetCrashRounds(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/crash/rounds/{round}", func(w http.ResponseWriter, r *http.Request) {
+		GetCrashRound(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/crash/verify", VerifyCrash).Methods("GET", "OPTIONS")
+	router.HandleFunc("/mining/template", func(w http.ResponseWriter, r *http.Request) {
+		GetBlockTemplate(w, r, chain)
+	}).Methods("GET", "OPTIONS")
+	router.HandleFunc("/mining/submit", func(w http.ResponseWriter, r *http.Request) {
+		SubmitBlock(w, r, chain)
+	}).Methods("POST", "OPTIONS")
+
+	go expireBlackjack(chain)
 
 	http.ListenAndServe(portStr, router)
 }
@@ -320,15 +526,31 @@ func HelloWorld(w http.ResponseWriter, r *http.Request) {
 func APICreateWallet(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
 	var wallets *wallet.Wallets
 	var err error
+	var cwReq CreateWalletRequest
+
+	// The body is optional, an empty request creates a P-256 wallet
+	if err := json.NewDecoder(r.Body).Decode(&cwReq); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	keyType, err := wallet.ParseKeyType(cwReq.KeyType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Try to load existing wallets, create empty if doesn't exist
 	wallets, err = wallet.CreateWallets(nodeID)
+	if errors.Is(err, wallet.ErrLocked) {
+		walletError(w, err)
+		return
+	}
 	if err != nil {
 		wallets = &wallet.Wallets{}
 		wallets.Wallets = make(map[string]*wallet.Wallet)
 	}
 
-	address := wallets.AddWallet()
+	address := wallets.AddWalletWithType(keyType)
 	wallets.SaveFile(nodeID)
 
 	// Create initial balance transaction (100 coins) with error handling
@@ -360,6 +582,7 @@ func APICreateWallet(w http.ResponseWriter, r *http.Request, chain *blockchain.B
 
 	response := map[string]string{
 		"address": address,
+		"keyType": keyType.String(),
 		"message": "Wallet created with 100 initial balance",
 	}
 
@@ -420,7 +643,7 @@ func SendTransaction(w http.ResponseWriter, r *http.Request, chain *blockchain.B
 	// Load wallets and get sender wallet
 	wallets, err := wallet.CreateWallets(nodeID)
 	if err != nil {
-		http.Error(w, "Failed to load wallets", http.StatusInternalServerError)
+		walletError(w, err)
 		return
 	}
 	// Check if wallet exists
@@ -431,6 +654,8 @@ func SendTransaction(w http.ResponseWriter, r *http.Request, chain *blockchain.B
 	senderWallet := wallets.GetWallet(txReq.From)
 
 	// Create transaction with error handling
+	chain.LockSpends()
+	defer chain.UnlockSpends()
 	var tx *blockchain.Transaction
 	var txPanicked bool
 	func() {
@@ -550,69 +775,6 @@ func abs(x int) int {
 	return x
 }
 
-func DiceRoll(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
-	var drReq DiceRollRequest
-
-	// Decode JSON request body
-	err := json.NewDecoder(r.Body).Decode(&drReq)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Use common game handler with dice roll function
-	handleGameTransaction(w, r, chain, GameRequest{
-		From:   drReq.From,
-		Amount: drReq.Amount,
-	}, func(w *wallet.Wallet, amount int, utxo *blockchain.UTXOSet) *blockchain.GameResult {
-		return blockchain.NewDiceRollTransaction(w, amount, utxo)
-	}, "Dice Roll")
-}
-
-func CoinFlip(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
-	var cfReq CoinflipRequest
-
-	// Decode JSON request body
-	err := json.NewDecoder(r.Body).Decode(&cfReq)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Use common game handler with coinflip function
-	handleGameTransaction(w, r, chain, GameRequest{
-		From:   cfReq.From,
-		Amount: cfReq.Amount,
-	}, func(w *wallet.Wallet, amount int, utxo *blockchain.UTXOSet) *blockchain.GameResult {
-		return blockchain.NewCoinflipTransaction(w, amount, utxo)
-	}, "Coinflip")
-}
-
-func NumberRange(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
-	var nrReq NumberRangeRequest
-
-	// Decode JSON request body
-	err := json.NewDecoder(r.Body).Decode(&nrReq)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Validate guess parameter
-	if nrReq.Guess < 1 || nrReq.Guess > 100 {
-		http.Error(w, "Guess must be between 1 and 100", http.StatusBadRequest)
-		return
-	}
-
-	// Use common game handler with number range function
-	handleGameTransaction(w, r, chain, GameRequest{
-		From:   nrReq.From,
-		Amount: nrReq.Amount,
-	}, func(w *wallet.Wallet, amount int, utxo *blockchain.UTXOSet) *blockchain.GameResult {
-		return blockchain.NewNumberRangeTransaction(w, amount, nrReq.Guess, utxo)
-	}, "Number Range")
-}
-
 type BlockInfo struct {
 	Height       int               `json:"height"`
 	Hash         string            `json:"hash"`
@@ -623,14 +785,17 @@ type BlockInfo struct {
 }
 
 type TransactionInfo struct {
-	ID      string `json:"id"`
-	Inputs  int    `json:"inputs"`
-	Outputs int    `json:"outputs"`
+	ID      string                 `json:"id"`
+	Inputs  int                    `json:"inputs"`
+	Outputs int                    `json:"outputs"`
+	Game    *blockchain.GameRecord `json:"game,omitempty"`
 }
 
 type BlockchainResponse struct {
 	Blocks []BlockInfo `json:"blocks"`
 	Total  int         `json:"totalBlocks"`
+	// Jackpot is the current progressive jackpot pool
+	Jackpot int `json:"jackpot"`
 }
 
 func GetBlockchain(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
@@ -659,10 +824,12 @@ func GetBlockchain(w http.ResponseWriter, r *http.Request, chain *blockchain.Blo
 		// Convert transactions to summary info
 		var txInfos []TransactionInfo
 		for _, tx := range block.Transactions {
+			record, _ := tx.GameRecord()
 			txInfos = append(txInfos, TransactionInfo{
 				ID:      fmt.Sprintf("%x", tx.ID),
 				Inputs:  len(tx.Inputs),
 				Outputs: len(tx.Outputs),
+				Game:    record,
 			})
 		}
 
@@ -689,6 +856,9 @@ func GetBlockchain(w http.ResponseWriter, r *http.Request, chain *blockchain.Blo
 		Blocks: blocks,
 		Total:  count,
 	}
+	if wallets, err := wallet.CreateWallets(nodeID); err == nil {
+		response.Jackpot = blockchain.JackpotPool(chain, wallets.Jackpot)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,165 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+type BlackjackRequest struct {
+	From       string `json:"from"`
+	Amount     int    `json:"amount"`
+	ClientSeed string `json:"clientSeed"`
+	Nonce      int    `json:"nonce"`
+}
+
+func blackjackTable(w http.ResponseWriter, chain *blockchain.BlockChain) (games.BlackjackTable, bool) {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		walletError(w, err)
+		return games.BlackjackTable{}, false
+	}
+
+	return games.BlackjackTable{Blockchain: chain, Wallets: wallets, NodeID: nodeID}, true
+}
+
+// playBlackjack runs a table operation, turning the panics of the
+// transaction constructors into errors like handleGameTransaction does.
+func playBlackjack(w http.ResponseWriter, play func() (*games.BlackjackSession, error)) {
+	var session *games.BlackjackSession
+	var err error
+	var panicked bool
+
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				http.Error(w, fmt.Sprintf("Failed to play blackjack: %v", rec), http.StatusBadRequest)
+				panicked = true
+			}
+		}()
+		session, err = play()
+	}()
+
+	if panicked {
+		return
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if session == nil {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), refusalStatus(err, status))
+		return
+	}
+
+	writeJSON(w, session.State())
+}
+
+// StartBlackjack places a bet and deals a new session.
+func StartBlackjack(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	var req BlackjackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validAddress(req.From) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+
+	table, ok := blackjackTable(w, chain)
+	if !ok {
+		return
+	}
+	if table.Wallets.House == "" {
+		http.Error(w, "No house wallet configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	playBlackjack(w, func() (*games.BlackjackSession, error) {
+		return table.Open(req.From, req.Amount, req.ClientSeed, req.Nonce)
+	})
+}
+
+// BlackjackAction plays hit, stand, double or split on a session.
+func BlackjackAction(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	vars := mux.Vars(r)
+
+	table, ok := blackjackTable(w, chain)
+	if !ok {
+		return
+	}
+
+	playBlackjack(w, func() (*games.BlackjackSession, error) {
+		return table.Act(vars["id"], vars["action"])
+	})
+}
+
+func GetBlackjackSession(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	table, ok := blackjackTable(w, chain)
+	if !ok {
+		return
+	}
+
+	session, err := table.Session(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, session.State())
+}
+
+// GetBlackjackSessions lists sessions, optionally of one address.
+func GetBlackjackSessions(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	address := r.URL.Query().Get("address")
+	if address != "" && !validAddress(address) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+
+	table, ok := blackjackTable(w, chain)
+	if !ok {
+		return
+	}
+
+	states := []games.BlackjackState{}
+	for _, session := range table.Sessions(address) {
+		states = append(states, session.State())
+	}
+
+	writeJSON(w, map[string]interface{}{"sessions": states})
+}
+
+// expireBlackjack settles timed out sessions, including those left over
+// from before a restart.
+func expireBlackjack(chain *blockchain.BlockChain) {
+	for {
+		func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					log.Printf("Failed to expire blackjack sessions: %v", rec)
+				}
+			}()
+
+			wallets, err := wallet.CreateWallets(nodeID)
+			if err != nil {
+				return
+			}
+			table := games.BlackjackTable{Blockchain: chain, Wallets: wallets, NodeID: nodeID}
+			for _, id := range table.ExpireSessions() {
+				log.Printf("Blackjack session %s timed out", id)
+			}
+		}()
+
+		time.Sleep(30 * time.Second)
+	}
+}
@@ -0,0 +1,216 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// CrashBetRequest places a crash bet. CashOut is the automatic cash-out
+// multiplier, like 2.5, empty to cash out by hand.
+type CrashBetRequest struct {
+	From    string  `json:"from"`
+	Amount  int     `json:"amount"`
+	CashOut float64 `json:"cashOut"`
+}
+
+// CrashVerification lists the rounds a revealed hash proves, newest first.
+type CrashVerification struct {
+	Commitment string             `json:"commitment"`
+	ChainRound int                `json:"chainRound"`
+	Valid      bool               `json:"valid"`
+	Rounds     []CrashVerifyRound `json:"rounds"`
+}
+
+type CrashVerifyRound struct {
+	ChainRound int    `json:"chainRound"`
+	Hash       string `json:"hash"`
+	Bust       int    `json:"bust"`
+}
+
+func crashTable(w http.ResponseWriter, chain *blockchain.BlockChain) (games.CrashTable, bool) {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		walletError(w, err)
+		return games.CrashTable{}, false
+	}
+
+	return games.CrashTable{Blockchain: chain, Wallets: wallets, NodeID: nodeID}, true
+}
+
+// GetCrash returns the round being played.
+func GetCrash(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	state, err := games.CrashTable{Blockchain: chain}.State()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, state)
+}
+
+func PlaceCrashBet(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	var req CrashBetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validAddress(req.From) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+	table, ok := crashTable(w, chain)
+	if !ok {
+		return
+	}
+
+	state, err := table.Bet(req.From, req.Amount, int(math.Round(req.CashOut*100)))
+	if err != nil {
+		http.Error(w, err.Error(), refusalStatus(err, http.StatusBadRequest))
+		return
+	}
+	writeJSON(w, state)
+}
+
+// CrashCashOut cashes out the bet of "from" while the round runs.
+func CrashCashOut(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	var req CrashBetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	state, err := games.CrashTable{Blockchain: chain}.CashOut(req.From)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, state)
+}
+
+// StreamCrash streams the round state as server-sent events, one "data:"
+// line of JSON per update, until the client goes away.
+func StreamCrash(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	updates, cancel := games.CrashTable{Blockchain: chain}.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case state := <-updates:
+			data, err := json.Marshal(state)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", state.Phase, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// GetCrashRounds lists past rounds, newest first, 50 unless ?limit= says
+// otherwise.
+func GetCrashRounds(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a number", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	rounds := games.CrashTable{Blockchain: chain}.Rounds(limit)
+	if rounds == nil {
+		rounds = []games.CrashRound{}
+	}
+	writeJSON(w, map[string]interface{}{"rounds": rounds})
+}
+
+func GetCrashRound(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	n, err := strconv.Atoi(mux.Vars(r)["round"])
+	if err != nil {
+		http.Error(w, "round must be a number", http.StatusBadRequest)
+		return
+	}
+
+	round, err := games.CrashTable{Blockchain: chain}.Round(n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, round)
+}
+
+// VerifyCrash checks a revealed round hash against a commitment and
+// recomputes the busts of that round and the ?rounds= (10) before it.
+func VerifyCrash(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	hash, err := hex.DecodeString(query.Get("hash"))
+	if err != nil || len(hash) != 32 {
+		http.Error(w, "hash must be a hex sha256 hash", http.StatusBadRequest)
+		return
+	}
+	commitment, err := hex.DecodeString(query.Get("commitment"))
+	if err != nil || len(commitment) != 32 {
+		http.Error(w, "commitment must be a hex sha256 hash", http.StatusBadRequest)
+		return
+	}
+	count := 10
+	if value := query.Get("rounds"); value != "" {
+		count, err = strconv.Atoi(value)
+		if err != nil || count < 1 {
+			http.Error(w, "rounds must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	writeJSON(w, verifyCrash(hash, commitment, count))
+}
+
+func verifyCrash(hash, commitment []byte, count int) CrashVerification {
+	round := blockchain.VerifyCrashHash(hash, commitment)
+	result := CrashVerification{
+		Commitment: hex.EncodeToString(commitment),
+		ChainRound: round,
+		Valid:      round > 0,
+		Rounds:     []CrashVerifyRound{},
+	}
+	if count > round {
+		count = round
+	}
+
+	for i := 0; i < count; i++ {
+		result.Rounds = append(result.Rounds, CrashVerifyRound{
+			ChainRound: round - i,
+			Hash:       hex.EncodeToString(hash),
+			Bust:       blockchain.CrashBust(hash),
+		})
+		hash = blockchain.PreviousCrashHash(hash)
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+)
+
+type ServerSeedInfo struct {
+	Hash       string `json:"hash"`
+	Seed       string `json:"seed,omitempty"`
+	Created    int64  `json:"created"`
+	Revealed   bool   `json:"revealed"`
+	RevealedAt int64  `json:"revealedAt,omitempty"`
+}
+
+func seedInfo(seed blockchain.ServerSeed) ServerSeedInfo {
+	info := ServerSeedInfo{
+		Hash:       hex.EncodeToString(seed.Hash),
+		Created:    seed.Created,
+		Revealed:   seed.Revealed,
+		RevealedAt: seed.RevealedAt,
+	}
+	if seed.Revealed {
+		info.Seed = hex.EncodeToString(seed.Seed)
+	}
+	return info
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(v)
+}
+
+// GetFairSeed returns the hash of the server seed the next bets are played
+// against. Players should note it before betting.
+func GetFairSeed(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	writeJSON(w, seedInfo(blockchain.FairSeeds{Blockchain: chain}.Active()))
+}
+
+func GetFairSeeds(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	seeds := blockchain.FairSeeds{Blockchain: chain}.Seeds()
+
+	infos := []ServerSeedInfo{}
+	for _, seed := range seeds {
+		infos = append(infos, seedInfo(seed))
+	}
+
+	writeJSON(w, map[string]interface{}{"seeds": infos})
+}
+
+// RotateFairSeed reveals the active seed and commits to a new one.
+func RotateFairSeed(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	revealed, next, err := blockchain.FairSeeds{Blockchain: chain}.Rotate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"revealed": seedInfo(revealed),
+		"next":     seedInfo(next),
+	})
+}
+
+// VerifyFairRoll recomputes the outcome of a bet made against a revealed seed.
+func VerifyFairRoll(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	query := r.URL.Query()
+
+	hash, err := hex.DecodeString(query.Get("serverSeedHash"))
+	if err != nil || len(hash) == 0 {
+		http.Error(w, "serverSeedHash must be hex", http.StatusBadRequest)
+		return
+	}
+	nonce, err := strconv.Atoi(query.Get("nonce"))
+	if err != nil {
+		http.Error(w, "nonce must be a number", http.StatusBadRequest)
+		return
+	}
+	clientSeed := query.Get("clientSeed")
+	game := query.Get("game")
+
+	seed, err := blockchain.FairSeeds{Blockchain: chain}.Lookup(hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !seed.Revealed {
+		http.Error(w, "Server seed has not been revealed yet, rotate it first", http.StatusConflict)
+		return
+	}
+
+	outcome, outcomeText, err := games.Verify(game, seed.Seed, clientSeed, nonce)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"game":        game,
+		"serverSeed":  fmt.Sprintf("%x", seed.Seed),
+		"clientSeed":  clientSeed,
+		"nonce":       nonce,
+		"outcome":     outcome,
+		"outcomeText": outcomeText,
+	}
+	if g, ok := games.Get(game); ok {
+		if grid, ok := g.(games.GridGame); ok {
+			response["grid"] = grid.Grid(outcome)
+		}
+	}
+	writeJSON(w, response)
+}
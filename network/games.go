@@ -0,0 +1,179 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// GetGames lists the registered games with their parameters and payout
+// tables.
+func GetGames(w http.ResponseWriter, r *http.Request) {
+	infos := []games.Info{}
+	for _, game := range games.All() {
+		info := game.Info()
+		if info.Params == nil {
+			info.Params = []games.Param{}
+		}
+		infos = append(infos, info)
+	}
+
+	writeJSON(w, map[string]interface{}{"games": infos})
+}
+
+// GetGamesConfig reports the live game configuration with the return to
+// player and house edge it results in.
+func GetGamesConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"file":  games.ConfigFile(),
+		"games": games.Settings(),
+	})
+}
+
+// GetSlotsRTP proves the return of the slot machine in use from its reels
+// and pay table.
+func GetSlotsRTP(w http.ResponseWriter, r *http.Request) {
+	m := games.CurrentSlotMachine()
+	writeJSON(w, map[string]interface{}{
+		"machine": m,
+		"report":  games.SlotsRTP(m),
+	})
+}
+
+// PlayGame plays one bet on game. Game parameters go in "params", but are
+// also accepted at the top level of the body like the old per-game
+// endpoints took them, e.g. {"from": ..., "amount": 10, "guess": 50}.
+func PlayGame(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain, game games.Game) {
+	req, err := decodeGameRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	handleGameTransaction(w, r, chain, req, game)
+}
+
+func decodeGameRequest(r *http.Request) (GameRequest, error) {
+	req := GameRequest{Params: games.Params{}}
+
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return req, err
+	}
+
+	for key, raw := range body {
+		var err error
+		switch key {
+		case "from":
+			err = json.Unmarshal(raw, &req.From)
+		case "amount":
+			err = json.Unmarshal(raw, &req.Amount)
+		case "clientSeed":
+			err = json.Unmarshal(raw, &req.ClientSeed)
+		case "nonce":
+			err = json.Unmarshal(raw, &req.Nonce)
+		case "params":
+			var params map[string]json.RawMessage
+			err = json.Unmarshal(raw, &params)
+			for name, value := range params {
+				req.Params[name] = paramString(value)
+			}
+		default:
+			req.Params[key] = paramString(raw)
+		}
+		if err != nil {
+			return req, err
+		}
+	}
+
+	return req, nil
+}
+
+// paramString turns a JSON value into the string form games parse, so 50
+// and "50" are the same guess.
+func paramString(raw json.RawMessage) string {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return string(raw)
+	}
+}
+
+type GameRecordInfo struct {
+	TxID      string                `json:"txid"`
+	Height    int                   `json:"height"`
+	Timestamp int64                 `json:"timestamp"`
+	Record    blockchain.GameRecord `json:"record"`
+}
+
+func gameRecordInfo(game blockchain.IndexedGame) GameRecordInfo {
+	return GameRecordInfo{
+		TxID:      hex.EncodeToString(game.TxID),
+		Height:    game.Height,
+		Timestamp: game.Timestamp,
+		Record:    game.Record,
+	}
+}
+
+// GetGameRecords lists indexed bets, optionally for one address, together
+// with the realised and expected house edge over them.
+func GetGameRecords(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	address := r.URL.Query().Get("address")
+	if address != "" && !validAddress(address) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+
+	games := blockchain.GameIndex{Blockchain: chain}.Records(address)
+
+	infos := []GameRecordInfo{}
+	for _, game := range games {
+		infos = append(infos, gameRecordInfo(game))
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"records": infos,
+		"summary": blockchain.SummarizeGames(games),
+	})
+}
+
+func GetGameRecord(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	txID, err := hex.DecodeString(mux.Vars(r)["txid"])
+	if err != nil {
+		http.Error(w, "txid must be hex", http.StatusBadRequest)
+		return
+	}
+
+	game, err := blockchain.GameIndex{Blockchain: chain}.Get(txID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%v", err), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, gameRecordInfo(*game))
+}
+
+func validAddress(address string) (valid bool) {
+	defer func() {
+		if recover() != nil {
+			valid = false
+		}
+	}()
+
+	return wallet.ValidateAddress(address)
+}
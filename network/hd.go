@@ -0,0 +1,77 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// HDAddressRequest derives the next address of an account, a change
+// address when Change is set.
+type HDAddressRequest struct {
+	Account uint32 `json:"account"`
+	Change  bool   `json:"change"`
+}
+
+type HDAddressBalance struct {
+	wallet.HDAddress
+	Balance int `json:"balance"`
+}
+
+// GetHDAddresses lists the HD addresses of the node's wallet file with
+// their balances.
+func GetHDAddresses(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if errors.Is(err, wallet.ErrLocked) {
+		walletError(w, err)
+		return
+	}
+	if err != nil || wallets.HD == nil {
+		http.Error(w, "No HD keychain, run createhd or restorehd first", http.StatusNotFound)
+		return
+	}
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+	addresses := []HDAddressBalance{}
+	total := 0
+	for _, address := range wallets.HDAddresses() {
+		w := wallets.GetWallet(address.Address)
+		balance := UTXOSet.Balance(wallet.PublicKeyHash(w.PublicKey))
+		total += balance
+		addresses = append(addresses, HDAddressBalance{address, balance})
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"type":      wallets.HD.Type.String(),
+		"addresses": addresses,
+		"balance":   total,
+	})
+}
+
+func NewHDAddress(w http.ResponseWriter, r *http.Request) {
+	var req HDAddressRequest
+	// The body is optional, an empty request derives a receiving address
+	// of account 0
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		walletError(w, err)
+		return
+	}
+	address, err := wallets.NewHDAddress(req.Account, req.Change)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	wallets.SaveFile(nodeID)
+
+	writeJSON(w, address)
+}
@@ -0,0 +1,36 @@
+package network
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// GetHouse reports the house bankroll and the largest bets it can cover.
+func GetHouse(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if errors.Is(err, wallet.ErrLocked) {
+		walletError(w, err)
+		return
+	}
+	if err != nil || wallets.House == "" {
+		http.Error(w, "No house wallet configured", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, blockchain.NewHouseReport(chain, wallets.House, games.MaxMultipliers()))
+}
+
+// GetJackpot reports the progressive jackpot pool.
+func GetJackpot(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		walletError(w, err)
+		return
+	}
+
+	writeJSON(w, blockchain.NewJackpotReport(chain, wallets.Jackpot))
+}
@@ -0,0 +1,175 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+type TicketRequest struct {
+	From       string `json:"from"`
+	Tickets    int    `json:"tickets"`
+	Commitment string `json:"commitment"`
+}
+
+type TicketJSON struct {
+	TxID       string `json:"txid"`
+	Round      int    `json:"round"`
+	Height     int    `json:"height"`
+	Player     string `json:"player"`
+	Commitment string `json:"commitment"`
+	Tickets    int    `json:"tickets"`
+}
+
+type DrawJSON struct {
+	TxID   string                 `json:"txid"`
+	Height int                    `json:"height"`
+	Draw   blockchain.LotteryDraw `json:"draw"`
+}
+
+func ticketJSON(ticket blockchain.LotteryTicket) TicketJSON {
+	return TicketJSON{
+		TxID:       hex.EncodeToString(ticket.TxID),
+		Round:      ticket.Round,
+		Height:     ticket.Height,
+		Player:     ticket.Player,
+		Commitment: ticket.Commitment,
+		Tickets:    ticket.Tickets,
+	}
+}
+
+// BuyTickets buys lottery tickets for the current round, one coin each.
+func BuyTickets(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	var req TicketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validAddress(req.From) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+	if req.Tickets <= 0 {
+		http.Error(w, "Tickets must be greater than 0", http.StatusBadRequest)
+		return
+	}
+
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		walletError(w, err)
+		return
+	}
+	if _, exists := wallets.Wallets[req.From]; !exists {
+		http.Error(w, "Wallet not found", http.StatusNotFound)
+		return
+	}
+	player := wallets.GetWallet(req.From)
+	lottery := wallets.LotteryWallet(nodeID)
+
+	defer games.LockBets(req.From)()
+	if err := (games.Policies{Blockchain: chain}).Check(req.From, req.Tickets); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if req.Commitment == "" {
+		req.Commitment = blockchain.RandomClientSeed()
+	}
+
+	chain.LockSpends()
+	defer chain.UnlockSpends()
+	var tx *blockchain.Transaction
+	var block *blockchain.Block
+	var panicked bool
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				http.Error(w, fmt.Sprintf("Failed to buy tickets: %v", rec), http.StatusBadRequest)
+				panicked = true
+			}
+		}()
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		tx = blockchain.NewLotteryTicketTransaction(&player, string(lottery.Address()), req.Tickets, req.Commitment, &UTXOSet)
+		block = chain.MineBlock([]*blockchain.Transaction{tx})
+		UTXOSet.Update(block)
+	}()
+	if panicked {
+		return
+	}
+
+	round := block.Height / blockchain.LotteryRoundBlocks()
+	writeJSON(w, map[string]interface{}{
+		"txid":       hex.EncodeToString(tx.ID),
+		"round":      round,
+		"drawHeight": blockchain.LotteryDrawHeight(round),
+		"tickets":    req.Tickets,
+		"commitment": req.Commitment,
+	})
+}
+
+// GetLottery reports the lottery address and its rounds.
+func GetLottery(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		walletError(w, err)
+		return
+	}
+
+	roundBlocks := blockchain.LotteryRoundBlocks()
+	rounds := blockchain.Lottery{Blockchain: chain}.Rounds()
+	if rounds == nil {
+		rounds = []blockchain.LotteryRound{}
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"address":      wallets.Lottery,
+		"roundBlocks":  roundBlocks,
+		"currentRound": (chain.GetBestHeight() + 1) / roundBlocks,
+		"rounds":       rounds,
+	})
+}
+
+// GetLotteryRound lists the tickets of a round and its draw, if drawn.
+func GetLotteryRound(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	round, err := strconv.Atoi(mux.Vars(r)["round"])
+	if err != nil || round < 0 {
+		http.Error(w, "Invalid round", http.StatusBadRequest)
+		return
+	}
+
+	lottery := blockchain.Lottery{Blockchain: chain}
+
+	tickets := []TicketJSON{}
+	for _, ticket := range lottery.Tickets(round) {
+		tickets = append(tickets, ticketJSON(ticket))
+	}
+
+	response := map[string]interface{}{"round": round, "tickets": tickets}
+	for _, draw := range lottery.Draws() {
+		if draw.Draw.Round == round {
+			response["draw"] = DrawJSON{TxID: hex.EncodeToString(draw.TxID), Height: draw.Height, Draw: draw.Draw}
+		}
+	}
+
+	writeJSON(w, response)
+}
+
+// GetLotteryWinners lists the past draws, newest first.
+func GetLotteryWinners(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	draws := blockchain.Lottery{Blockchain: chain}.Draws()
+
+	winners := []DrawJSON{}
+	for i := len(draws) - 1; i >= 0; i-- {
+		winners = append(winners, DrawJSON{TxID: hex.EncodeToString(draws[i].TxID), Height: draws[i].Height, Draw: draws[i].Draw})
+	}
+
+	writeJSON(w, map[string]interface{}{"winners": winners})
+}
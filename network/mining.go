@@ -0,0 +1,209 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+)
+
+var memoryPoolMu sync.Mutex
+
+// BlockTemplateResponse is the JSON form of a blockchain.BlockTemplate, in
+// the spirit of getblocktemplate. Transactions are hex encoded serialized
+// transactions that must follow the miner's coinbase in the block.
+type BlockTemplateResponse struct {
+	PrevHash      string   `json:"prevHash"`
+	Height        int      `json:"height"`
+	Timestamp     int64    `json:"timestamp"`
+	Difficulty    int      `json:"difficulty"`
+	Target        string   `json:"target"`
+	CoinbaseValue int      `json:"coinbaseValue"`
+	Transactions  []string `json:"transactions"`
+}
+
+type SubmitBlockRequest struct {
+	Block string `json:"block"`
+}
+
+func mempoolTransactions() []*blockchain.Transaction {
+	memoryPoolMu.Lock()
+	defer memoryPoolMu.Unlock()
+
+	var txs []*blockchain.Transaction
+	for id := range memoryPool {
+		tx := memoryPool[id]
+		txs = append(txs, &tx)
+	}
+
+	return txs
+}
+
+func removeFromMempool(txs []*blockchain.Transaction) {
+	memoryPoolMu.Lock()
+	defer memoryPoolMu.Unlock()
+
+	for _, tx := range txs {
+		delete(memoryPool, hex.EncodeToString(tx.ID))
+	}
+}
+
+func NewBlockTemplateResponse(t *blockchain.BlockTemplate) BlockTemplateResponse {
+	response := BlockTemplateResponse{
+		PrevHash:      hex.EncodeToString(t.PrevHash),
+		Height:        t.Height,
+		Timestamp:     t.Timestamp,
+		Difficulty:    t.Difficulty,
+		Target:        fmt.Sprintf("%064x", t.Target),
+		CoinbaseValue: t.CoinbaseValue,
+		Transactions:  []string{},
+	}
+	for _, tx := range t.Transactions {
+		response.Transactions = append(response.Transactions, hex.EncodeToString(tx.Serialize()))
+	}
+
+	return response
+}
+
+func (r BlockTemplateResponse) Template() (*blockchain.BlockTemplate, error) {
+	prevHash, err := hex.DecodeString(r.PrevHash)
+	if err != nil {
+		return nil, fmt.Errorf("bad prevHash: %w", err)
+	}
+	target, ok := new(big.Int).SetString(r.Target, 16)
+	if !ok {
+		return nil, errors.New("bad target")
+	}
+
+	t := &blockchain.BlockTemplate{
+		PrevHash:      prevHash,
+		Height:        r.Height,
+		Timestamp:     r.Timestamp,
+		Difficulty:    r.Difficulty,
+		Target:        target,
+		CoinbaseValue: r.CoinbaseValue,
+	}
+	for _, raw := range r.Transactions {
+		data, err := hex.DecodeString(raw)
+		if err != nil {
+			return nil, fmt.Errorf("bad transaction: %w", err)
+		}
+		tx := blockchain.DeserializeTransaction(data)
+		t.Transactions = append(t.Transactions, &tx)
+	}
+
+	return t, nil
+}
+
+func GetBlockTemplate(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	template := chain.NewBlockTemplate(mempoolTransactions())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(NewBlockTemplateResponse(template))
+}
+
+func SubmitBlock(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	var req SubmitBlockRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	data, err := hex.DecodeString(req.Block)
+	if err != nil {
+		http.Error(w, "Block must be hex encoded", http.StatusBadRequest)
+		return
+	}
+
+	var block *blockchain.Block
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("malformed block: %v", rec)
+			}
+		}()
+		block = blockchain.Deserialize(data)
+		err = chain.SubmitBlock(block)
+	}()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Block rejected: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	removeFromMempool(block.Transactions)
+	announceBlock(block)
+
+	response := map[string]interface{}{
+		"status": "accepted",
+		"block":  fmt.Sprintf("%x", block.Hash),
+		"height": block.Height,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// announceBlock tells known peers about a block solved by an external miner.
+func announceBlock(block *blockchain.Block) {
+	if nodeAddress == "" {
+		return
+	}
+	for _, node := range KnownNodes {
+		if node != nodeAddress {
+			SendInv(node, "block", [][]byte{block.Hash})
+		}
+	}
+}
+
+// FetchBlockTemplate asks the node at apiURL for work.
+func FetchBlockTemplate(apiURL string) (*blockchain.BlockTemplate, error) {
+	resp, err := http.Get(apiURL + "/mining/template")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("template request failed: %s", bytes.TrimSpace(body))
+	}
+
+	var response BlockTemplateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response.Template()
+}
+
+// SubmitSolvedBlock hands a solved block back to the node at apiURL.
+func SubmitSolvedBlock(apiURL string, block *blockchain.Block) error {
+	payload, err := json.Marshal(SubmitBlockRequest{Block: hex.EncodeToString(block.Serialize())})
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(apiURL+"/mining/submit", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return errors.New(string(bytes.TrimSpace(body)))
+	}
+
+	return nil
+}
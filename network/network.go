@@ -29,6 +29,8 @@ var (
 	// Central node should be set via environment variable for flexibility
 	KnownNodes      = []string{}
 	blocksInTransit = [][]byte{}
+	// Tip before the blocks in transit, their listeners run once all arrived
+	syncFrom        []byte
 	memoryPool      = make(map[string]blockchain.Transaction)
 )
 
@@ -230,6 +232,11 @@ func HandleBlock(request []byte, chain *blockchain.BlockChain) {
 	block := blockchain.Deserialize(blockData)
 
 	fmt.Println("Recevied a new block!")
+	chain.LockSpends()
+	defer chain.UnlockSpends()
+	if syncFrom == nil {
+		syncFrom = chain.LastHash
+	}
 	chain.AddBlock(block)
 
 	fmt.Printf("Added block %x\n", block.Hash)
@@ -242,9 +249,38 @@ func HandleBlock(request []byte, chain *blockchain.BlockChain) {
 	} else {
 		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
 		UTXOSet.Reindex()
+		for _, connected := range connectedSince(chain, syncFrom) {
+			blockchain.BlockConnected(chain, connected)
+		}
+		syncFrom = nil
 	}
 }
 
+// connectedSince lists the blocks of the main chain after the block with
+// hash from, oldest first. All of them are listed when from isn't on the
+// main chain anymore.
+func connectedSince(chain *blockchain.BlockChain, from []byte) []*blockchain.Block {
+	var blocks []*blockchain.Block
+
+	iter := chain.Iterator()
+	for {
+		block := iter.Next()
+		if bytes.Equal(block.Hash, from) {
+			break
+		}
+		blocks = append(blocks, block)
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+	return blocks
+}
+
 func HandleGetBlocks(request []byte, chain *blockchain.BlockChain) {
 	var buff bytes.Buffer
 	var payload GetBlocks
@@ -327,9 +363,16 @@ func HandleTx(request []byte, chain *blockchain.BlockChain) {
 
 	txData := payload.Transaction
 	tx := blockchain.DeserializeTransaction(txData)
+	if !verifyPoolTx(chain, &tx) {
+		fmt.Printf("Rejected invalid transaction %x\n", tx.ID)
+		return
+	}
+	memoryPoolMu.Lock()
 	memoryPool[hex.EncodeToString(tx.ID)] = tx
+	poolSize := len(memoryPool)
+	memoryPoolMu.Unlock()
 
-	fmt.Printf(" %s, %d", nodeAddress, len(memoryPool))
+	fmt.Printf(" %s, %d", nodeAddress, poolSize)
 
 	if nodeAddress == KnownNodes[0] {
 		for _, node := range KnownNodes {
@@ -340,6 +383,17 @@ func HandleTx(request []byte, chain *blockchain.BlockChain) {
 	}
 }
 
+// verifyPoolTx checks a transaction before it enters the memory pool. The
+// signatures land in the shared cache, so mining it later is cheap.
+func verifyPoolTx(chain *blockchain.BlockChain, tx *blockchain.Transaction) (valid bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			valid = false
+		}
+	}()
+
+	return chain.VerifyTransaction(tx)
+}
 
 func HandleInv(request []byte, chain *blockchain.BlockChain) {
 	var buff bytes.Buffer
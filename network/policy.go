@@ -0,0 +1,85 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+)
+
+// refusalStatus is 403 Forbidden for bets a responsible gambling policy
+// refuses, status for any other error.
+func refusalStatus(err error, status int) int {
+	var refused *games.PolicyError
+	if errors.As(err, &refused) {
+		return http.StatusForbidden
+	}
+	return status
+}
+
+// GetPolicy returns the policy of an address with what it staked and lost
+// in the last 24 hours.
+func GetPolicy(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	address := mux.Vars(r)["address"]
+	if !validAddress(address) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, games.Policies{Blockchain: chain}.Status(address))
+}
+
+// SetPolicy changes a policy with a message signed by the address's key.
+func SetPolicy(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	var signed games.SignedPolicy
+	if err := json.NewDecoder(r.Body).Decode(&signed); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validAddress(signed.Address) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+
+	policies := games.Policies{Blockchain: chain}
+	if _, err := policies.Apply(signed); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, games.ErrPolicySignature) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, policies.Status(signed.Address))
+}
+
+// SubmitPolicy posts a signed policy message to a node's API.
+func SubmitPolicy(apiURL string, signed games.SignedPolicy) (*games.PolicyStatus, error) {
+	payload, err := json.Marshal(signed)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(apiURL+"/policies", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, errors.New(string(bytes.TrimSpace(body)))
+	}
+
+	var status games.PolicyStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
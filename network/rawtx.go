@@ -0,0 +1,287 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// BuildTxRequest asks for an unsigned transaction sending Amount from the
+// key PubKey, hex encoded as wallet.EncodePublicKey writes it, to To.
+type BuildTxRequest struct {
+	PubKey string `json:"pubKey"`
+	To     string `json:"to"`
+	Amount int    `json:"amount"`
+}
+
+type TxInputJSON struct {
+	TxID      string `json:"txid"`
+	Out       int    `json:"out"`
+	Signature string `json:"signature,omitempty"`
+	PubKey    string `json:"pubKey,omitempty"`
+}
+
+type TxOutputJSON struct {
+	Value      int    `json:"value"`
+	PubKeyHash string `json:"pubKeyHash"`
+}
+
+// TxJSON spells out a transaction, its bytes hex encoded.
+type TxJSON struct {
+	ID      string         `json:"id"`
+	Inputs  []TxInputJSON  `json:"inputs"`
+	Outputs []TxOutputJSON `json:"outputs"`
+}
+
+type PrevOutputJSON struct {
+	TxID       string `json:"txid"`
+	Out        int    `json:"out"`
+	Value      int    `json:"value"`
+	PubKeyHash string `json:"pubKeyHash"`
+}
+
+// UnsignedTxResponse is a transaction to sign: Raw is the serialized
+// transaction, Tx the same spelled out, and SigHashes the digest each input
+// signs. A careful signer recomputes them from Tx and PrevOutputs.
+type UnsignedTxResponse struct {
+	From        string           `json:"from"`
+	Raw         string           `json:"raw"`
+	Tx          TxJSON           `json:"tx"`
+	PrevOutputs []PrevOutputJSON `json:"prevOutputs"`
+	SigHashes   []string         `json:"sigHashes"`
+}
+
+// SubmitTxRequest hands in a signed transaction, serialized and hex encoded.
+// A client that cannot serialize transactions sends back the Raw it got
+// from /tx/build with a hex encoded signature per input in Signatures.
+type SubmitTxRequest struct {
+	Raw        string   `json:"raw"`
+	Signatures []string `json:"signatures,omitempty"`
+}
+
+func NewTxJSON(tx *blockchain.Transaction) TxJSON {
+	j := TxJSON{ID: hex.EncodeToString(tx.ID), Inputs: []TxInputJSON{}, Outputs: []TxOutputJSON{}}
+	for _, in := range tx.Inputs {
+		j.Inputs = append(j.Inputs, TxInputJSON{
+			TxID:      hex.EncodeToString(in.ID),
+			Out:       in.Out,
+			Signature: hex.EncodeToString(in.Signature),
+			PubKey:    hex.EncodeToString(in.PubKey),
+		})
+	}
+	for _, out := range tx.Outputs {
+		j.Outputs = append(j.Outputs, TxOutputJSON{Value: out.Value, PubKeyHash: hex.EncodeToString(out.PubKeyHash)})
+	}
+
+	return j
+}
+
+func NewUnsignedTxResponse(tx *blockchain.Transaction, prevOuts []blockchain.PrevOutput) (UnsignedTxResponse, error) {
+	hashes, err := tx.SigHashes(prevOuts)
+	if err != nil {
+		return UnsignedTxResponse{}, err
+	}
+
+	response := UnsignedTxResponse{
+		Raw:       hex.EncodeToString(tx.Serialize()),
+		Tx:        NewTxJSON(tx),
+		SigHashes: []string{},
+	}
+	if len(tx.Inputs) > 0 {
+		response.From = string(wallet.Wallet{PublicKey: tx.Inputs[0].PubKey}.Address())
+	}
+	for _, prev := range prevOuts {
+		response.PrevOutputs = append(response.PrevOutputs, PrevOutputJSON{
+			TxID:       hex.EncodeToString(prev.TxID),
+			Out:        prev.Out,
+			Value:      prev.Value,
+			PubKeyHash: hex.EncodeToString(prev.PubKeyHash),
+		})
+	}
+	for _, hash := range hashes {
+		response.SigHashes = append(response.SigHashes, hex.EncodeToString(hash))
+	}
+
+	return response, nil
+}
+
+// Transaction reads back the serialized transaction and its previous
+// outputs.
+func (r UnsignedTxResponse) Transaction() (*blockchain.Transaction, []blockchain.PrevOutput, error) {
+	data, err := hex.DecodeString(r.Raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad raw transaction: %w", err)
+	}
+	tx, err := blockchain.ParseTransaction(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var prevOuts []blockchain.PrevOutput
+	for _, prev := range r.PrevOutputs {
+		txID, err := hex.DecodeString(prev.TxID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad previous output: %w", err)
+		}
+		pubKeyHash, err := hex.DecodeString(prev.PubKeyHash)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad previous output: %w", err)
+		}
+		prevOuts = append(prevOuts, blockchain.PrevOutput{TxID: txID, Out: prev.Out, Value: prev.Value, PubKeyHash: pubKeyHash})
+	}
+
+	return &tx, prevOuts, nil
+}
+
+// BuildTransaction makes an unsigned transaction for a client holding its
+// own key.
+func BuildTransaction(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	var req BuildTxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	pubKey, err := hex.DecodeString(req.PubKey)
+	if err != nil {
+		http.Error(w, "Public key must be hex encoded", http.StatusBadRequest)
+		return
+	}
+	if !wallet.ValidateAddress(req.To) {
+		http.Error(w, "Invalid 'to' address", http.StatusBadRequest)
+		return
+	}
+	if req.Amount <= 0 {
+		http.Error(w, "Amount must be greater than 0", http.StatusBadRequest)
+		return
+	}
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+	tx, prevOuts, err := blockchain.NewUnsignedTransaction(pubKey, req.To, req.Amount, &UTXOSet)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response, err := NewUnsignedTxResponse(tx, prevOuts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, response)
+}
+
+// SubmitRawTransaction validates a transaction signed by a client and
+// mines it.
+func SubmitRawTransaction(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	var req SubmitTxRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	data, err := hex.DecodeString(req.Raw)
+	if err != nil {
+		http.Error(w, "Transaction must be hex encoded", http.StatusBadRequest)
+		return
+	}
+	tx, err := blockchain.ParseTransaction(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Signatures != nil {
+		if len(req.Signatures) != len(tx.Inputs) {
+			http.Error(w, fmt.Sprintf("Need %d signatures, got %d", len(tx.Inputs), len(req.Signatures)), http.StatusBadRequest)
+			return
+		}
+		for i, sig := range req.Signatures {
+			if tx.Inputs[i].Signature, err = hex.DecodeString(sig); err != nil {
+				http.Error(w, "Signatures must be hex encoded", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	chain.LockSpends()
+	defer chain.UnlockSpends()
+
+	if err := chain.ValidateRawTransaction(&tx); err != nil {
+		http.Error(w, fmt.Sprintf("Transaction rejected: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var block *blockchain.Block
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("%v", rec)
+			}
+		}()
+		block = chain.MineBlock([]*blockchain.Transaction{&tx})
+	}()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to mine transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+	UTXOSet.Update(block)
+
+	writeJSON(w, map[string]string{
+		"status":  "success",
+		"message": "Transaction mined successfully",
+		"txid":    hex.EncodeToString(tx.ID),
+		"block":   fmt.Sprintf("%x", block.Hash),
+	})
+}
+
+// FetchUnsignedTransaction asks the node at apiURL for a transaction to
+// sign.
+func FetchUnsignedTransaction(apiURL string, req BuildTxRequest) (*UnsignedTxResponse, error) {
+	var response UnsignedTxResponse
+	if err := postJSON(apiURL+"/tx/build", req, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// SubmitSignedTransaction hands a signed transaction to the node at apiURL
+// and returns the hash of the block it was mined in.
+func SubmitSignedTransaction(apiURL string, tx *blockchain.Transaction) (string, error) {
+	var response map[string]string
+	req := SubmitTxRequest{Raw: hex.EncodeToString(tx.Serialize())}
+	if err := postJSON(apiURL+"/tx/submit", req, &response); err != nil {
+		return "", err
+	}
+	return response["block"], nil
+}
+
+func postJSON(url string, req, response interface{}) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return errors.New(string(bytes.TrimSpace(body)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(response)
+}
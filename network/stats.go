@@ -0,0 +1,25 @@
+package network
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+)
+
+// GetPlayerStats reports the bets of an address per game.
+func GetPlayerStats(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	address := mux.Vars(r)["address"]
+	if !validAddress(address) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, blockchain.Stats{Blockchain: chain}.Player(address))
+}
+
+// GetHouseStats reports the results of the house over every bet on chain.
+func GetHouseStats(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	writeJSON(w, blockchain.Stats{Blockchain: chain}.House())
+}
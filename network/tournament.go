@@ -0,0 +1,210 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// CreateTournamentRequest opens a tournament. Blocks is how many blocks
+// from now it ends, Prizes the percent of the pool of each place.
+type CreateTournamentRequest struct {
+	Game     string `json:"game"`
+	EntryFee int    `json:"entryFee"`
+	Chips    int    `json:"chips"`
+	Blocks   int    `json:"blocks"`
+	Prizes   []int  `json:"prizes"`
+}
+
+func tournamentBook(w http.ResponseWriter, chain *blockchain.BlockChain) (games.TournamentBook, bool) {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		walletError(w, err)
+		return games.TournamentBook{}, false
+	}
+
+	return games.TournamentBook{Blockchain: chain, Wallets: wallets, NodeID: nodeID}, true
+}
+
+func CreateTournament(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	var req CreateTournamentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	book, ok := tournamentBook(w, chain)
+	if !ok {
+		return
+	}
+
+	tournament, err := book.Create(req.Game, req.EntryFee, req.Chips, req.Blocks, req.Prizes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, tournament)
+}
+
+// GetTournaments lists the tournaments without their bets.
+func GetTournaments(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	book, ok := tournamentBook(w, chain)
+	if !ok {
+		return
+	}
+
+	tournaments := []games.Tournament{}
+	for _, tournament := range book.Tournaments() {
+		tournament.Bets = nil
+		tournaments = append(tournaments, tournament)
+	}
+
+	writeJSON(w, map[string]interface{}{"tournaments": tournaments})
+}
+
+func GetTournament(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	book, ok := tournamentBook(w, chain)
+	if !ok {
+		return
+	}
+
+	tournament, err := book.Tournament(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, tournament)
+}
+
+// RegisterTournament pays the entry fee of "from".
+func RegisterTournament(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	var req GameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validAddress(req.From) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+	book, ok := tournamentBook(w, chain)
+	if !ok {
+		return
+	}
+
+	var tournament *games.Tournament
+	var err error
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("Failed to build entry transaction: %v", rec)
+			}
+		}()
+		tournament, err = book.Register(mux.Vars(r)["id"], req.From)
+	}()
+	if err != nil {
+		status := http.StatusBadRequest
+		if tournament == nil {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), refusalStatus(err, status))
+		return
+	}
+	writeJSON(w, tournament)
+}
+
+// PlayTournament bets chips on the tournament's game. The body is that of
+// the game endpoints, with the amount in chips.
+func PlayTournament(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	req, err := decodeGameRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validAddress(req.From) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+	book, ok := tournamentBook(w, chain)
+	if !ok {
+		return
+	}
+
+	tournament, bet, err := book.Play(mux.Vars(r)["id"], req.From, req.Amount, req.Params, req.ClientSeed, req.Nonce)
+	if err != nil {
+		status := http.StatusBadRequest
+		if tournament == nil {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	chips := 0
+	for _, entry := range tournament.Entries {
+		if entry.Player == req.From {
+			chips = entry.Chips
+		}
+	}
+	writeJSON(w, map[string]interface{}{"bet": bet, "chips": chips})
+}
+
+// GetTournamentLeaderboard ranks the players by chips, live while the
+// tournament runs.
+func GetTournamentLeaderboard(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	book, ok := tournamentBook(w, chain)
+	if !ok {
+		return
+	}
+
+	tournament, err := book.Tournament(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	leaderboard := tournament.Leaderboard()
+	if leaderboard == nil {
+		leaderboard = []games.TournamentEntry{}
+	}
+	writeJSON(w, map[string]interface{}{
+		"id":          tournament.ID,
+		"status":      tournament.Status,
+		"height":      chain.GetBestHeight(),
+		"endHeight":   tournament.EndHeight,
+		"pool":        tournament.Pool(),
+		"leaderboard": leaderboard,
+	})
+}
+
+// GetTournamentResults returns the places and prizes of a finished
+// tournament with the transaction that paid them.
+func GetTournamentResults(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	book, ok := tournamentBook(w, chain)
+	if !ok {
+		return
+	}
+
+	tournament, err := book.Tournament(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if tournament.Status != games.TournamentFinished {
+		http.Error(w, fmt.Sprintf("tournament %s ends at height %d", tournament.ID, tournament.EndHeight), http.StatusConflict)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"id":       tournament.ID,
+		"pool":     tournament.Pool(),
+		"results":  tournament.Results,
+		"payoutTx": hex.EncodeToString(tournament.PayoutTx),
+	})
+}
@@ -0,0 +1,164 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/games"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// WagerRequest is the body of every wager call. Commitment is the hex
+// sha256 of the player's secret, which only goes to the node on reveal.
+type WagerRequest struct {
+	From       string `json:"from"`
+	Opponent   string `json:"opponent"`
+	Amount     int    `json:"amount"`
+	Commitment string `json:"commitment"`
+	Secret     string `json:"secret"`
+}
+
+func wagerBook(w http.ResponseWriter, chain *blockchain.BlockChain) (games.WagerBook, bool) {
+	wallets, err := wallet.CreateWallets(nodeID)
+	if err != nil {
+		walletError(w, err)
+		return games.WagerBook{}, false
+	}
+
+	return games.WagerBook{Blockchain: chain, Wallets: wallets, NodeID: nodeID}, true
+}
+
+// runWager runs a wager operation, turning the panics of the transaction
+// constructors into errors like playBlackjack does.
+func runWager(w http.ResponseWriter, run func() (*games.Wager, error)) {
+	var wager *games.Wager
+	var err error
+	var panicked bool
+
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				http.Error(w, fmt.Sprintf("Failed to build wager transaction: %v", rec), http.StatusBadRequest)
+				panicked = true
+			}
+		}()
+		wager, err = run()
+	}()
+
+	if panicked {
+		return
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if wager == nil {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), refusalStatus(err, status))
+		return
+	}
+
+	writeJSON(w, wager.State())
+}
+
+func decodeWagerRequest(w http.ResponseWriter, r *http.Request) (WagerRequest, bool) {
+	var req WagerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+	if !validAddress(req.From) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return req, false
+	}
+	if req.Opponent != "" && !validAddress(req.Opponent) {
+		http.Error(w, "Invalid opponent address", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// CreateWager offers a wager, to one opponent or to anyone.
+func CreateWager(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	req, ok := decodeWagerRequest(w, r)
+	if !ok {
+		return
+	}
+	book, ok := wagerBook(w, chain)
+	if !ok {
+		return
+	}
+
+	wager, err := book.Create(req.From, req.Opponent, req.Amount, req.Commitment)
+	if err != nil {
+		http.Error(w, err.Error(), refusalStatus(err, http.StatusBadRequest))
+		return
+	}
+	writeJSON(w, wager.State())
+}
+
+// WagerAction accepts, reveals, refunds or cancels a wager.
+func WagerAction(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	vars := mux.Vars(r)
+
+	req, ok := decodeWagerRequest(w, r)
+	if !ok {
+		return
+	}
+	book, ok := wagerBook(w, chain)
+	if !ok {
+		return
+	}
+
+	id := vars["id"]
+	switch vars["action"] {
+	case "accept":
+		runWager(w, func() (*games.Wager, error) { return book.Accept(id, req.From, req.Commitment) })
+	case "reveal":
+		runWager(w, func() (*games.Wager, error) { return book.Reveal(id, req.From, req.Secret) })
+	case "refund":
+		runWager(w, func() (*games.Wager, error) { return book.Refund(id, req.From) })
+	case "cancel":
+		runWager(w, func() (*games.Wager, error) { return book.Cancel(id, req.From) })
+	default:
+		http.Error(w, "Unknown wager action", http.StatusNotFound)
+	}
+}
+
+func GetWager(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	book, ok := wagerBook(w, chain)
+	if !ok {
+		return
+	}
+
+	wager, err := book.Wager(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, wager.State())
+}
+
+// GetWagers lists wagers, optionally of one address.
+func GetWagers(w http.ResponseWriter, r *http.Request, chain *blockchain.BlockChain) {
+	address := r.URL.Query().Get("address")
+	if address != "" && !validAddress(address) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+
+	book, ok := wagerBook(w, chain)
+	if !ok {
+		return
+	}
+
+	states := []games.WagerState{}
+	for _, wager := range book.Wagers(address) {
+		states = append(states, wager.State())
+	}
+
+	writeJSON(w, map[string]interface{}{"wagers": states})
+}
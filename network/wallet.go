@@ -0,0 +1,119 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+// defaultUnlockTimeout keeps an unlocked wallet open for five minutes
+// unless the request asks otherwise.
+const defaultUnlockTimeout = 300
+
+// UnlockRequest unlocks the wallet file for Timeout seconds, the default
+// when it is 0.
+type UnlockRequest struct {
+	Passphrase string `json:"passphrase"`
+	Timeout    int    `json:"timeout"`
+}
+
+type WalletStatusResponse struct {
+	Encrypted bool `json:"encrypted"`
+	Locked    bool `json:"locked"`
+	// Until is when the wallet locks again, unset without a timeout
+	Until string `json:"until,omitempty"`
+}
+
+// walletError answers a request whose wallet file failed to load, with
+// 423 Locked while the wallet is locked so that nothing gets signed.
+func walletError(w http.ResponseWriter, err error) {
+	if errors.Is(err, wallet.ErrLocked) {
+		http.Error(w, "Wallet is locked, unlock it first", http.StatusLocked)
+		return
+	}
+	http.Error(w, "Failed to load wallets", http.StatusInternalServerError)
+}
+
+func walletStatus() WalletStatusResponse {
+	encrypted, locked, until := wallet.WalletStatus(nodeID)
+	status := WalletStatusResponse{Encrypted: encrypted, Locked: locked}
+	if encrypted && !locked && !until.IsZero() {
+		status.Until = until.UTC().Format(time.RFC3339)
+	}
+	return status
+}
+
+func GetWalletStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, walletStatus())
+}
+
+func UnlockWallet(w http.ResponseWriter, r *http.Request) {
+	var req UnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Timeout < 0 {
+		http.Error(w, "Timeout can't be negative", http.StatusBadRequest)
+		return
+	}
+	if req.Timeout == 0 {
+		req.Timeout = defaultUnlockTimeout
+	}
+
+	err := wallet.Unlock(nodeID, req.Passphrase, time.Duration(req.Timeout)*time.Second)
+	if errors.Is(err, wallet.ErrPassphrase) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, walletStatus())
+}
+
+func LockWallet(w http.ResponseWriter, r *http.Request) {
+	wallet.Lock(nodeID)
+	writeJSON(w, walletStatus())
+}
+
+// SubmitWalletUnlock asks a node's API to unlock its wallet file for
+// timeout seconds.
+func SubmitWalletUnlock(apiURL, passphrase string, timeout int) (*WalletStatusResponse, error) {
+	payload, err := json.Marshal(UnlockRequest{Passphrase: passphrase, Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+	return postWallet(apiURL+"/wallet/unlock", payload)
+}
+
+// SubmitWalletLock asks a node's API to lock its wallet file.
+func SubmitWalletLock(apiURL string) (*WalletStatusResponse, error) {
+	return postWallet(apiURL+"/wallet/lock", nil)
+}
+
+func postWallet(url string, payload []byte) (*WalletStatusResponse, error) {
+	resp, err := http.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, errors.New(string(bytes.TrimSpace(body)))
+	}
+
+	var status WalletStatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
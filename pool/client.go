@@ -0,0 +1,180 @@
+package pool
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+)
+
+// Client is a minimal Stratum v1 miner used as a local stand-in for real
+// mining hardware. It hashes the job header on Workers goroutines and
+// submits every hash that meets the share difficulty.
+type Client struct {
+	PoolAddr string
+	Worker   string
+	Workers  int
+
+	conn       net.Conn
+	encMu      sync.Mutex
+	enc        *json.Encoder
+	nextID     int
+	extraNonce int
+	shareBits  int
+
+	Accepted int
+	Rejected int
+}
+
+func NewClient(poolAddr, worker string, workers int) *Client {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Client{PoolAddr: poolAddr, Worker: worker, Workers: workers, shareBits: blockchain.Difficulty}
+}
+
+func (c *Client) call(method string, params ...interface{}) {
+	c.encMu.Lock()
+	defer c.encMu.Unlock()
+
+	c.nextID++
+	raw := make([]json.RawMessage, len(params))
+	for i, p := range params {
+		raw[i], _ = json.Marshal(p)
+	}
+	c.enc.Encode(Request{ID: c.nextID, Method: method, Params: raw})
+}
+
+// Run connects, subscribes, authorises and mines until ctx is cancelled or
+// the connection drops.
+func (c *Client) Run(ctx context.Context) error {
+	conn, err := net.Dial("tcp", c.PoolAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	c.conn = conn
+	c.enc = json.NewEncoder(conn)
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	c.call(MethodSubscribe, "stand-in-miner/1.0")
+	c.call(MethodAuthorize, c.Worker, "x")
+
+	var cancelJob context.CancelFunc = func() {}
+	defer func() { cancelJob() }()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var msg struct {
+			ID     interface{}       `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+			Result json.RawMessage   `json:"result"`
+			Error  json.RawMessage   `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+
+		switch msg.Method {
+		case MethodSetDifficulty:
+			if len(msg.Params) > 0 {
+				json.Unmarshal(msg.Params[0], &c.shareBits)
+			}
+		case MethodNotify:
+			job, err := ParseJob(msg.Params)
+			if err != nil {
+				fmt.Printf("Bad job: %v\n", err)
+				continue
+			}
+			cancelJob()
+			cancelJob = c.startJob(ctx, job)
+		case "":
+			c.handleResponse(msg.ID, msg.Result, msg.Error)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return scanner.Err()
+}
+
+func (c *Client) handleResponse(id interface{}, result, stratumErr json.RawMessage) {
+	// The subscribe result carries our extra nonce
+	var subscribe []json.RawMessage
+	if json.Unmarshal(result, &subscribe) == nil && len(subscribe) >= 2 {
+		var extraNonce string
+		if json.Unmarshal(subscribe[1], &extraNonce) == nil {
+			n, _ := strconv.ParseInt(extraNonce, 16, 64)
+			c.extraNonce = int(n)
+		}
+		return
+	}
+
+	if id == nil || string(result) == "null" && string(stratumErr) == "null" {
+		return
+	}
+	var accepted bool
+	json.Unmarshal(result, &accepted)
+	if accepted {
+		c.Accepted++
+	} else if string(stratumErr) != "null" {
+		c.Rejected++
+		fmt.Printf("Share rejected: %s\n", stratumErr)
+	}
+}
+
+// startJob mines job in the background until the returned func is called.
+func (c *Client) startJob(ctx context.Context, job Job) context.CancelFunc {
+	jobCtx, cancel := context.WithCancel(ctx)
+	go c.mine(jobCtx, job, c.shareBits)
+
+	return cancel
+}
+
+func (c *Client) mine(ctx context.Context, job Job, shareBits int) {
+	prevHash, err1 := hex.DecodeString(job.PrevHash)
+	txRoot, err2 := hex.DecodeString(job.TxRoot)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	target := blockchain.TargetForBits(shareBits)
+
+	var wg sync.WaitGroup
+	for w := 0; w < c.Workers; w++ {
+		wg.Add(1)
+		go func(first int) {
+			defer wg.Done()
+			var intHash big.Int
+
+			for nonce := first; nonce >= 0 && nonce < math.MaxInt64; nonce += c.Workers {
+				if ctx.Err() != nil {
+					return
+				}
+				hash := sha256.Sum256(blockchain.HeaderData(prevHash, txRoot, nonce, job.Time, c.extraNonce))
+				intHash.SetBytes(hash[:])
+				if intHash.Cmp(target) == -1 {
+					c.call(MethodSubmit, c.Worker, job.ID, "", strconv.FormatInt(job.Time, 16), strconv.FormatInt(int64(nonce), 16))
+					// Don't flood a single-machine test pool with shares
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,107 @@
+package pool
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+)
+
+// Share is one accepted proof of work below the share target, credited to
+// the payout address of the worker that found it.
+type Share struct {
+	Address string
+	Weight  int
+}
+
+// ShareWindow keeps the last N shares for pay-per-last-N-shares payouts.
+type ShareWindow struct {
+	mu     sync.Mutex
+	size   int
+	shares []Share
+	total  int
+}
+
+func NewShareWindow(size int) *ShareWindow {
+	return &ShareWindow{size: size}
+}
+
+func (sw *ShareWindow) Add(share Share) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	sw.shares = append(sw.shares, share)
+	sw.total++
+	if len(sw.shares) > sw.size {
+		sw.shares = sw.shares[len(sw.shares)-sw.size:]
+	}
+}
+
+// Total is the number of shares ever added, used to notice changes.
+func (sw *ShareWindow) Total() int {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	return sw.total
+}
+
+// Weights sums share weight per address over the window.
+func (sw *ShareWindow) Weights() map[string]int {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	weights := make(map[string]int)
+	for _, share := range sw.shares {
+		weights[share.Address] += share.Weight
+	}
+
+	return weights
+}
+
+// Payouts splits reward over the window proportionally to share weight after
+// taking feePercent for the pool. Rounding dust and the fee go to
+// poolAddress, which also receives everything while the window is empty.
+func (sw *ShareWindow) Payouts(reward int, feePercent float64, poolAddress string) map[string]int {
+	weights := sw.Weights()
+	payouts := make(map[string]int)
+
+	totalWeight := 0
+	for _, w := range weights {
+		totalWeight += w
+	}
+	if totalWeight == 0 {
+		payouts[poolAddress] = reward
+		return payouts
+	}
+
+	distributable := reward - int(float64(reward)*feePercent/100)
+	paid := 0
+	for address, w := range weights {
+		amount := distributable * w / totalWeight
+		if amount > 0 {
+			payouts[address] += amount
+			paid += amount
+		}
+	}
+	if reward > paid {
+		payouts[poolAddress] += reward - paid
+	}
+
+	return payouts
+}
+
+// PayoutOutputs turns payouts into coinbase outputs in a stable order.
+func PayoutOutputs(payouts map[string]int) []blockchain.TxOutput {
+	var addresses []string
+	for address := range payouts {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	var outputs []blockchain.TxOutput
+	for _, address := range addresses {
+		outputs = append(outputs, *blockchain.NewTXOutput(payouts[address], address))
+	}
+
+	return outputs
+}
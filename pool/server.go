@@ -0,0 +1,393 @@
+package pool
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ItsHotdogFred/blockchain/blockchain"
+	"github.com/ItsHotdogFred/blockchain/network"
+	"github.com/ItsHotdogFred/blockchain/wallet"
+)
+
+const (
+	jobRefreshInterval = 5 * time.Second
+	maxJobs            = 16
+	// writeTimeout bounds every write to a miner, so one that stops reading
+	// can't hold up the others
+	writeTimeout = 10 * time.Second
+)
+
+// Server is a Stratum v1 pool. It gets work from a node's template API,
+// pays the block reward to recent share submitters through the coinbase
+// (PPLNS) and submits solved blocks back to the node.
+type Server struct {
+	NodeURL     string
+	PoolAddress string
+	ShareBits   int
+	WindowSize  int
+	FeePercent  float64
+
+	mu             sync.Mutex
+	window         *ShareWindow
+	jobs           map[string]*poolJob
+	jobOrder       []string
+	current        *poolJob
+	nextJobID      int
+	nextExtraNonce int
+	seen           map[string]bool
+	sessions       map[*session]bool
+	stats          map[string]*WorkerStats
+}
+
+type poolJob struct {
+	Job
+	block       *blockchain.Block
+	txRoot      []byte
+	sharesTotal int
+}
+
+// WorkerStats is the share accounting kept per worker name.
+type WorkerStats struct {
+	Accepted int
+	Rejected int
+	Blocks   int
+}
+
+type session struct {
+	conn       net.Conn
+	encMu      sync.Mutex
+	enc        *json.Encoder
+	extraNonce int
+	subscribed bool
+	workers    map[string]string
+}
+
+func NewServer(nodeURL, poolAddress string, shareBits, windowSize int, feePercent float64) *Server {
+	return &Server{
+		NodeURL:     nodeURL,
+		PoolAddress: poolAddress,
+		ShareBits:   shareBits,
+		WindowSize:  windowSize,
+		FeePercent:  feePercent,
+		window:      NewShareWindow(windowSize),
+		jobs:        make(map[string]*poolJob),
+		seen:        make(map[string]bool),
+		sessions:    make(map[*session]bool),
+		stats:       make(map[string]*WorkerStats),
+	}
+}
+
+func (s *Server) shareTarget() *big.Int {
+	return blockchain.TargetForBits(s.ShareBits)
+}
+
+// ListenAndServe accepts miners on addr until the listener fails.
+func (s *Server) ListenAndServe(addr string) error {
+	if err := s.refreshJob(true); err != nil {
+		return err
+	}
+	go s.refreshLoop()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	fmt.Printf("Stratum pool listening on %s, share difficulty %d bits\n", addr, s.ShareBits)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) refreshLoop() {
+	ticker := time.NewTicker(jobRefreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.refreshJob(false); err != nil {
+			fmt.Printf("Could not refresh job: %v\n", err)
+		}
+	}
+}
+
+// refreshJob builds a new job when the tip moved or the share window changed
+// since the current job, so coinbase payouts follow the window.
+func (s *Server) refreshJob(force bool) error {
+	template, err := network.FetchBlockTemplate(s.NodeURL)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	clean := s.current == nil || !bytes.Equal(s.current.block.PrevHash, template.PrevHash)
+	sharesTotal := s.window.Total()
+	if !force && !clean && s.current.sharesTotal == sharesTotal {
+		s.mu.Unlock()
+		return nil
+	}
+
+	payouts := s.window.Payouts(template.CoinbaseValue, s.FeePercent, s.PoolAddress)
+	coinbase := blockchain.CoinbaseTxWithOutputs(PayoutOutputs(payouts), "")
+	block := template.NewBlock(coinbase)
+	txRoot := block.HashTransactions()
+
+	s.nextJobID++
+	job := &poolJob{
+		Job: Job{
+			ID:         strconv.FormatInt(int64(s.nextJobID), 16),
+			PrevHash:   hex.EncodeToString(block.PrevHash),
+			TxRoot:     hex.EncodeToString(txRoot),
+			Difficulty: blockchain.Difficulty,
+			Time:       block.Timestamp,
+			Clean:      clean,
+		},
+		block:       block,
+		txRoot:      txRoot,
+		sharesTotal: sharesTotal,
+	}
+
+	if clean {
+		s.jobs = make(map[string]*poolJob)
+		s.jobOrder = nil
+		s.seen = make(map[string]bool)
+	}
+	s.jobs[job.ID] = job
+	s.jobOrder = append(s.jobOrder, job.ID)
+	if len(s.jobOrder) > maxJobs {
+		delete(s.jobs, s.jobOrder[0])
+		s.jobOrder = s.jobOrder[1:]
+	}
+	s.current = job
+
+	var subscribed []*session
+	for sess := range s.sessions {
+		if sess.subscribed {
+			subscribed = append(subscribed, sess)
+		}
+	}
+	s.mu.Unlock()
+
+	// Each miner gets the job on its own, a slow one only holds up itself
+	var wg sync.WaitGroup
+	for _, sess := range subscribed {
+		wg.Add(1)
+		go func(sess *session) {
+			defer wg.Done()
+			sess.notify(MethodNotify, job.Params())
+		}(sess)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	s.mu.Lock()
+	s.nextExtraNonce++
+	sess := &session{
+		conn:       conn,
+		enc:        json.NewEncoder(conn),
+		extraNonce: s.nextExtraNonce,
+		workers:    make(map[string]string),
+	}
+	s.sessions[sess] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, sess)
+		s.mu.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			sess.reply(nil, nil, stratumError(ErrOther, "malformed request"))
+			continue
+		}
+		s.handleRequest(sess, req)
+	}
+}
+
+func (s *Server) handleRequest(sess *session, req Request) {
+	switch req.Method {
+	case MethodSubscribe:
+		s.mu.Lock()
+		sess.subscribed = true
+		job := s.current
+		s.mu.Unlock()
+
+		extraNonce := fmt.Sprintf("%08x", sess.extraNonce)
+		sess.reply(req.ID, []interface{}{
+			[][]string{{MethodSetDifficulty, extraNonce}, {MethodNotify, extraNonce}},
+			extraNonce,
+			0,
+		}, nil)
+		sess.notify(MethodSetDifficulty, []interface{}{s.ShareBits})
+		if job != nil {
+			sess.notify(MethodNotify, job.Params())
+		}
+
+	case MethodAuthorize:
+		params, err := stringParams(req.Params, 1)
+		if err != nil {
+			sess.reply(req.ID, false, stratumError(ErrOther, err.Error()))
+			return
+		}
+		// Workers are named ADDRESS or ADDRESS.rig, the address gets paid
+		worker := params[0]
+		address := strings.SplitN(worker, ".", 2)[0]
+		if !validAddress(address) {
+			sess.reply(req.ID, false, stratumError(ErrUnauthorized, "worker name must start with a payout address"))
+			return
+		}
+		s.mu.Lock()
+		sess.workers[worker] = address
+		if s.stats[worker] == nil {
+			s.stats[worker] = &WorkerStats{}
+		}
+		s.mu.Unlock()
+		sess.reply(req.ID, true, nil)
+
+	case MethodSubmit:
+		accepted, stratumErr := s.submitShare(sess, req.Params)
+		sess.reply(req.ID, accepted, stratumErr)
+
+	default:
+		sess.reply(req.ID, nil, stratumError(ErrOther, "unknown method "+req.Method))
+	}
+}
+
+// submitShare checks a share against the share target and, when it also
+// meets the block target, submits the block to the node.
+func (s *Server) submitShare(sess *session, rawParams []json.RawMessage) (bool, interface{}) {
+	params, err := stringParams(rawParams, 5)
+	if err != nil {
+		return false, stratumError(ErrOther, err.Error())
+	}
+	worker, jobID, ntime, nonceHex := params[0], params[1], params[3], params[4]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	address, ok := sess.workers[worker]
+	if !sess.subscribed {
+		return false, stratumError(ErrNotSubscribed, "not subscribed")
+	}
+	if !ok {
+		return false, stratumError(ErrUnauthorized, "unauthorized worker")
+	}
+	stats := s.stats[worker]
+
+	job := s.jobs[jobID]
+	if job == nil {
+		stats.Rejected++
+		return false, stratumError(ErrJobNotFound, "job not found")
+	}
+	nonce, err := strconv.ParseInt(nonceHex, 16, 64)
+	if err != nil || ntime != strconv.FormatInt(job.Time, 16) {
+		stats.Rejected++
+		return false, stratumError(ErrOther, "bad nonce or ntime")
+	}
+
+	shareKey := fmt.Sprintf("%s:%d:%d", jobID, sess.extraNonce, nonce)
+	if s.seen[shareKey] {
+		stats.Rejected++
+		return false, stratumError(ErrDuplicateShare, "duplicate share")
+	}
+
+	hash := sha256.Sum256(blockchain.HeaderData(job.block.PrevHash, job.txRoot, int(nonce), job.Time, sess.extraNonce))
+	intHash := new(big.Int).SetBytes(hash[:])
+	if intHash.Cmp(s.shareTarget()) != -1 {
+		stats.Rejected++
+		return false, stratumError(ErrLowDifficulty, "low difficulty share")
+	}
+
+	s.seen[shareKey] = true
+	stats.Accepted++
+	s.window.Add(Share{Address: address, Weight: 1 << uint(s.ShareBits)})
+
+	if intHash.Cmp(blockchain.TargetForBits(blockchain.Difficulty)) == -1 {
+		block := *job.block
+		block.Nonce = int(nonce)
+		block.ExtraNonce = sess.extraNonce
+		block.Hash = hash[:]
+
+		if err := network.SubmitSolvedBlock(s.NodeURL, &block); err != nil {
+			fmt.Printf("Block from %s rejected by node: %v\n", worker, err)
+		} else {
+			stats.Blocks++
+			fmt.Printf("Block %d %x found by %s\n", block.Height, block.Hash, worker)
+			go s.refreshJob(true)
+		}
+	}
+
+	return true, nil
+}
+
+// Stats returns a copy of the per-worker share accounting.
+func (s *Server) Stats() map[string]WorkerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make(map[string]WorkerStats)
+	for worker, st := range s.stats {
+		stats[worker] = *st
+	}
+
+	return stats
+}
+
+// PendingPayouts is what the next block would pay per address.
+func (s *Server) PendingPayouts() map[string]int {
+	return s.window.Payouts(blockchain.BlockReward, s.FeePercent, s.PoolAddress)
+}
+
+func (sess *session) reply(id, result, stratumErr interface{}) {
+	sess.write(Response{ID: id, Result: result, Error: stratumErr})
+}
+
+func (sess *session) notify(method string, params []interface{}) {
+	sess.write(Notification{ID: nil, Method: method, Params: params})
+}
+
+// write sends a message within writeTimeout. A miner that doesn't take it
+// is disconnected, which ends handleConn and drops the session.
+func (sess *session) write(message interface{}) {
+	sess.encMu.Lock()
+	defer sess.encMu.Unlock()
+
+	sess.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := sess.enc.Encode(message); err != nil {
+		sess.conn.Close()
+	}
+}
+
+func validAddress(address string) (valid bool) {
+	defer func() {
+		if recover() != nil {
+			valid = false
+		}
+	}()
+
+	return wallet.ValidateAddress(address)
+}
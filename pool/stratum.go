@@ -0,0 +1,103 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Stratum v1 speaks newline delimited JSON-RPC over TCP. Requests and
+// responses carry an id, server notifications have a null id.
+type Request struct {
+	ID     interface{}       `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type Response struct {
+	ID     interface{} `json:"id"`
+	Result interface{} `json:"result"`
+	Error  interface{} `json:"error"`
+}
+
+type Notification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+const (
+	MethodSubscribe     = "mining.subscribe"
+	MethodAuthorize     = "mining.authorize"
+	MethodSubmit        = "mining.submit"
+	MethodNotify        = "mining.notify"
+	MethodSetDifficulty = "mining.set_difficulty"
+)
+
+// Stratum error codes as used by most pools.
+const (
+	ErrOther          = 20
+	ErrJobNotFound    = 21
+	ErrDuplicateShare = 22
+	ErrLowDifficulty  = 23
+	ErrUnauthorized   = 24
+	ErrNotSubscribed  = 25
+)
+
+func stratumError(code int, message string) []interface{} {
+	return []interface{}{code, message, nil}
+}
+
+// Job is the payload of a mining.notify. Our header has no merkle branch to
+// rebuild, the miner hashes prevHash, txRoot, nonce and difficulty plus the
+// job time and its connection's extra nonce.
+type Job struct {
+	ID         string
+	PrevHash   string
+	TxRoot     string
+	Difficulty int
+	Time       int64
+	Clean      bool
+}
+
+func (j Job) Params() []interface{} {
+	return []interface{}{j.ID, j.PrevHash, j.TxRoot, j.Difficulty, strconv.FormatInt(j.Time, 16), j.Clean}
+}
+
+func ParseJob(params []json.RawMessage) (Job, error) {
+	var job Job
+	var ntime string
+
+	if len(params) < 6 {
+		return job, fmt.Errorf("mining.notify needs 6 params, got %d", len(params))
+	}
+	targets := []interface{}{&job.ID, &job.PrevHash, &job.TxRoot, &job.Difficulty, &ntime, &job.Clean}
+	for i, target := range targets {
+		if err := json.Unmarshal(params[i], target); err != nil {
+			return job, fmt.Errorf("bad mining.notify param %d: %w", i, err)
+		}
+	}
+
+	t, err := strconv.ParseInt(ntime, 16, 64)
+	if err != nil {
+		return job, fmt.Errorf("bad ntime: %w", err)
+	}
+	job.Time = t
+
+	return job, nil
+}
+
+func stringParams(params []json.RawMessage, n int) ([]string, error) {
+	if len(params) < n {
+		return nil, fmt.Errorf("expected %d params, got %d", n, len(params))
+	}
+
+	values := make([]string, n)
+	for i := 0; i < n; i++ {
+		if err := json.Unmarshal(params[i], &values[i]); err != nil {
+			return nil, fmt.Errorf("param %d: %w", i, err)
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,118 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// BIP39 mnemonics encode 128 to 256 bits of entropy plus a checksum of
+// entropy/32 bits as words of 11 bits each. The seed is PBKDF2 of the
+// sentence with the passphrase as salt, so the same words restore the same
+// keys in any BIP39 wallet.
+
+//go:embed bip39_english.txt
+var bip39English string
+
+var (
+	bip39Words = strings.Fields(bip39English)
+	bip39Index = make(map[string]int)
+)
+
+func init() {
+	for i, word := range bip39Words {
+		bip39Index[word] = i
+	}
+}
+
+// NewMnemonic draws a random mnemonic of words words: 12, 15, 18, 21 or 24.
+func NewMnemonic(words int) (string, error) {
+	if words < 12 || words > 24 || words%3 != 0 {
+		return "", errors.New("a mnemonic has 12, 15, 18, 21 or 24 words")
+	}
+
+	entropy := make([]byte, words/3*4)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return EntropyMnemonic(entropy)
+}
+
+// EntropyMnemonic encodes entropy of 16 to 32 bytes, a multiple of 4.
+func EntropyMnemonic(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", errors.New("entropy must be 16 to 32 bytes, a multiple of 4")
+	}
+
+	checksumBits := len(entropy) / 4
+	hash := sha256.Sum256(entropy)
+	bits := new(big.Int).SetBytes(entropy)
+	bits.Lsh(bits, uint(checksumBits))
+	bits.Or(bits, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	count := (len(entropy)*8 + checksumBits) / 11
+	words := make([]string, count)
+	mask := big.NewInt(2047)
+	for i := count - 1; i >= 0; i-- {
+		words[i] = bip39Words[new(big.Int).And(bits, mask).Int64()]
+		bits.Rsh(bits, 11)
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicEntropy checks the words and checksum of a mnemonic and returns
+// its entropy.
+func MnemonicEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, errors.New("a mnemonic has 12, 15, 18, 21 or 24 words")
+	}
+
+	bits := new(big.Int)
+	for _, word := range words {
+		i, ok := bip39Index[strings.ToLower(word)]
+		if !ok {
+			return nil, fmt.Errorf("%q is not a BIP39 word", word)
+		}
+		bits.Lsh(bits, 11)
+		bits.Or(bits, big.NewInt(int64(i)))
+	}
+
+	checksumBits := len(words) / 3
+	checksum := new(big.Int).And(bits, big.NewInt(1<<checksumBits-1)).Int64()
+	bits.Rsh(bits, uint(checksumBits))
+
+	entropy := make([]byte, len(words)/3*4)
+	bits.FillBytes(entropy)
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-checksumBits)) != checksum {
+		return nil, errors.New("the mnemonic checksum doesn't match, check the words")
+	}
+
+	return entropy, nil
+}
+
+// MnemonicSeed checks a mnemonic and derives its 64 byte seed. BIP39 NFKD
+// normalizes the sentence and passphrase; the word list is plain ASCII and
+// passphrases must be too, so there is nothing to normalize.
+func MnemonicSeed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := MnemonicEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+	for _, r := range passphrase {
+		if r > 127 {
+			return nil, errors.New("the passphrase must be ASCII")
+		}
+	}
+
+	sentence := strings.ToLower(strings.Join(strings.Fields(mnemonic), " "))
+	return pbkdf2.Key([]byte(sentence), []byte("mnemonic"+passphrase), 2048, 64, sha512.New), nil
+}
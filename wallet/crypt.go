@@ -0,0 +1,391 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// An encrypted wallet file is the gob of the wallets sealed with AES-256-GCM
+// under a key derived from the passphrase with scrypt:
+//
+//	magic | scrypt N, r, p as uint32 | salt | nonce | ciphertext
+//
+// with everything before the nonce authenticated as additional data. The
+// key, never the passphrase, is kept in memory while the wallet is unlocked,
+// until a timeout or Lock. Files without the magic are plain gob, as written
+// before encryption existed, and load without a passphrase.
+
+var (
+	walletMagic = []byte("BCWLTv1\x00")
+
+	// ErrLocked is returned when an encrypted wallet file is needed while
+	// it is locked.
+	ErrLocked = errors.New("the wallet is locked, unlock it first")
+	// ErrPassphrase is returned for a wrong passphrase.
+	ErrPassphrase = errors.New("wrong wallet passphrase")
+
+	// PassphrasePrompt, if set, is asked for the passphrase when a locked
+	// wallet file is loaded, and the wallet stays unlocked for the rest of
+	// the process. The CLI sets it, the API server unlocks explicitly.
+	PassphrasePrompt func() (string, error)
+
+	promptMu   sync.Mutex
+	unlockedMu sync.Mutex
+	unlocked   = make(map[string]*unlockedKey)
+)
+
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	walletSaltLength   = 16
+	walletHeaderLength = 8 + 3*4 + walletSaltLength
+)
+
+type walletCipher struct {
+	N, R, P uint32
+	Salt    []byte
+}
+
+type unlockedKey struct {
+	cipher walletCipher
+	key    []byte
+	until  time.Time
+	timer  *time.Timer
+}
+
+func (c walletCipher) header() []byte {
+	header := append([]byte{}, walletMagic...)
+	header = binary.BigEndian.AppendUint32(header, c.N)
+	header = binary.BigEndian.AppendUint32(header, c.R)
+	header = binary.BigEndian.AppendUint32(header, c.P)
+	return append(header, c.Salt...)
+}
+
+func (c walletCipher) deriveKey(passphrase string) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), c.Salt, int(c.N), int(c.R), int(c.P), 32)
+}
+
+func newWalletCipher() walletCipher {
+	salt := make([]byte, walletSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		log.Panic(err)
+	}
+	return walletCipher{N: scryptN, R: scryptR, P: scryptP, Salt: salt}
+}
+
+func isEncrypted(content []byte) bool { return bytes.HasPrefix(content, walletMagic) }
+
+func parseWalletCipher(content []byte) (walletCipher, error) {
+	if len(content) < walletHeaderLength {
+		return walletCipher{}, errors.New("truncated wallet file")
+	}
+	c := walletCipher{
+		N:    binary.BigEndian.Uint32(content[8:]),
+		R:    binary.BigEndian.Uint32(content[12:]),
+		P:    binary.BigEndian.Uint32(content[16:]),
+		Salt: content[20:walletHeaderLength],
+	}
+	// Bound the work a tampered header can ask for
+	if c.N > 1<<20 || c.R > 32 || c.P > 16 {
+		return walletCipher{}, errors.New("the wallet file asks for unreasonable scrypt parameters")
+	}
+	return c, nil
+}
+
+func aead(key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		log.Panic(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		log.Panic(err)
+	}
+	return gcm
+}
+
+func seal(c walletCipher, key, plain []byte) []byte {
+	gcm := aead(key)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		log.Panic(err)
+	}
+
+	header := c.header()
+	sealed := append(append(header, nonce...), gcm.Seal(nil, nonce, plain, header)...)
+	return sealed
+}
+
+func unseal(key, content []byte) ([]byte, error) {
+	gcm := aead(key)
+	if len(content) < walletHeaderLength+gcm.NonceSize() {
+		return nil, errors.New("truncated wallet file")
+	}
+
+	header := content[:walletHeaderLength]
+	nonce := content[walletHeaderLength : walletHeaderLength+gcm.NonceSize()]
+	plain, err := gcm.Open(nil, nonce, content[walletHeaderLength+gcm.NonceSize():], header)
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+	return plain, nil
+}
+
+// unlockedFor is the key of nodeId's wallet if it is unlocked for the
+// cipher of the file.
+func unlockedFor(nodeId string, c walletCipher) []byte {
+	unlockedMu.Lock()
+	defer unlockedMu.Unlock()
+
+	u, ok := unlocked[nodeId]
+	if !ok || !bytes.Equal(u.cipher.Salt, c.Salt) {
+		return nil
+	}
+	// A copy, as lock wipes the key
+	return append([]byte{}, u.key...)
+}
+
+func setUnlocked(nodeId string, c walletCipher, key []byte, timeout time.Duration) {
+	unlockedMu.Lock()
+	defer unlockedMu.Unlock()
+
+	lock(nodeId)
+	u := &unlockedKey{cipher: c, key: key}
+	if timeout > 0 {
+		u.lockAt(nodeId, time.Now().Add(timeout))
+	}
+	unlocked[nodeId] = u
+}
+
+// lockAt sets the time u locks. Callers hold unlockedMu.
+func (u *unlockedKey) lockAt(nodeId string, until time.Time) {
+	if u.timer != nil {
+		u.timer.Stop()
+	}
+	u.until = until
+	u.timer = time.AfterFunc(time.Until(until), func() {
+		unlockedMu.Lock()
+		defer unlockedMu.Unlock()
+		if unlocked[nodeId] == u {
+			lock(nodeId)
+		}
+	})
+}
+
+func lock(nodeId string) {
+	if u, ok := unlocked[nodeId]; ok {
+		if u.timer != nil {
+			u.timer.Stop()
+		}
+		for i := range u.key {
+			u.key[i] = 0
+		}
+		delete(unlocked, nodeId)
+	}
+}
+
+// Lock forgets the key of nodeId's wallet file.
+func Lock(nodeId string) {
+	unlockedMu.Lock()
+	defer unlockedMu.Unlock()
+
+	lock(nodeId)
+}
+
+// readWalletFile returns the gob of nodeId's wallet file, decrypted.
+func readWalletFile(nodeId string) ([]byte, error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf(walletFile, nodeId))
+	if err != nil || !isEncrypted(content) {
+		return content, err
+	}
+
+	c, err := parseWalletCipher(content)
+	if err != nil {
+		return nil, err
+	}
+	key := unlockedFor(nodeId, c)
+	if key == nil && PassphrasePrompt != nil {
+		if key, err = prompt(nodeId, c); err != nil {
+			return nil, err
+		}
+	}
+	if key == nil {
+		return nil, ErrLocked
+	}
+
+	return unseal(key, content)
+}
+
+// prompt asks PassphrasePrompt, up to three times, once for all the
+// callers waiting on the same file.
+func prompt(nodeId string, c walletCipher) ([]byte, error) {
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	if key := unlockedFor(nodeId, c); key != nil {
+		return key, nil
+	}
+	for tries := 1; ; tries++ {
+		passphrase, err := PassphrasePrompt()
+		if err != nil {
+			return nil, err
+		}
+		err = Unlock(nodeId, passphrase, 0)
+		if err == nil {
+			return unlockedFor(nodeId, c), nil
+		}
+		if !errors.Is(err, ErrPassphrase) || tries == 3 {
+			return nil, err
+		}
+	}
+}
+
+// writeWalletFile writes the gob of the wallets, encrypted when the file is.
+func writeWalletFile(nodeId string, plain []byte) error {
+	path := fmt.Sprintf(walletFile, nodeId)
+
+	content, err := ioutil.ReadFile(path)
+	if err == nil && isEncrypted(content) {
+		c, err := parseWalletCipher(content)
+		if err != nil {
+			return err
+		}
+		key := unlockedFor(nodeId, c)
+		if key == nil {
+			return ErrLocked
+		}
+		plain = seal(c, key, plain)
+	}
+
+	return writeFileAtomic(path, plain)
+}
+
+// writeFileAtomic replaces a file, readable by its owner only, through a
+// temporary file so a crash never leaves half a wallet.
+func writeFileAtomic(path string, content []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
+
+// Unlock checks the passphrase of nodeId's encrypted wallet file and keeps
+// its key for timeout, or until Lock if timeout is 0.
+func Unlock(nodeId, passphrase string, timeout time.Duration) error {
+	content, err := ioutil.ReadFile(fmt.Sprintf(walletFile, nodeId))
+	if err != nil {
+		return err
+	}
+	if !isEncrypted(content) {
+		return errors.New("the wallet file is not encrypted")
+	}
+
+	c, err := parseWalletCipher(content)
+	if err != nil {
+		return err
+	}
+	key, err := c.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+	if _, err := unseal(key, content); err != nil {
+		return err
+	}
+
+	setUnlocked(nodeId, c, key, timeout)
+	return nil
+}
+
+// WalletStatus reports whether nodeId's wallet file is encrypted, whether it
+// is locked, and when it locks again, zero for no timeout.
+func WalletStatus(nodeId string) (encrypted, locked bool, until time.Time) {
+	content, err := ioutil.ReadFile(fmt.Sprintf(walletFile, nodeId))
+	if err != nil || !isEncrypted(content) {
+		return false, false, time.Time{}
+	}
+	c, err := parseWalletCipher(content)
+	if err != nil {
+		return true, true, time.Time{}
+	}
+
+	unlockedMu.Lock()
+	defer unlockedMu.Unlock()
+	u, ok := unlocked[nodeId]
+	if !ok || !bytes.Equal(u.cipher.Salt, c.Salt) {
+		return true, true, time.Time{}
+	}
+	return true, false, u.until
+}
+
+// encryptWith rewrites nodeId's wallet file, in plain gob, under a new
+// passphrase and leaves it unlocked for the process.
+func encryptWith(nodeId string, plain []byte, passphrase string) error {
+	if passphrase == "" {
+		return errors.New("the passphrase can't be empty")
+	}
+
+	c := newWalletCipher()
+	key, err := c.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(fmt.Sprintf(walletFile, nodeId), seal(c, key, plain)); err != nil {
+		return err
+	}
+
+	setUnlocked(nodeId, c, key, 0)
+	return nil
+}
+
+// EncryptWallet encrypts nodeId's plain wallet file with a passphrase.
+func EncryptWallet(nodeId, passphrase string) error {
+	content, err := ioutil.ReadFile(fmt.Sprintf(walletFile, nodeId))
+	if err != nil {
+		return err
+	}
+	if isEncrypted(content) {
+		return errors.New("the wallet file is already encrypted, use changepassphrase")
+	}
+	return encryptWith(nodeId, content, passphrase)
+}
+
+// ChangePassphrase re-encrypts nodeId's wallet file under a new passphrase,
+// with a new salt. The file stays locked if it was, or unlocked until the
+// time it was going to lock.
+func ChangePassphrase(nodeId, oldPassphrase, newPassphrase string) error {
+	_, locked, until := WalletStatus(nodeId)
+	if err := Unlock(nodeId, oldPassphrase, 0); err != nil {
+		return err
+	}
+	plain, err := readWalletFile(nodeId)
+	if err == nil {
+		err = encryptWith(nodeId, plain, newPassphrase)
+	}
+
+	unlockedMu.Lock()
+	defer unlockedMu.Unlock()
+	if u, ok := unlocked[nodeId]; ok && !locked && !until.IsZero() {
+		u.lockAt(nodeId, until)
+	} else if locked {
+		lock(nodeId)
+	}
+	return err
+}
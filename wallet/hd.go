@@ -0,0 +1,184 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Hierarchical deterministic keys follow SLIP-0010, BIP32 generalised to
+// other curves: P-256 keys derive like BIP32 secp256k1 keys, normal or
+// hardened, while ed25519 keys only have hardened children. Addresses sit
+// on BIP44 paths m/44'/1'/account'/change/index, change 0 for receiving
+// and 1 for change, with every level hardened for ed25519.
+
+const (
+	// Hardened is added to an index to derive a hardened child
+	Hardened = uint32(0x80000000)
+
+	HDPurpose = 44
+	// HDCoinType is the SLIP-0044 "testnet" coin type shared by all coins
+	// without a registered type
+	HDCoinType = 1
+	// HDGapLimit is the number of unused addresses in a row after which
+	// discovery stops scanning a chain, as in BIP44
+	HDGapLimit = 20
+)
+
+// HDKey is an extended private key, a key and its chain code.
+type HDKey struct {
+	Type      KeyType
+	Key       []byte
+	ChainCode []byte
+}
+
+func hdCurveSeed(kt KeyType) ([]byte, error) {
+	switch kt {
+	case ECDSAP256:
+		return []byte("Nist256p1 seed"), nil
+	case Ed25519:
+		return []byte("ed25519 seed"), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", kt)
+	}
+}
+
+func hmacSHA512(key []byte, data ...[]byte) ([]byte, []byte) {
+	mac := hmac.New(sha512.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
+
+// NewMasterKey derives the master key of a seed.
+func NewMasterKey(seed []byte, kt KeyType) (HDKey, error) {
+	curveSeed, err := hdCurveSeed(kt)
+	if err != nil {
+		return HDKey{}, err
+	}
+
+	key, chainCode := hmacSHA512(curveSeed, seed)
+	// A P-256 key must be in [1, n), otherwise hash again
+	for kt == ECDSAP256 && !validP256Key(new(big.Int).SetBytes(key)) {
+		key, chainCode = hmacSHA512(curveSeed, key, chainCode)
+	}
+	return HDKey{Type: kt, Key: key, ChainCode: chainCode}, nil
+}
+
+func validP256Key(k *big.Int) bool {
+	return k.Sign() > 0 && k.Cmp(elliptic.P256().Params().N) < 0
+}
+
+// Child derives child index of the key, hardened when index has the
+// Hardened bit.
+func (k HDKey) Child(index uint32) (HDKey, error) {
+	hardened := index >= Hardened
+	if k.Type == Ed25519 && !hardened {
+		return HDKey{}, errors.New("ed25519 keys only have hardened children")
+	}
+
+	var data []byte
+	if hardened {
+		data = append([]byte{0}, k.Key...)
+	} else {
+		curve := elliptic.P256()
+		x, y := curve.ScalarBaseMult(k.Key)
+		data = elliptic.MarshalCompressed(curve, x, y)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	il, ir := hmacSHA512(k.ChainCode, data)
+	if k.Type == Ed25519 {
+		return HDKey{Type: k.Type, Key: il, ChainCode: ir}, nil
+	}
+
+	n := elliptic.P256().Params().N
+	for {
+		child := new(big.Int).SetBytes(il)
+		if child.Cmp(n) < 0 {
+			child.Add(child, new(big.Int).SetBytes(k.Key))
+			child.Mod(child, n)
+			if child.Sign() != 0 {
+				return HDKey{Type: k.Type, Key: child.FillBytes(make([]byte, 32)), ChainCode: ir}, nil
+			}
+		}
+		// An invalid child, with odds of about 2^-32, moves on to the
+		// next hash as SLIP-0010 says
+		il, ir = hmacSHA512(k.ChainCode, []byte{1}, ir, binary.BigEndian.AppendUint32(nil, index))
+	}
+}
+
+// Derive follows a path of child indexes from the key.
+func (k HDKey) Derive(path []uint32) (HDKey, error) {
+	for _, index := range path {
+		var err error
+		if k, err = k.Child(index); err != nil {
+			return HDKey{}, err
+		}
+	}
+	return k, nil
+}
+
+// Wallet is the wallet of the key pair.
+func (k HDKey) Wallet() *Wallet {
+	private := PrivateKeyData{Type: k.Type, D: k.Key}
+	return &Wallet{private, private.PublicKey()}
+}
+
+// ParsePath reads a path like m/44'/1'/0'/0/5, with ' or h marking hardened
+// indexes.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("path %q must start with m", path)
+	}
+
+	var indexes []uint32
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		i, err := strconv.ParseUint(part, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("bad index %q in path %q", part, path)
+		}
+		index := uint32(i)
+		if hardened {
+			index += Hardened
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// FormatPath writes a path the way ParsePath reads it.
+func FormatPath(path []uint32) string {
+	s := "m"
+	for _, index := range path {
+		if index >= Hardened {
+			s += fmt.Sprintf("/%d'", index-Hardened)
+		} else {
+			s += fmt.Sprintf("/%d", index)
+		}
+	}
+	return s
+}
+
+// HDPath is the BIP44 path of an address of the key type.
+func HDPath(kt KeyType, account, change, index uint32) []uint32 {
+	path := []uint32{HDPurpose + Hardened, HDCoinType + Hardened, account + Hardened, change, index}
+	if kt == Ed25519 {
+		path[3] += Hardened
+		path[4] += Hardened
+	}
+	return path
+}
@@ -0,0 +1,219 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// HDKeychain is the seed the HD addresses of a wallet file derive from.
+// The mnemonic is kept so it can be shown again for a backup.
+type HDKeychain struct {
+	Mnemonic string
+	Seed     []byte
+	Type     KeyType
+	// Paths maps the derived addresses to their paths
+	Paths map[string]string
+	// Next is the next unused index of each "account/change" chain
+	Next map[string]uint32
+}
+
+// HDAddress is an address of the keychain and where it derives from.
+type HDAddress struct {
+	Address string `json:"address"`
+	Path    string `json:"path"`
+	Account uint32 `json:"account"`
+	Change  uint32 `json:"change"`
+	Index   uint32 `json:"index"`
+}
+
+func hdChain(account, change uint32) string { return fmt.Sprintf("%d/%d", account, change) }
+
+// derive makes the wallet of an address of the keychain.
+func (hd *HDKeychain) derive(account, change, index uint32) (*Wallet, HDAddress, error) {
+	master, err := NewMasterKey(hd.Seed, hd.Type)
+	if err != nil {
+		return nil, HDAddress{}, err
+	}
+	path := HDPath(hd.Type, account, change, index)
+	key, err := master.Derive(path)
+	if err != nil {
+		return nil, HDAddress{}, err
+	}
+
+	w := key.Wallet()
+	return w, HDAddress{Address: string(w.Address()), Path: FormatPath(path), Account: account, Change: change, Index: index}, nil
+}
+
+// setHD starts a keychain on the seed of mnemonic.
+func (ws *Wallets) setHD(mnemonic, passphrase string, kt KeyType) error {
+	if ws.HD != nil {
+		return errors.New("the wallet file already has an HD keychain")
+	}
+	seed, err := MnemonicSeed(mnemonic, passphrase)
+	if err != nil {
+		return err
+	}
+	if _, err := hdCurveSeed(kt); err != nil {
+		return err
+	}
+
+	ws.HD = &HDKeychain{
+		Mnemonic: mnemonic,
+		Seed:     seed,
+		Type:     kt,
+		Paths:    make(map[string]string),
+		Next:     make(map[string]uint32),
+	}
+	return nil
+}
+
+// CreateHD starts an HD keychain on a new mnemonic of words words and
+// returns the mnemonic. The passphrase, which may be empty, is needed
+// along with the words to restore the keys.
+func (ws *Wallets) CreateHD(words int, passphrase string, kt KeyType) (string, error) {
+	mnemonic, err := NewMnemonic(words)
+	if err != nil {
+		return "", err
+	}
+	return mnemonic, ws.setHD(mnemonic, passphrase, kt)
+}
+
+// RestoreHD starts an HD keychain on an existing mnemonic. DiscoverHD then
+// finds the addresses in use.
+func (ws *Wallets) RestoreHD(mnemonic, passphrase string, kt KeyType) error {
+	return ws.setHD(mnemonic, passphrase, kt)
+}
+
+// addHD puts a derived wallet in the file and moves its chain past it.
+func (ws *Wallets) addHD(w *Wallet, addr HDAddress) {
+	ws.Wallets[addr.Address] = w
+	ws.HD.Paths[addr.Address] = addr.Path
+	if chain := hdChain(addr.Account, addr.Change); ws.HD.Next[chain] <= addr.Index {
+		ws.HD.Next[chain] = addr.Index + 1
+	}
+}
+
+// NewHDAddress derives the next address of an account, on the change chain
+// when change is set.
+func (ws *Wallets) NewHDAddress(account uint32, change bool) (HDAddress, error) {
+	if ws.HD == nil {
+		return HDAddress{}, errors.New("no HD keychain, run createhd or restorehd first")
+	}
+
+	c := uint32(0)
+	if change {
+		c = 1
+	}
+	w, addr, err := ws.HD.derive(account, c, ws.HD.Next[hdChain(account, c)])
+	if err != nil {
+		return HDAddress{}, err
+	}
+	ws.addHD(w, addr)
+	return addr, nil
+}
+
+// HDAddresses lists the derived addresses by account, chain and index.
+func (ws *Wallets) HDAddresses() []HDAddress {
+	if ws.HD == nil {
+		return nil
+	}
+
+	var addresses []HDAddress
+	for address, path := range ws.HD.Paths {
+		indexes, err := ParsePath(path)
+		if err != nil || len(indexes) != 5 {
+			continue
+		}
+		addresses = append(addresses, HDAddress{
+			Address: address,
+			Path:    path,
+			Account: indexes[2] - Hardened,
+			Change:  indexes[3] &^ Hardened,
+			Index:   indexes[4] &^ Hardened,
+		})
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		a, b := addresses[i], addresses[j]
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+		if a.Change != b.Change {
+			return a.Change < b.Change
+		}
+		return a.Index < b.Index
+	})
+	return addresses
+}
+
+// DiscoverHD scans the keychain for addresses used on chain, the way BIP44
+// discovers accounts: each chain of an account is scanned until gap unused
+// addresses in a row, and the scan stops at the first account without any
+// used address. used reports whether a public key hash has any history.
+// The addresses found are added to the file and returned.
+func (ws *Wallets) DiscoverHD(used func(pubKeyHash []byte) bool, gap int) ([]HDAddress, error) {
+	if ws.HD == nil {
+		return nil, errors.New("no HD keychain, run createhd or restorehd first")
+	}
+	if gap < 1 {
+		gap = HDGapLimit
+	}
+
+	var found []HDAddress
+	for account := uint32(0); ; account++ {
+		accountUsed := false
+		for change := uint32(0); change <= 1; change++ {
+			for index, unused := uint32(0), 0; unused < gap; index++ {
+				w, addr, err := ws.HD.derive(account, change, index)
+				if err != nil {
+					return found, err
+				}
+				if !used(PublicKeyHash(w.PublicKey)) {
+					unused++
+					continue
+				}
+				unused = 0
+				accountUsed = true
+				ws.addHD(w, addr)
+				found = append(found, addr)
+			}
+		}
+		if !accountUsed {
+			return found, nil
+		}
+	}
+}
+
+// FindKey returns the wallet of the file whose key hashes to pubKeyHash.
+// When the file doesn't have it but path is a path of the keychain that
+// derives it, the key is derived, so a keychain restored on a machine that
+// never scanned the chain can still sign.
+func (ws *Wallets) FindKey(pubKeyHash []byte, path string) (*Wallet, bool) {
+	for _, w := range ws.Wallets {
+		if bytes.Equal(PublicKeyHash(w.PublicKey), pubKeyHash) {
+			return w, true
+		}
+	}
+	if ws.HD == nil || path == "" {
+		return nil, false
+	}
+
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, false
+	}
+	master, err := NewMasterKey(ws.HD.Seed, ws.HD.Type)
+	if err != nil {
+		return nil, false
+	}
+	key, err := master.Derive(indexes)
+	if err != nil {
+		return nil, false
+	}
+	w := key.Wallet()
+	if !bytes.Equal(PublicKeyHash(w.PublicKey), pubKeyHash) {
+		return nil, false
+	}
+	return w, true
+}
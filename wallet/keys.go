@@ -0,0 +1,186 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+)
+
+// KeyType identifies the signature scheme a key pair belongs to. The zero
+// value is P-256 ECDSA so wallets saved before key types existed still load.
+type KeyType byte
+
+const (
+	ECDSAP256 KeyType = iota
+	Ed25519
+)
+
+const (
+	p256CoordLength        = 32
+	p256PublicKeyLength    = 1 + 2*p256CoordLength
+	ed25519PublicKeyLength = 1 + ed25519.PublicKeySize
+
+	ed25519Version = byte(0x01)
+)
+
+func (kt KeyType) String() string {
+	switch kt {
+	case ECDSAP256:
+		return "p256"
+	case Ed25519:
+		return "ed25519"
+	default:
+		return fmt.Sprintf("unknown(%d)", byte(kt))
+	}
+}
+
+func ParseKeyType(name string) (KeyType, error) {
+	switch strings.ToLower(name) {
+	case "", "p256", "ecdsa":
+		return ECDSAP256, nil
+	case "ed25519":
+		return Ed25519, nil
+	default:
+		return 0, fmt.Errorf("unknown key type %q", name)
+	}
+}
+
+// AddressVersion is the version byte used in addresses for this key type.
+func (kt KeyType) AddressVersion() byte {
+	if kt == Ed25519 {
+		return ed25519Version
+	}
+	return version
+}
+
+func KeyTypeFromVersion(v byte) (KeyType, bool) {
+	switch v {
+	case version:
+		return ECDSAP256, true
+	case ed25519Version:
+		return Ed25519, true
+	default:
+		return 0, false
+	}
+}
+
+// EncodePublicKey prefixes the key material with its type. P-256 points are
+// written as fixed width X‖Y so coordinates with leading zeros survive.
+func EncodePublicKey(kt KeyType, key []byte) []byte {
+	return append([]byte{byte(kt)}, key...)
+}
+
+// DecodePublicKey splits an encoded public key into its type and material.
+// Keys created before key types existed are raw, variable width X‖Y and are
+// reported as P-256.
+func DecodePublicKey(pubKey []byte) (KeyType, []byte) {
+	switch {
+	case len(pubKey) == p256PublicKeyLength && pubKey[0] == byte(ECDSAP256):
+		return ECDSAP256, pubKey[1:]
+	case len(pubKey) == ed25519PublicKeyLength && pubKey[0] == byte(Ed25519):
+		return Ed25519, pubKey[1:]
+	default:
+		return ECDSAP256, pubKey
+	}
+}
+
+func NewTypedKeyPair(kt KeyType) (PrivateKeyData, []byte) {
+	switch kt {
+	case Ed25519:
+		pub, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Panic(err)
+		}
+		return PrivateKeyData{Type: Ed25519, D: private.Seed()}, EncodePublicKey(Ed25519, pub)
+	case ECDSAP256:
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			log.Panic(err)
+		}
+		pkd := PrivateKeyData{Type: ECDSAP256, D: private.D.Bytes()}
+		return pkd, pkd.PublicKey()
+	default:
+		log.Panicf("unsupported key type %s", kt)
+		return PrivateKeyData{}, nil
+	}
+}
+
+// PublicKey derives the encoded public key for this private key.
+func (pkd PrivateKeyData) PublicKey() []byte {
+	if pkd.Type == Ed25519 {
+		pub := ed25519.NewKeyFromSeed(pkd.D).Public().(ed25519.PublicKey)
+		return EncodePublicKey(Ed25519, pub)
+	}
+
+	private := pkd.ToECDSA()
+	point := make([]byte, 2*p256CoordLength)
+	private.PublicKey.X.FillBytes(point[:p256CoordLength])
+	private.PublicKey.Y.FillBytes(point[p256CoordLength:])
+	return EncodePublicKey(ECDSAP256, point)
+}
+
+// Sign signs a digest with the scheme matching the key type. ECDSA signatures
+// are fixed width r‖s.
+func (pkd PrivateKeyData) Sign(digest []byte) []byte {
+	if pkd.Type == Ed25519 {
+		return ed25519.Sign(ed25519.NewKeyFromSeed(pkd.D), digest)
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, pkd.ToECDSA(), digest)
+	if err != nil {
+		log.Panic(err)
+	}
+	signature := make([]byte, 2*p256CoordLength)
+	r.FillBytes(signature[:p256CoordLength])
+	s.FillBytes(signature[p256CoordLength:])
+
+	return signature
+}
+
+// Owns reports whether pubKey, in either the typed or legacy encoding, is
+// the public half of this key.
+func (pkd PrivateKeyData) Owns(pubKey []byte) bool {
+	kt, key := DecodePublicKey(pubKey)
+	if kt != pkd.Type {
+		return false
+	}
+	_, own := DecodePublicKey(pkd.PublicKey())
+	if kt == Ed25519 {
+		return bytes.Equal(key, own)
+	}
+
+	x, y := splitHalves(key)
+	ownX, ownY := splitHalves(own)
+	return x.Cmp(ownX) == 0 && y.Cmp(ownY) == 0
+}
+
+// VerifySignature dispatches on the key type encoded in pubKey.
+func VerifySignature(pubKey, digest, signature []byte) bool {
+	kt, key := DecodePublicKey(pubKey)
+
+	switch kt {
+	case Ed25519:
+		return len(signature) == ed25519.SignatureSize && ed25519.Verify(ed25519.PublicKey(key), digest, signature)
+	case ECDSAP256:
+		if len(key) == 0 || len(signature) == 0 {
+			return false
+		}
+		r, s := splitHalves(signature)
+		x, y := splitHalves(key)
+		rawPubKey := ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		return ecdsa.Verify(&rawPubKey, digest, r, s)
+	default:
+		return false
+	}
+}
+
+func splitHalves(data []byte) (*big.Int, *big.Int) {
+	half := len(data) / 2
+	return new(big.Int).SetBytes(data[:half]), new(big.Int).SetBytes(data[half:])
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
 	"log"
 	"math/big"
@@ -23,13 +22,19 @@ type Wallet struct {
 }
 
 type PrivateKeyData struct {
-	D []byte
+	Type KeyType
+	D    []byte
+}
+
+func (w Wallet) KeyType() KeyType {
+	kt, _ := DecodePublicKey(w.PublicKey)
+	return kt
 }
 
 func (w Wallet) Address() []byte {
 	pubHash := PublicKeyHash(w.PublicKey)
 
-	versionedHash := append([]byte{version}, pubHash...)
+	versionedHash := append([]byte{w.KeyType().AddressVersion()}, pubHash...)
 	checksum := Checksum(versionedHash)
 
 	fullHash := append(versionedHash, checksum...)
@@ -40,6 +45,12 @@ func (w Wallet) Address() []byte {
 
 func ValidateAddress(address string) bool {
 	pubKeyHash := Base58Decode([]byte(address))
+	if len(pubKeyHash) <= 1+checksumLength {
+		return false
+	}
+	if _, ok := KeyTypeFromVersion(pubKeyHash[0]); !ok {
+		return false
+	}
 	actualChecksum := pubKeyHash[len(pubKeyHash)-checksumLength:]
 	version := pubKeyHash[0]
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-checksumLength]
@@ -48,20 +59,25 @@ func ValidateAddress(address string) bool {
 	return bytes.Equal(actualChecksum, targetChecksum)
 }
 
-func NewKeyPair() (PrivateKeyData, []byte) {
-	curve := elliptic.P256()
-
-	private, err := ecdsa.GenerateKey(curve, rand.Reader)
-	if err != nil {
-		log.Panic(err)
+// AddressKeyType reports the key type encoded in an address's version byte.
+func AddressKeyType(address string) (KeyType, bool) {
+	decoded := Base58Decode([]byte(address))
+	if len(decoded) == 0 {
+		return 0, false
 	}
+	return KeyTypeFromVersion(decoded[0])
+}
 
-	pub := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
-	return PrivateKeyData{private.D.Bytes()}, pub
+func NewKeyPair() (PrivateKeyData, []byte) {
+	return NewTypedKeyPair(ECDSAP256)
 }
 
 func MakeWallet() *Wallet {
-	private, public := NewKeyPair()
+	return MakeWalletWithType(ECDSAP256)
+}
+
+func MakeWalletWithType(kt KeyType) *Wallet {
+	private, public := NewTypedKeyPair(kt)
 	wallet := Wallet{private, public}
 
 	return &wallet
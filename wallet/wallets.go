@@ -4,16 +4,31 @@ import (
 	"bytes"
 	"crypto/elliptic"
 	"encoding/gob"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
+	"sync"
 )
 
 const walletFile = "./tmp/wallets_%s.data"
 
 type Wallets struct {
 	Wallets map[string]*Wallet
+	// House is the address of the bankroll games are played against
+	House string
+	// Escrow holds the stakes of games that are still in progress
+	Escrow string
+	// Jackpot holds the progressive jackpot pool
+	Jackpot string
+	// Lottery collects ticket payments until a round is drawn
+	Lottery string
+	// Oracle co-signs the payouts and refunds of wagers between players
+	Oracle string
+	// Tournament collects entry fees until the prizes are paid
+	Tournament string
+	// HD is the keychain HD addresses derive from, nil without one
+	HD *HDKeychain
 }
 
 
@@ -27,7 +42,11 @@ func CreateWallets(nodeId string) (*Wallets, error) {
 }
 
 func (ws *Wallets) AddWallet() string {
-	wallet := MakeWallet()
+	return ws.AddWalletWithType(ECDSAP256)
+}
+
+func (ws *Wallets) AddWalletWithType(kt KeyType) string {
+	wallet := MakeWalletWithType(kt)
 	address := string(wallet.Address())
 
 	ws.Wallets[address] = wallet
@@ -49,6 +68,95 @@ func (ws Wallets) GetWallet(address string) Wallet {
 	return *ws.Wallets[address]
 }
 
+// AddHouseWallet creates the house bankroll wallet, replacing the current
+// house designation. The old house wallet stays in the file.
+func (ws *Wallets) AddHouseWallet(kt KeyType) string {
+	address := ws.AddWalletWithType(kt)
+	ws.House = address
+
+	return address
+}
+
+// HouseWallet returns the house bankroll wallet.
+func (ws Wallets) HouseWallet() (Wallet, error) {
+	if ws.House == "" {
+		return Wallet{}, errors.New("no house wallet, run createhouse first")
+	}
+	w, ok := ws.Wallets[ws.House]
+	if !ok {
+		return Wallet{}, fmt.Errorf("house wallet %s is not in the wallet file", ws.House)
+	}
+
+	return *w, nil
+}
+
+// roleWalletMu keeps two callers from creating the same role wallet.
+var roleWalletMu sync.Mutex
+
+// roles are the addresses of the wallets the node keeps for its own use.
+func (ws *Wallets) roles() []*string {
+	return []*string{&ws.House, &ws.Escrow, &ws.Jackpot, &ws.Lottery, &ws.Oracle, &ws.Tournament}
+}
+
+// roleWallet returns the wallet of role, one of the fields of ws, creating
+// and saving it the first time it is needed. The file is read again under
+// the lock since another caller may have created it after ws was loaded,
+// and the wallets saved meanwhile are kept.
+func (ws *Wallets) roleWallet(role *string, nodeId string) Wallet {
+	roleWalletMu.Lock()
+	defer roleWalletMu.Unlock()
+
+	if *role == "" {
+		var file Wallets
+		if err := file.LoadFile(nodeId); err == nil {
+			for address, w := range file.Wallets {
+				if _, ok := ws.Wallets[address]; !ok {
+					ws.Wallets[address] = w
+				}
+			}
+			fileRoles := file.roles()
+			for i, r := range ws.roles() {
+				if *r == "" {
+					*r = *fileRoles[i]
+				}
+			}
+		}
+	}
+	if *role == "" {
+		*role = ws.AddWallet()
+		ws.SaveFile(nodeId)
+	}
+
+	return *ws.Wallets[*role]
+}
+
+// EscrowWallet returns the wallet holding the stakes of games in progress.
+func (ws *Wallets) EscrowWallet(nodeId string) Wallet {
+	return ws.roleWallet(&ws.Escrow, nodeId)
+}
+
+// JackpotWallet returns the wallet holding the progressive jackpot pool.
+func (ws *Wallets) JackpotWallet(nodeId string) Wallet {
+	return ws.roleWallet(&ws.Jackpot, nodeId)
+}
+
+// LotteryWallet returns the wallet collecting lottery ticket payments.
+func (ws *Wallets) LotteryWallet(nodeId string) Wallet {
+	return ws.roleWallet(&ws.Lottery, nodeId)
+}
+
+// OracleWallet returns the wallet co-signing wager payouts and refunds.
+func (ws *Wallets) OracleWallet(nodeId string) Wallet {
+	return ws.roleWallet(&ws.Oracle, nodeId)
+}
+
+// TournamentWallet returns the wallet collecting tournament entry fees.
+func (ws *Wallets) TournamentWallet(nodeId string) Wallet {
+	return ws.roleWallet(&ws.Tournament, nodeId)
+}
+
+// LoadFile reads the wallet file, decrypting it if it is encrypted. A
+// locked file returns ErrLocked.
 func (ws *Wallets) LoadFile(nodeId string) error {
 	walletFile := fmt.Sprintf(walletFile, nodeId)
 	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
@@ -57,7 +165,7 @@ func (ws *Wallets) LoadFile(nodeId string) error {
 
 	var wallets Wallets
 
-	fileContent, err := ioutil.ReadFile(walletFile)
+	fileContent, err := readWalletFile(nodeId)
 	if err != nil {
 		return err
 	}
@@ -69,15 +177,16 @@ func (ws *Wallets) LoadFile(nodeId string) error {
 		return err
 	}
 
-	ws.Wallets = wallets.Wallets
+	*ws = wallets
 
 	return nil
 
 }
 
+// SaveFile writes the wallet file, readable by its owner only and
+// encrypted if it was. It panics while an encrypted file is locked.
 func (ws *Wallets) SaveFile(nodeId string) {
 	var content bytes.Buffer
-	walletFile := fmt.Sprintf(walletFile, nodeId)
 
 	gob.Register(elliptic.P256())
 
@@ -87,7 +196,7 @@ func (ws *Wallets) SaveFile(nodeId string) {
 		log.Panic(err)
 	}
 
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0644)
+	err = writeWalletFile(nodeId, content.Bytes())
 	if err != nil {
 		log.Panic(err)
 	}
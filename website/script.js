@@ -16,6 +16,11 @@ function displayBlockchainBlocks() {
     fetchBlockchainData().then(blockchainData => {
         if (!blockchainData || !blockchainData.blocks) return;
 
+        const jackpotElement = document.getElementById('jackpot-amount');
+        if (jackpotElement) {
+            jackpotElement.textContent = `Jackpot: ${blockchainData.jackpot || 0}`;
+        }
+
         const container = document.getElementById('blockchain-container');
         if (!container) return;
 
@@ -86,6 +91,21 @@ function printBlockchainAPI(gameType, result, data) {
     return { gameType, result, data };
 }
 
+// Provably fair: keep a client seed and bet counter per browser. The nonce
+// only ever goes up so every bet gets a fresh roll.
+function nextFairRoll() {
+    let clientSeed = localStorage.getItem('clientSeed');
+    if (!clientSeed) {
+        const bytes = new Uint8Array(16);
+        crypto.getRandomValues(bytes);
+        clientSeed = Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+        localStorage.setItem('clientSeed', clientSeed);
+    }
+    const nonce = parseInt(localStorage.getItem('fairNonce') || '0');
+    localStorage.setItem('fairNonce', nonce + 1);
+    return { clientSeed, nonce };
+}
+
 function fetchBalance(address) {
     fetch(`http://localhost:6969/balance?address=${address}`)
     .then(response => {
@@ -170,6 +190,9 @@ document.getElementById('create-wallet-btn').onclick = function(e) {
         printBlockchainAPI('WALLET_CREATION', 'SUCCESS', {
             address: data.address,
             message: data.message,
+            serverSeedHash: data.serverSeedHash,
+            clientSeed: data.clientSeed,
+            nonce: data.nonce,
             timestamp: new Date().toISOString()
         });
 
@@ -205,7 +228,7 @@ document.getElementById('coinflip-gamble-btn').onclick = function(e) {
         headers: {
             'Content-Type': 'application/json'
         },
-        body: JSON.stringify({ amount: parseFloat(betAmount), from: address })
+        body: JSON.stringify({ amount: parseFloat(betAmount), from: address, ...nextFairRoll() })
     })
     .then(response => {
         if (!response.ok) {
@@ -235,6 +258,9 @@ document.getElementById('coinflip-gamble-btn').onclick = function(e) {
             address: address,
             amount: parseFloat(betAmount),
             message: data.message,
+            serverSeedHash: data.serverSeedHash,
+            clientSeed: data.clientSeed,
+            nonce: data.nonce,
             timestamp: new Date().toISOString()
         });
 
@@ -270,7 +296,7 @@ document.getElementById('diceroll-gamble-btn').onclick = function(e) {
         headers: {
             'Content-Type': 'application/json'
         },
-        body: JSON.stringify({ amount: parseFloat(betAmount), from: address })
+        body: JSON.stringify({ amount: parseFloat(betAmount), from: address, ...nextFairRoll() })
     })
     .then(response => {
         if (!response.ok) {
@@ -300,6 +326,9 @@ document.getElementById('diceroll-gamble-btn').onclick = function(e) {
             address: address,
             amount: parseFloat(betAmount),
             message: data.message,
+            serverSeedHash: data.serverSeedHash,
+            clientSeed: data.clientSeed,
+            nonce: data.nonce,
             timestamp: new Date().toISOString()
         });
 
@@ -340,7 +369,7 @@ document.getElementById('NumberGuess-gamble-btn').onclick = function(e) {
         headers: {
             'Content-Type': 'application/json'
         },
-        body: JSON.stringify({ amount: parseFloat(betAmount), guess: parseInt(guess), from: address })
+        body: JSON.stringify({ amount: parseFloat(betAmount), guess: parseInt(guess), from: address, ...nextFairRoll() })
     })
     .then(response => {
         if (!response.ok) {
@@ -371,6 +400,9 @@ document.getElementById('NumberGuess-gamble-btn').onclick = function(e) {
             amount: parseFloat(betAmount),
             guess: parseInt(guess),
             message: data.message,
+            serverSeedHash: data.serverSeedHash,
+            clientSeed: data.clientSeed,
+            nonce: data.nonce,
             timestamp: new Date().toISOString()
         });
 
@@ -389,3 +421,80 @@ document.getElementById('NumberGuess-gamble-btn').onclick = function(e) {
 
     return false;
 }
+
+document.getElementById('roulette-gamble-btn').onclick = function(e) {
+    e.preventDefault();
+    e.stopPropagation();
+
+    const bets = document.getElementById('roulette-bets').value.replace(/\s+/g, '');
+    const address = localStorage.getItem('walletAddress') || document.getElementById('create-wallet-address').innerText.replace('Address: ', '');
+
+    // The amount of a spin is the sum of its stakes
+    let betAmount = 0;
+    for (const bet of bets.split(',')) {
+        const stake = parseInt(bet.split('=')[1]);
+        if (isNaN(stake) || stake <= 0) {
+            alert('Please enter bets as TYPE[:TARGET]=STAKE, e.g. straight:17=10,red=5');
+            return false;
+        }
+        betAmount += stake;
+    }
+
+    fetch('http://localhost:6969/games/roulette/play', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ amount: betAmount, from: address, params: { bets: bets }, ...nextFairRoll() })
+    })
+    .then(response => {
+        if (!response.ok) {
+            return response.text().then(text => { throw new Error(text || response.statusText); });
+        }
+        return response.json();
+    })
+    .then(data => {
+        const messageElement = document.getElementById('roulette-message');
+        const messageText = document.getElementById('roulette-message-text');
+        if (messageText) {
+            messageText.textContent = data.message;
+            messageElement.style.display = 'block';
+
+            // Set color based on win/loss
+            if (data.result === 'WIN') {
+                messageElement.className = 'notification is-success';
+            } else if (data.result === 'LOSS') {
+                messageElement.className = 'notification is-danger';
+            } else {
+                messageElement.className = 'notification is-info';
+            }
+        }
+
+        // Auto-run blockchain print API
+        printBlockchainAPI('ROULETTE', data.result, {
+            address: address,
+            amount: betAmount,
+            bets: bets,
+            outcome: data.outcomeText,
+            message: data.message,
+            serverSeedHash: data.serverSeedHash,
+            clientSeed: data.clientSeed,
+            nonce: data.nonce,
+            timestamp: new Date().toISOString()
+        });
+
+        fetchBalance(address);
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        const messageElement = document.getElementById('roulette-message');
+        const messageText = document.getElementById('roulette-message-text');
+        if (messageText) {
+            messageText.textContent = 'Error: ' + error.message;
+            messageElement.style.display = 'block';
+            messageElement.className = 'notification is-danger';
+        }
+    });
+
+    return false;
+}
@@ -0,0 +1,548 @@
+// Client-side signing: the node builds an unsigned transaction with
+// POST /tx/build, it is checked and signed here with a key that never
+// leaves the browser, and the signatures are handed back to POST /tx/submit,
+// which puts them in the transaction.
+//
+// Nothing is signed on the node's word. The raw transaction has to be the
+// gob encoding of the transaction the node spelled out and checked below,
+// and the ID and the digest of every input are recomputed here from it and
+// the outputs it spends; the node's digests only have to agree. Gob puts
+// the definitions of the types in front of each value, numbered by the
+// node's process, so they are taken from the raw transaction after checking
+// they describe Transaction, TxInput and TxOutput. The values of the outputs
+// spent aren't covered by any signature and come from the node; a
+// transaction paying out other than it spends is rejected by the chain.
+
+const SIGNER_API = 'http://localhost:6969';
+
+const KEY_P256 = 0;
+const KEY_ED25519 = 1;
+
+function hexToBytes(hex) {
+    if (hex.length % 2 !== 0 || /[^0-9a-f]/i.test(hex)) {
+        throw new Error('not a hex string');
+    }
+    const bytes = new Uint8Array(hex.length / 2);
+    for (let i = 0; i < bytes.length; i++) {
+        bytes[i] = parseInt(hex.substr(i * 2, 2), 16);
+    }
+    return bytes;
+}
+
+function bytesToHex(bytes) {
+    return Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+}
+
+function concatBytes(...parts) {
+    const out = new Uint8Array(parts.reduce((n, p) => n + p.length, 0));
+    let offset = 0;
+    for (const part of parts) {
+        out.set(part, offset);
+        offset += part.length;
+    }
+    return out;
+}
+
+function sameBytes(a, b) {
+    return a.length === b.length && a.every((v, i) => v === b[i]);
+}
+
+async function sha256(bytes) {
+    return new Uint8Array(await crypto.subtle.digest('SHA-256', bytes));
+}
+
+function parseTx(json) {
+    return {
+        id: hexToBytes(json.id),
+        inputs: json.inputs.map(input => ({
+            txid: hexToBytes(input.txid),
+            out: input.out,
+            signature: hexToBytes(input.signature || ''),
+            pubKey: hexToBytes(input.pubKey || ''),
+        })),
+        outputs: json.outputs.map(output => ({
+            value: output.value,
+            pubKeyHash: hexToBytes(output.pubKeyHash),
+        })),
+    };
+}
+
+// --- gob ---
+
+// Ids of the types gob predefines
+const GOB_INT = 2;
+const GOB_BYTES = 5;
+
+function gobUint(n) {
+    n = BigInt(n);
+    if (n < 128n) {
+        return new Uint8Array([Number(n)]);
+    }
+    const bytes = [];
+    for (; n > 0n; n >>= 8n) {
+        bytes.unshift(Number(n & 0xffn));
+    }
+    return Uint8Array.from([256 - bytes.length, ...bytes]);
+}
+
+function gobInt(n) {
+    n = BigInt(n);
+    return gobUint(n < 0n ? (~n << 1n) | 1n : n << 1n);
+}
+
+function gobBytes(bytes) {
+    return concatBytes(gobUint(bytes.length), bytes);
+}
+
+// gobStruct encodes the fields of a struct in order. Fields with a zero
+// value are null and left out, like Go does.
+function gobStruct(fields) {
+    const parts = [];
+    let last = -1;
+    fields.forEach((field, i) => {
+        if (field !== null) {
+            parts.push(gobUint(i - last), field);
+            last = i;
+        }
+    });
+    parts.push(new Uint8Array([0]));
+    return concatBytes(...parts);
+}
+
+const gobIntField = n => (n === 0 ? null : gobInt(n));
+const gobBytesField = bytes => (bytes.length === 0 ? null : gobBytes(bytes));
+const gobSliceField = items => (items.length === 0 ? null : concatBytes(gobUint(items.length), ...items));
+
+function gobTransaction(tx) {
+    return gobStruct([
+        gobBytesField(tx.id),
+        gobSliceField(tx.inputs.map(input => gobStruct([
+            gobBytesField(input.txid),
+            gobIntField(input.out),
+            gobBytesField(input.signature),
+            gobBytesField(input.pubKey),
+        ]))),
+        gobSliceField(tx.outputs.map(output => gobStruct([
+            gobIntField(output.value),
+            gobBytesField(output.pubKeyHash),
+        ]))),
+    ]);
+}
+
+function gobMessage(typeId, payload) {
+    const body = concatBytes(gobInt(typeId), payload);
+    return concatBytes(gobUint(body.length), body);
+}
+
+class GobReader {
+    constructor(bytes) {
+        this.bytes = bytes;
+        this.pos = 0;
+    }
+
+    byte() {
+        if (this.pos >= this.bytes.length) {
+            throw new Error('the raw transaction is cut short');
+        }
+        return this.bytes[this.pos++];
+    }
+
+    uint() {
+        const b = this.byte();
+        if (b < 128) {
+            return BigInt(b);
+        }
+        if (256 - b > 8) {
+            throw new Error('the raw transaction holds a malformed number');
+        }
+        let n = 0n;
+        for (let i = 0; i < 256 - b; i++) {
+            n = (n << 8n) | BigInt(this.byte());
+        }
+        return n;
+    }
+
+    int() {
+        const u = this.uint();
+        return Number(u & 1n ? ~(u >> 1n) : u >> 1n);
+    }
+
+    // count reads a length, which can't be more than the bytes left
+    count() {
+        const n = this.uint();
+        if (n > BigInt(this.bytes.length - this.pos)) {
+            throw new Error('the raw transaction is cut short');
+        }
+        return Number(n);
+    }
+
+    string() {
+        const n = this.count();
+        this.pos += n;
+        return new TextDecoder().decode(this.bytes.subarray(this.pos - n, this.pos));
+    }
+
+    // struct reads the fields present, by number, with fields[i] reading
+    // field i
+    struct(fields) {
+        const value = {};
+        for (let field = -1; ;) {
+            const delta = this.uint();
+            if (delta === 0n) {
+                return value;
+            }
+            field += Number(delta);
+            if (!fields[field]) {
+                throw new Error('the raw transaction defines its types in an unexpected way');
+            }
+            value[field] = fields[field](this);
+        }
+    }
+
+    slice(elem) {
+        return Array.from({ length: this.count() }, () => elem(this));
+    }
+}
+
+// The type definitions gob sends, wireType in encoding/gob: a slice type
+// is field 1, its element type field 1 of that; a struct type is field 2,
+// its fields, name and type, field 1 of that.
+const gobCommonType = r => r.struct([r => r.string(), r => r.int()]);
+const gobFieldType = r => r.struct([r => r.string(), r => r.int()]);
+const gobWireType = r => r.struct([
+    null,
+    r => r.struct([gobCommonType, r => r.int()]),
+    r => r.struct([gobCommonType, r => r.slice(gobFieldType)]),
+]);
+
+// gobTypes reads the type definitions in front of the value of a
+// serialized transaction. It returns them with the bytes they take and the
+// type id of the value.
+function gobTypes(raw) {
+    const r = new GobReader(raw);
+    const types = new Map();
+    for (;;) {
+        const start = r.pos;
+        const end = r.count() + r.pos;
+        const id = r.int();
+        if (id > 0) {
+            return { types, prefix: raw.slice(0, start), id };
+        }
+        if (id === 0 || types.has(-id)) {
+            throw new Error('the raw transaction defines a type twice');
+        }
+        types.set(-id, gobWireType(r));
+        if (r.pos !== end) {
+            throw new Error('the raw transaction holds a malformed type definition');
+        }
+    }
+}
+
+const GOB_TX_INPUT = { struct: [['ID', GOB_BYTES], ['Out', GOB_INT], ['Signature', GOB_BYTES], ['PubKey', GOB_BYTES]] };
+const GOB_TX_OUTPUT = { struct: [['Value', GOB_INT], ['PubKeyHash', GOB_BYTES]] };
+const GOB_TRANSACTION = {
+    struct: [['ID', GOB_BYTES], ['Inputs', { slice: GOB_TX_INPUT }], ['Outputs', { slice: GOB_TX_OUTPUT }]],
+};
+
+// gobDefines reports whether type id is shape: a predefined type id, a
+// slice or a struct with these fields in this order.
+function gobDefines(types, id, shape) {
+    if (typeof shape === 'number') {
+        return id === shape;
+    }
+    const wire = types.get(id);
+    if (!wire || (wire[1] !== undefined) === (wire[2] !== undefined)) {
+        return false;
+    }
+    if (shape.slice) {
+        return wire[1] !== undefined && gobDefines(types, wire[1][1], shape.slice);
+    }
+    const fields = (wire[2] && wire[2][1]) || [];
+    return fields.length === shape.struct.length &&
+        shape.struct.every(([name, fieldShape], i) => fields[i][0] === name && gobDefines(types, fields[i][1], fieldShape));
+}
+
+// txDigests checks that the raw transaction from /tx/build is tx and
+// returns the digest each of its inputs signs, computed like
+// Transaction.SigHash.
+async function txDigests(unsigned, tx) {
+    const raw = hexToBytes(unsigned.raw);
+    const { types, prefix, id } = gobTypes(raw);
+    if (!gobDefines(types, id, GOB_TRANSACTION)) {
+        throw new Error('the raw transaction is not a transaction');
+    }
+    const encode = t => concatBytes(prefix, gobMessage(id, gobTransaction(t)));
+    if (!sameBytes(raw, encode(tx))) {
+        throw new Error('the raw transaction is not the transaction the node spelled out');
+    }
+
+    const none = new Uint8Array(0);
+    const unsignedInputs = tx.inputs.map(input => ({ ...input, signature: none }));
+    if (!sameBytes(tx.id, await sha256(encode({ id: none, inputs: unsignedInputs, outputs: tx.outputs })))) {
+        throw new Error('the transaction has a wrong id');
+    }
+
+    const digests = [];
+    for (const [i, input] of tx.inputs.entries()) {
+        const prev = unsigned.prevOutputs.find(p => p.txid === bytesToHex(input.txid) && p.out === input.out);
+        if (!prev) {
+            throw new Error(`no previous output for input ${i}`);
+        }
+        const inputs = tx.inputs.map((other, j) => ({
+            txid: other.txid,
+            out: other.out,
+            signature: none,
+            pubKey: j === i ? hexToBytes(prev.pubKeyHash) : none,
+        }));
+        digests.push(await sha256(encode({ id: none, inputs, outputs: tx.outputs })));
+    }
+    return digests;
+}
+
+// --- keys ---
+
+const P256 = {
+    p: 0xffffffff00000001000000000000000000000000ffffffffffffffffffffffffn,
+    a: 0xffffffff00000001000000000000000000000000fffffffffffffffffffffffcn,
+    n: 0xffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551n,
+    gx: 0x6b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296n,
+    gy: 0x4fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f5n,
+};
+
+function mod(a, m) {
+    const r = a % m;
+    return r >= 0n ? r : r + m;
+}
+
+function modInverse(a, m) {
+    let [r0, r1, s0, s1] = [mod(a, m), m, 1n, 0n];
+    while (r1 !== 0n) {
+        const q = r0 / r1;
+        [r0, r1] = [r1, r0 - q * r1];
+        [s0, s1] = [s1, s0 - q * s1];
+    }
+    return mod(s0, m);
+}
+
+function pointAdd(P, Q) {
+    if (P === null) return Q;
+    if (Q === null) return P;
+    const { p, a } = P256;
+    let slope;
+    if (P[0] === Q[0]) {
+        if (mod(P[1] + Q[1], p) === 0n) {
+            return null;
+        }
+        slope = mod((3n * P[0] * P[0] + a) * modInverse(2n * P[1], p), p);
+    } else {
+        slope = mod((Q[1] - P[1]) * modInverse(Q[0] - P[0], p), p);
+    }
+    const x = mod(slope * slope - P[0] - Q[0], p);
+    return [x, mod(slope * (P[0] - x) - P[1], p)];
+}
+
+function scalarBaseMult(k) {
+    let result = null;
+    let addend = [P256.gx, P256.gy];
+    for (; k > 0n; k >>= 1n) {
+        if (k & 1n) {
+            result = pointAdd(result, addend);
+        }
+        addend = pointAdd(addend, addend);
+    }
+    return result;
+}
+
+function bigIntFromBytes(bytes) {
+    return bytes.length === 0 ? 0n : BigInt('0x' + bytesToHex(bytes));
+}
+
+function bigIntToBytes(n, length) {
+    return hexToBytes(n.toString(16).padStart(length * 2, '0'));
+}
+
+function randomScalar() {
+    for (;;) {
+        const k = bigIntFromBytes(crypto.getRandomValues(new Uint8Array(32)));
+        if (k > 0n && k < P256.n) {
+            return k;
+        }
+    }
+}
+
+// signP256 signs a digest the way wallet.PrivateKeyData.Sign does, r‖s.
+function signP256(d, digest) {
+    const e = bigIntFromBytes(digest);
+    for (;;) {
+        const k = randomScalar();
+        const r = mod(scalarBaseMult(k)[0], P256.n);
+        const s = mod(modInverse(k, P256.n) * (e + r * d), P256.n);
+        if (r !== 0n && s !== 0n) {
+            return concatBytes(bigIntToBytes(r, 32), bigIntToBytes(s, 32));
+        }
+    }
+}
+
+function base64UrlToBytes(s) {
+    const binary = atob(s.replace(/-/g, '+').replace(/_/g, '/'));
+    return Uint8Array.from(binary, c => c.charCodeAt(0));
+}
+
+// importKey reads a private key, hex encoded as the wallet file keeps it:
+// the P-256 scalar or the Ed25519 seed. The public key is encoded with its
+// type, like wallet.EncodePublicKey.
+async function importKey(keyType, privateKeyHex) {
+    const secret = hexToBytes(privateKeyHex);
+
+    if (keyType === KEY_P256) {
+        const d = bigIntFromBytes(secret);
+        if (d <= 0n || d >= P256.n) {
+            throw new Error('not a P-256 private key');
+        }
+        const [x, y] = scalarBaseMult(d);
+        return {
+            pubKey: concatBytes([KEY_P256], bigIntToBytes(x, 32), bigIntToBytes(y, 32)),
+            sign: async digest => signP256(d, digest),
+        };
+    }
+
+    if (keyType === KEY_ED25519) {
+        if (secret.length !== 32) {
+            throw new Error('an Ed25519 seed is 32 bytes');
+        }
+        const pkcs8 = concatBytes(hexToBytes('302e020100300506032b657004220420'), secret);
+        const key = await crypto.subtle.importKey('pkcs8', pkcs8, { name: 'Ed25519' }, true, ['sign']);
+        const jwk = await crypto.subtle.exportKey('jwk', key);
+        return {
+            pubKey: concatBytes([KEY_ED25519], base64UrlToBytes(jwk.x)),
+            sign: async digest => new Uint8Array(await crypto.subtle.sign({ name: 'Ed25519' }, key, digest)),
+        };
+    }
+
+    throw new Error(`unknown key type ${keyType}`);
+}
+
+// --- addresses ---
+
+const BASE58 = '123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz';
+
+function base58Decode(s) {
+    let n = 0n;
+    for (const c of s) {
+        const digit = BASE58.indexOf(c);
+        if (digit < 0) {
+            throw new Error('not a base58 string');
+        }
+        n = n * 58n + BigInt(digit);
+    }
+    const body = n === 0n ? new Uint8Array(0) : hexToBytes(n.toString(16).padStart(Math.ceil(n.toString(16).length / 2) * 2, '0'));
+    const zeros = s.length - s.replace(/^1+/, '').length;
+    return concatBytes(new Uint8Array(zeros), body);
+}
+
+// addressPubKeyHash is the public key hash an address pays to, after
+// checking its checksum.
+async function addressPubKeyHash(address) {
+    const decoded = base58Decode(address);
+    if (decoded.length <= 5) {
+        throw new Error(`invalid address ${address}`);
+    }
+    const payload = decoded.slice(0, -4);
+    const checksum = (await sha256(await sha256(payload))).slice(0, 4);
+    if (!sameBytes(checksum, decoded.slice(-4))) {
+        throw new Error(`invalid address ${address}`);
+    }
+    return payload.slice(1);
+}
+
+// --- building, checking, signing and submitting ---
+
+async function postSigner(path, body) {
+    const response = await fetch(`${SIGNER_API}${path}`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body),
+    });
+    const text = await response.text();
+    if (!response.ok) {
+        throw new Error(text.trim());
+    }
+    return JSON.parse(text);
+}
+
+// checkUnsigned makes sure the node built what was asked: a transaction
+// spending only our outputs and paying amount to `to`, the rest back to us,
+// with a digest for every input.
+async function checkUnsigned(unsigned, tx, pubKey, to, amount) {
+    if (!tx.inputs.every(input => sameBytes(input.pubKey, pubKey))) {
+        throw new Error('the node sent a transaction spending someone else\'s outputs');
+    }
+    if (unsigned.sigHashes.length !== tx.inputs.length) {
+        throw new Error(`the node sent ${unsigned.sigHashes.length} digests for ${tx.inputs.length} inputs`);
+    }
+
+    // Digests over other outputs than ours would only yield signatures the
+    // chain rejects, so the outputs spent tell us our public key hash
+    const own = unsigned.prevOutputs[0].pubKeyHash;
+    if (!unsigned.prevOutputs.every(prev => prev.pubKeyHash === own)) {
+        throw new Error('the node sent previous outputs of several keys');
+    }
+
+    let spent = 0;
+    tx.inputs.forEach((input, i) => {
+        const prev = unsigned.prevOutputs.find(p => p.txid === bytesToHex(input.txid) && p.out === input.out);
+        if (!prev) {
+            throw new Error(`no previous output for input ${i}`);
+        }
+        spent += prev.value;
+    });
+
+    const recipient = bytesToHex(await addressPubKeyHash(to));
+    let paid = 0;
+    let total = 0;
+    tx.outputs.forEach((output, i) => {
+        const hash = bytesToHex(output.pubKeyHash);
+        if (hash === recipient) {
+            paid += output.value;
+        } else if (hash !== own) {
+            throw new Error(`output ${i} of the transaction pays someone else`);
+        }
+        total += output.value;
+    });
+    if (paid !== amount && recipient !== own) {
+        throw new Error(`the transaction pays ${paid}, not ${amount}`);
+    }
+    if (total !== spent) {
+        throw new Error(`the transaction spends ${spent} but pays out ${total}`);
+    }
+}
+
+// signTransaction signs the digest of every input of an unsigned
+// transaction from POST /tx/build and returns the body for /tx/submit. The
+// digests are computed here, and must match those of the node.
+async function signTransaction(unsigned, key) {
+    const digests = await txDigests(unsigned, parseTx(unsigned.tx));
+    if (unsigned.sigHashes.length !== digests.length || digests.some((digest, i) => bytesToHex(digest) !== unsigned.sigHashes[i])) {
+        throw new Error('the node sent digests that are not those of the transaction');
+    }
+
+    const signatures = [];
+    for (const digest of digests) {
+        signatures.push(bytesToHex(await key.sign(digest)));
+    }
+    return { raw: unsigned.raw, signatures };
+}
+
+// sendSigned sends amount to an address from a key held by the browser.
+async function sendSigned(keyType, privateKeyHex, to, amount) {
+    const key = await importKey(keyType, privateKeyHex);
+    const unsigned = await postSigner('/tx/build', { pubKey: bytesToHex(key.pubKey), to, amount });
+
+    await checkUnsigned(unsigned, parseTx(unsigned.tx), key.pubKey, to, amount);
+
+    return postSigner('/tx/submit', await signTransaction(unsigned, key));
+}
+
+if (typeof module !== 'undefined') {
+    module.exports = { parseTx, checkUnsigned, txDigests, importKey, signTransaction, sendSigned, addressPubKeyHash, KEY_P256, KEY_ED25519 };
+}